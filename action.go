@@ -0,0 +1,70 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidActionToken is returned by VerifyActionToken when the token is
+// unknown, expired, has already been used, or was issued for a different
+// action.
+var ErrInvalidActionToken = errors.New("scs: invalid action token")
+
+const actionTokenPrefix = "__actionToken."
+
+// ActionToken returns a new single-use token which authorizes the named action
+// (such as "delete-account") for the current session, for the duration ttl.
+// It is intended for embedding in forms or links to confirm a dangerous
+// action, and must be checked with VerifyActionToken in the same session. Only
+// a hash of the token is stored in the session data. Expired tokens are
+// removed from the session data each time ActionToken is called.
+func (s *SessionManager) ActionToken(ctx context.Context, action string, ttl time.Duration) (string, error) {
+	if s.ReadOnly {
+		return "", ErrReadOnly
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UnixNano()
+	for _, key := range s.Keys(ctx) {
+		if strings.HasPrefix(key, actionTokenPrefix) && strings.HasSuffix(key, ".expiry") && now > s.GetInt64(ctx, key) {
+			s.Remove(ctx, key)
+			s.Remove(ctx, strings.TrimSuffix(key, ".expiry")+".action")
+		}
+	}
+
+	prefix := actionTokenPrefix + hashToken(token)
+	s.Put(ctx, prefix+".action", action)
+	s.Put(ctx, prefix+".expiry", time.Now().Add(ttl).UnixNano())
+
+	return token, nil
+}
+
+// VerifyActionToken consumes a token created by ActionToken, and returns nil if
+// it was issued for the named action in the current session and hasn't
+// expired. Each token can only be verified once, whether or not verification
+// succeeds. Otherwise it returns ErrInvalidActionToken.
+func (s *SessionManager) VerifyActionToken(ctx context.Context, action string, token string) error {
+	if token == "" {
+		return ErrInvalidActionToken
+	}
+
+	prefix := actionTokenPrefix + hashToken(token)
+	if !s.Exists(ctx, prefix+".action") {
+		return ErrInvalidActionToken
+	}
+
+	issuedFor := s.PopString(ctx, prefix+".action")
+	expiry := s.GetInt64(ctx, prefix+".expiry")
+	s.Remove(ctx, prefix+".expiry")
+
+	if issuedFor != action || time.Now().UnixNano() > expiry {
+		return ErrInvalidActionToken
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActionToken(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.ActionToken(ctx, "delete-account", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range s.Keys(ctx) {
+		if key == actionTokenPrefix+token+".action" {
+			t.Error("expected token to be hashed in the session data")
+		}
+	}
+
+	if err := s.VerifyActionToken(ctx, "delete-account", token); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.VerifyActionToken(ctx, "delete-account", token); err != ErrInvalidActionToken {
+		t.Errorf("got %v: expected %v", err, ErrInvalidActionToken)
+	}
+
+	token, _ = s.ActionToken(ctx, "delete-account", time.Minute)
+	if err := s.VerifyActionToken(ctx, "change-email", token); err != ErrInvalidActionToken {
+		t.Errorf("got %v: expected %v", err, ErrInvalidActionToken)
+	}
+	if err := s.VerifyActionToken(ctx, "delete-account", token); err != ErrInvalidActionToken {
+		t.Errorf("got %v: expected token to be consumed by failed verification", err)
+	}
+
+	token, _ = s.ActionToken(ctx, "delete-account", -time.Second)
+	if err := s.VerifyActionToken(ctx, "delete-account", token); err != ErrInvalidActionToken {
+		t.Errorf("got %v: expected %v", err, ErrInvalidActionToken)
+	}
+
+	s.ActionToken(ctx, "delete-account", -time.Second)
+	s.ActionToken(ctx, "delete-account", time.Minute)
+	if n := len(s.Keys(ctx)); n != 2 {
+		t.Errorf("got %d keys: expected expired tokens to be removed", n)
+	}
+
+	other, _ := s.Load(context.Background(), "")
+	token, _ = s.ActionToken(ctx, "delete-account", time.Minute)
+	if err := s.VerifyActionToken(other, "delete-account", token); err != ErrInvalidActionToken {
+		t.Errorf("got %v: expected token to be bound to the session", err)
+	}
+}
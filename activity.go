@@ -0,0 +1,127 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	activityTimesKey      = "__activity.times"
+	activityCategoriesKey = "__activity.categories"
+)
+
+// ActivityTracking contains the configuration settings for recording a
+// sliding window of recent activity in each session.
+type ActivityTracking struct {
+	// Size is the number of activity records kept for each session. When
+	// the window is full the oldest record is discarded. If zero, 10 is used.
+	Size int
+
+	// Category returns a short, coarse label for the request, which is
+	// recorded alongside the activity time. It should not return anything
+	// sensitive, as it is stored in the session data. The default is the
+	// first segment of the request path (for example "account" for the path
+	// "/account/settings"), or "/" for the root path.
+	Category func(r *http.Request) string
+}
+
+// Activity is a single record in the recent activity window for a session.
+type Activity struct {
+	// Time is when the request was made.
+	Time time.Time `json:"time"`
+
+	// Category is the label returned by ActivityTracking.Category.
+	Category string `json:"category"`
+}
+
+// RecentActivity returns the recent activity recorded for the current session,
+// oldest first. The SessionManager.ActivityTracking setting must be enabled for
+// activity to be recorded; otherwise nil is returned. It is intended to support
+// anomaly checks in the application, such as detecting an unusual burst of
+// requests or requests from two distant places in quick succession.
+func (s *SessionManager) RecentActivity(ctx context.Context) []Activity {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	times, _ := sd.values[activityTimesKey].([]int64)
+	categories, _ := sd.values[activityCategoriesKey].([]string)
+	if len(times) == 0 {
+		return nil
+	}
+
+	activity := make([]Activity, len(times))
+	for i, ns := range times {
+		activity[i].Time = time.Unix(0, ns).UTC()
+		if i < len(categories) {
+			activity[i].Category = categories[i]
+		}
+	}
+	return activity
+}
+
+// trackActivity appends the request to the recent activity window in the
+// session data. Like trackDevice it is called after the session is loaded and
+// again before it is committed, but it only records each request once. Note
+// that this causes the session to be committed to the store on every request.
+func (s *SessionManager) trackActivity(r *http.Request) {
+	at := s.ActivityTracking
+	if at == nil || s.ReadOnly {
+		return
+	}
+
+	size := at.Size
+	if size <= 0 {
+		size = 10
+	}
+
+	var category string
+	if at.Category != nil {
+		category = at.Category(r)
+	} else {
+		category = pathCategory(r.URL.Path)
+	}
+
+	sd := s.getSessionDataFromContext(r.Context())
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.activityRecorded || (sd.token == "" && sd.status != Modified) {
+		return
+	}
+
+	times, _ := sd.values[activityTimesKey].([]int64)
+	categories, _ := sd.values[activityCategoriesKey].([]string)
+	if len(categories) != len(times) {
+		categories = make([]string, len(times))
+	}
+
+	times = append(times, time.Now().UnixNano())
+	categories = append(categories, category)
+	if n := len(times) - size; n > 0 {
+		times = times[n:]
+		categories = categories[n:]
+	}
+
+	// Copy into new slices so that the values held by other requests using
+	// the same session are never shared.
+	sd.values[activityTimesKey] = append([]int64(nil), times...)
+	sd.values[activityCategoriesKey] = append([]string(nil), categories...)
+	sd.activityRecorded = true
+	sd.status = Modified
+}
+
+func pathCategory(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
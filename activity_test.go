@@ -0,0 +1,86 @@
+package scs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestActivityTracking(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.ActivityTracking = &ActivityTracking{Size: 3}
+
+	var activity []Activity
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			s.Put(r.Context(), "userID", 1)
+		}
+		activity = s.RecentActivity(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/anonymous", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want no session cookie for unmodified session; got %q", rr.Header().Get("Set-Cookie"))
+	}
+	if activity != nil {
+		t.Errorf("want no activity; got %v", activity)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/login", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	cookie := rr.Result().Cookies()[0]
+
+	for _, path := range []string{"/account/settings", "/", "/orders/1", "/orders/2"} {
+		r = httptest.NewRequest(http.MethodGet, path, nil)
+		r.AddCookie(cookie)
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	// The current request is recorded when the session is loaded, so it is
+	// the last record seen by the handler.
+	want := []string{"orders", "orders", "/"}
+	if len(activity) != len(want) {
+		t.Fatalf("want %d records; got %v", len(want), activity)
+	}
+	for i, a := range activity {
+		if a.Category != want[i] {
+			t.Errorf("record %d: want category %q; got %q", i, want[i], a.Category)
+		}
+		if time.Since(a.Time) > time.Minute {
+			t.Errorf("record %d: want recent time; got %v", i, a.Time)
+		}
+		if i > 0 && a.Time.Before(activity[i-1].Time) {
+			t.Errorf("record %d: want records oldest first; got %v", i, activity)
+		}
+	}
+}
+
+func TestPathCategory(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":                  "/",
+		"/":                 "/",
+		"/login":            "login",
+		"/account/settings": "account",
+		"/orders/":          "orders",
+	}
+
+	for path, want := range tests {
+		if got := pathCategory(path); got != want {
+			t.Errorf("%q: want %q; got %q", path, want, got)
+		}
+	}
+}
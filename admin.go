@@ -0,0 +1,203 @@
+package scs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionInfo contains summary information about an active session, as
+// returned by the AdminHandler, SessionsForUser and FindByTag.
+type SessionInfo struct {
+	// ID is a stable, non-sensitive identifier for the session derived from
+	// a hash of the session token. It can't be used to access the session,
+	// and is the same as the fingerprint returned by RedactToken.
+	ID string `json:"id"`
+
+	// User is the value for SessionManager.UserKey in the session data,
+	// formatted with fmt.Sprint. It is empty if the value is not present.
+	User string `json:"user,omitempty"`
+
+	// Deadline is the absolute expiry time for the session.
+	Deadline time.Time `json:"deadline"`
+
+	// Device is the client metadata recorded for the session. It is nil
+	// unless SessionManager.DeviceTracking is enabled.
+	Device *DeviceInfo `json:"device,omitempty"`
+
+	// Tags are the tags attached to the session with Tag.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// AdminHandler returns a http.Handler which provides a JSON API for managing
+// the active sessions in the session store. The store must support iteration.
+// It should be mounted under a route which is only accessible to authenticated
+// administrators, with the route prefix removed using http.StripPrefix. For
+// example:
+//
+//	mux.Handle("/admin/sessions/", requireAdmin(http.StripPrefix("/admin/sessions", sessionManager.AdminHandler())))
+//
+// The following endpoints are supported:
+//
+//	GET  /                  lists all active sessions
+//	GET  /?user=<id>        lists all active sessions for a user
+//	POST /revoke?id=<id>    revokes a single session
+//	POST /revoke?user=<id>  revokes all sessions for a user
+//
+// The revoke endpoints respond with a JSON object containing the number of
+// sessions revoked. Listing and revoking by user requires the
+// SessionManager.UserKey setting.
+func (s *SessionManager) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := "/" + strings.Trim(r.URL.Path, "/")
+
+		switch {
+		case path == "/" && r.Method == http.MethodGet:
+			sessions, err := s.sessionInfo(r.Context(), r.URL.Query().Get("user"))
+			if err != nil {
+				s.ErrorFunc(w, r, err)
+				return
+			}
+			writeJSON(w, sessions)
+		case path == "/revoke" && r.Method == http.MethodPost:
+			id, user := r.URL.Query().Get("id"), r.URL.Query().Get("user")
+			if id == "" && user == "" {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			var n int
+			var err error
+			if id != "" {
+				n, err = s.revokeByID(r.Context(), id)
+			} else {
+				n, err = s.revokeUser(r.Context(), user)
+			}
+			if err != nil {
+				s.ErrorFunc(w, r, err)
+				return
+			}
+			writeJSON(w, map[string]int{"revoked": n})
+		case path == "/" || path == "/revoke":
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func (s *SessionManager) sessionInfo(ctx context.Context, user string) ([]SessionInfo, error) {
+	if user != "" && s.UserKey == "" {
+		return nil, ErrNoUserKey
+	}
+
+	all, err := s.doStoreAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []SessionInfo{}
+	for token, b := range all {
+		deadline, values, err := s.decode(b)
+		if err != nil {
+			return nil, err
+		} else if isTombstone(values) {
+			continue
+		}
+
+		info := s.newSessionInfo(token, deadline, values)
+		if user != "" && info.User != user {
+			continue
+		}
+
+		sessions = append(sessions, info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Deadline.Before(sessions[j].Deadline)
+	})
+
+	return sessions, nil
+}
+
+// newSessionInfo returns the SessionInfo for a session, given the token as it
+// appears in the store.
+func (s *SessionManager) newSessionInfo(storeToken string, deadline time.Time, values map[string]interface{}) SessionInfo {
+	info := SessionInfo{ID: s.fingerprint(storeToken), Deadline: deadline}
+	if val, exists := values[s.UserKey]; s.UserKey != "" && exists {
+		info.User = fmt.Sprint(val)
+	}
+	if s.DeviceTracking != nil {
+		di := deviceInfo(values)
+		info.Device = &di
+	}
+	if tags, _ := values[tagsKey].([]string); len(tags) > 0 {
+		info.Tags = append([]string(nil), tags...)
+	}
+	return info
+}
+
+func (s *SessionManager) revokeByID(ctx context.Context, id string) (int, error) {
+	all, err := s.doStoreAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for token := range all {
+		if s.fingerprint(token) == id {
+			return 1, s.revoke(ctx, token)
+		}
+	}
+
+	return 0, nil
+}
+
+// Inspect returns the deadline and data for the session with the given token,
+// without loading the session into a context. Unlike Load, it never changes
+// the session store: session data which can't be decoded is returned as an
+// error whatever the OnDecodeError setting, and a session retained as a
+// tombstone is reported as ErrSessionRevoked. The found return value is false
+// if the session doesn't exist or has expired.
+func (s *SessionManager) Inspect(ctx context.Context, token string) (deadline time.Time, values map[string]interface{}, found bool, err error) {
+	b, fields, found, err := s.findSession(ctx, token)
+	if err != nil || !found {
+		return time.Time{}, nil, false, err
+	}
+
+	deadline, values, _, err = s.decodeSession(b, fields)
+	if err != nil {
+		return time.Time{}, nil, false, err
+	} else if isTombstone(values) {
+		return time.Time{}, nil, false, ErrSessionRevoked
+	} else if !time.Now().Before(deadline) {
+		return time.Time{}, nil, false, nil
+	}
+
+	return deadline, values, true, nil
+}
+
+// revokeUser revokes all active sessions belonging to a user, and returns the
+// number revoked.
+func (s *SessionManager) revokeUser(ctx context.Context, userID string) (int, error) {
+	sessions, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, us := range sessions {
+		if err := s.revoke(ctx, us.storeToken); err != nil {
+			return i, err
+		}
+	}
+
+	return len(sessions), nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,172 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+	commitTestSession(t, s, "a", map[string]interface{}{"userID": 1})
+	commitTestSession(t, s, "b", map[string]interface{}{"userID": 1})
+	commitTestSession(t, s, "c", map[string]interface{}{"userID": 2})
+	commitTestSession(t, s, "d", map[string]interface{}{})
+
+	h := http.StripPrefix("/admin/sessions", s.AdminHandler())
+
+	execute := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	list := func(target string) []SessionInfo {
+		rr := execute(http.MethodGet, target)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, rr.Code)
+		}
+		var sessions []SessionInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &sessions); err != nil {
+			t.Fatal(err)
+		}
+		return sessions
+	}
+
+	if sessions := list("/admin/sessions/"); len(sessions) != 4 {
+		t.Errorf("want %d sessions; got %d", 4, len(sessions))
+	}
+
+	sessions := list("/admin/sessions?user=1")
+	if len(sessions) != 2 {
+		t.Fatalf("want %d sessions; got %d", 2, len(sessions))
+	}
+
+	rr := execute(http.MethodPost, "/admin/sessions/revoke?id="+sessions[0].ID)
+	if rr.Body.String() != "{\"revoked\":1}\n" {
+		t.Errorf("want %q; got %q", "{\"revoked\":1}\n", rr.Body.String())
+	}
+	if sessions := list("/admin/sessions?user=1"); len(sessions) != 1 {
+		t.Errorf("want %d sessions; got %d", 1, len(sessions))
+	}
+
+	rr = execute(http.MethodPost, "/admin/sessions/revoke?user=2")
+	if rr.Body.String() != "{\"revoked\":1}\n" {
+		t.Errorf("want %q; got %q", "{\"revoked\":1}\n", rr.Body.String())
+	}
+	if sessions := list("/admin/sessions/"); len(sessions) != 2 {
+		t.Errorf("want %d sessions; got %d", 2, len(sessions))
+	}
+
+	if rr := execute(http.MethodGet, "/admin/sessions/revoke"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d; got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+	if rr := execute(http.MethodPost, "/admin/sessions/revoke"); rr.Code != http.StatusBadRequest {
+		t.Errorf("want %d; got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	commitTestSession(t, s, "a", map[string]interface{}{"foo": "bar"})
+	s.Store.Commit("bad", []byte("not session data"), time.Now().Add(time.Hour))
+
+	deadline, values, found, err := s.Inspect(context.Background(), "a")
+	if err != nil || !found {
+		t.Fatalf("want session; got %v, %v", found, err)
+	}
+	if values["foo"] != "bar" || deadline.IsZero() {
+		t.Errorf("want session data; got %v, %v", deadline, values)
+	}
+
+	if _, _, found, err := s.Inspect(context.Background(), "missing"); err != nil || found {
+		t.Errorf("want not found; got %v, %v", found, err)
+	}
+
+	if _, _, _, err := s.Inspect(context.Background(), "bad"); err == nil {
+		t.Error("want decode error")
+	}
+	if _, found, _ := s.Store.Find("bad"); !found {
+		t.Error("want undecodable session to be kept in the store")
+	}
+}
+
+func TestAdminHandlerTombstone(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+	s.TombstoneTTL = time.Hour
+	commitTestSession(t, s, "a", map[string]interface{}{"userID": 1})
+
+	h := s.AdminHandler()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/revoke?user=1", nil))
+	if rr.Body.String() != "{\"revoked\":1}\n" {
+		t.Errorf("want %q; got %q", "{\"revoked\":1}\n", rr.Body.String())
+	}
+
+	ts, err := s.Tombstone(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts == nil {
+		t.Error("want revoked session to be kept as a tombstone")
+	}
+}
+
+func TestAuxiliaryRecordsSkipped(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+	commitTestSession(t, s, "a", map[string]interface{}{"userID": 1})
+
+	ctx, err := s.Load(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UseNonce(ctx, "nonce", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.HandoffToken(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "checkout", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.sessionInfo(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("want %d sessions listed; got %d", 1, len(sessions))
+	}
+
+	n := 0
+	err = s.Iterate(context.Background(), func(context.Context) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want %d sessions iterated; got %d", 1, n)
+	}
+
+	var buf bytes.Buffer
+	if n, err := s.Export(context.Background(), &buf); err != nil || n != 1 {
+		t.Errorf("want %d sessions exported; got %d, %v", 1, n, err)
+	}
+}
@@ -0,0 +1,48 @@
+package scs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+// affinityPrefix separates affinity keys from token fingerprints, so that the
+// affinity key sent to clients can't be matched to the fingerprints in logs
+// and admin tooling.
+const affinityPrefix = "scs.affinity:"
+
+// AffinityKey returns a short, stable value derived from a session token which
+// load balancers can use for sticky routing, without having access to the
+// token itself. The same token always has the same affinity key, but the
+// token can't be recovered from it. If SessionManager.TokenFingerprintKey is
+// set, the key is derived using HMAC-SHA256 with that key. An empty string is
+// returned for an empty token.
+func (s *SessionManager) AffinityKey(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	var sum []byte
+	if key := s.tokenFingerprintKey(); len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(affinityPrefix + token))
+		sum = mac.Sum(nil)
+	} else {
+		h := sha256.Sum256([]byte(affinityPrefix + token))
+		sum = h[:]
+	}
+	return base64.RawURLEncoding.EncodeToString(sum)[:16]
+}
+
+// writeAffinityHeader sets the AffinityHeader response header for the current
+// session, if there is one.
+func (s *SessionManager) writeAffinityHeader(w http.ResponseWriter, ctx context.Context) {
+	if s.AffinityHeader == "" {
+		return
+	}
+	if key := s.AffinityKey(s.Token(ctx)); key != "" {
+		w.Header().Set(s.AffinityHeader, key)
+	}
+}
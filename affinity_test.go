@@ -0,0 +1,71 @@
+package scs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAffinityKey(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	token := "XO6_D4NBpGP3D_BtekxTEO6o2ZvOzYnRgyYSt3VGgRU"
+
+	key := s.AffinityKey(token)
+	if len(key) != 16 || key != s.AffinityKey(token) {
+		t.Errorf("want a stable 16 character key; got %q", key)
+	}
+	if strings.Contains(token, key) || key == s.RedactToken(token) {
+		t.Errorf("want key to differ from the token and its fingerprint; got %q", key)
+	}
+	if s.AffinityKey("") != "" {
+		t.Error("want empty key for empty token")
+	}
+
+	s.TokenFingerprintKey = []byte("secret")
+	if s.AffinityKey(token) == key {
+		t.Error("want key to depend on TokenFingerprintKey")
+	}
+}
+
+func TestAffinityHeader(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.AffinityHeader = "X-Session-Affinity"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, sessionManager.GetString(r.Context(), "foo"))
+	})
+	h := sessionManager.LoadAndSave(mux)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/get", nil))
+	if got := rr.Header().Get("X-Session-Affinity"); got != "" {
+		t.Errorf("want no header without a session; got %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+	token := extractTokenFromCookie(rr.Header().Get("Set-Cookie"))
+	want := sessionManager.AffinityKey(token)
+	if got := rr.Header().Get("X-Session-Affinity"); got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+
+	r := httptest.NewRequest("GET", "/get", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if got := rr.Header().Get("X-Session-Affinity"); got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
@@ -0,0 +1,320 @@
+package scs
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncSavePolicy controls what the LoadAndSave middleware does when the
+// AsyncSave queue for a session is full.
+type AsyncSavePolicy int
+
+const (
+	// BlockWhenFull waits for space in the queue, applying backpressure to
+	// the request. This is the default.
+	BlockWhenFull AsyncSavePolicy = iota
+
+	// SaveSyncWhenFull commits the session data on the request goroutine,
+	// as if AsyncSave were not enabled.
+	SaveSyncWhenFull
+
+	// DropWhenFull discards the save, so changes made to the session data by
+	// the request are lost. It should only be used where losing an
+	// occasional change (such as an idle timeout extension) is acceptable.
+	DropWhenFull
+)
+
+// AsyncSave contains the configuration settings for committing session data
+// to the store from a pool of background workers instead of on the request
+// goroutine. The session data is encoded and the save queued before the
+// response is written, and the save runs concurrently with writing it, so it
+// may finish before or after the response has been sent.
+type AsyncSave struct {
+	// Workers is the number of background workers. Saves for the same
+	// session are always handled by the same worker, so they are committed
+	// in order. If zero, 4 is used.
+	Workers int
+
+	// QueueSize is the number of saves which can be waiting for each worker.
+	// If zero, 64 is used.
+	QueueSize int
+
+	// WhenFull controls what happens when the queue for a save is full. The
+	// default is BlockWhenFull.
+	WhenFull AsyncSavePolicy
+
+	// ErrorFunc, if set, is called when a background save fails. The id is
+	// the fingerprint of the session token, as returned by RedactToken. By
+	// default errors are logged using Go's standard logger.
+	ErrorFunc func(id string, err error)
+
+	// WaitForRedirects controls whether the LoadAndSave middleware waits for
+	// the session to be saved before sending a response with a 3xx redirect
+	// status, as if WaitForSave had been called, so that the request which
+	// follows the redirect sees the changes. The default value is false.
+	WaitForRedirects bool
+}
+
+// AsyncSaveStats contains counters describing the activity of the AsyncSave
+// workers, for exporting as metrics.
+type AsyncSaveStats struct {
+	// Queued is the number of saves added to the queue.
+	Queued uint64
+
+	// Saved is the number of saves committed by the workers.
+	Saved uint64
+
+	// Failed is the number of saves which returned an error.
+	Failed uint64
+
+	// Dropped is the number of saves discarded because the queue was full.
+	Dropped uint64
+
+	// Sync is the number of saves committed on the request goroutine
+	// because the queue was full or the SessionManager was shut down.
+	Sync uint64
+
+	// Waited is the number of saves which the request waited for, because
+	// of WaitForSave or WaitForRedirects.
+	Waited uint64
+
+	// Pending is the number of saves currently waiting in the queue.
+	Pending int
+}
+
+type saveJob struct {
+	token string
+	enc   *encodedSession
+	base  map[string][]byte
+
+	// done, if not nil, receives the result of the save instead of it being
+	// reported to the ErrorFunc.
+	done chan error
+}
+
+// saver holds the queues and counters for AsyncSave.
+type saver struct {
+	once   sync.Once
+	queues []chan saveJob
+
+	// mu is held for reading while a save is being queued, and for writing
+	// when the queues are closed to new saves by Shutdown.
+	mu      sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+
+	queued, saved, failed, dropped, sync, waited uint64
+}
+
+// AsyncSaveStats returns the current AsyncSave counters. It returns the zero
+// AsyncSaveStats if AsyncSave is not enabled.
+func (s *SessionManager) AsyncSaveStats() AsyncSaveStats {
+	sv := &s.saver
+	stats := AsyncSaveStats{
+		Queued:  atomic.LoadUint64(&sv.queued),
+		Saved:   atomic.LoadUint64(&sv.saved),
+		Failed:  atomic.LoadUint64(&sv.failed),
+		Dropped: atomic.LoadUint64(&sv.dropped),
+		Sync:    atomic.LoadUint64(&sv.sync),
+		Waited:  atomic.LoadUint64(&sv.waited),
+	}
+	sv.mu.RLock()
+	for _, q := range sv.queues {
+		stats.Pending += len(q)
+	}
+	sv.mu.RUnlock()
+	return stats
+}
+
+// commitAsync prepares the session data for committing and queues it to be
+// committed by a background worker, returning the token and expiry time for
+// the session cookie. If the save can't be queued, the session data is
+// committed synchronously using Commit instead.
+func (s *SessionManager) commitAsync(ctx context.Context) (token string, expiry time.Time, err error) {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	if err := s.prepareCommit(sd); err != nil {
+		sd.mu.Unlock()
+		return "", time.Time{}, err
+	}
+	if err := s.checkUserQuota(ctx, sd); err != nil {
+		sd.mu.Unlock()
+		return "", time.Time{}, err
+	}
+	// The session data is encoded now, as the handler may still change the
+	// values while the job is waiting to be written.
+	enc, err := s.encodeSession(sd.token, sd.deadline, sd.values)
+	if err != nil {
+		sd.mu.Unlock()
+		return "", time.Time{}, err
+	}
+	job := saveJob{token: sd.token, enc: enc, base: sd.baseFields()}
+	if sd.waitForSave {
+		job.done = make(chan error, 1)
+	}
+	// The fields committed by the worker aren't recorded in the session
+	// data, so any further commit in this request is made in full.
+	sd.fields, sd.fieldsToken = nil, ""
+	sd.mu.Unlock()
+
+	expiry = enc.expiry
+	if !s.enqueueSave(job) {
+		atomic.AddUint64(&s.saver.sync, 1)
+		return s.Commit(ctx)
+	}
+	if job.done != nil {
+		atomic.AddUint64(&s.saver.waited, 1)
+		if err := <-job.done; err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	return job.token, expiry, nil
+}
+
+// WaitForSave makes the LoadAndSave middleware wait until the session has
+// been saved before sending the response, when AsyncSave is enabled. It
+// should be called before redirecting the client after changing the session
+// data, so that the request which follows the redirect sees the changes
+// rather than racing the background save:
+//
+//	sessionManager.Put(r.Context(), "flash", "Saved!")
+//	sessionManager.WaitForSave(r.Context())
+//	http.Redirect(w, r, "/", http.StatusSeeOther)
+//
+// The save is still made by the background worker for the session, so it is
+// committed after any saves already queued for the session, and errors are
+// passed to the ErrorFunc rather than AsyncSave.ErrorFunc. WaitForSave has no
+// effect if AsyncSave is not enabled, as saves are always synchronous.
+func (s *SessionManager) WaitForSave(ctx context.Context) {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	sd.waitForSave = true
+	sd.mu.Unlock()
+}
+
+// enqueueSave adds a job to the queue for its session, and reports whether it
+// was queued (or dropped, which is treated as queued). It returns false if the
+// job should be committed synchronously instead.
+func (s *SessionManager) enqueueSave(job saveJob) bool {
+	sv := &s.saver
+	sv.once.Do(func() { s.startSaveWorkers() })
+
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+
+	if sv.closed || len(sv.queues) == 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(job.token))
+	q := sv.queues[int(h.Sum32()%uint32(len(sv.queues)))]
+
+	switch policy := s.AsyncSave.WhenFull; {
+	case job.done != nil:
+		// A save which the request waits for is never dropped, and waits
+		// for space in the queue so that it's committed after the saves
+		// already queued for the session.
+		q <- job
+	case policy == SaveSyncWhenFull || policy == DropWhenFull:
+		select {
+		case q <- job:
+		default:
+			if policy == SaveSyncWhenFull {
+				return false
+			}
+			atomic.AddUint64(&sv.dropped, 1)
+			return true
+		}
+	default:
+		q <- job
+	}
+
+	atomic.AddUint64(&sv.queued, 1)
+	return true
+}
+
+func (s *SessionManager) startSaveWorkers() {
+	cfg := s.AsyncSave
+	workers, size := cfg.Workers, cfg.QueueSize
+	if workers <= 0 {
+		workers = 4
+	}
+	if size <= 0 {
+		size = 64
+	}
+
+	sv := &s.saver
+	sv.stopped = make(chan struct{})
+	queues := make([]chan saveJob, workers)
+	for i := range queues {
+		queues[i] = make(chan saveJob, size)
+	}
+
+	// Close the queues to new saves when Shutdown is called. The workers keep
+	// running until then, so that any blocked saves can be queued.
+	ok := s.goBackground(func(shutdown <-chan struct{}) {
+		<-shutdown
+		sv.mu.Lock()
+		sv.closed = true
+		sv.mu.Unlock()
+		close(sv.stopped)
+	})
+	if !ok {
+		return
+	}
+
+	for _, q := range queues {
+		q := q
+		s.goBackground(func(<-chan struct{}) {
+			for {
+				select {
+				case job := <-q:
+					s.runSave(job)
+				case <-sv.stopped:
+					for {
+						select {
+						case job := <-q:
+							s.runSave(job)
+						default:
+							return
+						}
+					}
+				}
+			}
+		})
+	}
+
+	sv.mu.Lock()
+	sv.queues = queues
+	sv.mu.Unlock()
+}
+
+func (s *SessionManager) runSave(job saveJob) {
+	ctx := context.Background()
+	_, large, _, err := s.commitEncoded(ctx, job.token, job.enc, job.base)
+	if job.done != nil {
+		job.done <- err
+	}
+	if err != nil {
+		atomic.AddUint64(&s.saver.failed, 1)
+		if job.done != nil {
+			return
+		}
+		id := s.RedactToken(job.token)
+		if s.AsyncSave.ErrorFunc != nil {
+			s.AsyncSave.ErrorFunc(id, err)
+		} else {
+			log.Printf("scs: async save for session %s: %v", id, err)
+		}
+		return
+	}
+	atomic.AddUint64(&s.saver.saved, 1)
+	s.reportLargeSession(ctx, large)
+}
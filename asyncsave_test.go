@@ -0,0 +1,304 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestAsyncSave(t *testing.T) {
+	t.Parallel()
+
+	store := memstore.NewWithCleanupInterval(0)
+	s := New()
+	s.Store = store
+	s.AsyncSave = &AsyncSave{Workers: 2, QueueSize: 8}
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" {
+		t.Fatalf("want session cookie; got %v", cookies)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find(cookies[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	} else if !found {
+		t.Fatal("want session committed after Shutdown")
+	}
+	_, values, err := s.Codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["foo"] != "bar" {
+		t.Errorf("want %q; got %v", "bar", values["foo"])
+	}
+
+	stats := s.AsyncSaveStats()
+	if stats.Queued != 1 || stats.Saved != 1 || stats.Pending != 0 {
+		t.Errorf("want 1 queued and saved; got %+v", stats)
+	}
+
+	// Once shut down, saves are made synchronously.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if _, found, _ := store.Find(rr.Result().Cookies()[0].Value); !found {
+		t.Error("want session committed synchronously after Shutdown")
+	}
+	if stats := s.AsyncSaveStats(); stats.Sync != 1 {
+		t.Errorf("want 1 synchronous save; got %+v", stats)
+	}
+}
+
+// blockingStore is a store whose Commit blocks until release is closed.
+type blockingStore struct {
+	*memstore.MemStore
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingStore) Commit(token string, data []byte, expiry time.Time) error {
+	<-b.release
+	if b.err != nil {
+		return b.err
+	}
+	return b.MemStore.Commit(token, data, expiry)
+}
+
+func TestAsyncSaveEncodesValues(t *testing.T) {
+	t.Parallel()
+
+	store := &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: make(chan struct{})}
+	s := New()
+	s.Store = store
+	s.AsyncSave = &AsyncSave{Workers: 1}
+
+	// The handler changes a value it has stored after the response has been
+	// written, while the save is still waiting in the store.
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := []string{"a"}
+		s.Put(r.Context(), "v", v)
+		w.Write([]byte("OK"))
+		v[0] = "b"
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	close(store.release)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find(rr.Result().Cookies()[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	} else if !found {
+		t.Fatal("want session committed after Shutdown")
+	}
+	_, values, err := s.Codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := values["v"].([]string); !ok || len(v) != 1 || v[0] != "a" {
+		t.Errorf("want [a]; got %v", values["v"])
+	}
+}
+
+func TestAsyncSaveWhenFull(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy      AsyncSavePolicy
+		wantDropped uint64
+		wantSync    uint64
+	}{
+		{DropWhenFull, 1, 0},
+		{SaveSyncWhenFull, 0, 1},
+	}
+
+	for _, tt := range tests {
+		store := &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: make(chan struct{})}
+		s := New()
+		s.Store = store
+		s.AsyncSave = &AsyncSave{Workers: 1, QueueSize: 1, WhenFull: tt.policy}
+
+		h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), "foo", "bar")
+		}))
+		serve := func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		// The first save is picked up by the worker, which blocks in the
+		// store, and the second fills the queue.
+		serve()
+		for s.AsyncSaveStats().Pending != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		serve()
+
+		// The third save finds the queue full. A synchronous save blocks in
+		// the store too, so it is released once the policy has been applied.
+		done := make(chan struct{})
+		go func() {
+			serve()
+			close(done)
+		}()
+		for {
+			stats := s.AsyncSaveStats()
+			if stats.Dropped+stats.Sync > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(store.release)
+		<-done
+
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		stats := s.AsyncSaveStats()
+		if stats.Dropped != tt.wantDropped || stats.Sync != tt.wantSync || stats.Saved != 2 {
+			t.Errorf("policy %d: got %+v", tt.policy, stats)
+		}
+	}
+}
+
+func TestAsyncSaveError(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	close(release)
+	s := New()
+	s.Store = &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: release, err: errors.New("boom")}
+
+	var mu sync.Mutex
+	var gotID string
+	var gotErr error
+	s.AsyncSave = &AsyncSave{ErrorFunc: func(id string, err error) {
+		mu.Lock()
+		gotID, gotErr = id, err
+		mu.Unlock()
+	}}
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if gotErr == nil || gotErr.Error() != "boom" {
+		t.Errorf("want error %q; got %v", "boom", gotErr)
+	}
+	if want := s.RedactToken(rr.Result().Cookies()[0].Value); gotID != want {
+		t.Errorf("want id %q; got %q", want, gotID)
+	}
+	if stats := s.AsyncSaveStats(); stats.Failed != 1 {
+		t.Errorf("want 1 failed save; got %+v", stats)
+	}
+}
+
+func TestWaitForSave(t *testing.T) {
+	t.Parallel()
+
+	for _, explicit := range []bool{true, false} {
+		store := &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: make(chan struct{})}
+		s := New()
+		s.Store = store
+		s.AsyncSave = &AsyncSave{Workers: 1, WaitForRedirects: !explicit}
+
+		h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), "flash", "Saved!")
+			if explicit {
+				s.WaitForSave(r.Context())
+			}
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		}))
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+			done <- rr
+		}()
+
+		select {
+		case <-done:
+			t.Fatalf("explicit %v: want response to wait for the save", explicit)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(store.release)
+		rr := <-done
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("explicit %v: got %d: expected %d", explicit, rr.Code, http.StatusSeeOther)
+		}
+		if _, found, _ := store.Find(rr.Result().Cookies()[0].Value); !found {
+			t.Errorf("explicit %v: want session committed before the response", explicit)
+		}
+		if stats := s.AsyncSaveStats(); stats.Waited != 1 || stats.Saved != 1 {
+			t.Errorf("explicit %v: got %+v", explicit, stats)
+		}
+		s.Shutdown(context.Background())
+	}
+}
+
+func TestWaitForSaveError(t *testing.T) {
+	t.Parallel()
+
+	errCommit := errors.New("commit failed")
+	store := &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: make(chan struct{}), err: errCommit}
+	close(store.release)
+
+	s := New()
+	s.Store = store
+	s.AsyncSave = &AsyncSave{
+		ErrorFunc: func(id string, err error) {
+			t.Errorf("want error returned to the request; got %v", err)
+		},
+	}
+	defer s.Shutdown(context.Background())
+
+	var gotErr error
+	s.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		gotErr = err
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+		s.WaitForSave(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if gotErr != errCommit {
+		t.Errorf("got %v: expected %v", gotErr, errCommit)
+	}
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got %d: expected %d", rr.Code, http.StatusInternalServerError)
+	}
+}
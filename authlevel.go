@@ -0,0 +1,77 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	authLevelKey         = "__authLevel"
+	authLevelFallbackKey = "__authLevel.fallback"
+	authLevelExpiryKey   = "__authLevel.expiry"
+)
+
+// SetAuthLevel sets the authentication level for the session. Levels are
+// application-defined integers where higher values represent stronger
+// authentication (for example 0 for anonymous, 1 for password and 2 for MFA).
+//
+// If SessionManager.AuthLevelTimeout is set and level is higher than the
+// current level, the session will automatically be downgraded to the current
+// level once the timeout has elapsed. Setting a level which is equal to or
+// lower than the current level clears any pending downgrade.
+//
+// As with any change in privilege level, you should call RenewToken before
+// raising the authentication level.
+func (s *SessionManager) SetAuthLevel(ctx context.Context, level int) {
+	current := s.AuthLevel(ctx)
+
+	s.Put(ctx, authLevelKey, level)
+	if s.AuthLevelTimeout > 0 && level > current {
+		s.Put(ctx, authLevelFallbackKey, current)
+		s.Put(ctx, authLevelExpiryKey, time.Now().Add(s.AuthLevelTimeout).UnixNano())
+		return
+	}
+
+	s.Remove(ctx, authLevelFallbackKey)
+	s.Remove(ctx, authLevelExpiryKey)
+}
+
+// AuthLevel returns the authentication level for the session, taking into
+// account any automatic downgrade. The zero value 0 is returned if no level
+// has been set.
+func (s *SessionManager) AuthLevel(ctx context.Context) int {
+	level := s.GetInt(ctx, authLevelKey)
+
+	expiry := s.GetInt64(ctx, authLevelExpiryKey)
+	if expiry != 0 && time.Now().UnixNano() > expiry {
+		level = s.GetInt(ctx, authLevelFallbackKey)
+		s.Put(ctx, authLevelKey, level)
+		s.Remove(ctx, authLevelFallbackKey)
+		s.Remove(ctx, authLevelExpiryKey)
+	}
+
+	return level
+}
+
+// RequireAuthLevel returns middleware which only allows requests to proceed if
+// the session's authentication level is at least level. Other requests are
+// passed to SessionManager.AuthLevelHandler, or sent a HTTP 403 "Forbidden"
+// response if it is not set. It must be used inside the LoadAndSave()
+// middleware.
+func (s *SessionManager) RequireAuthLevel(level int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.AuthLevel(r.Context()) < level {
+				if s.AuthLevelHandler != nil {
+					s.AuthLevelHandler.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
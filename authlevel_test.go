@@ -0,0 +1,80 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthLevel(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.AuthLevelTimeout = 50 * time.Millisecond
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.AuthLevel(ctx); got != 0 {
+		t.Errorf("want %d; got %d", 0, got)
+	}
+
+	s.SetAuthLevel(ctx, 1)
+	s.SetAuthLevel(ctx, 2)
+	if got := s.AuthLevel(ctx); got != 2 {
+		t.Errorf("want %d; got %d", 2, got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := s.AuthLevel(ctx); got != 1 {
+		t.Errorf("want %d; got %d", 1, got)
+	}
+
+	// Lowering the level clears any pending downgrade.
+	s.SetAuthLevel(ctx, 2)
+	s.SetAuthLevel(ctx, 1)
+	time.Sleep(100 * time.Millisecond)
+	if got := s.AuthLevel(ctx); got != 1 {
+		t.Errorf("want %d; got %d", 1, got)
+	}
+}
+
+func TestRequireAuthLevel(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	h := s.RequireAuthLevel(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetAuthLevel(ctx, 1)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, rr.Code)
+	}
+
+	s.AuthLevelHandler = http.RedirectHandler("/mfa", http.StatusSeeOther)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, rr.Code)
+	}
+
+	s.SetAuthLevel(ctx, 2)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if rr.Body.String() != "OK" {
+		t.Errorf("want %q; got %q", "OK", rr.Body.String())
+	}
+}
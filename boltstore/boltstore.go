@@ -8,7 +8,7 @@ import (
 	"go.etcd.io/bbolt"
 )
 
-var bucketName = []byte("scs:session")
+const bucketName = "scs:session"
 
 // BoltStore represents the session store.
 type BoltStore struct {
@@ -28,13 +28,14 @@ func New(db *bbolt.DB) *BoltStore {
 // from running (i.e. expired sessions will not be removed).
 func NewWithCleanupInterval(db *bbolt.DB, cleanupInterval time.Duration) *BoltStore {
 	db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(bucketName)
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
 		return err
 	})
 	bs := &BoltStore{
 		db: db,
 	}
 	if cleanupInterval > 0 {
+		bs.stopCleanup = make(chan bool)
 		go bs.startCleanup(cleanupInterval)
 	}
 	return bs
@@ -46,7 +47,7 @@ func NewWithCleanupInterval(db *bbolt.DB, cleanupInterval time.Duration) *BoltSt
 func (bs *BoltStore) Find(token string) (b []byte, exists bool, err error) {
 	var val []byte
 	err = bs.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		val = bucket.Get([]byte(token))
 		if val == nil {
 			return nil
@@ -76,7 +77,7 @@ func (bs *BoltStore) Commit(token string, b []byte, expiry time.Time) error {
 		binary.BigEndian.PutUint64(buf, uint64(expiry.UnixNano()))
 		val := append(buf, b...)
 
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		err := bucket.Put([]byte(token), val)
 		return err
 	})
@@ -86,7 +87,7 @@ func (bs *BoltStore) Commit(token string, b []byte, expiry time.Time) error {
 // instance.
 func (bs *BoltStore) Delete(token string) error {
 	return bs.db.Update(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		return bucket.Delete([]byte(token))
 	})
 }
@@ -97,7 +98,7 @@ func (bs *BoltStore) All() (map[string][]byte, error) {
 	sessions := make(map[string][]byte)
 
 	err := bs.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		cursor := bucket.Cursor()
 
 		for key, val := cursor.First(); key != nil; key, val = cursor.Next() {
@@ -116,12 +117,11 @@ func (bs *BoltStore) All() (map[string][]byte, error) {
 }
 
 func (bs *BoltStore) startCleanup(cleanupInterval time.Duration) {
-	bs.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(cleanupInterval)
 	for {
 		select {
 		case <-ticker.C:
-			err := bs.deleteExpired()
+			_, err := bs.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -148,13 +148,17 @@ func (bs *BoltStore) StopCleanup() {
 	}
 }
 
-func (bs *BoltStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (bs *BoltStore) DeleteExpired() (int, error) {
 	var expiredTokens [][]byte
 	bs.db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		bucket.ForEach(func(token, val []byte) error {
 			if uint64(time.Now().UnixNano()) > binary.BigEndian.Uint64(val[:8]) {
-				expiredTokens = append(expiredTokens, token)
+				expiredTokens = append(expiredTokens, append([]byte(nil), token...))
 			}
 			return nil
 		})
@@ -162,9 +166,9 @@ func (bs *BoltStore) deleteExpired() error {
 	})
 
 	if len(expiredTokens) > 0 {
-		return bs.db.Update(func(tx *bbolt.Tx) error {
+		err := bs.db.Update(func(tx *bbolt.Tx) error {
 			for _, token := range expiredTokens {
-				bucket := tx.Bucket(bucketName)
+				bucket := tx.Bucket([]byte(bucketName))
 				err := bucket.Delete([]byte(token))
 				if err != nil {
 					return err
@@ -172,7 +176,10 @@ func (bs *BoltStore) deleteExpired() error {
 			}
 			return nil
 		})
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	return nil
+	return len(expiredTokens), nil
 }
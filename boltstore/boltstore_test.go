@@ -19,7 +19,7 @@ func TestCommit(t *testing.T) {
 	bs.Commit("key1", []byte("value1"), time.Now().Add(time.Minute))
 
 	db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		v := bucket.Get([]byte("key1"))
 		if !bytes.Equal(v[8:], []byte("value1")) {
 			t.Fatalf("expected bytes `value1`, got %s", v)
@@ -138,7 +138,7 @@ func TestCleanup(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	err = db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		data := bucket.Get([]byte("session_token"))
 		if data != nil {
 			t.Fatalf("expected nil, got %v", data)
@@ -184,12 +184,12 @@ func TestDeleteExpired(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 
-	if err := m.deleteExpired(); err != nil {
+	if _, err := m.DeleteExpired(); err != nil {
 		t.Fatalf("expected nil, got %v", err)
 	}
 
 	err = db.View(func(tx *bbolt.Tx) error {
-		bucket := tx.Bucket(bucketName)
+		bucket := tx.Bucket([]byte(bucketName))
 		data := bucket.Get([]byte("session_token1"))
 		if data != nil {
 			t.Fatalf("expected nil, got %v", data)
@@ -37,6 +37,7 @@ func NewWithCleanupInterval(db *bun.DB, cleanupInterval time.Duration) (*BunStor
 	b := &BunStore{db: db}
 
 	if cleanupInterval > 0 {
+		b.stopCleanup = make(chan bool)
 		go b.startCleanup(cleanupInterval)
 	}
 
@@ -123,12 +124,11 @@ func (b *BunStore) AllCtx(ctx context.Context) (map[string][]byte, error) {
 }
 
 func (b *BunStore) startCleanup(interval time.Duration) {
-	b.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := b.deleteExpired()
+			_, err := b.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -155,14 +155,18 @@ func (b *BunStore) StopCleanup() {
 	}
 }
 
-func (b *BunStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (b *BunStore) DeleteExpired() (int, error) {
 	ctx := context.Background()
-	_, err := b.db.NewDelete().Model(&session{}).Where("expiry < ?", time.Now()).Exec(ctx)
+	res, err := b.db.NewDelete().Model(&session{}).Where("expiry < ?", time.Now()).Exec(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	return nil
+	n, err := res.RowsAffected()
+	return int(n), err
 }
 
 // We have to add the plain Store methods here to be recognized a Store
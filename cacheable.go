@@ -0,0 +1,71 @@
+package scs
+
+import (
+	"net/http"
+	"strings"
+)
+
+// cacheablePath reports whether the request path matches one of the
+// CacheablePaths prefixes.
+func (s *SessionManager) cacheablePath(r *http.Request) bool {
+	for _, prefix := range s.CacheablePaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheable reports whether the session cookie must not be sent with the
+// response, because the request path matches CacheablePaths or, if
+// SuppressCookieOnCacheable is set, the response is marked as cacheable by
+// shared caches.
+func (s *SessionManager) cacheable(w http.ResponseWriter, r *http.Request) bool {
+	if s.cacheablePath(r) {
+		return true
+	}
+	if !s.SuppressCookieOnCacheable {
+		return false
+	}
+
+	for _, v := range w.Header()["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			if directive == "public" || strings.HasPrefix(directive, "s-maxage") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commitWithoutCookie commits the session data for a cacheable response
+// without sending the session cookie. Session cookies already added to the
+// response headers (such as expired cookies for duplicates) are removed. The
+// data for a new session is not committed, because the client would never
+// receive its token.
+func (s *SessionManager) commitWithoutCookie(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	prefix := s.cookie().Name + "="
+	kept := h["Set-Cookie"][:0]
+	for _, v := range h["Set-Cookie"] {
+		if !strings.HasPrefix(v, prefix) {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		h.Del("Set-Cookie")
+	} else {
+		h["Set-Cookie"] = kept
+	}
+
+	ctx := r.Context()
+	if s.Status(ctx) != Modified || s.Token(ctx) == "" {
+		return
+	}
+	if _, _, err := s.Commit(ctx); err != nil {
+		s.ErrorFunc(w, r, err)
+		return
+	}
+	s.markCommitted(ctx)
+}
@@ -0,0 +1,100 @@
+package scs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheablePaths(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.CacheablePaths = []string{"/static/"}
+	sessionManager.MobileCompat = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "other", Value: "1"})
+		sessionManager.Put(r.Context(), "page", r.URL.Path)
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, sessionManager.GetString(r.Context(), "page"))
+	})
+	h := sessionManager.LoadAndSave(mux)
+
+	// A new session isn't created for a cacheable path.
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/static/a.css", nil))
+	if got := rr.Header()["Set-Cookie"]; len(got) != 1 || got[0] != "other=1" {
+		t.Errorf("want only the other cookie; got %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+	token := extractTokenFromCookie(rr.Header().Get("Set-Cookie"))
+
+	// Changes to an existing session are committed without a cookie.
+	r := httptest.NewRequest("GET", "/static/b.css", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if got := rr.Header()["Set-Cookie"]; len(got) != 1 || got[0] != "other=1" {
+		t.Errorf("want only the other cookie; got %q", got)
+	}
+	if got := rr.Header().Get("X-Session-Token"); got != "" {
+		t.Errorf("want no token header; got %q", got)
+	}
+
+	r = httptest.NewRequest("GET", "/get", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if got := rr.Body.String(); got != "/static/b.css" {
+		t.Errorf("want %q; got %q", "/static/b.css", got)
+	}
+}
+
+func TestSuppressCookieOnCacheable(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.SuppressCookieOnCacheable = true
+
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+		if cc := r.URL.Query().Get("cc"); cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
+		io.WriteString(w, "OK")
+	}))
+
+	tests := []struct {
+		cacheControl string
+		cookie       bool
+	}{
+		{"", true},
+		{"private, max-age=60", true},
+		{"public, max-age=60", false},
+		{"max-age=0, S-MAXAGE=600", false},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		q := r.URL.Query()
+		q.Set("cc", tt.cacheControl)
+		r.URL.RawQuery = q.Encode()
+		h.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get("Set-Cookie") != ""; got != tt.cookie {
+			t.Errorf("Cache-Control %q: want cookie %v; got %v", tt.cacheControl, tt.cookie, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package cachestore provides a session store which caches session data from
+// another (usually remote) session store in memory.
+package cachestore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Store is the interface for the underlying session store. It has the same
+// methods as scs.Store.
+type Store interface {
+	Delete(token string) (err error)
+	Find(token string) (b []byte, found bool, err error)
+	Commit(token string, b []byte, expiry time.Time) (err error)
+}
+
+// BatchFinder is implemented by session stores which can look up the data for
+// multiple session tokens in a single round trip. The returned map should
+// contain an entry for each token which was found and has not expired.
+type BatchFinder interface {
+	FindMany(tokens []string) (map[string][]byte, error)
+}
+
+// sweepEvery is the number of cache insertions between sweeps for expired
+// items.
+const sweepEvery = 1024
+
+type item struct {
+	object     []byte
+	expiration int64
+}
+
+// CacheStore represents the session store.
+type CacheStore struct {
+	store Store
+	ttl   time.Duration
+	items map[string]item
+	mu    sync.RWMutex
+
+	// writes counts cache insertions, so that expired items can be swept
+	// periodically.
+	writes int
+}
+
+// New returns a new CacheStore instance which caches session data from store
+// for at most ttl. Writes and deletes go through to the underlying store
+// immediately. Because changes made to the underlying store by other processes
+// aren't seen until the cached copy expires, ttl should be kept short if more
+// than one application instance shares the underlying store.
+func New(store Store, ttl time.Duration) *CacheStore {
+	return &CacheStore{
+		store: store,
+		ttl:   ttl,
+		items: make(map[string]item),
+	}
+}
+
+// Find returns the data for a given session token, from the cache if possible
+// and otherwise from the underlying store.
+func (c *CacheStore) Find(token string) ([]byte, bool, error) {
+	c.mu.RLock()
+	item, found := c.items[token]
+	c.mu.RUnlock()
+
+	if found && time.Now().UnixNano() < item.expiration {
+		return item.object, true, nil
+	}
+
+	b, found, err := c.store.Find(token)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	c.cache(token, b, time.Time{})
+	return b, true, nil
+}
+
+// Cached reports whether the data for a session token is currently held in the
+// cache, so that a call to Find won't need to query the underlying store.
+func (c *CacheStore) Cached(token string) bool {
+	c.mu.RLock()
+	item, found := c.items[token]
+	c.mu.RUnlock()
+
+	return found && time.Now().UnixNano() < item.expiration
+}
+
+// Commit adds a session token and data to the underlying store and the cache
+// with the given expiry time.
+func (c *CacheStore) Commit(token string, b []byte, expiry time.Time) error {
+	if err := c.store.Commit(token, b, expiry); err != nil {
+		c.evict(token)
+		return err
+	}
+
+	c.cache(token, b, expiry)
+	return nil
+}
+
+// Delete removes a session token and corresponding data from the underlying
+// store and the cache.
+func (c *CacheStore) Delete(token string) error {
+	c.evict(token)
+	return c.store.Delete(token)
+}
+
+// All returns a map containing the token and data for all active sessions in
+// the underlying store. It returns an error if the underlying store doesn't
+// support iteration.
+func (c *CacheStore) All() (map[string][]byte, error) {
+	s, ok := c.store.(interface {
+		All() (map[string][]byte, error)
+	})
+	if !ok {
+		return nil, errors.New("cachestore: underlying store does not support iteration")
+	}
+	return s.All()
+}
+
+// DeleteExpired removes expired entries from the cache and, if the underlying
+// store implements a DeleteExpired method, deletes expired sessions from the
+// underlying store. It returns the number of sessions deleted from the
+// underlying store.
+func (c *CacheStore) DeleteExpired() (int, error) {
+	now := time.Now().UnixNano()
+	c.mu.Lock()
+	for token, item := range c.items {
+		if now >= item.expiration {
+			delete(c.items, token)
+		}
+	}
+	c.mu.Unlock()
+
+	s, ok := c.store.(interface {
+		DeleteExpired() (int, error)
+	})
+	if !ok {
+		return 0, nil
+	}
+	return s.DeleteExpired()
+}
+
+// Close closes the underlying store if it has a Close() error method, or
+// otherwise stops its cleanup goroutine if it has a StopCleanup() method.
+func (c *CacheStore) Close() error {
+	switch s := c.store.(type) {
+	case interface{ Close() error }:
+		return s.Close()
+	case interface{ StopCleanup() }:
+		s.StopCleanup()
+	}
+	return nil
+}
+
+// Preload loads the data for the given session tokens from the underlying
+// store into the cache, for example to warm the cache after a deploy. Tokens
+// which are already cached are skipped. If the underlying store implements
+// BatchFinder the sessions are loaded with a single FindMany call, otherwise
+// Find is called for each token. It returns the number of sessions loaded.
+func (c *CacheStore) Preload(tokens []string) (int, error) {
+	now := time.Now().UnixNano()
+
+	var missing []string
+	c.mu.RLock()
+	for _, token := range tokens {
+		if item, found := c.items[token]; !found || now >= item.expiration {
+			missing = append(missing, token)
+		}
+	}
+	c.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	if bf, ok := c.store.(BatchFinder); ok {
+		found, err := bf.FindMany(missing)
+		if err != nil {
+			return 0, err
+		}
+		for token, b := range found {
+			c.cache(token, b, time.Time{})
+		}
+		return len(found), nil
+	}
+
+	n := 0
+	for _, token := range missing {
+		b, found, err := c.store.Find(token)
+		if err != nil {
+			return n, err
+		}
+		if found {
+			c.cache(token, b, time.Time{})
+			n++
+		}
+	}
+	return n, nil
+}
+
+// cache adds the data for token to the cache until the earlier of expiry (if
+// it is not zero) and the cache TTL.
+func (c *CacheStore) cache(token string, b []byte, expiry time.Time) {
+	expiration := time.Now().Add(c.ttl).UnixNano()
+	if !expiry.IsZero() && expiry.UnixNano() < expiration {
+		expiration = expiry.UnixNano()
+	}
+
+	c.mu.Lock()
+	c.items[token] = item{object: b, expiration: expiration}
+	c.writes++
+	if c.writes%sweepEvery == 0 {
+		now := time.Now().UnixNano()
+		for token, item := range c.items {
+			if now >= item.expiration {
+				delete(c.items, token)
+			}
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *CacheStore) evict(token string) {
+	c.mu.Lock()
+	delete(c.items, token)
+	c.mu.Unlock()
+}
@@ -0,0 +1,147 @@
+package cachestore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+type countingStore struct {
+	*memstore.MemStore
+	finds     int
+	findManys int
+}
+
+func (s *countingStore) Find(token string) ([]byte, bool, error) {
+	s.finds++
+	return s.MemStore.Find(token)
+}
+
+type batchStore struct {
+	*countingStore
+}
+
+func (s batchStore) FindMany(tokens []string) (map[string][]byte, error) {
+	s.findManys++
+	found := make(map[string][]byte)
+	for _, token := range tokens {
+		if b, ok, _ := s.MemStore.Find(token); ok {
+			found[token] = b
+		}
+	}
+	return found, nil
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{MemStore: memstore.NewWithCleanupInterval(0)}
+}
+
+func TestFind(t *testing.T) {
+	under := newCountingStore()
+	under.MemStore.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+
+	c := New(under, time.Minute)
+	for i := 0; i < 3; i++ {
+		b, found, err := c.Find("session_token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || !bytes.Equal(b, []byte("encoded_data")) {
+			t.Fatalf("got %v %q: expected %v %q", found, b, true, "encoded_data")
+		}
+	}
+	if under.finds != 1 {
+		t.Errorf("got %d underlying finds: expected %d", under.finds, 1)
+	}
+
+	_, found, err := c.Find("missing_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("got found: expected not found")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	under := newCountingStore()
+	under.MemStore.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+
+	c := New(under, 10*time.Millisecond)
+	c.Find("session_token")
+	time.Sleep(20 * time.Millisecond)
+	c.Find("session_token")
+
+	if under.finds != 2 {
+		t.Errorf("got %d underlying finds: expected %d", under.finds, 2)
+	}
+}
+
+func TestCommitAndDelete(t *testing.T) {
+	under := newCountingStore()
+	c := New(under, time.Minute)
+
+	if err := c.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := under.MemStore.Find("session_token"); !found {
+		t.Error("expected commit to be written to the underlying store")
+	}
+	if _, found, _ := c.Find("session_token"); !found || under.finds != 0 {
+		t.Errorf("expected committed data to be cached")
+	}
+
+	if err := c.Delete("session_token"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := c.Find("session_token"); found {
+		t.Error("expected deleted session not to be found")
+	}
+	if _, found, _ := under.MemStore.Find("session_token"); found {
+		t.Error("expected delete to be written to the underlying store")
+	}
+}
+
+func TestPreload(t *testing.T) {
+	under := newCountingStore()
+	under.MemStore.Commit("token1", []byte("data1"), time.Now().Add(time.Minute))
+	under.MemStore.Commit("token2", []byte("data2"), time.Now().Add(time.Minute))
+
+	c := New(under, time.Minute)
+	n, err := c.Preload([]string{"token1", "token2", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d: expected %d", n, 2)
+	}
+
+	under.finds = 0
+	c.Find("token1")
+	c.Find("token2")
+	if under.finds != 0 {
+		t.Errorf("got %d underlying finds: expected %d", under.finds, 0)
+	}
+}
+
+func TestPreloadBatch(t *testing.T) {
+	under := batchStore{newCountingStore()}
+	under.MemStore.Commit("token1", []byte("data1"), time.Now().Add(time.Minute))
+	under.MemStore.Commit("token2", []byte("data2"), time.Now().Add(time.Minute))
+
+	c := New(under, time.Minute)
+	c.Find("token1")
+
+	n, err := c.Preload([]string{"token1", "token2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d: expected %d", n, 1)
+	}
+	if under.findManys != 1 || under.finds != 1 {
+		t.Errorf("got %d FindMany and %d Find calls: expected 1 and 1", under.findManys, under.finds)
+	}
+}
@@ -0,0 +1,159 @@
+// Package cart provides a shopping cart which is stored in the session data of
+// an scs.SessionManager.
+//
+// The line items are stored as a single JSON-encoded []byte value, so they
+// work with any scs.Codec and don't need to be registered with encoding/gob.
+// Prices are not stored in the session; they are looked up with a PriceFunc
+// when the total is calculated, so the cart always reflects current prices.
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// DefaultKey is the session data key used by New when key is empty.
+const DefaultKey = "cart"
+
+// ErrInvalidQuantity is returned by AddItem when the quantity is not positive.
+var ErrInvalidQuantity = errors.New("cart: quantity must be positive")
+
+// ErrItemNotFound is returned by UpdateQty and RemoveItem when the cart
+// doesn't contain the item.
+var ErrItemNotFound = errors.New("cart: item not found")
+
+// ErrNoPriceFunc is returned by Total when the cart has no PriceFunc.
+var ErrNoPriceFunc = errors.New("cart: no PriceFunc")
+
+// PriceFunc returns the unit price of an item, in the smallest unit of the
+// currency (for example, cents).
+type PriceFunc func(ctx context.Context, sku string) (int64, error)
+
+// Item is a line item in a cart.
+type Item struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+// Cart is a shopping cart stored in the session data under a single key.
+type Cart struct {
+	sessionManager *scs.SessionManager
+	key            string
+	price          PriceFunc
+}
+
+// New returns a Cart which stores its items in the session data of
+// sessionManager under key (or DefaultKey if key is empty), and looks up
+// prices with price. The price may be nil if Total is not used.
+func New(sessionManager *scs.SessionManager, key string, price PriceFunc) *Cart {
+	if key == "" {
+		key = DefaultKey
+	}
+	return &Cart{sessionManager: sessionManager, key: key, price: price}
+}
+
+// Items returns the line items in the cart, in the order they were first
+// added. Nil is returned for an empty cart, or if the stored value can't be
+// decoded.
+func (c *Cart) Items(ctx context.Context) []Item {
+	b := c.sessionManager.GetBytes(ctx, c.key)
+	if len(b) == 0 {
+		return nil
+	}
+
+	var items []Item
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil
+	}
+	return items
+}
+
+// Count returns the total quantity of all items in the cart.
+func (c *Cart) Count(ctx context.Context) int {
+	n := 0
+	for _, item := range c.Items(ctx) {
+		n += item.Qty
+	}
+	return n
+}
+
+// AddItem adds qty of an item to the cart, increasing the quantity if the
+// cart already contains the item.
+func (c *Cart) AddItem(ctx context.Context, sku string, qty int) error {
+	if qty <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	items := c.Items(ctx)
+	for i := range items {
+		if items[i].SKU == sku {
+			items[i].Qty += qty
+			return c.save(ctx, items)
+		}
+	}
+	return c.save(ctx, append(items, Item{SKU: sku, Qty: qty}))
+}
+
+// UpdateQty sets the quantity of an item in the cart. The item is removed if
+// qty is zero or negative. ErrItemNotFound is returned if the cart doesn't
+// contain the item.
+func (c *Cart) UpdateQty(ctx context.Context, sku string, qty int) error {
+	items := c.Items(ctx)
+	for i := range items {
+		if items[i].SKU != sku {
+			continue
+		}
+		if qty <= 0 {
+			return c.save(ctx, append(items[:i], items[i+1:]...))
+		}
+		items[i].Qty = qty
+		return c.save(ctx, items)
+	}
+	return ErrItemNotFound
+}
+
+// RemoveItem removes an item from the cart. ErrItemNotFound is returned if the
+// cart doesn't contain the item.
+func (c *Cart) RemoveItem(ctx context.Context, sku string) error {
+	return c.UpdateQty(ctx, sku, 0)
+}
+
+// Clear removes all items from the cart.
+func (c *Cart) Clear(ctx context.Context) error {
+	return c.sessionManager.TryRemove(ctx, c.key)
+}
+
+// Total returns the total price of the items in the cart, using the PriceFunc
+// to look up the current unit price of each item.
+func (c *Cart) Total(ctx context.Context) (int64, error) {
+	if c.price == nil {
+		return 0, ErrNoPriceFunc
+	}
+
+	var total int64
+	for _, item := range c.Items(ctx) {
+		price, err := c.price(ctx, item.SKU)
+		if err != nil {
+			return 0, err
+		}
+		total += price * int64(item.Qty)
+	}
+	return total, nil
+}
+
+// save stores the items in the session data, removing the key if the cart is
+// empty.
+func (c *Cart) save(ctx context.Context, items []Item) error {
+	if len(items) == 0 {
+		return c.sessionManager.TryRemove(ctx, c.key)
+	}
+
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return c.sessionManager.TryPut(ctx, c.key, b)
+}
@@ -0,0 +1,129 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+var prices = map[string]int64{"apple": 50, "pear": 75}
+
+func price(ctx context.Context, sku string) (int64, error) {
+	p, ok := prices[sku]
+	if !ok {
+		return 0, errors.New("unknown sku")
+	}
+	return p, nil
+}
+
+func TestCart(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := scs.New()
+	c := New(sessionManager, "", price)
+
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.AddItem(ctx, "apple", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddItem(ctx, "pear", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddItem(ctx, "apple", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddItem(ctx, "apple", 0); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("want %v; got %v", ErrInvalidQuantity, err)
+	}
+
+	want := []Item{{SKU: "apple", Qty: 3}, {SKU: "pear", Qty: 1}}
+	if got := c.Items(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+	if got := c.Count(ctx); got != 4 {
+		t.Errorf("want %d; got %d", 4, got)
+	}
+	if got, err := c.Total(ctx); err != nil || got != 225 {
+		t.Errorf("want %d; got %d, %v", 225, got, err)
+	}
+
+	// The cart survives a round trip through the store.
+	token, _, err := sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = sessionManager.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpdateQty(ctx, "pear", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateQty(ctx, "plum", 1); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("want %v; got %v", ErrItemNotFound, err)
+	}
+	if err := c.RemoveItem(ctx, "apple"); err != nil {
+		t.Fatal(err)
+	}
+	want = []Item{{SKU: "pear", Qty: 4}}
+	if got := c.Items(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+
+	if err := c.RemoveItem(ctx, "pear"); err != nil {
+		t.Fatal(err)
+	}
+	if sessionManager.Exists(ctx, DefaultKey) {
+		t.Error("want the key to be removed for an empty cart")
+	}
+}
+
+func TestCartTotalErrors(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := scs.New()
+
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(sessionManager, "basket", nil).Total(ctx); !errors.Is(err, ErrNoPriceFunc) {
+		t.Errorf("want %v; got %v", ErrNoPriceFunc, err)
+	}
+
+	c := New(sessionManager, "basket", price)
+	if err := c.AddItem(ctx, "plum", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Total(ctx); err == nil {
+		t.Error("want error for an unknown price")
+	}
+	if !sessionManager.Exists(ctx, "basket") {
+		t.Error("want the cart to be stored under the given key")
+	}
+}
+
+func TestCartReadOnly(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := scs.New()
+	sessionManager.ReadOnly = true
+	c := New(sessionManager, "", price)
+
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddItem(ctx, "apple", 1); !errors.Is(err, scs.ErrReadOnly) {
+		t.Errorf("want %v; got %v", scs.ErrReadOnly, err)
+	}
+}
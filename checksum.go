@@ -0,0 +1,61 @@
+package scs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"time"
+)
+
+// ErrChecksum is the decode error for session data whose checksum doesn't
+// match, because it has been corrupted or only partly written. It is handled
+// according to SessionManager.OnDecodeError.
+var ErrChecksum = errors.New("scs: session data checksum mismatch")
+
+// checksumMagic marks the start of session data with a checksum. It is
+// followed by the CRC-32C of the encoded data, written big-endian, and then
+// the encoded data itself. The checksum is written before the data, so that
+// a partial write which cuts off the end of the data is detected.
+const checksumMagic = "\x00crc"
+
+const checksumLen = len(checksumMagic) + 4
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// encode encodes the session data with the Codec, adding a checksum if the
+// Checksum setting is enabled.
+func (s *SessionManager) encode(deadline time.Time, values map[string]interface{}) ([]byte, error) {
+	b, err := s.Codec.Encode(deadline, values)
+	if err != nil || !s.Checksum {
+		return b, err
+	}
+
+	out := make([]byte, checksumLen, checksumLen+len(b))
+	copy(out, checksumMagic)
+	binary.BigEndian.PutUint32(out[len(checksumMagic):], crc32.Checksum(b, checksumTable))
+	return append(out, b...), nil
+}
+
+// verifyChecksum returns the encoded session data without its checksum, or
+// ErrChecksum if the checksum doesn't match. Data without a checksum is
+// returned unchanged, unless required is true, in which case it is treated as
+// a mismatch.
+func verifyChecksum(b []byte, required bool) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte(checksumMagic)) {
+		if required {
+			return nil, ErrChecksum
+		}
+		return b, nil
+	}
+	if len(b) < checksumLen {
+		return nil, ErrChecksum
+	}
+
+	sum := binary.BigEndian.Uint32(b[len(checksumMagic):])
+	data := b[checksumLen:]
+	if crc32.Checksum(data, checksumTable) != sum {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
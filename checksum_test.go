@@ -0,0 +1,154 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Checksum = true
+	s.OnDecodeError = FailOnDecodeError
+
+	commit := func() string {
+		t.Helper()
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "foo", "bar")
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	token := commit()
+	ctx, err := s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetString(ctx, "foo") != "bar" {
+		t.Errorf("got %q: expected %q", s.GetString(ctx, "foo"), "bar")
+	}
+
+	// Data with a checksum can still be loaded when the setting is disabled.
+	s.Checksum = false
+	if _, err := s.Load(context.Background(), token); err != nil {
+		t.Errorf("got %v: expected no error", err)
+	}
+
+	// Corrupted data is detected.
+	b, _, err := s.Store.Find(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)/2] ^= 0xff
+	if err := s.Store.Commit(token, b, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(context.Background(), token); !errors.Is(err, ErrChecksum) {
+		t.Errorf("got %v: expected %v", err, ErrChecksum)
+	}
+
+	// Data without a checksum is rejected when the setting is enabled.
+	s.Checksum = true
+	legacy, err := s.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{"foo": "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.Commit("legacy", legacy, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(context.Background(), "legacy"); !errors.Is(err, ErrChecksum) {
+		t.Errorf("got %v: expected %v", err, ErrChecksum)
+	}
+
+	// It is loaded when the setting is disabled.
+	s.Checksum = false
+	ctx, err = s.Load(context.Background(), "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetString(ctx, "foo") != "baz" {
+		t.Errorf("got %q: expected %q", s.GetString(ctx, "foo"), "baz")
+	}
+
+	// By default, corrupted data is discarded.
+	s.OnDecodeError = DiscardOnDecodeError
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Token(ctx) != "" {
+		t.Errorf("want new session; got token %q", s.Token(ctx))
+	}
+	if _, found, _ := s.Store.Find(token); found {
+		t.Error("want corrupted data deleted")
+	}
+}
+
+func TestChecksumPartialStore(t *testing.T) {
+	t.Parallel()
+
+	store := newPartialStore()
+	s := New()
+	s.Store = store
+	s.Checksum = true
+	s.OnDecodeError = FailOnDecodeError
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetString(ctx, "foo") != "bar" {
+		t.Errorf("got %q: expected %q", s.GetString(ctx, "foo"), "bar")
+	}
+
+	store.values[token]["foo"][0] ^= 0xff
+	if _, err := s.Load(context.Background(), token); !errors.Is(err, ErrChecksum) {
+		t.Errorf("got %v: expected %v", err, ErrChecksum)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Checksum = true
+	b, err := s.encode(time.Time{}, map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Partial writes and lost bytes are detected, wherever they occur.
+	for i := 0; i < len(b); i++ {
+		if _, err := verifyChecksum(b[:i], true); err != ErrChecksum {
+			t.Errorf("truncated to %d bytes: got %v: expected %v", i, err, ErrChecksum)
+		}
+
+		damaged := append(append([]byte(nil), b[:i]...), b[i+1:]...)
+		if _, err := verifyChecksum(damaged, true); err != ErrChecksum {
+			t.Errorf("byte %d removed: got %v: expected %v", i, err, ErrChecksum)
+		}
+	}
+
+	if _, err := verifyChecksum(b, true); err != nil {
+		t.Errorf("got %v: expected no error", err)
+	}
+}
@@ -0,0 +1,184 @@
+package scs
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	cleanupRefsKey = "__cleanup.refs"
+	cleanupIDKey   = "__cleanup.id"
+	cleanupPrefix  = "cleanup:"
+
+	// cleanupRetention is how long a cleanup record is kept in the store after
+	// the deadline of its session, giving CleanupExpired time to find it.
+	cleanupRetention = 7 * 24 * time.Hour
+)
+
+// AddCleanupRef records a reference to an external resource (such as the key
+// of a temporary upload in object storage) which belongs to the current
+// session and must be cleaned up when the session ends. When the session is
+// destroyed, or expires and is then found by CleanupExpired, the
+// SessionManager.CleanupFunc is called with all of the session's references.
+// Use RemoveCleanupRef once the resource is no longer temporary, for example
+// when a multi-step upload is completed. CleanupFunc must be set for
+// references to be tracked in the store.
+func (s *SessionManager) AddCleanupRef(ctx context.Context, ref string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	refs, _ := sd.values[cleanupRefsKey].([]string)
+	for _, r := range refs {
+		if r == ref {
+			return nil
+		}
+	}
+
+	if _, ok := sd.values[cleanupIDKey].(string); !ok {
+		id, err := randomString(16)
+		if err != nil {
+			return err
+		}
+		sd.values[cleanupIDKey] = id
+	}
+	sd.values[cleanupRefsKey] = append(append([]string(nil), refs...), ref)
+	sd.status = Modified
+
+	return nil
+}
+
+// RemoveCleanupRef removes a reference recorded by AddCleanupRef, so that the
+// resource is no longer cleaned up when the session ends.
+func (s *SessionManager) RemoveCleanupRef(ctx context.Context, ref string) {
+	if s.ReadOnly {
+		return
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	refs, _ := sd.values[cleanupRefsKey].([]string)
+	kept := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if r != ref {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(refs) {
+		return
+	}
+
+	sd.values[cleanupRefsKey] = kept
+	sd.status = Modified
+}
+
+// CleanupRefs returns the references recorded by AddCleanupRef for the current
+// session.
+func (s *SessionManager) CleanupRefs(ctx context.Context) []string {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	refs, _ := sd.values[cleanupRefsKey].([]string)
+	return append([]string(nil), refs...)
+}
+
+// CleanupExpired finds sessions with references recorded by AddCleanupRef
+// which have expired (or been deleted from the store without Destroy), calls
+// the SessionManager.CleanupFunc with their references, and returns the number
+// of sessions cleaned up. It should be run periodically, at least once a week,
+// for example from the same scheduled job as GC. If CleanupFunc returns an
+// error, CleanupExpired stops and returns it, and the session will be cleaned
+// up again on the next run. The session store must support iteration.
+//
+// References are tracked with a record in the session store for each session,
+// stored with the "cleanup:" prefix.
+func (s *SessionManager) CleanupExpired(ctx context.Context) (int, error) {
+	if s.CleanupFunc == nil {
+		return 0, nil
+	}
+
+	all, err := s.allRecords(ctx, s.Store)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for key, b := range all {
+		if !strings.HasPrefix(key, cleanupPrefix) {
+			continue
+		}
+
+		_, values, err := s.decode(b)
+		if err != nil {
+			return n, err
+		}
+		token, _ := values["token"].(string)
+		if _, active := all[token]; active {
+			continue
+		}
+
+		refs, _ := values["refs"].([]string)
+		if len(refs) > 0 {
+			if err := s.CleanupFunc(ctx, refs); err != nil {
+				return n, err
+			}
+		}
+		if err := storeDelete(ctx, s.Store, key); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// commitCleanupRecord writes (or deletes, if there are no references) the
+// cleanup record for the session, which is used by CleanupExpired to find the
+// references for sessions which have expired.
+func (s *SessionManager) commitCleanupRecord(ctx context.Context, token string, deadline time.Time, values map[string]interface{}) error {
+	id, ok := values[cleanupIDKey].(string)
+	if !ok || s.CleanupFunc == nil {
+		return nil
+	}
+
+	refs, _ := values[cleanupRefsKey].([]string)
+	if len(refs) == 0 {
+		return storeDelete(ctx, s.Store, cleanupPrefix+id)
+	}
+
+	storeToken := token
+	if s.HashTokenInStore {
+		storeToken = hashToken(storeToken)
+	}
+
+	expiry := deadline.Add(cleanupRetention)
+	b, err := s.encode(expiry, map[string]interface{}{"token": storeToken, "refs": refs})
+	if err != nil {
+		return err
+	}
+	return storeCommit(ctx, s.Store, cleanupPrefix+id, b, expiry)
+}
+
+// cleanupDestroyed deletes the cleanup record for a destroyed session and
+// calls the CleanupFunc with its references. It must be called with sd.mu
+// held, before the session data is reset.
+func (s *SessionManager) cleanupDestroyed(ctx context.Context, sd *sessionData) (refs []string, err error) {
+	id, ok := sd.values[cleanupIDKey].(string)
+	if !ok || s.CleanupFunc == nil {
+		return nil, nil
+	}
+
+	refs, _ = sd.values[cleanupRefsKey].([]string)
+	return refs, storeDelete(ctx, s.Store, cleanupPrefix+id)
+}
@@ -0,0 +1,113 @@
+package scs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCleanupRefs(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var cleaned []string
+	s := New()
+	s.CleanupFunc = func(ctx context.Context, refs []string) error {
+		mu.Lock()
+		cleaned = append(cleaned, refs...)
+		mu.Unlock()
+		return nil
+	}
+
+	newSession := func(refs ...string) context.Context {
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ref := range refs {
+			if err := s.AddCleanupRef(ctx, ref); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, _, err := s.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+
+	// Destroying a session cleans up its references.
+	ctx := newSession("upload/1", "upload/2", "upload/1")
+	if got := s.CleanupRefs(ctx); !reflect.DeepEqual(got, []string{"upload/1", "upload/2"}) {
+		t.Errorf("want 2 references; got %v", got)
+	}
+	if err := s.Destroy(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cleaned, []string{"upload/1", "upload/2"}) {
+		t.Errorf("want references cleaned up on destroy; got %v", cleaned)
+	}
+
+	// Removed references are not cleaned up.
+	cleaned = nil
+	ctx = newSession("upload/3", "upload/4")
+	s.RemoveCleanupRef(ctx, "upload/3")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Active sessions are not cleaned up.
+	active := newSession("upload/5")
+
+	n, err := s.CleanupExpired(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || len(cleaned) != 0 {
+		t.Errorf("want nothing cleaned up; got %d, %v", n, cleaned)
+	}
+
+	// Expire the session by deleting it from the store directly.
+	if err := s.Store.Delete(token); err != nil {
+		t.Fatal(err)
+	}
+	n, err = s.CleanupExpired(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || !reflect.DeepEqual(cleaned, []string{"upload/4"}) {
+		t.Errorf("want upload/4 cleaned up; got %d, %v", n, cleaned)
+	}
+
+	// The references follow the session when the token is renewed.
+	if err := s.RenewToken(active); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Commit(active); err != nil {
+		t.Fatal(err)
+	}
+	cleaned = nil
+	if n, err = s.CleanupExpired(context.Background()); err != nil || n != 0 {
+		t.Errorf("want nothing cleaned up; got %d, %v", n, err)
+	}
+
+	// Sessions without references leave no cleanup records.
+	s.RemoveCleanupRef(active, "upload/5")
+	if _, _, err := s.Commit(active); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.doStoreAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) != 1 || keys[0] != s.Token(active) {
+		t.Errorf("want only the active session in the store; got %v", keys)
+	}
+}
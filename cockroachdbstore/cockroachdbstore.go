@@ -3,13 +3,31 @@ package cockroachdbstore
 import (
 	"database/sql"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultBatchSize is the default maximum number of expired sessions deleted
+// by each statement run by DeleteExpired.
+const defaultBatchSize = 1000
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
 // CockroachDBStore represents the session store.
 type CockroachDBStore struct {
 	db          *sql.DB
 	stopCleanup chan bool
+
+	// batchSize is the maximum number of expired sessions deleted by each
+	// statement run by DeleteExpired.
+	batchSize int32
+
+	stmts  map[stmtKey]*sql.Stmt
+	stmtMu sync.Mutex
 }
 
 // New returns a new CockroachDBStore instance, with a background cleanup goroutine
@@ -23,8 +41,9 @@ func New(db *sql.DB) *CockroachDBStore {
 // background cleanup goroutine. Setting it to 0 prevents the cleanup goroutine
 // from running (i.e. expired sessions will not be removed).
 func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *CockroachDBStore {
-	p := &CockroachDBStore{db: db}
+	p := &CockroachDBStore{db: db, batchSize: defaultBatchSize}
 	if cleanupInterval > 0 {
+		p.stopCleanup = make(chan bool)
 		go p.startCleanup(cleanupInterval)
 	}
 	return p
@@ -34,7 +53,7 @@ func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *Cockroac
 // If the session token is not found or is expired, the returned exists flag will
 // be set to false.
 func (p *CockroachDBStore) Find(token string) (b []byte, exists bool, err error) {
-	row := p.db.QueryRow("SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry", token)
+	row := p.queryRow(p.db, "SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry", token)
 	err = row.Scan(&b)
 	if err == sql.ErrNoRows {
 		return nil, false, nil
@@ -48,7 +67,7 @@ func (p *CockroachDBStore) Find(token string) (b []byte, exists bool, err error)
 // given expiry time. If the session token already exists, then the data and expiry
 // time are updated.
 func (p *CockroachDBStore) Commit(token string, b []byte, expiry time.Time) error {
-	_, err := p.db.Exec("INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry", token, b, expiry)
+	_, err := p.exec(p.db, "INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry", token, b, expiry)
 	if err != nil {
 		return err
 	}
@@ -58,7 +77,7 @@ func (p *CockroachDBStore) Commit(token string, b []byte, expiry time.Time) erro
 // Delete removes a session token and corresponding data from the CockroachDBStore
 // instance.
 func (p *CockroachDBStore) Delete(token string) error {
-	_, err := p.db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	_, err := p.exec(p.db, "DELETE FROM sessions WHERE token = $1", token)
 	return err
 }
 
@@ -96,12 +115,11 @@ func (p *CockroachDBStore) All() (map[string][]byte, error) {
 }
 
 func (p *CockroachDBStore) startCleanup(interval time.Duration) {
-	p.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := p.deleteExpired()
+			_, err := p.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -128,7 +146,103 @@ func (p *CockroachDBStore) StopCleanup() {
 	}
 }
 
-func (p *CockroachDBStore) deleteExpired() error {
-	_, err := p.db.Exec("DELETE FROM sessions WHERE expiry < current_timestamp")
+// Close stops the background cleanup goroutine, if it is running, and closes
+// the prepared statements used by the CockroachDBStore. It does not close the
+// underlying database connection pool. The CockroachDBStore can still be used after
+// Close, but statements will be prepared again.
+func (p *CockroachDBStore) Close() error {
+	p.StopCleanup()
+	p.stopCleanup = nil
+
+	p.stmtMu.Lock()
+	defer p.stmtMu.Unlock()
+
+	var err error
+	for _, stmt := range p.stmts {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	p.stmts = nil
 	return err
 }
+
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled. Expired sessions are deleted in batches (see
+// SetCleanupBatchSize), so that each statement only holds locks briefly.
+func (p *CockroachDBStore) DeleteExpired() (int, error) {
+	batchSize := atomic.LoadInt32(&p.batchSize)
+	if batchSize <= 0 {
+		res, err := p.db.Exec("DELETE FROM sessions WHERE expiry < current_timestamp")
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		return int(n), err
+	}
+
+	total := 0
+	for {
+		res, err := p.exec(p.db, "DELETE FROM sessions WHERE expiry < current_timestamp LIMIT $1", batchSize)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		total += int(n)
+		if err != nil || n < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// SetCleanupBatchSize sets the maximum number of expired sessions deleted by
+// each statement run by DeleteExpired. The default is 1000. Setting it to 0
+// deletes all expired sessions with a single statement, which can lock a large
+// sessions table for a long time.
+func (p *CockroachDBStore) SetCleanupBatchSize(n int) {
+	atomic.StoreInt32(&p.batchSize, int32(n))
+}
+
+// exec runs a statement on db using a cached prepared statement.
+func (p *CockroachDBStore) exec(db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := p.prepare(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// queryRow runs a query on db using a cached prepared statement. If the
+// statement can't be prepared, the query is run directly so that the error is
+// returned by Scan.
+func (p *CockroachDBStore) queryRow(db *sql.DB, query string, args ...interface{}) *sql.Row {
+	stmt, err := p.prepare(db, query)
+	if err != nil {
+		return db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// prepare returns the prepared statement for query on db, preparing it on
+// first use.
+func (p *CockroachDBStore) prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+
+	p.stmtMu.Lock()
+	defer p.stmtMu.Unlock()
+
+	if stmt, ok := p.stmts[key]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if p.stmts == nil {
+		p.stmts = make(map[stmtKey]*sql.Stmt)
+	}
+	p.stmts[key] = stmt
+	return stmt, nil
+}
@@ -3,11 +3,30 @@ package scs
 import (
 	"bytes"
 	"encoding/gob"
+	"sort"
 	"time"
 )
 
+func init() {
+	// These types are commonly stored in session data, and are registered so
+	// that they can be encoded by the gob codecs without the application
+	// having to register them first.
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
 // Codec is the interface for encoding/decoding session data to and from a byte
 // slice for use by the session store.
+//
+// The codecs in this package share a canonical representation for time.Time,
+// time.Duration and []byte values (including those nested in
+// map[string]interface{} and []interface{} values): times are stored as UTC
+// instants, and decoded as time.Time values in UTC with no monotonic clock
+// reading; durations are decoded as time.Duration; and byte slices are decoded
+// as []byte. So switching between the codecs doesn't change how these values
+// are returned by Get, GetTime and GetBytes.
 type Codec interface {
 	Encode(deadline time.Time, values map[string]interface{}) ([]byte, error)
 	Decode([]byte) (deadline time.Time, values map[string]interface{}, err error)
@@ -24,7 +43,7 @@ func (GobCodec) Encode(deadline time.Time, values map[string]interface{}) ([]byt
 		Values   map[string]interface{}
 	}{
 		Deadline: deadline,
-		Values:   values,
+		Values:   canonicalValues(values),
 	}
 
 	var b bytes.Buffer
@@ -49,3 +68,128 @@ func (GobCodec) Decode(b []byte) (time.Time, map[string]interface{}, error) {
 
 	return aux.Deadline, aux.Values, nil
 }
+
+// CanonicalCodec is used for encoding/decoding session data to and from a byte
+// slice using the encoding/gob package, like GobCodec, except that the encoded
+// form is canonical: the session values are written in sorted key order and
+// the deadline is normalized to UTC, so the same logical session data always
+// encodes to identical bytes. This is useful for stores and wrappers which
+// compare, deduplicate or diff the encoded data. Note that maps nested inside
+// session values are still encoded by encoding/gob in random order.
+//
+// CanonicalCodec can decode data encoded by GobCodec, so an existing
+// application can switch to it without losing sessions. The reverse is not
+// true.
+type CanonicalCodec struct{}
+
+// Encode converts a session deadline and values into a byte slice.
+func (CanonicalCodec) Encode(deadline time.Time, values map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]interface{}, len(keys))
+	for i, key := range keys {
+		items[i], _ = canonicalValue(values[key])
+	}
+
+	aux := &struct {
+		Deadline time.Time
+		Keys     []string
+		Items    []interface{}
+	}{
+		Deadline: deadline.UTC(),
+		Keys:     keys,
+		Items:    items,
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(&aux); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Decode converts a byte slice into a session deadline and values.
+func (CanonicalCodec) Decode(b []byte) (time.Time, map[string]interface{}, error) {
+	aux := &struct {
+		Deadline time.Time
+		Values   map[string]interface{}
+		Keys     []string
+		Items    []interface{}
+	}{}
+
+	r := bytes.NewReader(b)
+	if err := gob.NewDecoder(r).Decode(&aux); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if aux.Values == nil {
+		aux.Values = make(map[string]interface{}, len(aux.Keys))
+	}
+	for i, key := range aux.Keys {
+		if i < len(aux.Items) {
+			aux.Values[key] = aux.Items[i]
+		}
+	}
+
+	return aux.Deadline, aux.Values, nil
+}
+
+// canonicalValues returns values with any time.Time values converted to their
+// canonical form. The map is only copied if a value is changed.
+func canonicalValues(values map[string]interface{}) map[string]interface{} {
+	v, _ := canonicalValue(values)
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// canonicalValue converts time.Time values to UTC, without a monotonic clock
+// reading, recursing into map[string]interface{} and []interface{} values. It
+// reports whether the value was changed; maps and slices are copied rather
+// than modified.
+func canonicalValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case map[string]interface{}:
+		var copied map[string]interface{}
+		for key, val := range v {
+			cv, changed := canonicalValue(val)
+			if !changed {
+				continue
+			}
+			if copied == nil {
+				copied = make(map[string]interface{}, len(v))
+				for k, val := range v {
+					copied[k] = val
+				}
+			}
+			copied[key] = cv
+		}
+		if copied == nil {
+			return v, false
+		}
+		return copied, true
+	case []interface{}:
+		var copied []interface{}
+		for i, val := range v {
+			cv, changed := canonicalValue(val)
+			if !changed {
+				continue
+			}
+			if copied == nil {
+				copied = append([]interface{}(nil), v...)
+			}
+			copied[i] = cv
+		}
+		if copied == nil {
+			return v, false
+		}
+		return copied, true
+	}
+	return v, false
+}
@@ -0,0 +1,197 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCanonicalCodec(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	values := map[string]interface{}{
+		"a": "foo",
+		"b": 123,
+		"c": true,
+		"d": []byte("bar"),
+		"e": 1.5,
+		"f": "baz",
+		"g": int64(-7),
+	}
+
+	var codec CanonicalCodec
+	want, err := codec.Encode(deadline, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		copied := make(map[string]interface{})
+		for k, v := range values {
+			copied[k] = v
+		}
+		b, err := codec.Encode(deadline.In(time.FixedZone("EST", -5*3600)), copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Fatalf("want identical encoding on attempt %d", i)
+		}
+	}
+
+	gotDeadline, gotValues, err := codec.Decode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotDeadline.Equal(deadline) {
+		t.Errorf("want %v; got %v", deadline, gotDeadline)
+	}
+	if !reflect.DeepEqual(gotValues, values) {
+		t.Errorf("want %v; got %v", values, gotValues)
+	}
+
+	// Data encoded by GobCodec can be decoded.
+	b, err := GobCodec{}.Encode(deadline, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gotValues, err = codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValues, values) {
+		t.Errorf("want %v; got %v", values, gotValues)
+	}
+
+	// Empty session data is decoded to an empty map.
+	b, err = codec.Encode(deadline, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gotValues, err = codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotValues == nil || len(gotValues) != 0 {
+		t.Errorf("want empty map; got %#v", gotValues)
+	}
+}
+
+func TestCodecsCanonicalValues(t *testing.T) {
+	t.Parallel()
+
+	est := time.FixedZone("EST", -5*3600)
+	now := time.Now().In(est)
+	deadline := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	values := map[string]interface{}{
+		"time":     now,
+		"duration": 1500 * time.Millisecond,
+		"bytes":    []byte("bar"),
+		"nested": map[string]interface{}{
+			"time":  now,
+			"times": []interface{}{now},
+		},
+	}
+	want := map[string]interface{}{
+		"time":     now.UTC(),
+		"duration": 1500 * time.Millisecond,
+		"bytes":    []byte("bar"),
+		"nested": map[string]interface{}{
+			"time":  now.UTC(),
+			"times": []interface{}{now.UTC()},
+		},
+	}
+
+	codecs := map[string]Codec{
+		"gob":       GobCodec{},
+		"canonical": CanonicalCodec{},
+		"json":      JSONCodec{},
+	}
+	for name, codec := range codecs {
+		b, err := codec.Encode(deadline, values)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, got, err := codec.Decode(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v: expected %#v", name, got, want)
+		}
+	}
+
+	if _, ok := values["time"].(time.Time); !ok || values["time"].(time.Time).Location() != est {
+		t.Error("want values to be unmodified by Encode")
+	}
+}
+
+func TestFallbackCodec(t *testing.T) {
+	t.Parallel()
+
+	old := New()
+	ctx, err := old.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Put(ctx, "foo", "bar")
+	token, _, err := old.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New()
+	s.Store = old.Store
+	s.Codec = JSONCodec{}
+	s.FallbackCodec = GobCodec{}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetString(ctx, "foo"); got != "bar" {
+		t.Errorf("got %q: expected %q", got, "bar")
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("got %v: expected %v", s.Status(ctx), Modified)
+	}
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := s.Store.Find(token)
+	if err != nil || !found {
+		t.Fatalf("got %v, %v: expected session in store", found, err)
+	}
+	if _, values, err := (JSONCodec{}).Decode(b); err != nil || values["foo"] != "bar" {
+		t.Errorf("got %v, %v: expected session re-encoded with the Codec", values, err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status(ctx) != Unmodified {
+		t.Errorf("got %v: expected %v", s.Status(ctx), Unmodified)
+	}
+}
+
+func TestFallbackCodecBothFail(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Codec = JSONCodec{}
+	s.FallbackCodec = GobCodec{}
+
+	_, _, err := s.decode([]byte("garbage"))
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if _, _, jerr := (JSONCodec{}).Decode([]byte("garbage")); err.Error() != jerr.Error() {
+		t.Errorf("got %v: expected the Codec's error %v", err, jerr)
+	}
+}
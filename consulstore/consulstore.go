@@ -36,6 +36,7 @@ func NewWithOptions(client *api.Client, cleanupInterval time.Duration, prefix st
 	}
 
 	if cleanupInterval > 0 {
+		c.stopCleanup = make(chan bool)
 		go c.startCleanup(cleanupInterval)
 	}
 
@@ -102,12 +103,11 @@ func (c *ConsulStore) All() (map[string][]byte, error) {
 }
 
 func (c *ConsulStore) startCleanup(cleanupInterval time.Duration) {
-	c.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(cleanupInterval)
 	for {
 		select {
 		case <-ticker.C:
-			err := c.deleteExpired()
+			_, err := c.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -134,17 +134,25 @@ func (c *ConsulStore) StopCleanup() {
 	}
 }
 
-func (c *ConsulStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (c *ConsulStore) DeleteExpired() (int, error) {
 	pairs, _, err := c.kv.List(c.prefix, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	n := 0
 	for _, pair := range pairs {
 		if uint64(time.Now().UnixNano()) > binary.BigEndian.Uint64(pair.Value[:8]) {
-			c.kv.Delete(pair.Key, nil)
+			if _, err := c.kv.Delete(pair.Key, nil); err != nil {
+				return n, err
+			}
+			n++
 		}
 	}
 
-	return nil
+	return n, nil
 }
@@ -0,0 +1,151 @@
+package scs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidCookie is returned by SessionCookie.Validate, and passed to the
+// ErrorFunc by the LoadAndSave middleware, when the cookie settings would
+// produce a cookie that browsers reject or silently alter.
+var ErrInvalidCookie = errors.New("scs: invalid session cookie settings")
+
+// Validate checks the cookie settings for mistakes which would cause browsers
+// to reject the cookie, or Go's net/http package to silently drop attributes
+// from it, and returns an error wrapping ErrInvalidCookie describing the first
+// problem found. It reports:
+//
+//   - A missing or invalid cookie Name.
+//   - A Domain or DomainFunc which is set when HostOnly is true.
+//   - A Domain which isn't a valid domain name (including one with a port),
+//     or which is a top-level domain, or which is a public suffix according
+//     to the PublicSuffix function.
+//   - A Name with the "__Host-" or "__Secure-" prefix whose other settings
+//     don't meet the prefix's requirements.
+//   - A SameSite value of http.SameSiteNoneMode without Secure.
+//
+// A leading dot in Domain is allowed, and is ignored as it is by browsers.
+// Validate is called by the LoadAndSave middleware on every request, but can
+// also be called when the application starts to detect problems early. The
+// LoadAndSave middleware also checks the Domain returned by DomainFunc for the
+// request in the same way.
+func (c SessionCookie) Validate() error {
+	if c.Name == "" || strings.IndexFunc(c.Name, func(r rune) bool { return !isTokenRune(r) }) >= 0 {
+		return fmt.Errorf("%w: invalid cookie name %q", ErrInvalidCookie, c.Name)
+	}
+
+	if c.DomainFunc != nil && c.HostOnly {
+		return fmt.Errorf("%w: DomainFunc is set for a HostOnly cookie", ErrInvalidCookie)
+	}
+	if c.Domain != "" {
+		if c.HostOnly {
+			return fmt.Errorf("%w: Domain %q is set for a HostOnly cookie", ErrInvalidCookie, c.Domain)
+		}
+		if err := c.validateDomain(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(c.Name, "__Host-"):
+		if !c.Secure || c.Path != "/" || c.Domain != "" {
+			return fmt.Errorf("%w: a cookie named %q must be Secure, have the Path \"/\" and no Domain", ErrInvalidCookie, c.Name)
+		}
+	case strings.HasPrefix(c.Name, "__Secure-"):
+		if !c.Secure {
+			return fmt.Errorf("%w: a cookie named %q must be Secure", ErrInvalidCookie, c.Name)
+		}
+	}
+
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		return fmt.Errorf("%w: a cookie with SameSite=None must be Secure", ErrInvalidCookie)
+	}
+
+	return nil
+}
+
+func (c SessionCookie) validateDomain() error {
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+
+	if net.ParseIP(domain) != nil {
+		return nil
+	}
+
+	if strings.Contains(domain, ":") {
+		return fmt.Errorf("%w: Domain %q must not include a port", ErrInvalidCookie, c.Domain)
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 {
+		return fmt.Errorf("%w: Domain %q is too long", ErrInvalidCookie, c.Domain)
+	}
+	for _, label := range labels {
+		if !validDomainLabel(label) {
+			return fmt.Errorf("%w: Domain %q is not a valid domain name", ErrInvalidCookie, c.Domain)
+		}
+	}
+
+	if len(labels) == 1 {
+		return fmt.Errorf("%w: Domain %q is a top-level domain", ErrInvalidCookie, c.Domain)
+	}
+	if c.PublicSuffix != nil {
+		if suffix, _ := c.PublicSuffix(domain); suffix == domain {
+			return fmt.Errorf("%w: Domain %q is a public suffix", ErrInvalidCookie, c.Domain)
+		}
+	}
+
+	return nil
+}
+
+// domain returns the value for the Domain attribute of the cookie written for
+// the request r, which is nil if the cookie isn't written for a request.
+func (c SessionCookie) domain(r *http.Request) string {
+	if c.HostOnly {
+		return ""
+	}
+	if c.DomainFunc != nil && r != nil {
+		return c.DomainFunc(r)
+	}
+	return c.Domain
+}
+
+// validateRequest checks the cookie settings with Validate, and then checks
+// the Domain returned by DomainFunc for the request r.
+func (c SessionCookie) validateRequest(r *http.Request) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.DomainFunc == nil {
+		return nil
+	}
+
+	resolved := c
+	resolved.Domain = c.domain(r)
+	resolved.DomainFunc = nil
+	return resolved.Validate()
+}
+
+func validDomainLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenRune reports whether r may appear in a cookie name, which must be an
+// RFC 7230 token.
+func isTokenRune(r rune) bool {
+	if r <= ' ' || r >= 0x7f {
+		return false
+	}
+	return !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+}
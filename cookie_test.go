@@ -0,0 +1,138 @@
+package scs
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCookieValidate(t *testing.T) {
+	t.Parallel()
+
+	publicSuffix := func(domain string) (string, bool) {
+		if strings.HasSuffix(domain, "co.uk") {
+			return "co.uk", true
+		}
+		return domain[strings.LastIndex(domain, ".")+1:], true
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *SessionCookie)
+		wantErr string
+	}{
+		{"default", func(c *SessionCookie) {}, ""},
+		{"domain", func(c *SessionCookie) { c.Domain = "example.com" }, ""},
+		{"leading dot", func(c *SessionCookie) { c.Domain = ".Example.COM" }, ""},
+		{"subdomain of public suffix", func(c *SessionCookie) { c.Domain = "example.co.uk" }, ""},
+		{"ip address", func(c *SessionCookie) { c.Domain = "192.0.2.1" }, ""},
+		{"host only", func(c *SessionCookie) { c.HostOnly = true }, ""},
+		{"host prefix", func(c *SessionCookie) { c.Name = "__Host-session"; c.Secure = true }, ""},
+		{"empty name", func(c *SessionCookie) { c.Name = "" }, "invalid cookie name"},
+		{"invalid name", func(c *SessionCookie) { c.Name = "my session" }, "invalid cookie name"},
+		{"host only with domain", func(c *SessionCookie) { c.HostOnly = true; c.Domain = "example.com" }, "HostOnly"},
+		{"host only with domain func", func(c *SessionCookie) { c.HostOnly = true; c.DomainFunc = func(*http.Request) string { return "" } }, "HostOnly"},
+		{"port", func(c *SessionCookie) { c.Domain = "example.com:8080" }, "port"},
+		{"invalid domain", func(c *SessionCookie) { c.Domain = "exa mple.com" }, "not a valid domain"},
+		{"empty label", func(c *SessionCookie) { c.Domain = "example..com" }, "not a valid domain"},
+		{"top-level domain", func(c *SessionCookie) { c.Domain = "com" }, "top-level domain"},
+		{"public suffix", func(c *SessionCookie) { c.Domain = ".co.uk" }, "public suffix"},
+		{"host prefix without secure", func(c *SessionCookie) { c.Name = "__Host-session" }, "must be Secure"},
+		{"host prefix with domain", func(c *SessionCookie) { c.Name = "__Host-session"; c.Secure = true; c.Domain = "example.com" }, "no Domain"},
+		{"secure prefix", func(c *SessionCookie) { c.Name = "__Secure-session" }, "must be Secure"},
+		{"samesite none", func(c *SessionCookie) { c.SameSite = http.SameSiteNoneMode }, "SameSite=None"},
+	}
+
+	for _, tt := range tests {
+		c := New().Cookie
+		c.PublicSuffix = publicSuffix
+		tt.modify(&c)
+
+		err := c.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: want no error; got %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidCookie) || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: want error containing %q; got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestHostOnlyCookie(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.Cookie.HostOnly = true
+
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if cookie := rr.Header().Get("Set-Cookie"); cookie == "" || strings.Contains(cookie, "Domain") {
+		t.Errorf("want host-only cookie; got %q", cookie)
+	}
+
+	sessionManager.Cookie.Domain = "example.com"
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("want %d; got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if cookie := rr.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("want no cookie; got %q", cookie)
+	}
+}
+
+func TestDomainFunc(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.Cookie.DomainFunc = func(r *http.Request) string {
+		if r.Host == "localhost" {
+			return ""
+		}
+		return r.Host
+	}
+
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	}))
+
+	tests := []struct {
+		host string
+		want string
+		code int
+	}{
+		{"tenant.example.com", "Domain=tenant.example.com", http.StatusOK},
+		{"shop.example.org", "Domain=shop.example.org", http.StatusOK},
+		{"localhost", "", http.StatusOK},
+		{"example.com:8080", "", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "http://"+tt.host+"/", nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: want %d; got %d", tt.host, tt.code, rr.Code)
+			continue
+		}
+		cookie := rr.Header().Get("Set-Cookie")
+		if tt.code != http.StatusOK {
+			if cookie != "" {
+				t.Errorf("%s: want no cookie; got %q", tt.host, cookie)
+			}
+			continue
+		}
+		if tt.want == "" && (cookie == "" || strings.Contains(cookie, "Domain")) {
+			t.Errorf("%s: want host-only cookie; got %q", tt.host, cookie)
+		} else if tt.want != "" && !strings.Contains(cookie, tt.want) {
+			t.Errorf("%s: want cookie containing %q; got %q", tt.host, tt.want, cookie)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package scs
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CSRFMode selects how a CSRF middleware verifies that unsafe requests (those
+// with a method other than GET, HEAD, OPTIONS or TRACE) were made by your own
+// pages.
+type CSRFMode int
+
+const (
+	// CSRFSynchronizerToken stores a random token in the session data, and
+	// requires unsafe requests to include it in a header or form field. This
+	// is the strongest mode, and suits server-rendered forms.
+	CSRFSynchronizerToken CSRFMode = iota
+
+	// CSRFDoubleSubmit sends a random token in a separate cookie, and requires
+	// unsafe requests to include the same value in a header or form field.
+	// It suits single-page applications which read the cookie with
+	// JavaScript, and doesn't require the session to be committed.
+	CSRFDoubleSubmit
+
+	// CSRFOriginCheck requires unsafe requests to have an Origin header (or,
+	// if that is missing, a Referer header) matching the request host or one
+	// of the trusted origins. No token is needed.
+	CSRFOriginCheck
+)
+
+// ErrCSRFMismatch describes why a request was rejected by the CSRF middleware.
+// It is available to the FailureHandler via CSRFFailureReason.
+var ErrCSRFMismatch = errors.New("scs: CSRF token missing or incorrect")
+
+// ErrCSRFOrigin is the CSRFFailureReason when the Origin or Referer header is
+// missing or not trusted.
+var ErrCSRFOrigin = errors.New("scs: CSRF origin missing or not trusted")
+
+const csrfTokenKey = "__csrfToken"
+
+type csrfContextKey struct{}
+
+type csrfContext struct {
+	token  string
+	reason error
+}
+
+// CSRF provides middleware which protects against cross-site request forgery.
+// Different route groups can use different CSRF values with different modes.
+type CSRF struct {
+	// SessionManager is the session manager used to store the token in
+	// CSRFSynchronizerToken mode.
+	SessionManager *SessionManager
+
+	// Mode selects the verification strategy.
+	Mode CSRFMode
+
+	// HeaderName is the request header checked for the token. The default is
+	// "X-CSRF-Token".
+	HeaderName string
+
+	// FieldName is the form field checked for the token if the header is not
+	// present. The default is "csrf_token".
+	FieldName string
+
+	// Cookie contains the configuration settings for the token cookie in
+	// CSRFDoubleSubmit mode. The default name is "csrf", and HttpOnly is
+	// false so the cookie can be read by JavaScript. Secure defaults to the
+	// session cookie setting, and follows changes made with
+	// SessionManager.SetCookieSecure.
+	Cookie SessionCookie
+
+	// TrustedOrigins lists additional origins (such as
+	// "https://app.example.com") accepted in CSRFOriginCheck mode.
+	TrustedOrigins []string
+
+	// FailureHandler is called when a request fails verification. The reason
+	// is available via CSRFFailureReason. The default behavior is to send a
+	// HTTP 403 "Forbidden" response.
+	FailureHandler http.Handler
+}
+
+// NewCSRF returns a new CSRF for the session manager s with the given mode and
+// the default settings.
+func NewCSRF(s *SessionManager, mode CSRFMode) *CSRF {
+	return &CSRF{
+		SessionManager: s,
+		Mode:           mode,
+		HeaderName:     "X-CSRF-Token",
+		FieldName:      "csrf_token",
+		Cookie: SessionCookie{
+			Name:     "csrf",
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+			Secure:   s.cookie().Secure,
+		},
+	}
+}
+
+// CSRFToken returns the CSRF token for the current request, for embedding in
+// forms or returning to JavaScript clients. It returns the empty string "" if
+// the request hasn't passed through a CSRF middleware, or the middleware is
+// using CSRFOriginCheck mode.
+func CSRFToken(ctx context.Context) string {
+	cc, _ := ctx.Value(csrfContextKey{}).(*csrfContext)
+	if cc == nil {
+		return ""
+	}
+	return cc.token
+}
+
+// CSRFFailureReason returns the reason a request was rejected, for use in a
+// CSRF.FailureHandler.
+func CSRFFailureReason(ctx context.Context) error {
+	cc, _ := ctx.Value(csrfContextKey{}).(*csrfContext)
+	if cc == nil {
+		return nil
+	}
+	return cc.reason
+}
+
+// Handler returns the CSRF middleware. In CSRFSynchronizerToken mode it must be
+// used inside the LoadAndSave() middleware.
+func (c *CSRF) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cc := &csrfContext{}
+		r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, cc))
+
+		var err error
+		switch c.Mode {
+		case CSRFSynchronizerToken:
+			cc.token, err = c.sessionToken(r.Context())
+		case CSRFDoubleSubmit:
+			cc.token, err = c.cookieToken(w, r)
+		}
+		if err != nil {
+			c.SessionManager.ErrorFunc(w, r, err)
+			return
+		}
+
+		if !csrfSafeMethod(r.Method) {
+			if c.Mode == CSRFOriginCheck {
+				if !c.trustedOrigin(r) {
+					cc.reason = ErrCSRFOrigin
+				}
+			} else if !c.tokenMatches(r, cc.token) {
+				cc.reason = ErrCSRFMismatch
+			}
+
+			if cc.reason != nil {
+				if c.FailureHandler != nil {
+					c.FailureHandler.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CSRF) sessionToken(ctx context.Context) (string, error) {
+	s := c.SessionManager
+	if token := s.GetString(ctx, csrfTokenKey); token != "" {
+		return token, nil
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	s.Put(ctx, csrfTokenKey, token)
+	return token, nil
+}
+
+func (c *CSRF) cookieToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(c.Cookie.Name); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	cookie := &http.Cookie{
+		Name:     c.Cookie.Name,
+		Value:    token,
+		Path:     c.Cookie.Path,
+		Domain:   c.Cookie.domain(r),
+		Secure:   c.SessionManager.companionSecure(c.Cookie.Secure),
+		HttpOnly: c.Cookie.HttpOnly,
+		SameSite: c.Cookie.SameSite,
+	}
+	if c.Cookie.Persist {
+		expiry := time.Now().Add(c.SessionManager.lifetime())
+		cookie.Expires = time.Unix(expiry.Unix()+1, 0)
+		cookie.MaxAge = int(c.SessionManager.lifetime().Seconds())
+	}
+	w.Header().Add("Set-Cookie", c.SessionManager.cookieString(cookie))
+
+	// A newly issued cookie can't have been submitted with this request, so
+	// verification of an unsafe request will fail, as it should.
+	return token, nil
+}
+
+func (c *CSRF) tokenMatches(r *http.Request, want string) bool {
+	if want == "" {
+		return false
+	}
+
+	got := r.Header.Get(c.HeaderName)
+	if got == "" {
+		got = r.PostFormValue(c.FieldName)
+	}
+
+	if c.Mode == CSRFDoubleSubmit {
+		// The cookie must have been sent with the request, not just issued.
+		cookie, err := r.Cookie(c.Cookie.Name)
+		if err != nil || cookie.Value != want {
+			return false
+		}
+	}
+
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func (c *CSRF) trustedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer, err := url.Parse(r.Header.Get("Referer"))
+		if err != nil || referer.Host == "" {
+			return false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, trusted := range c.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(trusted, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
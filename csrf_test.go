@@ -0,0 +1,159 @@
+package scs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFSynchronizerToken(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	csrf := NewCSRF(sessionManager, CSRFSynchronizerToken)
+
+	h := sessionManager.LoadAndSave(csrf.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r.Context())))
+	})))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	token := rr.Body.String()
+	cookie := strings.SplitN(rr.Header().Get("Set-Cookie"), ";", 2)[0]
+	if token == "" {
+		t.Fatal("want CSRF token")
+	}
+
+	post := func(field, header string) int {
+		form := url.Values{"csrf_token": {field}}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Cookie", cookie)
+		if header != "" {
+			r.Header.Set("X-CSRF-Token", header)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	if code := post(token, ""); code != http.StatusOK {
+		t.Errorf("form field: want %d; got %d", http.StatusOK, code)
+	}
+	if code := post("", token); code != http.StatusOK {
+		t.Errorf("header: want %d; got %d", http.StatusOK, code)
+	}
+	if code := post("wrong", ""); code != http.StatusForbidden {
+		t.Errorf("wrong token: want %d; got %d", http.StatusForbidden, code)
+	}
+	if code := post("", ""); code != http.StatusForbidden {
+		t.Errorf("missing token: want %d; got %d", http.StatusForbidden, code)
+	}
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	csrf := NewCSRF(sessionManager, CSRFDoubleSubmit)
+
+	var reason error
+	csrf.FailureHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reason = CSRFFailureReason(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	h := csrf.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf" || cookies[0].HttpOnly {
+		t.Fatalf("want readable csrf cookie; got %v", cookies)
+	}
+	token := cookies[0].Value
+
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Cookie", "csrf="+token)
+	r.Header.Set("X-CSRF-Token", token)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+
+	// Without the cookie, a header value alone is not enough.
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-CSRF-Token", token)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusTeapot || reason != ErrCSRFMismatch {
+		t.Errorf("want %d and %v; got %d and %v", http.StatusTeapot, ErrCSRFMismatch, rr.Code, reason)
+	}
+}
+
+func TestCSRFOriginCheck(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	csrf := NewCSRF(sessionManager, CSRFOriginCheck)
+	csrf.TrustedOrigins = []string{"https://app.example.com"}
+
+	h := csrf.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		origin  string
+		referer string
+		want    int
+	}{
+		{"https://example.com", "", http.StatusOK},
+		{"https://app.example.com", "", http.StatusOK},
+		{"https://evil.example.net", "", http.StatusForbidden},
+		{"", "https://example.com/form", http.StatusOK},
+		{"", "https://evil.example.net/form", http.StatusForbidden},
+		{"", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "https://example.com/", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.referer != "" {
+			r.Header.Set("Referer", tt.referer)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if rr.Code != tt.want {
+			t.Errorf("origin %q referer %q: want %d; got %d", tt.origin, tt.referer, tt.want, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("safe method: want %d; got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestCSRFCookieSecureReload(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	csrf := NewCSRF(sessionManager, CSRFDoubleSubmit)
+	h := csrf.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The cookie follows changes to the session cookie's Secure setting.
+	for _, secure := range []bool{true, false} {
+		sessionManager.SetCookieSecure(secure)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Secure != secure {
+			t.Errorf("want csrf cookie with Secure %v; got %v", secure, cookies)
+		}
+	}
+}
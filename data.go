@@ -5,8 +5,13 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,12 +34,96 @@ const (
 	Destroyed
 )
 
+// DecodeErrorPolicy controls what the Load method does when the session data
+// in the store can't be decoded by the codec.
+type DecodeErrorPolicy int
+
+const (
+	// DiscardOnDecodeError deletes the undecodable session data from the store
+	// and starts a new, empty session. This is the default.
+	DiscardOnDecodeError DecodeErrorPolicy = iota
+
+	// FailOnDecodeError returns the decode error from Load (so the LoadAndSave
+	// middleware passes it to ErrorFunc).
+	FailOnDecodeError
+
+	// RecoverOnDecodeError calls the SessionManager.RecoverFunc function with
+	// the raw session data, and uses the deadline and values it returns.
+	RecoverOnDecodeError
+)
+
+// ErrReadOnly is returned by methods which would change the session data when
+// the SessionManager.ReadOnly setting is true.
+var ErrReadOnly = errors.New("scs: session is read-only")
+
+// ErrNoSessionManager indicates that a SessionManager method was called with a
+// context.Context which doesn't contain session data for that session manager.
+// This usually means that the handler isn't wrapped with the LoadAndSave()
+// middleware (or the Load method wasn't called), or that the request context
+// was replaced with one not derived from it. By default the methods panic with
+// this error; see the SessionManager.TolerateMissingSession setting.
+var ErrNoSessionManager = errors.New("scs: no session data in context (is the handler wrapped with the SessionManager's LoadAndSave() middleware?)")
+
+// ErrUnknownToken is passed to the SessionManager.ErrorFunc function when the
+// StrictTokens setting is true and a request presents a session token which
+// doesn't match a session in the store.
+var ErrUnknownToken = errors.New("scs: unknown session token")
+
+// ErrHeadersWritten is passed to the SessionManager.LateWriteFunc hook when
+// session data which was modified after the response headers were written
+// could not be committed.
+var ErrHeadersWritten = errors.New("scs: session modified after response headers were written")
+
+// ErrKeyNotFound is returned by Scan when the key does not exist in the session
+// data. The returned error wraps ErrKeyNotFound and includes the key, so it
+// should be checked for using errors.Is.
+var ErrKeyNotFound = errors.New("scs: key not found")
+
+// ErrTypeAssertionFailed is returned by Scan when the stored value cannot be
+// assigned to the destination. The returned error wraps ErrTypeAssertionFailed
+// and includes the key and the type of the stored value, so it should be
+// checked for using errors.Is.
+var ErrTypeAssertionFailed = errors.New("scs: type assertion failed")
+
+const (
+	lastActivityKey = "__lastActivity"
+	tokenIssuedKey  = "__tokenIssued"
+)
+
 type sessionData struct {
 	deadline time.Time
 	status   Status
 	token    string
 	values   map[string]interface{}
+	touched  bool
+	noExtend bool
+	stats    loadStats
 	mu       sync.Mutex
+
+	activityRecorded bool
+
+	// degraded is true if the session couldn't be loaded because the store
+	// timed out, so it must not be committed by the LoadAndSave middleware.
+	degraded bool
+
+	// locks holds the locks acquired by Lock for the current request.
+	locks map[string]heldLock
+
+	// waitForSave is true if WaitForSave has been called for the current
+	// request.
+	waitForSave bool
+
+	// partial holds the keys loaded if only some of the session data has
+	// been loaded from a KeysStore, and loadErr holds any error from loading
+	// the rest of the session data.
+	partial map[string]bool
+	loadErr error
+
+	// fields holds the encoded values last loaded from or committed to a
+	// PartialStore for the token fieldsToken, so that only the values which
+	// have changed need to be committed.
+	fields      map[string][]byte
+	fieldsToken string
 }
 
 func newSessionData(lifetime time.Duration) *sessionData {
@@ -52,37 +141,123 @@ func newSessionData(lifetime time.Duration) *sessionData {
 // Most applications will use the LoadAndSave() middleware and will not need to
 // use this method.
 func (s *SessionManager) Load(ctx context.Context, token string) (context.Context, error) {
+	return s.load(ctx, token, s.HotKeys)
+}
+
+// load loads the session data for token as described by Load. If keys is not
+// nil and the store implements KeysStore, only the values for keys (and the
+// reserved keys needed to check the session) are loaded, and the rest of the
+// session data is loaded when it is first used.
+func (s *SessionManager) load(ctx context.Context, token string, keys []string) (context.Context, error) {
 	if _, ok := ctx.Value(s.contextKey).(*sessionData); ok {
 		return ctx, nil
 	}
 
 	if token == "" {
-		return s.addSessionDataToContext(ctx, newSessionData(s.Lifetime)), nil
+		return s.addSessionDataToContext(ctx, newSessionData(s.lifetime())), nil
 	}
 
-	b, found, err := s.doStoreFind(ctx, token)
-	if err != nil {
+	// Tokens which the TokenFilter shows aren't in the store are treated in
+	// the same way as tokens which aren't found.
+	if s.unknownToken(token) {
+		return s.addSessionDataToContext(ctx, newSessionData(s.lifetime())), nil
+	}
+
+	start := time.Now()
+	cacheHit := s.storeCached(token)
+
+	keys = s.partialLoadKeys(keys)
+	b, fields, found, err := s.findSessionKeys(ctx, token, keys)
+	if errors.Is(err, ErrStoreTimeout) && s.OnStoreTimeout == DegradeOnStoreTimeout {
+		sd := newSessionData(s.lifetime())
+		sd.degraded = true
+		return s.addSessionDataToContext(ctx, sd), nil
+	} else if err != nil {
 		return nil, err
 	} else if !found {
-		return s.addSessionDataToContext(ctx, newSessionData(s.Lifetime)), nil
+		sd := newSessionData(s.lifetime())
+		sd.stats = loadStats{duration: time.Since(start)}
+		return s.addSessionDataToContext(ctx, sd), nil
 	}
 
 	sd := &sessionData{
 		status: Unmodified,
 		token:  token,
 	}
-	if sd.deadline, sd.values, err = s.Codec.Decode(b); err != nil {
-		return nil, err
+	size := len(b)
+	if fields != nil {
+		sd.fields, sd.fieldsToken = fields, token
+		if keys != nil {
+			sd.partial = make(map[string]bool, len(keys))
+			for _, key := range keys {
+				sd.partial[key] = true
+			}
+		}
+		for _, fb := range fields {
+			size += len(fb)
+		}
+	}
+	var fallback bool
+	if sd.deadline, sd.values, fallback, err = s.decodeSession(b, fields); err != nil {
+		switch s.OnDecodeError {
+		case FailOnDecodeError:
+			return nil, err
+		case RecoverOnDecodeError:
+			if s.RecoverFunc == nil {
+				return nil, err
+			}
+			if sd.deadline, sd.values, err = s.RecoverFunc(ctx, b, err); err != nil {
+				return nil, err
+			}
+			sd.deadline = sd.deadline.UTC()
+			if sd.values == nil {
+				sd.values = make(map[string]interface{})
+			}
+		default:
+			if err := s.doStoreDelete(ctx, token); err != nil {
+				return nil, err
+			}
+			return s.addSessionDataToContext(ctx, newSessionData(s.lifetime())), nil
+		}
 	}
 
-	// Mark the session data as modified if an idle timeout is being used. This
-	// will force the session data to be re-committed to the session store with
-	// a new expiry time.
-	if s.IdleTimeout > 0 {
+	if isTombstone(sd.values) {
+		return nil, ErrSessionRevoked
+	}
+
+	// Don't trust the store to have expired the session on time (its clock
+	// may differ from ours), but only check the absolute deadline. The idle
+	// timeout is enforced by the store expiry alone.
+	if !time.Now().Before(sd.deadline) {
+		return s.addSessionDataToContext(ctx, newSessionData(s.lifetime())), nil
+	}
+
+	// Session data decoded by the FallbackCodec is marked as modified, so that
+	// it is re-encoded with the Codec when the session is committed.
+	if fallback && !s.ReadOnly {
 		sd.status = Modified
 	}
 
-	return s.addSessionDataToContext(ctx, sd), nil
+	expired := s.expireKeys(sd)
+	impersonation, impersonationExpired := s.expireImpersonation(sd)
+	s.lockIdleSession(sd)
+
+	// Mark the session data as touched if an idle timeout is being used. This
+	// will cause the session status to be reported as Modified (unless the
+	// request has been marked as not extending the session), forcing the
+	// session data to be re-committed to the session store with a new expiry
+	// time.
+	if s.idleTimeout() > 0 && !s.ReadOnly {
+		sd.touched = true
+	}
+
+	sd.stats = loadStats{found: true, cacheHit: cacheHit, size: size, duration: time.Since(start)}
+	ctx = s.addSessionDataToContext(ctx, sd)
+	s.notifyExpiredKeys(ctx, expired)
+	if impersonationExpired {
+		s.auditExpiredImpersonation(ctx, impersonation)
+	}
+	return ctx, nil
 }
 
 // Commit saves the session data to the session store and returns the session
@@ -91,74 +266,289 @@ func (s *SessionManager) Load(ctx context.Context, token string) (context.Contex
 // Most applications will use the LoadAndSave() middleware and will not need to
 // use this method.
 func (s *SessionManager) Commit(ctx context.Context) (string, time.Time, error) {
-	sd := s.getSessionDataFromContext(ctx)
+	if err := s.checkSession(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if s.ReadOnly {
+		return "", time.Time{}, ErrReadOnly
+	}
+
+	// Session data which was only partially loaded can be committed as it is,
+	// as only the values which were loaded (and have changed) are written,
+	// unless the size of the whole session is needed.
+	sd := s.getPartialSessionData(ctx)
+
+	// The large session hook is deferred first so that it runs after the
+	// session data is unlocked, allowing it to use the session.
+	var large *LargeSession
+	defer func() { s.reportLargeSession(ctx, large) }()
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	if sd.token == "" {
+	if sd.partial != nil && (s.UserQuota > 0 || s.LargeSessionFunc != nil) {
+		s.completeLoad(ctx, sd)
+	}
+	if sd.loadErr != nil {
+		return "", time.Time{}, sd.loadErr
+	}
+
+	if err := s.prepareCommit(sd); err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.checkUserQuota(ctx, sd); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiry, ls, fields, err := s.commitValues(ctx, sd.token, sd.deadline, sd.values, sd.baseFields())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	large = ls
+	sd.fields, sd.fieldsToken = fields, sd.token
+
+	return sd.token, expiry, nil
+}
+
+// prepareCommit generates a token for a new session and records the activity
+// time, ready for the session data to be committed. It must be called with
+// sd.mu held.
+func (s *SessionManager) prepareCommit(sd *sessionData) error {
+	created := sd.token == ""
+	if created {
 		var err error
 		if sd.token, err = generateToken(); err != nil {
-			return "", time.Time{}, err
+			return err
+		}
+		if s.RotateEvery > 0 {
+			sd.values[tokenIssuedKey] = time.Now().UnixNano()
 		}
 	}
 
-	b, err := s.Codec.Encode(sd.deadline, sd.values)
-	if err != nil {
-		return "", time.Time{}, err
+	// The activity time is recorded for new sessions, so that the idle expiry
+	// time is kept when a request marked with NoExtend modifies the session.
+	// It is only added to existing sessions if RecordActivity or
+	// SoftIdleTimeout is enabled.
+	if s.idleTimeout() > 0 && (created || !sd.noExtend) {
+		_, recorded := sd.values[lastActivityKey]
+		if created || recorded || s.RecordActivity || s.SoftIdleTimeout {
+			sd.values[lastActivityKey] = time.Now().UnixNano()
+		}
 	}
 
-	expiry := sd.deadline
-	if s.IdleTimeout > 0 {
-		ie := time.Now().Add(s.IdleTimeout).UTC()
-		if ie.Before(expiry) {
-			expiry = ie
+	return nil
+}
+
+// encodedSession is the session data encoded for committing to the store by
+// writeSession. It holds no references to the session values, so the values
+// can be changed while it is being written.
+type encodedSession struct {
+	deadline time.Time
+	expiry   time.Time
+
+	// b is the encoded session data, or fields are the encoded values if the
+	// store implements PartialStore.
+	b      []byte
+	fields map[string][]byte
+
+	// cleanup holds the values used for the cleanup record.
+	cleanup map[string]interface{}
+
+	// large is the information for the LargeSessionFunc, if it should be
+	// called.
+	large *LargeSession
+}
+
+// encodeSession encodes the session data for committing to the store.
+func (s *SessionManager) encodeSession(token string, deadline time.Time, values map[string]interface{}) (*encodedSession, error) {
+	enc := &encodedSession{deadline: deadline, expiry: s.expiry(deadline, values)}
+
+	var size int
+	if _, ok := s.partialStore(); ok {
+		fields, err := s.encodeFields(deadline, values)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range fields {
+			size += len(b)
 		}
+		enc.fields = fields
+	} else {
+		b, err := s.encode(deadline, values)
+		if err != nil {
+			return nil, err
+		}
+		enc.b, size = b, len(b)
 	}
 
-	if err := s.doStoreCommit(ctx, sd.token, b, expiry); err != nil {
-		return "", time.Time{}, err
+	if id, ok := values[cleanupIDKey].(string); ok {
+		refs, _ := values[cleanupRefsKey].([]string)
+		enc.cleanup = map[string]interface{}{cleanupIDKey: id, cleanupRefsKey: append([]string(nil), refs...)}
 	}
 
-	return sd.token, expiry, nil
+	if s.LargeSessionFunc != nil {
+		storeToken := token
+		if s.HashTokenInStore {
+			storeToken = hashToken(storeToken)
+		}
+		enc.large = s.largeSession(storeToken, size, values)
+	}
+
+	return enc, nil
+}
+
+// writeSession writes the encoded session data to the session store,
+// returning the expiry time used and, if the LargeSessionFunc should be called,
+// information about the session size. If the store implements PartialStore,
+// only the values which differ from base are written, and the fields now in
+// the store are returned.
+func (s *SessionManager) writeSession(ctx context.Context, token string, enc *encodedSession, base map[string][]byte) (time.Time, *LargeSession, map[string][]byte, error) {
+	var fields map[string][]byte
+	if ps, ok := s.partialStore(); ok {
+		if err := s.commitFields(ps, token, enc.fields, base, enc.expiry); err != nil {
+			return time.Time{}, nil, nil, err
+		}
+		fields = enc.fields
+	} else if err := s.doStoreCommit(ctx, token, enc.b, enc.expiry); err != nil {
+		return time.Time{}, nil, nil, err
+	}
+
+	if err := s.commitCleanupRecord(ctx, token, enc.deadline, enc.cleanup); err != nil {
+		return time.Time{}, nil, nil, err
+	}
+
+	storeToken := token
+	if s.HashTokenInStore {
+		storeToken = hashToken(storeToken)
+	}
+	s.filterToken(storeToken)
+
+	return enc.expiry, enc.large, fields, nil
+}
+
+// baseFields returns the fields to compare the session values with when
+// committing them to a PartialStore, or nil if the session data must be
+// committed in full. It must be called with sd.mu held.
+func (sd *sessionData) baseFields() map[string][]byte {
+	if sd.fieldsToken != sd.token {
+		return nil
+	}
+	return sd.fields
 }
 
 // Destroy deletes the session data from the session store and sets the session
 // status to Destroyed. Any further operations in the same request cycle will
-// result in a new session being created.
+// result in a new session being created. If the session has references
+// recorded by AddCleanupRef, the CleanupFunc is called with them once the
+// session has been destroyed, and any error it returns is returned by Destroy.
 func (s *SessionManager) Destroy(ctx context.Context) error {
+	if err := s.checkSession(ctx); err != nil {
+		return err
+	}
+
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	refs, err := s.destroy(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(refs) > 0 {
+		return s.CleanupFunc(ctx, refs)
+	}
+	return nil
+}
+
+// destroy deletes the session data and returns the references to clean up.
+func (s *SessionManager) destroy(ctx context.Context) ([]string, error) {
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	err := s.doStoreDelete(ctx, sd.token)
+	var err error
+	if s.TombstoneTTL > 0 && sd.token != "" {
+		storeToken := sd.token
+		if s.HashTokenInStore {
+			storeToken = hashToken(storeToken)
+		}
+		err = s.tombstone(ctx, storeToken, sd.deadline, sd.values)
+	} else {
+		err = s.doStoreDelete(ctx, sd.token)
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	refs, err := s.cleanupDestroyed(ctx, sd)
+	if err != nil {
+		return nil, err
 	}
 
 	sd.status = Destroyed
 
 	// Reset everything else to defaults.
 	sd.token = ""
-	sd.deadline = time.Now().Add(s.Lifetime).UTC()
+	sd.deadline = time.Now().Add(s.lifetime()).UTC()
 	for key := range sd.values {
 		delete(sd.values, key)
 	}
 
-	return nil
+	return refs, nil
+}
+
+// DestroyToken deletes the session with the given token from the session store,
+// for example to log a user out of a session other than the current one (such
+// as from an administration page). It doesn't affect the session data for the
+// current request, and is a no-op if the session doesn't exist.
+func (s *SessionManager) DestroyToken(ctx context.Context, token string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if s.HashTokenInStore {
+		token = hashToken(token)
+	}
+	return s.revoke(ctx, token)
 }
 
 // Put adds a key and corresponding value to the session data. Any existing
 // value for the key will be replaced. The session data status will be set to
-// Modified.
+// Modified. If the value is rejected by SessionManager.Validators, or the key
+// has been protected with Protect, the value is discarded and the session
+// data is left unchanged. If SessionManager.ReadOnly is set, Put is a no-op;
+// use TryPut to have these cases reported as an error.
 func (s *SessionManager) Put(ctx context.Context, key string, val interface{}) {
+	if s.ReadOnly {
+		return
+	}
+	if err := s.put(ctx, key, val, false); err != nil {
+		s.rejectWrite(ctx, key, err)
+	}
+}
+
+// put validates and adds a value to the session data. Keys protected with
+// Protect are only written if privileged is true.
+func (s *SessionManager) put(ctx context.Context, key string, val interface{}, privileged bool) error {
+	if err := s.validate(key, val); err != nil {
+		return err
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if !privileged && isProtected(sd, key) {
+		return ErrKeyProtected
+	}
 	sd.values[key] = val
+	delete(sd.values, keyExpiryPrefix+key)
 	sd.status = Modified
-	sd.mu.Unlock()
+	return nil
 }
 
 // Get returns the value for a given key from the session data. The return
@@ -173,7 +563,7 @@ func (s *SessionManager) Put(ctx context.Context, key string, val interface{}) {
 // Also see the GetString(), GetInt(), GetBytes() and other helper methods which
 // wrap the type conversion for common types.
 func (s *SessionManager) Get(ctx context.Context, key string) interface{} {
-	sd := s.getSessionDataFromContext(ctx)
+	sd := s.getSessionDataForKey(ctx, key)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
@@ -181,11 +571,90 @@ func (s *SessionManager) Get(ctx context.Context, key string) interface{} {
 	return sd.values[key]
 }
 
+// Scan copies the value for a given key from the session data into the value
+// pointed to by dst, which must be a non-nil pointer. Unlike Get and the
+// helper methods such as GetString, it reports why a value couldn't be
+// retrieved: if the key does not exist the error wraps ErrKeyNotFound, and if
+// the stored value can't be assigned to *dst the error wraps
+// ErrTypeAssertionFailed. For example:
+//
+//	var userID int
+//	err := sessionManager.Scan(r.Context(), "userID", &userID)
+//	if errors.Is(err, scs.ErrKeyNotFound) {
+//		// The user is not logged in.
+//	}
+//
+// Numeric destinations (*int, *int32, *int64 and *float64) follow the same
+// conversion rules as GetInt.
+func (s *SessionManager) Scan(ctx context.Context, key string, dst interface{}) error {
+	if err := s.checkSession(ctx); err != nil {
+		return err
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	val, exists := sd.values[key]
+	sd.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+
+	ok := true
+	switch d := dst.(type) {
+	case *int:
+		*d, ok = toInt(val)
+	case *int32:
+		*d, ok = toInt32(val)
+	case *int64:
+		*d, ok = toInt64(val)
+	case *float64:
+		*d, ok = toFloat64(val)
+	default:
+		rv := reflect.ValueOf(dst)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("scs: Scan destination for key %q must be a non-nil pointer, not %T", key, dst)
+		}
+
+		elem := rv.Elem()
+		switch {
+		case val == nil:
+			elem.Set(reflect.Zero(elem.Type()))
+		case reflect.TypeOf(val).AssignableTo(elem.Type()):
+			elem.Set(reflect.ValueOf(val))
+		default:
+			ok = false
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: key %q holds %T, not assignable to %s", ErrTypeAssertionFailed, key, val, reflect.TypeOf(dst).Elem())
+	}
+	return nil
+}
+
 // Pop acts like a one-time Get. It returns the value for a given key from the
 // session data and deletes the key and value from the session data. The
 // session data status will be set to Modified. The return value has the type
 // interface{} so will usually need to be type asserted before you can use it.
+// If SessionManager.ReadOnly is set, or the key has been protected with
+// Protect, Pop returns nil and leaves the session data unchanged; use TryPop
+// to have these cases reported as an error.
 func (s *SessionManager) Pop(ctx context.Context, key string) interface{} {
+	val, _ := s.TryPop(ctx, key)
+	return val
+}
+
+// TryPop returns the value for a given key from the session data and deletes
+// it, in the same way as Pop, but returns ErrKeyProtected if the key has been
+// protected with Protect, and ErrReadOnly if SessionManager.ReadOnly is set.
+// It returns nil and a nil error if the key is not present.
+func (s *SessionManager) TryPop(ctx context.Context, key string) (interface{}, error) {
+	if s.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
@@ -193,18 +662,33 @@ func (s *SessionManager) Pop(ctx context.Context, key string) interface{} {
 
 	val, exists := sd.values[key]
 	if !exists {
-		return nil
+		return nil, nil
+	} else if isProtected(sd, key) {
+		return nil, ErrKeyProtected
 	}
 	delete(sd.values, key)
+	delete(sd.values, keyExpiryPrefix+key)
 	sd.status = Modified
 
-	return val
+	return val, nil
 }
 
 // Remove deletes the given key and corresponding value from the session data.
 // The session data status will be set to Modified. If the key is not present
-// this operation is a no-op.
+// this operation is a no-op. It is also a no-op if SessionManager.ReadOnly is
+// set; use TryRemove to have this reported as an error.
 func (s *SessionManager) Remove(ctx context.Context, key string) {
+	if s.ReadOnly {
+		return
+	}
+	if err := s.remove(ctx, key, false); err != nil {
+		s.rejectWrite(ctx, key, err)
+	}
+}
+
+// remove deletes a key from the session data. Keys protected with Protect are
+// only deleted if privileged is true.
+func (s *SessionManager) remove(ctx context.Context, key string, privileged bool) error {
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
@@ -212,17 +696,60 @@ func (s *SessionManager) Remove(ctx context.Context, key string) {
 
 	_, exists := sd.values[key]
 	if !exists {
-		return
+		return nil
+	}
+	if !privileged && isProtected(sd, key) {
+		return ErrKeyProtected
 	}
 
 	delete(sd.values, key)
+	delete(sd.values, keyExpiryPrefix+key)
 	sd.status = Modified
+	return nil
+}
+
+// RemoveByPrefix deletes all keys starting with prefix, and their values, from
+// the session data under a single lock, and returns the number of keys
+// deleted. For example, RemoveByPrefix(ctx, "cart.") removes "cart.item.1"
+// and "cart.item.2". Keys protected with Protect are left in place. The
+// session data status will be set to Modified if any keys are deleted.
+func (s *SessionManager) RemoveByPrefix(ctx context.Context, prefix string) int {
+	if s.ReadOnly {
+		return 0
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	n := 0
+	for key := range sd.values {
+		if !strings.HasPrefix(key, prefix) || isProtected(sd, key) {
+			continue
+		}
+		delete(sd.values, key)
+		delete(sd.values, keyExpiryPrefix+key)
+		n++
+	}
+	if n > 0 {
+		sd.status = Modified
+	}
+	return n
 }
 
 // Clear removes all data for the current session. The session token and
 // lifetime are unaffected. If there is no data in the current session this is
 // a no-op.
 func (s *SessionManager) Clear(ctx context.Context) error {
+	if err := s.checkSession(ctx); err != nil {
+		return err
+	}
+
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
@@ -241,7 +768,7 @@ func (s *SessionManager) Clear(ctx context.Context) error {
 
 // Exists returns true if the given key is present in the session data.
 func (s *SessionManager) Exists(ctx context.Context, key string) bool {
-	sd := s.getSessionDataFromContext(ctx)
+	sd := s.getSessionDataForKey(ctx, key)
 
 	sd.mu.Lock()
 	_, exists := sd.values[key]
@@ -280,6 +807,14 @@ func (s *SessionManager) Keys(ctx context.Context) []string {
 // logout operations). See https://github.com/OWASP/CheatSheetSeries/blob/master/cheatsheets/Session_Management_Cheat_Sheet.md#renew-the-session-id-after-any-privilege-level-change
 // for additional information.
 func (s *SessionManager) RenewToken(ctx context.Context) error {
+	if err := s.checkSession(ctx); err != nil {
+		return err
+	}
+
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
@@ -298,7 +833,51 @@ func (s *SessionManager) RenewToken(ctx context.Context) error {
 	}
 
 	sd.token = newToken
-	sd.deadline = time.Now().Add(s.Lifetime).UTC()
+	sd.deadline = time.Now().Add(s.lifetime()).UTC()
+	sd.status = Modified
+	if s.RotateEvery > 0 {
+		sd.values[tokenIssuedKey] = time.Now().UnixNano()
+	}
+
+	return nil
+}
+
+// rotateToken replaces the session token if it was issued more than
+// RotateEvery ago. Unlike RenewToken, the session deadline is unchanged.
+func (s *SessionManager) rotateToken(ctx context.Context) error {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.token == "" {
+		return nil
+	}
+
+	now := time.Now()
+	issued, ok := toInt64(sd.values[tokenIssuedKey])
+	if !ok {
+		// The session was created before rotation was enabled, so start
+		// counting from now.
+		sd.values[tokenIssuedKey] = now.UnixNano()
+		sd.status = Modified
+		return nil
+	}
+	if now.Sub(time.Unix(0, issued)) < s.RotateEvery {
+		return nil
+	}
+
+	if err := s.doStoreDelete(ctx, sd.token); err != nil {
+		return err
+	}
+
+	newToken, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	sd.token = newToken
+	sd.values[tokenIssuedKey] = now.UnixNano()
 	sd.status = Modified
 
 	return nil
@@ -308,18 +887,28 @@ func (s *SessionManager) RenewToken(ctx context.Context) error {
 // session tokens are lost across an oauth or similar redirect flows. Use Clear()
 // if no values of the new session are to be used.
 func (s *SessionManager) MergeSession(ctx context.Context, token string) error {
+	if err := s.checkSession(ctx); err != nil {
+		return err
+	}
+
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
-	b, found, err := s.doStoreFind(ctx, token)
+	b, fields, found, err := s.findSession(ctx, token)
 	if err != nil {
 		return err
 	} else if !found {
 		return nil
 	}
 
-	deadline, values, err := s.Codec.Decode(b)
+	deadline, values, _, err := s.decodeSession(b, fields)
 	if err != nil {
 		return err
+	} else if isTombstone(values) {
+		return ErrSessionRevoked
 	}
 
 	sd.mu.Lock()
@@ -344,14 +933,42 @@ func (s *SessionManager) MergeSession(ctx context.Context, token string) error {
 
 // Status returns the current status of the session data.
 func (s *SessionManager) Status(ctx context.Context) Status {
-	sd := s.getSessionDataFromContext(ctx)
+	sd := s.getPartialSessionData(ctx)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
+	if sd.status == Unmodified && sd.touched && !sd.noExtend {
+		return Modified
+	}
 	return sd.status
 }
 
+// markModified sets the session status to Modified, so that the session is
+// committed and a session cookie written at the end of the request.
+func (s *SessionManager) markModified(ctx context.Context) {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.status = Modified
+}
+
+// markCommitted resets the status of a session which has just been committed,
+// so that any further modifications in the same request can be detected.
+func (s *SessionManager) markCommitted(ctx context.Context) {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.status == Modified {
+		sd.status = Unmodified
+	}
+	sd.touched = false
+}
+
 // GetString returns the string value for a given key from the session data.
 // The zero value for a string ("") is returned if the key does not exist or the
 // value could not be type asserted to a string.
@@ -378,49 +995,49 @@ func (s *SessionManager) GetBool(ctx context.Context, key string) bool {
 
 // GetInt returns the int value for a given key from the session data. The
 // zero value for an int (0) is returned if the key does not exist or the
-// value could not be type asserted to an int.
+// value could not be converted to an int.
+//
+// GetInt, GetInt64, GetInt32 and GetFloat (and the equivalent Pop methods)
+// convert between numeric types, so that a value reads back the same way
+// regardless of how it was stored or whether it has been through a codec which
+// doesn't preserve Go types (such as one based on encoding/json). The rules
+// are:
+//
+//   - Any signed or unsigned integer type, and json.Number, is converted if
+//     its value fits in the target type without overflow.
+//   - float32 and float64 values are converted to an integer type only if
+//     they have no fractional part and fit in the target type; they are never
+//     rounded or truncated.
+//   - Any integer type is converted to a float64, even if this loses
+//     precision.
+//   - All other types (including strings) are not converted, and the zero
+//     value is returned.
 func (s *SessionManager) GetInt(ctx context.Context, key string) int {
-	val := s.Get(ctx, key)
-	i, ok := val.(int)
-	if !ok {
-		return 0
-	}
+	i, _ := toInt(s.Get(ctx, key))
 	return i
 }
 
 // GetInt64 returns the int64 value for a given key from the session data. The
 // zero value for an int64 (0) is returned if the key does not exist or the
-// value could not be type asserted to an int64.
+// value could not be converted to an int64.
 func (s *SessionManager) GetInt64(ctx context.Context, key string) int64 {
-	val := s.Get(ctx, key)
-	i, ok := val.(int64)
-	if !ok {
-		return 0
-	}
+	i, _ := toInt64(s.Get(ctx, key))
 	return i
 }
 
 // GetInt32 returns the int value for a given key from the session data. The
 // zero value for an int32 (0) is returned if the key does not exist or the
-// value could not be type asserted to an int32.
+// value could not be converted to an int32.
 func (s *SessionManager) GetInt32(ctx context.Context, key string) int32 {
-	val := s.Get(ctx, key)
-	i, ok := val.(int32)
-	if !ok {
-		return 0
-	}
+	i, _ := toInt32(s.Get(ctx, key))
 	return i
 }
 
 // GetFloat returns the float64 value for a given key from the session data. The
 // zero value for an float64 (0) is returned if the key does not exist or the
-// value could not be type asserted to a float64.
+// value could not be converted to a float64.
 func (s *SessionManager) GetFloat(ctx context.Context, key string) float64 {
-	val := s.Get(ctx, key)
-	f, ok := val.(float64)
-	if !ok {
-		return 0
-	}
+	f, _ := toFloat64(s.Get(ctx, key))
 	return f
 }
 
@@ -478,26 +1095,18 @@ func (s *SessionManager) PopBool(ctx context.Context, key string) bool {
 // PopInt returns the int value for a given key and then deletes it from the
 // session data. The session data status will be set to Modified. The zero
 // value for an int (0) is returned if the key does not exist or the value could
-// not be type asserted to an int.
+// not be converted to an int.
 func (s *SessionManager) PopInt(ctx context.Context, key string) int {
-	val := s.Pop(ctx, key)
-	i, ok := val.(int)
-	if !ok {
-		return 0
-	}
+	i, _ := toInt(s.Pop(ctx, key))
 	return i
 }
 
 // PopFloat returns the float64 value for a given key and then deletes it from the
 // session data. The session data status will be set to Modified. The zero
 // value for an float64 (0) is returned if the key does not exist or the value
-// could not be type asserted to a float64.
+// could not be converted to a float64.
 func (s *SessionManager) PopFloat(ctx context.Context, key string) float64 {
-	val := s.Pop(ctx, key)
-	f, ok := val.(float64)
-	if !ok {
-		return 0
-	}
+	f, _ := toFloat64(s.Pop(ctx, key))
 	return f
 }
 
@@ -535,9 +1144,35 @@ func (s *SessionManager) RememberMe(ctx context.Context, val bool) {
 	s.Put(ctx, "__rememberMe", val)
 }
 
+// Preload loads the session data for the given tokens into the session store's
+// cache ahead of anticipated traffic, for example after a deploy or a cache
+// flush. It returns the number of sessions loaded. The session store must
+// implement a Preload(tokens []string) (int, error) method, as the cachestore
+// package does; otherwise Preload returns an error.
+func (s *SessionManager) Preload(ctx context.Context, tokens []string) (int, error) {
+	p, ok := s.Store.(interface {
+		Preload(tokens []string) (int, error)
+	})
+	if !ok {
+		return 0, fmt.Errorf("scs: type %T does not support preloading", s.Store)
+	}
+
+	if s.HashTokenInStore {
+		hashed := make([]string, len(tokens))
+		for i, token := range tokens {
+			hashed[i] = hashToken(token)
+		}
+		tokens = hashed
+	}
+
+	return p.Preload(tokens)
+}
+
 // Iterate retrieves all active (i.e. not expired) sessions from the store and
-// executes the provided function fn for each session. If the session store
-// being used does not support iteration then Iterate will panic.
+// executes the provided function fn for each session. Other records kept in
+// the store by the SessionManager, such as used nonces and locks, are skipped.
+// If the session store being used does not support iteration then Iterate
+// will panic.
 func (s *SessionManager) Iterate(ctx context.Context, fn func(context.Context) error) error {
 	allSessions, err := s.doStoreAll(ctx)
 	if err != nil {
@@ -550,9 +1185,11 @@ func (s *SessionManager) Iterate(ctx context.Context, fn func(context.Context) e
 			token:  token,
 		}
 
-		sd.deadline, sd.values, err = s.Codec.Decode(b)
+		sd.deadline, sd.values, err = s.decode(b)
 		if err != nil {
 			return err
+		} else if isTombstone(sd.values) {
+			continue
 		}
 
 		ctx = s.addSessionDataToContext(ctx, sd)
@@ -566,11 +1203,29 @@ func (s *SessionManager) Iterate(ctx context.Context, fn func(context.Context) e
 	return nil
 }
 
+// GC deletes all expired sessions from the session store and returns the
+// number deleted. Stores which don't expire sessions automatically (such as
+// the SQL-based stores, boltstore and memstore) run a background cleanup
+// goroutine by default; GC allows cleanup to be run from a scheduled job
+// instead, for example with the cleanup goroutine disabled via the store's
+// NewWithCleanupInterval function. The store must implement CleanupStore or
+// CleanupCtxStore. Stores which expire sessions automatically (such as Redis)
+// don't, and GC returns 0 and a nil error for them.
+func (s *SessionManager) GC(ctx context.Context) (int, error) {
+	switch cs := s.Store.(type) {
+	case CleanupCtxStore:
+		return cs.DeleteExpiredCtx(ctx)
+	case CleanupStore:
+		return cs.DeleteExpired()
+	}
+	return 0, nil
+}
+
 // Deadline returns the 'absolute' expiry time for the session. Please note
 // that if you are using an idle timeout, it is possible that a session will
 // expire due to non-use before the returned deadline.
 func (s *SessionManager) Deadline(ctx context.Context) time.Time {
-	sd := s.getSessionDataFromContext(ctx)
+	sd := s.getPartialSessionData(ctx)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
@@ -580,14 +1235,21 @@ func (s *SessionManager) Deadline(ctx context.Context) time.Time {
 
 // SetDeadline updates the 'absolute' expiry time for the session. Please note
 // that if you are using an idle timeout, it is possible that a session will
-// expire due to non-use before the set deadline.
+// expire due to non-use before the set deadline. The deadline is stored in UTC
+// with any monotonic clock reading removed, so that it means the same instant
+// after it has been through the session store. SetDeadline is a no-op if
+// SessionManager.ReadOnly is set.
 func (s *SessionManager) SetDeadline(ctx context.Context, expire time.Time) {
+	if s.ReadOnly {
+		return
+	}
+
 	sd := s.getSessionDataFromContext(ctx)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	sd.deadline = expire
+	sd.deadline = expire.UTC()
 	sd.status = Modified
 }
 
@@ -595,7 +1257,7 @@ func (s *SessionManager) SetDeadline(ctx context.Context, expire time.Time) {
 // empty string "" if it is called before the session has been committed to
 // the store.
 func (s *SessionManager) Token(ctx context.Context) string {
-	sd := s.getSessionDataFromContext(ctx)
+	sd := s.getPartialSessionData(ctx)
 
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
@@ -603,25 +1265,143 @@ func (s *SessionManager) Token(ctx context.Context) string {
 	return sd.token
 }
 
+// decode decodes session data using the session manager's codec, normalizing
+// the deadline to UTC. Codecs may preserve the time zone of the deadline (the
+// gob encoding of a time.Time includes its zone offset), so data written by
+// another process could otherwise carry a different location.
+func (s *SessionManager) decode(b []byte) (time.Time, map[string]interface{}, error) {
+	deadline, values, _, err := s.decodeFallback(b)
+	return deadline, values, err
+}
+
+// decodeFallback decodes session data with the Codec, falling back to the
+// FallbackCodec (if set) when the Codec returns an error. It reports whether
+// the FallbackCodec was used.
+func (s *SessionManager) decodeFallback(b []byte) (time.Time, map[string]interface{}, bool, error) {
+	b, err := verifyChecksum(b, s.Checksum)
+	if err != nil {
+		return time.Time{}, nil, false, err
+	}
+
+	deadline, values, err := decodeWith(s.Codec, b)
+	if err == nil || s.FallbackCodec == nil {
+		return deadline, values, false, err
+	}
+
+	deadline, values, ferr := decodeWith(s.FallbackCodec, b)
+	if ferr != nil {
+		return time.Time{}, nil, false, err
+	}
+	return deadline, values, true, nil
+}
+
+func decodeWith(codec Codec, b []byte) (deadline time.Time, values map[string]interface{}, err error) {
+	// Session data can be tampered with in some stores, so a Codec which
+	// panics on malformed data is treated as returning an error.
+	defer func() {
+		if r := recover(); r != nil {
+			deadline, values, err = time.Time{}, nil, fmt.Errorf("scs: unable to decode session data: %v", r)
+		}
+	}()
+
+	deadline, values, err = codec.Decode(b)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	return deadline.UTC(), values, nil
+}
+
+// expiry returns the time at which a session with the given deadline and
+// values should expire in the store, taking into account the idle timeout and
+// the time of the last activity recorded in the session data.
+func (s *SessionManager) expiry(deadline time.Time, values map[string]interface{}) time.Time {
+	expiry := deadline
+	if s.idleTimeout() > 0 && !s.SoftIdleTimeout {
+		ie := time.Now().Add(s.idleTimeout()).UTC()
+		if ns, ok := values[lastActivityKey].(int64); ok {
+			ie = time.Unix(0, ns).Add(s.idleTimeout()).UTC()
+		}
+		if ie.Before(expiry) {
+			expiry = ie
+		}
+	}
+	return expiry
+}
+
 func (s *SessionManager) addSessionDataToContext(ctx context.Context, sd *sessionData) context.Context {
 	return context.WithValue(ctx, s.contextKey, sd)
 }
 
+// getSessionDataFromContext returns the session data for ctx, first loading
+// the rest of the session data if only some of it was loaded.
 func (s *SessionManager) getSessionDataFromContext(ctx context.Context) *sessionData {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	if sd.partial != nil {
+		s.completeLoad(ctx, sd)
+	}
+	sd.mu.Unlock()
+
+	return sd
+}
+
+// getPartialSessionData returns the session data for ctx, which may only have
+// some of the session values loaded. It must only be used to read the session
+// status and metadata, or values checked with sessionData.loaded.
+func (s *SessionManager) getPartialSessionData(ctx context.Context) *sessionData {
 	c, ok := ctx.Value(s.contextKey).(*sessionData)
 	if !ok {
-		panic("scs: no session data in context")
+		if s.TolerateMissingSession {
+			// Changes to this session data are discarded.
+			return newSessionData(s.lifetime())
+		}
+		panic(ErrNoSessionManager)
 	}
 	return c
 }
 
+// checkSession returns ErrNoSessionManager if ctx doesn't contain session data
+// and TolerateMissingSession is set. (Otherwise, the subsequent call to
+// getSessionDataFromContext will panic.)
+func (s *SessionManager) checkSession(ctx context.Context) error {
+	if _, ok := ctx.Value(s.contextKey).(*sessionData); !ok && s.TolerateMissingSession {
+		return ErrNoSessionManager
+	}
+	return nil
+}
+
 func generateToken() (string, error) {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
+	// The random bytes and their encoding are written to fixed-size arrays,
+	// so that generating a token allocates at most the random bytes (if they
+	// escape to the heap through the rand.Reader interface) and the returned
+	// string.
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
 		return "", err
 	}
-	return base64.RawURLEncoding.EncodeToString(b), nil
+
+	var token [43]byte
+	base64.RawURLEncoding.Encode(token[:], b[:])
+	return string(token[:]), nil
+}
+
+// validToken reports whether token has the format of a token returned by
+// generateToken: 43 characters from the unpadded base64url alphabet.
+func validToken(token string) bool {
+	if len(token) != 43 {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
 }
 
 func hashToken(token string) string {
@@ -631,19 +1411,18 @@ func hashToken(token string) string {
 
 type contextKey string
 
-var (
-	contextKeyID      uint64
-	contextKeyIDMutex = &sync.Mutex{}
-)
+// contextKeyID is only accessed atomically, so that SessionManagers can be
+// created concurrently (for example, in parallel tests).
+var contextKeyID uint64
 
 func generateContextKey() contextKey {
-	contextKeyIDMutex.Lock()
-	defer contextKeyIDMutex.Unlock()
-	atomic.AddUint64(&contextKeyID, 1)
-	return contextKey(fmt.Sprintf("session.%d", contextKeyID))
+	id := atomic.AddUint64(&contextKeyID, 1)
+	return contextKey(fmt.Sprintf("session.%d", id))
 }
 
 func (s *SessionManager) doStoreDelete(ctx context.Context, token string) (err error) {
+	defer s.tokenLocks.lock(token)()
+
 	if s.HashTokenInStore {
 		token = hashToken(token)
 	}
@@ -670,6 +1449,14 @@ func (s *SessionManager) doStoreFind(ctx context.Context, token string) (b []byt
 }
 
 func (s *SessionManager) doStoreCommit(ctx context.Context, token string, b []byte, expiry time.Time) (err error) {
+	defer s.tokenLocks.lock(token)()
+
+	return s.storeCommitLocked(ctx, token, b, expiry)
+}
+
+// storeCommitLocked is doStoreCommit for callers which have locked the token
+// in s.tokenLocks.
+func (s *SessionManager) storeCommitLocked(ctx context.Context, token string, b []byte, expiry time.Time) (err error) {
 	if s.HashTokenInStore {
 		token = hashToken(token)
 	}
@@ -682,16 +1469,226 @@ func (s *SessionManager) doStoreCommit(ctx context.Context, token string, b []by
 	return s.Store.Commit(token, b, expiry)
 }
 
+// doStoreAll returns the encoded data for all sessions in the store, as
+// described by allSessions.
 func (s *SessionManager) doStoreAll(ctx context.Context) (map[string][]byte, error) {
-	cs, ok := s.Store.(IterableCtxStore)
+	return s.allSessions(ctx, s.Store)
+}
+
+// allSessions returns the encoded data for all sessions in store, as described
+// by allRecords, leaving out the other records kept in the store (such as
+// nonces and locks).
+func (s *SessionManager) allSessions(ctx context.Context, store Store) (map[string][]byte, error) {
+	all, err := s.allRecords(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range all {
+		if isAuxiliaryRecord(key) {
+			delete(all, key)
+		}
+	}
+	return all, nil
+}
+
+// auxiliaryPrefixes are the prefixes of the keys for the records other than
+// sessions which the SessionManager keeps in the session store.
+var auxiliaryPrefixes = []string{
+	cleanupPrefix,
+	duplicateCreationPrefix,
+	"handoff:",
+	"lock:",
+	"login:",
+	"nonce:",
+	"remember:",
+}
+
+// isAuxiliaryRecord reports whether key is the key for a record other than a
+// session in the session store.
+func isAuxiliaryRecord(key string) bool {
+	for _, prefix := range auxiliaryPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allRecords returns the encoded data for all records in store, including
+// sessions held as separate values by an IterablePartialStore, which are
+// re-encoded with the Codec.
+func (s *SessionManager) allRecords(ctx context.Context, store Store) (map[string][]byte, error) {
+	all, err := storeAll(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, ok := store.(IterablePartialStore)
+	if !ok {
+		return all, nil
+	}
+
+	partial, err := ips.AllValues()
+	if err != nil {
+		return nil, err
+	}
+	for token, fields := range partial {
+		deadline, values, _, err := s.decodeSession(nil, fields)
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.encode(deadline, values)
+		if err != nil {
+			return nil, err
+		}
+		all[token] = b
+	}
+
+	return all, nil
+}
+
+func storeAll(ctx context.Context, store Store) (map[string][]byte, error) {
+	cs, ok := store.(IterableCtxStore)
 	if ok {
 		return cs.AllCtx(ctx)
 	}
 
-	is, ok := s.Store.(IterableStore)
+	is, ok := store.(IterableStore)
 	if ok {
 		return is.All()
 	}
 
-	panic(fmt.Sprintf("type %T does not support iteration", s.Store))
+	panic(fmt.Sprintf("type %T does not support iteration", store))
+}
+
+func storeFind(ctx context.Context, store Store, token string) ([]byte, bool, error) {
+	c, ok := store.(interface {
+		FindCtx(context.Context, string) ([]byte, bool, error)
+	})
+	if ok {
+		return c.FindCtx(ctx, token)
+	}
+	return store.Find(token)
+}
+
+func storeCommit(ctx context.Context, store Store, token string, b []byte, expiry time.Time) error {
+	c, ok := store.(interface {
+		CommitCtx(context.Context, string, []byte, time.Time) error
+	})
+	if ok {
+		return c.CommitCtx(ctx, token, b, expiry)
+	}
+	return store.Commit(token, b, expiry)
+}
+
+func storeDelete(ctx context.Context, store Store, token string) error {
+	c, ok := store.(interface {
+		DeleteCtx(context.Context, string) error
+	})
+	if ok {
+		return c.DeleteCtx(ctx, token)
+	}
+	return store.Delete(token)
+}
+
+// toInt64 converts a numeric session value to an int64 according to the rules
+// described in the GetInt documentation.
+func toInt64(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return uintToInt64(uint64(v))
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return uintToInt64(v)
+	case float32:
+		return floatToInt64(float64(v))
+	case float64:
+		return floatToInt64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil {
+			return floatToInt64(f)
+		}
+	}
+	return 0, false
+}
+
+func uintToInt64(u uint64) (int64, bool) {
+	if u > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(u), true
+}
+
+func floatToInt64(f float64) (int64, bool) {
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range.
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func toInt(val interface{}) (int, bool) {
+	i, ok := toInt64(val)
+	if !ok || int64(int(i)) != i {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func toInt32(val interface{}) (int32, bool) {
+	i, ok := toInt64(val)
+	if !ok || i < math.MinInt32 || i > math.MaxInt32 {
+		return 0, false
+	}
+	return int32(i), true
+}
+
+// toFloat64 converts a numeric session value to a float64 according to the
+// rules described in the GetInt documentation.
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	if i, ok := toInt64(val); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
 }
@@ -3,12 +3,16 @@ package scs
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/alexedwards/scs/v2/mockstore"
 )
 
@@ -25,6 +29,43 @@ func TestSessionDataFromContext(t *testing.T) {
 	s.getSessionDataFromContext(context.Background())
 }
 
+func TestNoSessionManager(t *testing.T) {
+	t.Parallel()
+
+	t.Run("panics by default", func(t *testing.T) {
+		defer func() {
+			err, _ := recover().(error)
+			if err != ErrNoSessionManager {
+				t.Errorf("got panic %v: expected %v", err, ErrNoSessionManager)
+			}
+		}()
+
+		s := New()
+		s.GetString(context.Background(), "foo")
+	})
+
+	t.Run("tolerated", func(t *testing.T) {
+		s := New()
+		s.TolerateMissingSession = true
+		ctx := context.Background()
+
+		s.Put(ctx, "foo", "bar")
+		if v := s.GetString(ctx, "foo"); v != "" {
+			t.Errorf("got %q: expected %q", v, "")
+		}
+		if _, _, err := s.Commit(ctx); err != ErrNoSessionManager {
+			t.Errorf("got %v: expected %v", err, ErrNoSessionManager)
+		}
+		if err := s.Destroy(ctx); err != ErrNoSessionManager {
+			t.Errorf("got %v: expected %v", err, ErrNoSessionManager)
+		}
+		var v string
+		if err := s.Scan(ctx, "foo", &v); err != ErrNoSessionManager {
+			t.Errorf("got %v: expected %v", err, ErrNoSessionManager)
+		}
+	})
+}
+
 func TestSessionManager_Load(T *testing.T) {
 	T.Parallel()
 
@@ -181,6 +222,7 @@ func TestSessionManager_Load(T *testing.T) {
 
 	T.Run("with error decoding found token", func(t *testing.T) {
 		s := New()
+		s.OnDecodeError = FailOnDecodeError
 
 		ctx := context.Background()
 		expected := "example"
@@ -199,6 +241,48 @@ func TestSessionManager_Load(T *testing.T) {
 		}
 	})
 
+	T.Run("discarding data which can't be decoded", func(t *testing.T) {
+		s := New()
+
+		if err := s.Store.Commit("example", []byte("corrupt"), time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("error committing to session store: %v", err)
+		}
+
+		newCtx, err := s.Load(context.Background(), "example")
+		if err != nil {
+			t.Errorf("unexpected error loading from session manager: %v", err)
+		}
+		if token := s.Token(newCtx); token != "" {
+			t.Errorf("expected new session; got token %q", token)
+		}
+		if _, found, _ := s.Store.Find("example"); found {
+			t.Error("expected corrupt session data to be deleted")
+		}
+	})
+
+	T.Run("recovering data which can't be decoded", func(t *testing.T) {
+		s := New()
+		s.OnDecodeError = RecoverOnDecodeError
+		s.RecoverFunc = func(ctx context.Context, b []byte, err error) (time.Time, map[string]interface{}, error) {
+			return time.Now().Add(time.Hour), map[string]interface{}{"raw": string(b)}, nil
+		}
+
+		if err := s.Store.Commit("example", []byte("corrupt"), time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("error committing to session store: %v", err)
+		}
+
+		newCtx, err := s.Load(context.Background(), "example")
+		if err != nil {
+			t.Errorf("unexpected error loading from session manager: %v", err)
+		}
+		if token := s.Token(newCtx); token != "example" {
+			t.Errorf("expected token %q; got %q", "example", token)
+		}
+		if raw := s.GetString(newCtx, "raw"); raw != "corrupt" {
+			t.Errorf("expected %q; got %q", "corrupt", raw)
+		}
+	})
+
 	T.Run("with token hashing", func(t *testing.T) {
 		s := New()
 		s.HashTokenInStore = true
@@ -431,6 +515,52 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	sd := newSessionData(time.Hour)
+	sd.values["string"] = "bar"
+	sd.values["int"] = 42
+	sd.values["time"] = time.Unix(1, 0)
+	ctx := s.addSessionDataToContext(context.Background(), sd)
+
+	var str string
+	if err := s.Scan(ctx, "string", &str); err != nil || str != "bar" {
+		t.Errorf("got %q, %v: expected %q, <nil>", str, err, "bar")
+	}
+
+	var f float64
+	if err := s.Scan(ctx, "int", &f); err != nil || f != 42 {
+		t.Errorf("got %v, %v: expected %v, <nil>", f, err, 42)
+	}
+
+	var tm time.Time
+	if err := s.Scan(ctx, "time", &tm); err != nil || !tm.Equal(time.Unix(1, 0)) {
+		t.Errorf("got %v, %v: expected %v, <nil>", tm, err, time.Unix(1, 0))
+	}
+
+	err := s.Scan(ctx, "missing", &str)
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got %v: expected %v", err, ErrKeyNotFound)
+	}
+	if !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("got %q: expected error to contain the key", err)
+	}
+
+	err = s.Scan(ctx, "string", &tm)
+	if !errors.Is(err, ErrTypeAssertionFailed) {
+		t.Errorf("got %v: expected %v", err, ErrTypeAssertionFailed)
+	}
+	if want := `scs: type assertion failed: key "string" holds string, not assignable to time.Time`; err.Error() != want {
+		t.Errorf("got %q: expected %q", err, want)
+	}
+
+	if err := s.Scan(ctx, "string", str); err == nil {
+		t.Errorf("expected error for non-pointer destination")
+	}
+}
+
 func TestPop(t *testing.T) {
 	t.Parallel()
 
@@ -477,6 +607,41 @@ func TestRemove(t *testing.T) {
 	}
 }
 
+func TestRemoveByPrefix(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	sd := newSessionData(time.Hour)
+	sd.values["cart.item.1"] = "foo"
+	sd.values["cart.item.2"] = "bar"
+	sd.values["cart.owner"] = "baz"
+	sd.values["cartography"] = "boz"
+	sd.values[keyExpiryPrefix+"cart.item.2"] = time.Now().Add(time.Hour).UnixNano()
+	ctx := s.addSessionDataToContext(context.Background(), sd)
+
+	if err := s.Protect(ctx, "cart.owner"); err != nil {
+		t.Fatal(err)
+	}
+	sd.status = Unmodified
+
+	if n := s.RemoveByPrefix(ctx, "cart.missing."); n != 0 || sd.status != Unmodified {
+		t.Errorf("got %d, %v: expected 0, %v", n, sd.status, Unmodified)
+	}
+
+	if n := s.RemoveByPrefix(ctx, "cart."); n != 2 {
+		t.Errorf("got %d: expected %d", n, 2)
+	}
+
+	keys := s.Keys(ctx)
+	if !reflect.DeepEqual(keys, []string{protectedKeysKey, "cart.owner", "cartography"}) {
+		t.Errorf("got %v: expected remaining keys", keys)
+	}
+
+	if sd.status != Modified {
+		t.Errorf("got %v: expected %v", sd.status, "modified")
+	}
+}
+
 func TestClear(t *testing.T) {
 	t.Parallel()
 
@@ -611,6 +776,56 @@ func TestGetFloat(t *testing.T) {
 	}
 }
 
+func TestNumericConversion(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	sd := newSessionData(time.Hour)
+	sd.values["int"] = 42
+	sd.values["uint8"] = uint8(42)
+	sd.values["int64"] = int64(1 << 40)
+	sd.values["wholeFloat"] = float64(42)
+	sd.values["fraction"] = 42.5
+	sd.values["jsonInt"] = json.Number("42")
+	sd.values["jsonFloat"] = json.Number("42.5")
+	sd.values["hugeUint"] = uint64(math.MaxUint64)
+	sd.values["string"] = "42"
+	ctx := s.addSessionDataToContext(context.Background(), sd)
+
+	tests := []struct {
+		key       string
+		wantInt   int
+		wantInt32 int32
+		wantInt64 int64
+		wantFloat float64
+	}{
+		{"int", 42, 42, 42, 42},
+		{"uint8", 42, 42, 42, 42},
+		{"int64", 1 << 40, 0, 1 << 40, 1 << 40},
+		{"wholeFloat", 42, 42, 42, 42},
+		{"fraction", 0, 0, 0, 42.5},
+		{"jsonInt", 42, 42, 42, 42},
+		{"jsonFloat", 0, 0, 0, 42.5},
+		{"hugeUint", 0, 0, 0, math.MaxUint64},
+		{"string", 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := s.GetInt(ctx, tt.key); got != tt.wantInt {
+			t.Errorf("GetInt(%q): got %v: expected %v", tt.key, got, tt.wantInt)
+		}
+		if got := s.GetInt32(ctx, tt.key); got != tt.wantInt32 {
+			t.Errorf("GetInt32(%q): got %v: expected %v", tt.key, got, tt.wantInt32)
+		}
+		if got := s.GetInt64(ctx, tt.key); got != tt.wantInt64 {
+			t.Errorf("GetInt64(%q): got %v: expected %v", tt.key, got, tt.wantInt64)
+		}
+		if got := s.GetFloat(ctx, tt.key); got != tt.wantFloat {
+			t.Errorf("GetFloat(%q): got %v: expected %v", tt.key, got, tt.wantFloat)
+		}
+	}
+}
+
 func TestGetBytes(t *testing.T) {
 	t.Parallel()
 
@@ -847,3 +1062,228 @@ func TestStatus(t *testing.T) {
 		t.Errorf("got %d: expected %d", status, Destroyed)
 	}
 }
+
+func TestDeadlineTimeZones(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	t.Run("serialized in another time zone", func(t *testing.T) {
+		deadline := time.Now().Add(time.Hour).In(time.FixedZone("UTC-5", -5*60*60)).Round(0)
+		b, err := s.Codec.Encode(deadline, map[string]interface{}{"foo": "bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _ := generateToken()
+		if err := s.Store.Commit(token, b, deadline); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err := s.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := s.Deadline(ctx)
+		if got.Location() != time.UTC || !got.Equal(deadline) {
+			t.Errorf("got %v: expected %v in UTC", got, deadline)
+		}
+	})
+
+	t.Run("deadline passed before store expiry", func(t *testing.T) {
+		b, err := s.Codec.Encode(time.Now().Add(-time.Minute), map[string]interface{}{"foo": "bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _ := generateToken()
+		if err := s.Store.Commit(token, b, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err := s.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Token(ctx) != "" || s.Exists(ctx, "foo") {
+			t.Errorf("expected expired session not to be loaded")
+		}
+	})
+
+	t.Run("across a DST transition", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			t.Skip("time zone database not available")
+		}
+
+		// 2:30am on 8 March 2037 doesn't exist in New York; clocks go
+		// forward from 2am to 3am.
+		deadline := time.Date(2037, 3, 8, 1, 30, 0, 0, loc).Add(90 * time.Minute)
+
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetDeadline(ctx, deadline)
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err = s.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := s.Deadline(ctx)
+		want := time.Date(2037, 3, 8, 8, 0, 0, 0, time.UTC)
+		if got.Location() != time.UTC || !got.Equal(want) {
+			t.Errorf("got %v: expected %v", got, want)
+		}
+	})
+}
+
+func TestPreload(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	if _, err := s.Preload(context.Background(), []string{"token"}); err == nil {
+		t.Error("expected error for store which doesn't support preloading")
+	}
+
+	store := &preloadStore{Store: s.Store}
+	s.Store = store
+	s.HashTokenInStore = true
+
+	n, err := s.Preload(context.Background(), []string{"token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d: expected %d", n, 1)
+	}
+	if !reflect.DeepEqual(store.tokens, []string{hashToken("token")}) {
+		t.Errorf("got %v: expected hashed token", store.tokens)
+	}
+}
+
+type preloadStore struct {
+	Store
+	tokens []string
+}
+
+func (s *preloadStore) Preload(tokens []string) (int, error) {
+	s.tokens = tokens
+	return len(tokens), nil
+}
+
+func TestDestroyToken(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.HashTokenInStore = true
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DestroyToken(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "foo") {
+		t.Error("expected session to be destroyed")
+	}
+}
+
+func TestGC(t *testing.T) {
+	t.Parallel()
+
+	store := memstore.NewWithCleanupInterval(0)
+	s := New()
+	s.Store = store
+
+	store.Commit("expired1", []byte("a"), time.Now().Add(-time.Minute))
+	store.Commit("expired2", []byte("b"), time.Now().Add(-time.Minute))
+	store.Commit("active", []byte("c"), time.Now().Add(time.Minute))
+
+	n, err := s.GC(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 sessions deleted; got %d", n)
+	}
+
+	n, err = s.GC(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want 0 sessions deleted; got %d", n)
+	}
+	if _, found, _ := store.Find("active"); !found {
+		t.Error("want active session to remain")
+	}
+
+	s.Store = &mockstore.MockStore{}
+	if n, err := s.GC(context.Background()); n != 0 || err != nil {
+		t.Errorf("want 0 and nil error for store without cleanup; got %d, %v", n, err)
+	}
+}
+
+type panicCodec struct {
+	GobCodec
+}
+
+func (panicCodec) Decode(b []byte) (time.Time, map[string]interface{}, error) {
+	panic("malformed data")
+}
+
+func TestDecodePanic(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Codec = panicCodec{}
+	s.OnDecodeError = FailOnDecodeError
+
+	token, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.Commit(token, []byte("malformed"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Load(context.Background(), token)
+	if err == nil || !strings.Contains(err.Error(), "malformed data") {
+		t.Errorf("want decode error; got %v", err)
+	}
+}
+
+func TestGenerateTokenAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := generateToken(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 2 {
+		t.Errorf("want at most 2 allocations per token; got %v", allocs)
+	}
+}
+
+func BenchmarkGenerateToken(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := generateToken(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
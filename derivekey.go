@@ -0,0 +1,73 @@
+package scs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrInvalidKeyLength is returned by DeriveKey when the requested key length
+// is less than 1 or greater than 8160 bytes.
+var ErrInvalidKeyLength = errors.New("scs: invalid derived key length")
+
+const keySecretKey = "__keySecret"
+
+// DeriveKey returns a key of the given length in bytes, derived with HKDF
+// (RFC 5869, using SHA-256) from a random secret held in the session data. The
+// same session and purpose always give the same key, and different purposes
+// give independent keys, so the key can be used to encrypt data stored on the
+// client (such as a localStorage payload) without storing any extra keys. The
+// secret is created the first time DeriveKey is called for a session, which
+// modifies the session. It is kept when the token is renewed, and discarded
+// when the session is destroyed, after which data encrypted with the derived
+// keys can no longer be decrypted.
+//
+// Anyone who can read the session data in the store can derive the keys, so
+// use EncryptionKey or EncryptionSecrets if the store isn't trusted.
+func (s *SessionManager) DeriveKey(ctx context.Context, purpose string, length int) ([]byte, error) {
+	if length < 1 || length > 255*sha256.Size {
+		return nil, ErrInvalidKeyLength
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	secret, _ := sd.values[keySecretKey].([]byte)
+	if len(secret) == 0 {
+		if s.ReadOnly {
+			return nil, ErrReadOnly
+		}
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		sd.values[keySecretKey] = secret
+		sd.status = Modified
+	}
+
+	return hkdf(secret, []byte(purpose), length), nil
+}
+
+// hkdf derives a key of the given length from secret and info using HKDF with
+// SHA-256 and no salt. The length must be at most 255*sha256.Size.
+func hkdf(secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	var key, t []byte
+	for i := byte(1); len(key) < length; i++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		t = expand.Sum(nil)
+		key = append(key, t...)
+	}
+	return key[:length]
+}
@@ -0,0 +1,100 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := s.DeriveKey(ctx, "localStorage", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 32 {
+		t.Errorf("got %d: expected %d bytes", len(key), 32)
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("got %v: expected %v", s.Status(ctx), Modified)
+	}
+
+	other, err := s.DeriveKey(ctx, "other", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(key, other) {
+		t.Error("want different keys for different purposes")
+	}
+
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same key is derived in a later request, and after the token is
+	// renewed.
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenewToken(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.DeriveKey(ctx, "localStorage", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("want the same key for the same session and purpose")
+	}
+
+	// Other sessions derive different keys.
+	ctx2, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := s.DeriveKey(ctx2, "localStorage", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(key, key2) {
+		t.Error("want different keys for different sessions")
+	}
+
+	for _, length := range []int{0, 255*32 + 1} {
+		if _, err := s.DeriveKey(ctx, "localStorage", length); err != ErrInvalidKeyLength {
+			t.Errorf("length %d: got %v: expected %v", length, err, ErrInvalidKeyLength)
+		}
+	}
+
+	s.ReadOnly = true
+	ctx3, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeriveKey(ctx3, "localStorage", 32); err != ErrReadOnly {
+		t.Errorf("got %v: expected %v", err, ErrReadOnly)
+	}
+}
+
+func TestHKDF(t *testing.T) {
+	t.Parallel()
+
+	// RFC 5869, test case 3.
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	want := "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"
+
+	if got := hex.EncodeToString(hkdf(ikm, nil, 42)); got != want {
+		t.Errorf("got %s: expected %s", got, want)
+	}
+}
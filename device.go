@@ -0,0 +1,161 @@
+package scs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	deviceUserAgentKey = "__device.userAgent"
+	deviceIPKey        = "__device.ip"
+	deviceLocationKey  = "__device.location"
+	deviceLastSeenKey  = "__device.lastSeen"
+)
+
+// DeviceTracking contains the configuration settings for recording client
+// metadata alongside each session.
+type DeviceTracking struct {
+	// ClientIP returns the IP address of the client for a request. The
+	// default is the host part of r.RemoteAddr. If your application is
+	// behind a trusted proxy, you should set this to a function which reads
+	// the appropriate forwarding header instead.
+	ClientIP func(r *http.Request) string
+
+	// Locate, if set, resolves a client IP address to a coarse, human
+	// readable location (for example "Berlin, Germany").
+	Locate func(ip string) string
+
+	// Interval is the minimum length of time between updates to the
+	// last-seen time for a session. Updating the metadata causes the session
+	// to be committed to the store, so this limits the number of writes. If
+	// zero, one minute is used. Changes to the client IP address or user
+	// agent are always recorded immediately.
+	Interval time.Duration
+}
+
+// DeviceInfo contains the client metadata recorded for a session.
+type DeviceInfo struct {
+	// UserAgent is the User-Agent header sent by the client.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// Device is a rough classification of the client derived from the user
+	// agent. It is one of "desktop", "mobile", "tablet", "bot" or "unknown".
+	Device string `json:"device"`
+
+	// IP is the client IP address.
+	IP string `json:"ip,omitempty"`
+
+	// Location is the coarse location resolved by DeviceTracking.Locate.
+	Location string `json:"location,omitempty"`
+
+	// LastSeen is the last time the session was used, accurate to within
+	// DeviceTracking.Interval.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// DeviceInfo returns the client metadata recorded for the current session.
+// The SessionManager.DeviceTracking setting must be enabled for metadata to be
+// recorded; otherwise the zero DeviceInfo (with the Device "unknown") is
+// returned.
+func (s *SessionManager) DeviceInfo(ctx context.Context) DeviceInfo {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	return deviceInfo(sd.values)
+}
+
+// SessionsForUser returns summary information, including the recorded client
+// metadata, for all active sessions belonging to a user. It can be used to
+// build an 'active sessions' security page. The SessionManager.UserKey setting
+// is required and the session store must support iteration.
+func (s *SessionManager) SessionsForUser(ctx context.Context, userID string) ([]SessionInfo, error) {
+	if userID == "" || s.UserKey == "" {
+		return nil, ErrNoUserKey
+	}
+	return s.sessionInfo(ctx, userID)
+}
+
+// trackDevice records the client metadata for the request in the session data.
+// It is called after the session is loaded and again before it is committed, so
+// that new sessions which are never modified don't create a session in the
+// store.
+func (s *SessionManager) trackDevice(r *http.Request) {
+	dt := s.DeviceTracking
+	if dt == nil || s.ReadOnly {
+		return
+	}
+
+	var ip string
+	if dt.ClientIP != nil {
+		ip = dt.ClientIP(r)
+	} else {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+
+	interval := dt.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	sd := s.getSessionDataFromContext(r.Context())
+	ua := r.UserAgent()
+	now := time.Now()
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.token == "" && sd.status != Modified {
+		return
+	}
+
+	current := deviceInfo(sd.values)
+	if current.UserAgent == ua && current.IP == ip && now.Sub(current.LastSeen) < interval {
+		return
+	}
+
+	if ip != current.IP || current.LastSeen.IsZero() {
+		location := ""
+		if dt.Locate != nil && ip != "" {
+			location = dt.Locate(ip)
+		}
+		sd.values[deviceLocationKey] = location
+	}
+
+	sd.values[deviceUserAgentKey] = ua
+	sd.values[deviceIPKey] = ip
+	sd.values[deviceLastSeenKey] = now.UnixNano()
+	sd.status = Modified
+}
+
+func deviceInfo(values map[string]interface{}) DeviceInfo {
+	di := DeviceInfo{}
+	di.UserAgent, _ = values[deviceUserAgentKey].(string)
+	di.IP, _ = values[deviceIPKey].(string)
+	di.Location, _ = values[deviceLocationKey].(string)
+	if ns, ok := values[deviceLastSeenKey].(int64); ok {
+		di.LastSeen = time.Unix(0, ns).UTC()
+	}
+	di.Device = classifyDevice(di.UserAgent)
+	return di
+}
+
+func classifyDevice(ua string) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(lower, "bot") || strings.Contains(lower, "crawler") || strings.Contains(lower, "spider"):
+		return "bot"
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet"):
+		return "tablet"
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "android"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
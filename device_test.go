@@ -0,0 +1,94 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeviceTracking(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+	s.DeviceTracking = &DeviceTracking{
+		Locate: func(ip string) string {
+			return "Example City"
+		},
+	}
+
+	var info DeviceInfo
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			s.Put(r.Context(), "userID", 1)
+		}
+		info = s.DeviceInfo(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/anonymous", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want no session cookie for unmodified session; got %q", rr.Header().Get("Set-Cookie"))
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/login", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	sessions, err := s.SessionsForUser(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Device == nil {
+		t.Fatalf("want 1 session with device info; got %v", sessions)
+	}
+
+	device := sessions[0].Device
+	if device.Device != "mobile" {
+		t.Errorf("want %q; got %q", "mobile", device.Device)
+	}
+	if device.IP != "192.0.2.1" {
+		t.Errorf("want %q; got %q", "192.0.2.1", device.IP)
+	}
+	if device.Location != "Example City" {
+		t.Errorf("want %q; got %q", "Example City", device.Location)
+	}
+	if time.Since(device.LastSeen) > time.Minute {
+		t.Errorf("want recent last seen time; got %v", device.LastSeen)
+	}
+
+	// Within the interval, an unchanged client doesn't modify the session.
+	r = httptest.NewRequest(http.MethodGet, "/get", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148")
+	r.AddCookie(rr.Result().Cookies()[0])
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want no session cookie; got %q", rr.Header().Get("Set-Cookie"))
+	}
+	if info.Device != "mobile" {
+		t.Errorf("want %q; got %q", "mobile", info.Device)
+	}
+}
+
+func TestClassifyDevice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"": "unknown",
+		"Googlebot/2.1 (+http://www.google.com/bot.html)":                             "bot",
+		"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)":                               "tablet",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36":               "mobile",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0.0": "desktop",
+	}
+
+	for ua, want := range tests {
+		if got := classifyDevice(ua); got != want {
+			t.Errorf("%q: want %q; got %q", ua, want, got)
+		}
+	}
+}
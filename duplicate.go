@@ -0,0 +1,147 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DuplicateCreation contains the configuration settings for reconciling
+// sessions created by parallel requests from the same client. Without it, when
+// a client without a session cookie makes two requests at once (for example,
+// when a page and an API call are loaded together on the first visit), each
+// request creates a separate session and the cookie for one overwrites the
+// other, losing the data stored in it.
+//
+// The first new session committed for a client is recorded in the session
+// store, under the "create:" prefix, for the Window. When another new session
+// is committed for the same client within the Window, its values are merged
+// into the first session, it is deleted, and the cookie for the first session
+// is sent instead, so that both requests end up with the same session. The
+// record holds the first session's token, so it is kept in the store in the
+// clear even if HashTokenInStore is set. Sessions committed by AsyncSave are
+// not reconciled.
+type DuplicateCreation struct {
+	// KeyFunc returns the key which identifies the client making a request.
+	// Requests for which it returns "" are not reconciled. It must be set.
+	//
+	// The key must be unique to a single client: requests from different
+	// clients which return the same key within the Window are merged into one
+	// session, giving the second user the first user's session data. Values
+	// which can be shared between clients, such as the IP address (which is
+	// shared by everyone behind the same NAT or proxy) and the User-Agent
+	// header, must not be used. Use a value which the client generates or is
+	// given before its first session is created instead, such as a random
+	// pre-session cookie or a nonce sent by the client with each request.
+	KeyFunc func(r *http.Request) string
+
+	// Window is how long after the first session is created that further new
+	// sessions for the same client are merged into it. If zero, 5 seconds is
+	// used.
+	Window time.Duration
+
+	// MergeFunc, if set, returns the values for the merged session, given the
+	// values of the first session and of the duplicate. By default the values
+	// of the first session are kept, and the values of the duplicate are
+	// added to them, replacing any with the same key (last writer wins).
+	MergeFunc func(first, duplicate map[string]interface{}) map[string]interface{}
+}
+
+const duplicateCreationPrefix = "create:"
+
+// reconcileCreation records the newly committed session with the given token
+// as the first session for the client making the request, or merges it into
+// the first session if one has already been recorded, as described by
+// DuplicateCreation. It returns the token and expiry of the session which the
+// client should use.
+func (s *SessionManager) reconcileCreation(ctx context.Context, r *http.Request, token string, expiry time.Time) (string, time.Time, error) {
+	dc := s.DuplicateCreation
+	key := dc.KeyFunc(r)
+	if key == "" {
+		return token, expiry, nil
+	}
+
+	window := dc.Window
+	if window <= 0 {
+		window = 5 * time.Second
+	}
+
+	recordKey := duplicateCreationPrefix + hashToken(key)
+	recordExpiry := time.Now().Add(window)
+	b, err := s.encode(recordExpiry.UTC(), map[string]interface{}{"token": token})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.addLock(ctx, recordKey, b, recordExpiry); err == nil {
+		return token, expiry, nil
+	} else if !errors.Is(err, ErrLocked) {
+		return "", time.Time{}, err
+	}
+
+	b, found, err := storeFind(ctx, s.Store, recordKey)
+	if err != nil {
+		return "", time.Time{}, err
+	} else if !found {
+		return token, expiry, nil
+	}
+	_, values, err := s.decode(b)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	first, _ := values["token"].(string)
+	if first == "" || first == token {
+		return token, expiry, nil
+	}
+
+	return s.mergeDuplicate(ctx, first, token, expiry)
+}
+
+// mergeDuplicate merges the values of the session in ctx, which has just been
+// committed with the token duplicate, into the session with the token first,
+// and deletes the duplicate. The session in ctx is updated to be the merged
+// session.
+func (s *SessionManager) mergeDuplicate(ctx context.Context, first, duplicate string, expiry time.Time) (string, time.Time, error) {
+	b, fields, found, err := s.findSession(ctx, first)
+	if err != nil {
+		return "", time.Time{}, err
+	} else if !found {
+		return duplicate, expiry, nil
+	}
+	deadline, existing, _, err := s.decodeSession(b, fields)
+	if err != nil {
+		return "", time.Time{}, err
+	} else if isTombstone(existing) || !time.Now().Before(deadline) {
+		return duplicate, expiry, nil
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	var merged map[string]interface{}
+	if s.DuplicateCreation.MergeFunc != nil {
+		merged = s.DuplicateCreation.MergeFunc(existing, sd.values)
+	} else {
+		merged = make(map[string]interface{}, len(existing)+len(sd.values))
+		for k, v := range existing {
+			merged[k] = v
+		}
+		for k, v := range sd.values {
+			merged[k] = v
+		}
+	}
+
+	expiry, _, fields, err = s.commitValues(ctx, first, deadline, merged, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.doStoreDelete(ctx, duplicate); err != nil {
+		return "", time.Time{}, err
+	}
+
+	sd.token, sd.deadline, sd.values = first, deadline, merged
+	sd.fields, sd.fieldsToken = fields, first
+	return first, expiry, nil
+}
@@ -0,0 +1,126 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDuplicateCreation(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"memstore", "noaddstore"} {
+		s := New()
+		mem := s.Store
+		if name == "noaddstore" {
+			s.Store = noAddStore{mem}
+		}
+		s.DuplicateCreation = &DuplicateCreation{
+			KeyFunc: func(r *http.Request) string { return r.Header.Get("X-Client") },
+		}
+
+		h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), r.URL.Query().Get("key"), "x")
+			s.Put(r.Context(), "last", r.URL.Query().Get("key"))
+		}))
+		serve := func(path, client string) string {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Header.Set("X-Client", client)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("%s: want 1 cookie; got %d", name, len(cookies))
+			}
+			return cookies[0].Value
+		}
+
+		first := serve("/?key=a", "client")
+		second := serve("/?key=b", "client")
+		other := serve("/?key=c", "other")
+		none := serve("/?key=d", "")
+
+		if second != first {
+			t.Errorf("%s: want duplicate session merged into the first", name)
+		}
+		if other == first || none == first {
+			t.Errorf("%s: want sessions for other clients kept separate", name)
+		}
+
+		ctx, err := s.Load(context.Background(), first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.Exists(ctx, "a") || !s.Exists(ctx, "b") || s.GetString(ctx, "last") != "b" {
+			t.Errorf("%s: want merged values; got %v", name, s.Keys(ctx))
+		}
+
+		all, err := mem.(IterableStore).All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions := 0
+		for token := range all {
+			if token == first || token == other || token == none {
+				sessions++
+			}
+		}
+		if len(all) != sessions+2 {
+			t.Errorf("%s: want the duplicate session deleted; got %d records", name, len(all))
+		}
+	}
+}
+
+func TestDuplicateCreationConcurrent(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.DuplicateCreation = &DuplicateCreation{
+		KeyFunc: func(r *http.Request) string { return "client" },
+		MergeFunc: func(first, duplicate map[string]interface{}) map[string]interface{} {
+			merged := make(map[string]interface{})
+			for k, v := range duplicate {
+				merged[k] = v
+			}
+			for k, v := range first {
+				merged[k] = v
+			}
+			return merged
+		},
+	}
+
+	var ready sync.WaitGroup
+	ready.Add(2)
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), r.URL.Query().Get("key"), "x")
+		// Both requests create their sessions before either commits.
+		ready.Done()
+		ready.Wait()
+	}))
+
+	tokens := make([]string, 2)
+	var wg sync.WaitGroup
+	for i, key := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/?key="+key, nil))
+			tokens[i] = rr.Result().Cookies()[0].Value
+		}(i, key)
+	}
+	wg.Wait()
+
+	if tokens[0] != tokens[1] {
+		t.Fatalf("want the same session for both requests; got %v", tokens)
+	}
+	ctx, err := s.Load(context.Background(), tokens[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exists(ctx, "a") || !s.Exists(ctx, "b") {
+		t.Errorf("want merged values; got %v", s.Keys(ctx))
+	}
+}
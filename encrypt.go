@@ -0,0 +1,107 @@
+package scs
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// ErrNoEncryptionKey is returned by PutEncryptedString and GetEncryptedString
+// when neither SessionManager.EncryptionKey nor EncryptionSecrets is set.
+var ErrNoEncryptionKey = errors.New("scs: no encryption key")
+
+// ErrDecryptionFailed is returned by GetEncryptedString when a value can't be
+// decrypted, because it wasn't encrypted with any of the keys, was stored
+// under a different session key, or has been tampered with.
+var ErrDecryptionFailed = errors.New("scs: value could not be decrypted")
+
+// PutEncryptedString adds a string value to the session data, encrypted with
+// SessionManager.EncryptionKey (or the current key from EncryptionSecrets)
+// using AES-GCM. It is intended for individual
+// sensitive values (such as identity numbers or third-party access tokens),
+// so that they are not readable in the session store or by admin tooling,
+// while the rest of the session data remains inspectable. The encrypted value
+// is bound to key, so it can't be moved to a different key in the session
+// data. Use GetEncryptedString to retrieve the value.
+func (s *SessionManager) PutEncryptedString(ctx context.Context, key string, val string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	current, _ := s.encryptionKeys()
+	aead, err := fieldCipher(current)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(val)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	return s.TryPut(ctx, key, aead.Seal(nonce, nonce, []byte(val), []byte(key)))
+}
+
+// GetEncryptedString returns the decrypted string value for a given key which
+// was added with PutEncryptedString. The empty string and a nil error are
+// returned if the key does not exist. ErrDecryptionFailed is returned if the
+// value is not an encrypted value or can't be decrypted.
+func (s *SessionManager) GetEncryptedString(ctx context.Context, key string) (string, error) {
+	_, all := s.encryptionKeys()
+	if len(all) == 0 {
+		return "", ErrNoEncryptionKey
+	}
+
+	val := s.Get(ctx, key)
+	if val == nil {
+		return "", nil
+	}
+
+	b, ok := val.([]byte)
+	if !ok {
+		return "", ErrDecryptionFailed
+	}
+
+	for _, k := range all {
+		aead, err := fieldCipher(k)
+		if err != nil {
+			return "", err
+		}
+		if len(b) < aead.NonceSize() {
+			continue
+		}
+		plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(key))
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", ErrDecryptionFailed
+}
+
+// encryptionKeys returns the current key for encrypting values, and all of
+// the keys for decrypting them.
+func (s *SessionManager) encryptionKeys() (current []byte, all [][]byte) {
+	if s.EncryptionSecrets != nil {
+		return s.EncryptionSecrets.Current(), s.EncryptionSecrets.All()
+	}
+
+	key := s.encryptionKey()
+	if len(key) == 0 {
+		return nil, nil
+	}
+	return key, [][]byte{key}
+}
+
+func fieldCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, ErrNoEncryptionKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
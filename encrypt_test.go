@@ -0,0 +1,78 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEncryptedString(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.EncryptionKey = []byte("01234567890123456789012345678901")
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PutEncryptedString(ctx, "ssn", "078-05-1120"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, ok := s.Get(ctx, "ssn").([]byte)
+	if !ok || bytes.Contains(raw, []byte("078-05-1120")) {
+		t.Errorf("want encrypted value in session data; got %q", s.Get(ctx, "ssn"))
+	}
+
+	got, err := s.GetEncryptedString(ctx, "ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "078-05-1120" {
+		t.Errorf("want %q; got %q", "078-05-1120", got)
+	}
+
+	// The value survives a round trip through the store.
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetEncryptedString(ctx, "ssn"); err != nil || got != "078-05-1120" {
+		t.Errorf("want %q; got %q, %v", "078-05-1120", got, err)
+	}
+
+	if got, err := s.GetEncryptedString(ctx, "missing"); err != nil || got != "" {
+		t.Errorf("want empty string and nil error; got %q, %v", got, err)
+	}
+
+	// The value is bound to its key.
+	s.Put(ctx, "moved", raw)
+	if _, err := s.GetEncryptedString(ctx, "moved"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("want %v; got %v", ErrDecryptionFailed, err)
+	}
+
+	s.Put(ctx, "plain", "078-05-1120")
+	if _, err := s.GetEncryptedString(ctx, "plain"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("want %v; got %v", ErrDecryptionFailed, err)
+	}
+
+	s.EncryptionKey = []byte("10987654321098765432109876543210")
+	if _, err := s.GetEncryptedString(ctx, "ssn"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("want %v; got %v", ErrDecryptionFailed, err)
+	}
+
+	s.EncryptionKey = nil
+	if err := s.PutEncryptedString(ctx, "ssn", "x"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("want %v; got %v", ErrNoEncryptionKey, err)
+	}
+	if _, err := s.GetEncryptedString(ctx, "ssn"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("want %v; got %v", ErrNoEncryptionKey, err)
+	}
+}
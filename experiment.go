@@ -0,0 +1,88 @@
+package scs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Bucket assigns the session to one of the variants for an A/B experiment and
+// returns it. The assignment is stored in the session data on the first call,
+// and subsequent calls return the same variant for as long as it is still
+// present in variants.
+//
+// The optional weights control the relative probability of each variant being
+// assigned; if weights is nil the variants are equally likely. When the session
+// has a token the assignment is derived deterministically from a hash of the
+// token and experiment name, otherwise it is chosen at random.
+//
+// If the SessionManager.ExposureFunc hook is set, it is called whenever a new
+// assignment is made.
+func (s *SessionManager) Bucket(ctx context.Context, experiment string, variants []string, weights []int) string {
+	if len(variants) == 0 {
+		panic("scs: Bucket called with no variants")
+	}
+	if weights != nil && len(weights) != len(variants) {
+		panic(fmt.Sprintf("scs: Bucket called with %d variants but %d weights", len(variants), len(weights)))
+	}
+
+	key := "__experiment." + experiment
+
+	current := s.GetString(ctx, key)
+	for _, variant := range variants {
+		if variant == current {
+			return current
+		}
+	}
+
+	total := uint64(0)
+	for i := range variants {
+		total += uint64(weight(weights, i))
+	}
+	if total == 0 {
+		panic("scs: Bucket called with zero total weight")
+	}
+
+	var n uint64
+	if token := s.Token(ctx); token != "" {
+		sum := sha256.Sum256([]byte(experiment + ":" + token))
+		n = binary.BigEndian.Uint64(sum[:8])
+	} else {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(err)
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	n %= total
+
+	variant := variants[len(variants)-1]
+	for i := range variants {
+		w := uint64(weight(weights, i))
+		if n < w {
+			variant = variants[i]
+			break
+		}
+		n -= w
+	}
+
+	s.Put(ctx, key, variant)
+
+	if s.ExposureFunc != nil {
+		s.ExposureFunc(ctx, experiment, variant)
+	}
+
+	return variant
+}
+
+func weight(weights []int, i int) int {
+	if weights == nil {
+		return 1
+	}
+	if weights[i] < 0 {
+		return 0
+	}
+	return weights[i]
+}
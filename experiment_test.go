@@ -0,0 +1,71 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	var exposures []string
+	s.ExposureFunc = func(ctx context.Context, experiment string, variant string) {
+		exposures = append(exposures, experiment+"="+variant)
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variant := s.Bucket(ctx, "checkout", []string{"control", "treatment"}, nil)
+	for i := 0; i < 10; i++ {
+		if got := s.Bucket(ctx, "checkout", []string{"control", "treatment"}, nil); got != variant {
+			t.Fatalf("want sticky variant %q; got %q", variant, got)
+		}
+	}
+	if len(exposures) != 1 || exposures[0] != "checkout="+variant {
+		t.Errorf("want one exposure for %q; got %v", variant, exposures)
+	}
+
+	if got := s.Bucket(ctx, "banner", []string{"a", "b", "c"}, []int{0, 1, 0}); got != "b" {
+		t.Errorf("want %q; got %q", "b", got)
+	}
+
+	// Variants which are no longer part of the experiment are reassigned.
+	if got := s.Bucket(ctx, "banner", []string{"a", "c"}, []int{1, 0}); got != "a" {
+		t.Errorf("want %q; got %q", "a", got)
+	}
+}
+
+func TestBucketDeterministic(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	b, err := s.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.Commit("example", b, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	var variants []string
+	for i := 0; i < 5; i++ {
+		ctx, err := s.Load(context.Background(), "example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		variants = append(variants, s.Bucket(ctx, "checkout", []string{"a", "b", "c", "d"}, nil))
+	}
+
+	for _, v := range variants[1:] {
+		if v != variants[0] {
+			t.Errorf("want deterministic assignments; got %v", variants)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// expiryStatus is the JSON response sent by the ExpiryHandler and
+// ExtendHandler.
+type expiryStatus struct {
+	Active        bool     `json:"active"`
+	ExpiresIn     float64  `json:"expires_in"`
+	IdleExpiresIn *float64 `json:"idle_expires_in"`
+	CanExtend     bool     `json:"can_extend"`
+}
+
+// ExpiryHandler returns a handler which responds with a JSON object describing
+// when the current session will expire, so that client-side applications can
+// warn users before their session expires. For example:
+//
+//	{"active":true,"expires_in":85412.5,"idle_expires_in":1195.2,"can_extend":true}
+//
+// The expires_in value is the number of seconds until the absolute session
+// deadline. The idle_expires_in value is the number of seconds until the
+// session expires due to inactivity; it is null if there is no idle timeout or
+// if no activity time is recorded for the session (see RecordActivity). The
+// can_extend value indicates whether calling the ExtendHandler would push back
+// the expiry time. If there is no session, active is false and the other
+// values are zero.
+//
+// Requests to the ExpiryHandler don't count as activity, so polling it doesn't
+// keep the session alive. It must be used inside the LoadAndSave()
+// middleware.
+func (s *SessionManager) ExpiryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.NoExtend(r.Context())
+		writeJSON(w, s.expiryStatus(r))
+	})
+}
+
+// NoExtend marks the current request as not counting as activity, so that it
+// doesn't reset the idle timeout. This is useful for requests which the client
+// makes automatically, such as long-polling or status checks, which would
+// otherwise keep the session alive for as long as the page is open. If the
+// request modifies the session data, the changes are still committed with the
+// existing idle expiry time, using the activity time recorded in the session
+// data. Only sessions created before the idle timeout was enabled have no
+// recorded activity time (unless RecordActivity is enabled), and their idle
+// timer is reset by the commit.
+func (s *SessionManager) NoExtend(ctx context.Context) {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	sd.noExtend = true
+	sd.mu.Unlock()
+}
+
+// NoExtendHandler returns middleware which calls NoExtend for every request,
+// for use on routes such as long-polling endpoints. It must be used inside the
+// LoadAndSave() middleware.
+func (s *SessionManager) NoExtendHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.NoExtend(r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ExtendHandler returns a handler which resets the idle timeout for the
+// current session and responds with the same JSON object as the
+// ExpiryHandler. It should be called with a POST request when the user
+// chooses to stay signed in. It must be used inside the LoadAndSave()
+// middleware.
+func (s *SessionManager) ExtendHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		sd := s.getSessionDataFromContext(r.Context())
+
+		sd.mu.Lock()
+		if sd.token != "" && s.idleTimeout() > 0 {
+			sd.status = Modified
+			sd.noExtend = false
+			if s.RecordActivity {
+				sd.values[lastActivityKey] = time.Now().UnixNano()
+			}
+		}
+		sd.mu.Unlock()
+
+		writeJSON(w, s.expiryStatus(r))
+	})
+}
+
+func (s *SessionManager) expiryStatus(r *http.Request) expiryStatus {
+	sd := s.getSessionDataFromContext(r.Context())
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.token == "" {
+		return expiryStatus{}
+	}
+
+	status := expiryStatus{
+		Active:    true,
+		ExpiresIn: time.Until(sd.deadline).Seconds(),
+	}
+
+	if s.idleTimeout() > 0 {
+		status.CanExtend = time.Now().Add(s.idleTimeout()).Before(sd.deadline)
+
+		if ns, ok := sd.values[lastActivityKey].(int64); ok {
+			idle := time.Until(time.Unix(0, ns).Add(s.idleTimeout())).Seconds()
+			if idle > status.ExpiresIn {
+				idle = status.ExpiresIn
+			}
+			status.IdleExpiresIn = &idle
+		}
+	}
+
+	return status
+}
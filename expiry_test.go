@@ -0,0 +1,161 @@
+package scs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExpiryHandler(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.IdleTimeout = 300 * time.Millisecond
+	sessionManager.Lifetime = time.Hour
+	sessionManager.RecordActivity = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.Handle("/expiry", sessionManager.ExpiryHandler())
+	mux.Handle("/extend", sessionManager.ExtendHandler())
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	status := func(header http.Header, body string) expiryStatus {
+		var status expiryStatus
+		if err := json.Unmarshal([]byte(body), &status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	st := status(ts.execute(t, "/expiry"))
+	if st.Active {
+		t.Errorf("want inactive session; got %+v", st)
+	}
+
+	ts.execute(t, "/put")
+
+	time.Sleep(200 * time.Millisecond)
+	header, body := ts.execute(t, "/expiry")
+	if header.Get("Set-Cookie") != "" {
+		t.Errorf("want no Set-Cookie header; got %q", header.Get("Set-Cookie"))
+	}
+	st = status(header, body)
+	if !st.Active || !st.CanExtend || st.IdleExpiresIn == nil {
+		t.Fatalf("want active extendable session with idle expiry; got %+v", st)
+	}
+	if *st.IdleExpiresIn > 0.15 {
+		t.Errorf("want idle_expires_in <= 0.15; got %v", *st.IdleExpiresIn)
+	}
+
+	// Polling the expiry handler doesn't keep the session alive.
+	time.Sleep(150 * time.Millisecond)
+	st = status(ts.execute(t, "/expiry"))
+	if st.Active {
+		t.Errorf("want expired session; got %+v", st)
+	}
+
+	ts.execute(t, "/put")
+	time.Sleep(200 * time.Millisecond)
+
+	rs, err := ts.Client().Post(ts.URL+"/extend", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	time.Sleep(200 * time.Millisecond)
+	st = status(ts.execute(t, "/expiry"))
+	if !st.Active {
+		t.Errorf("want extended session to be active; got %+v", st)
+	}
+}
+
+func TestNoExtend(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.IdleTimeout = 300 * time.Millisecond
+	sessionManager.Lifetime = time.Hour
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	})
+	mux.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.NoExtend(r.Context())
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	})
+	mux.Handle("/longpoll/", sessionManager.NoExtendHandler(http.StripPrefix("/longpoll", mux)))
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	for _, path := range []string{"/poll", "/longpoll/get"} {
+		ts.execute(t, "/put")
+
+		// Polling doesn't keep the session alive.
+		time.Sleep(200 * time.Millisecond)
+		header, body := ts.execute(t, path)
+		if header.Get("Set-Cookie") != "" {
+			t.Errorf("%s: want no Set-Cookie header; got %q", path, header.Get("Set-Cookie"))
+		}
+		if body != "bar" {
+			t.Errorf("%s: want %q; got %q", path, "bar", body)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+		if _, body := ts.execute(t, "/get"); body != "" {
+			t.Errorf("%s: want expired session; got %q", path, body)
+		}
+	}
+}
+
+func TestNoExtendModified(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.IdleTimeout = 300 * time.Millisecond
+	sessionManager.Lifetime = time.Hour
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	})
+	mux.HandleFunc("/count", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.NoExtend(r.Context())
+		polls := sessionManager.GetInt(r.Context(), "polls") + 1
+		sessionManager.Put(r.Context(), "polls", polls)
+		fmt.Fprint(w, polls)
+	})
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	ts.execute(t, "/put")
+
+	// The changes are committed without resetting the idle timer.
+	for _, want := range []string{"1", "2"} {
+		time.Sleep(100 * time.Millisecond)
+		if _, body := ts.execute(t, "/count"); body != want {
+			t.Errorf("want %q; got %q", want, body)
+		}
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, body := ts.execute(t, "/get"); body != "" {
+		t.Errorf("want expired session; got %q", body)
+	}
+}
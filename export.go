@@ -0,0 +1,115 @@
+package scs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// exportRecord is the portable representation of a session used by Export and
+// Import. The data is the session data as encoded by the session manager's
+// codec, and the token is the token as it appears in the store (i.e. hashed if
+// HashTokenInStore is set).
+type exportRecord struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+	Data   []byte    `json:"data"`
+}
+
+// Export writes all active sessions in the session store to w, as a stream of
+// JSON objects (one per line) containing the token, expiry time and encoded
+// session data. It returns the number of sessions written. The session store
+// must support iteration, otherwise Export will panic.
+//
+// Stores don't expose the expiry time of individual sessions, so the expiry
+// is calculated from the session deadline, the IdleTimeout setting and the
+// last activity time recorded in the session data, in the same way as Commit
+// does.
+func (s *SessionManager) Export(ctx context.Context, w io.Writer) (int, error) {
+	all, err := s.doStoreAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	n := 0
+	for token, b := range all {
+		deadline, values, err := s.decode(b)
+		if err != nil {
+			return n, err
+		}
+
+		err = enc.Encode(exportRecord{Token: token, Expiry: s.expiry(deadline, values), Data: b})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Import reads sessions written by Export from r and commits them to the
+// session store, returning the number of sessions imported. Sessions which have
+// expired since they were exported are skipped. The session data is stored
+// as-is, so the exporting and importing session managers must use the same
+// codec and HashTokenInStore settings.
+func (s *SessionManager) Import(ctx context.Context, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	n := 0
+	for {
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if !rec.Expiry.After(time.Now()) {
+			continue
+		}
+
+		if err := storeCommit(ctx, s.Store, rec.Token, rec.Data, rec.Expiry); err != nil {
+			return n, err
+		}
+		s.filterToken(rec.Token)
+		n++
+	}
+}
+
+// Migrate copies all active sessions from the src store to the dst store,
+// preserving their tokens and expiry times (calculated as described for
+// Export), and returns the number of sessions copied. It can be used to move
+// to a new session store without logging out all users. Sessions held as
+// separate values by an IterablePartialStore are copied as encoded session
+// data. The other records kept in the store by the SessionManager, such as
+// remember-me login series, are copied too. The src store must support
+// iteration, otherwise Migrate will panic.
+func (s *SessionManager) Migrate(ctx context.Context, src Store, dst Store) (int, error) {
+	all, err := s.allRecords(ctx, src)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for token, b := range all {
+		deadline, values, err := s.decode(b)
+		if err != nil {
+			return n, err
+		}
+
+		expiry := s.expiry(deadline, values)
+		if !expiry.After(time.Now()) {
+			continue
+		}
+
+		if err := storeCommit(ctx, dst, token, b, expiry); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
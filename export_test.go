@@ -0,0 +1,124 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+
+	src := New()
+	for _, token := range []string{"token1", "token2"} {
+		b, err := src.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{"token": token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := src.Store.Commit(token, b, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := src.Export(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want %d; got %d", 2, n)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("want %d lines; got %d", 2, lines)
+	}
+
+	dst := New()
+	n, err = dst.Import(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want %d; got %d", 2, n)
+	}
+
+	for _, token := range []string{"token1", "token2"} {
+		ctx, err := dst.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dst.GetString(ctx, "token"); got != token {
+			t.Errorf("want %q; got %q", token, got)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.IdleTimeout = time.Minute
+
+	src := memstore.NewWithCleanupInterval(0)
+	dst := memstore.NewWithCleanupInterval(0)
+
+	b, err := s.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Commit("live", b, time.Now().Add(time.Minute))
+
+	b, err = s.Codec.Encode(time.Now().Add(-time.Second), map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Commit("expired", b, time.Now().Add(time.Minute))
+
+	n, err := s.Migrate(context.Background(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want %d; got %d", 1, n)
+	}
+
+	if _, found, _ := dst.Find("live"); !found {
+		t.Error("want live session to be migrated")
+	}
+	if _, found, _ := dst.Find("expired"); found {
+		t.Error("want expired session not to be migrated")
+	}
+}
+
+func TestMigratePartialStore(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	src := newPartialStore()
+	dst := memstore.NewWithCleanupInterval(0)
+
+	fields, err := s.encodeFields(time.Now().Add(time.Hour), map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.CommitValues("fields", fields, nil, true, time.Now().Add(time.Hour))
+
+	n, err := s.Migrate(context.Background(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want %d; got %d", 1, n)
+	}
+
+	b, found, _ := dst.Find("fields")
+	if !found {
+		t.Fatal("want session held as separate values to be migrated")
+	}
+	if _, values, err := s.decode(b); err != nil || values["foo"] != "bar" {
+		t.Errorf("want migrated session data; got %v, %v", values, err)
+	}
+}
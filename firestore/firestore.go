@@ -40,6 +40,7 @@ func NewWithCleanupInterval(client *firestore.Client, cleanupInterval time.Durat
 	}
 
 	if cleanupInterval > 0 {
+		m.stopCleanup = make(chan bool)
 		go m.startCleanup(cleanupInterval)
 	}
 
@@ -112,12 +113,11 @@ func (m *FireStore) AllCtx(ctx context.Context) (map[string][]byte, error) {
 }
 
 func (m *FireStore) startCleanup(interval time.Duration) {
-	m.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := m.deleteExpired()
+			_, err := m.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -144,7 +144,12 @@ func (m *FireStore) StopCleanup() {
 	}
 }
 
-func (m *FireStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (m *FireStore) DeleteExpired() (int, error) {
+	n := 0
 	ctx := context.Background()
 	iter := m.Sessions.Where("Expiry", "<", time.Now()).Documents(ctx)
 	for {
@@ -161,9 +166,10 @@ func (m *FireStore) deleteExpired() error {
 			log.Printf("Failed to delete: %v", err)
 			continue
 		}
+		n++
 	}
 	iter.Stop()
-	return nil
+	return n, nil
 }
 
 // We have to add the plain Store methods here to be recognized a Store
@@ -0,0 +1,32 @@
+package scs
+
+import "context"
+
+const flashesKey = "__flashes"
+
+// AddFlash adds a message to the flash messages for the current session,
+// which are displayed to the user once only. The messages are retrieved and
+// removed from the session data with Flashes, or with the flashes template
+// function (see TemplateFuncs). AddFlash is a no-op if ReadOnly is set.
+func (s *SessionManager) AddFlash(ctx context.Context, message string) {
+	if s.ReadOnly {
+		return
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	flashes, _ := sd.values[flashesKey].([]string)
+	sd.values[flashesKey] = append(flashes[:len(flashes):len(flashes)], message)
+	sd.status = Modified
+}
+
+// Flashes returns the flash messages added with AddFlash, in the order they
+// were added, and removes them from the session data. It returns nil if there
+// are no messages, or if ReadOnly is set.
+func (s *SessionManager) Flashes(ctx context.Context) []string {
+	flashes, _ := s.Pop(ctx, flashesKey).([]string)
+	return flashes
+}
@@ -0,0 +1,38 @@
+package scs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFlashes(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddFlash(ctx, "one")
+	s.AddFlash(ctx, "two")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flashes := s.Flashes(ctx); !reflect.DeepEqual(flashes, []string{"one", "two"}) {
+		t.Errorf("want %v; got %v", []string{"one", "two"}, flashes)
+	}
+	if flashes := s.Flashes(ctx); flashes != nil {
+		t.Errorf("want no flashes; got %v", flashes)
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("want status %v; got %v", Modified, s.Status(ctx))
+	}
+}
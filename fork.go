@@ -0,0 +1,66 @@
+package scs
+
+import "context"
+
+// Fork creates a new session containing only the given keys (and their
+// values) from the current session, commits it to the session store and
+// returns its token. The new session has a new token and a full lifetime, and
+// keeps any ttl set with PutWithTTL and any protection set with Protect for
+// the copied keys. The current session is left untouched. Keys which don't
+// exist in the current session are ignored.
+//
+// Fork is useful for flows such as checkout-as-guest, where selected data
+// (like the contents of a cart) should continue in a separate session. The
+// new token can be sent to a client in a session cookie with
+// WriteSessionCookie, or loaded with Load. Values are copied shallowly, so
+// reference types such as slices and maps are shared with the current session
+// until it is next loaded from the store.
+func (s *SessionManager) Fork(ctx context.Context, keys ...string) (string, error) {
+	if err := s.checkSession(ctx); err != nil {
+		return "", err
+	}
+
+	if s.ReadOnly {
+		return "", ErrReadOnly
+	}
+
+	fork := newSessionData(s.lifetime())
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	var protected []string
+	for _, key := range keys {
+		val, exists := sd.values[key]
+		if !exists {
+			continue
+		}
+		fork.values[key] = val
+		if ns, ok := sd.values[keyExpiryPrefix+key]; ok {
+			fork.values[keyExpiryPrefix+key] = ns
+		}
+		if isProtected(sd, key) && !containsString(protected, key) {
+			protected = append(protected, key)
+		}
+	}
+	sd.mu.Unlock()
+
+	if len(protected) > 0 {
+		fork.values[protectedKeysKey] = protected
+	}
+
+	var large *LargeSession
+	defer func() { s.reportLargeSession(ctx, large) }()
+
+	if err := s.prepareCommit(fork); err != nil {
+		return "", err
+	}
+
+	_, ls, _, err := s.commitValues(ctx, fork.token, fork.deadline, fork.values, nil)
+	if err != nil {
+		return "", err
+	}
+	large = ls
+
+	return fork.token, nil
+}
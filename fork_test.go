@@ -0,0 +1,74 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Put(ctx, "user_id", 42)
+	s.Put(ctx, "cart", "3 items")
+	if err := s.PutProtected(ctx, "currency", "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWithTTL(ctx, "coupon", "SAVE10", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := s.Keys(ctx)
+
+	forked, err := s.Fork(ctx, "cart", "currency", "coupon", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forked == "" || forked == token {
+		t.Fatalf("want a new token; got %q", forked)
+	}
+	if s.Token(ctx) != token || !reflect.DeepEqual(s.Keys(ctx), keys) || s.IsProtected(ctx, "cart") {
+		t.Error("want the current session to be untouched")
+	}
+
+	fctx, err := s.Load(context.Background(), forked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(fctx, "user_id") || s.Exists(fctx, "missing") {
+		t.Error("want only the selected keys in the forked session")
+	}
+	if s.GetString(fctx, "cart") != "3 items" || s.GetString(fctx, "coupon") != "SAVE10" {
+		t.Error("want the selected values in the forked session")
+	}
+	if !s.IsProtected(fctx, "currency") || s.KeyExpiry(fctx, "coupon").IsZero() {
+		t.Error("want protection and ttl to be copied")
+	}
+}
+
+func TestForkReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.ReadOnly = true
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Fork(ctx, "foo"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("want %v; got %v", ErrReadOnly, err)
+	}
+}
@@ -0,0 +1,99 @@
+//go:build go1.18
+// +build go1.18
+
+package scs
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// The fuzz targets in this file cover the parsers which handle
+// attacker-controlled input: cookies and headers from the request, and (for
+// stores which can be written to by other parties) the encoded session data.
+// Run them with, for example:
+//
+//	go test -run=^$ -fuzz=FuzzLoadAndSave
+
+func FuzzLoadAndSave(f *testing.F) {
+	f.Add("session=lHqcPNiQp_5diPxumzOklsSdE-MJ7zyU6kjch1Ee0UM", "")
+	f.Add("session=a; session=b; session=", "lHqcPNiQp_5diPxumzOklsSdE-MJ7zyU6kjch1Ee0UM")
+	f.Add(`session="quoted"; remember=sel:val`, "\x00")
+	f.Add("session=lHqcPNiQp_5diPxumzOklsSdE-MJ7zyU6kjch1Ee0U\xff", "")
+
+	s := New()
+	s.MobileCompat = true
+	p := NewPersistentLogin(s, "userID")
+
+	var existing string
+	h := s.LoadAndSave(p.Restore(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			s.Put(r.Context(), "userID", 1)
+		}
+		s.GetInt(r.Context(), "userID")
+	})))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/login", nil))
+	existing = rr.Result().Cookies()[0].Value
+	f.Add("session="+existing, existing)
+
+	f.Fuzz(func(t *testing.T, cookie, header string) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Cookie", cookie)
+		r.Header.Set(s.TokenHeader, header)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, rr.Code)
+		}
+	})
+}
+
+func FuzzValidToken(f *testing.F) {
+	f.Add("lHqcPNiQp_5diPxumzOklsSdE-MJ7zyU6kjch1Ee0UM")
+	f.Add("lHqcPNiQp_5diPxumzOklsSdE-MJ7zyU6kjch1Ee0U=")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, token string) {
+		if !validToken(token) {
+			return
+		}
+		b, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Fatalf("valid token %q doesn't decode: %v", token, err)
+		}
+		if len(b) != 32 {
+			t.Fatalf("valid token %q decodes to %d bytes", token, len(b))
+		}
+	})
+}
+
+func FuzzCodecDecode(f *testing.F) {
+	for _, codec := range []Codec{GobCodec{}, CanonicalCodec{}} {
+		b, err := codec.Encode(time.Now(), map[string]interface{}{"foo": "bar", "baz": 1})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, codec := range []Codec{GobCodec{}, CanonicalCodec{}} {
+			deadline, values, err := codec.Decode(b)
+			if err != nil {
+				continue
+			}
+			// Anything which decodes must encode again.
+			if _, err := codec.Encode(deadline, values); err != nil {
+				t.Fatalf("%T: decoded data doesn't encode: %v", codec, err)
+			}
+		}
+	})
+}
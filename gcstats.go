@@ -0,0 +1,90 @@
+package scs
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// GCStats counts the sessions deleted by GCWithStats by the reason they
+// expired, to help with choosing the Lifetime and IdleTimeout values.
+type GCStats struct {
+	// Lifetime is the number of sessions which expired because they reached
+	// the end of their Lifetime.
+	Lifetime int
+
+	// Idle is the number of sessions which expired before the end of their
+	// Lifetime because they weren't used within the IdleTimeout.
+	Idle int
+
+	// Other is the number of other records deleted, such as the nonce and
+	// lock records held in the store, and session data which couldn't be
+	// decoded.
+	Other int
+}
+
+// Total returns the total number of records deleted.
+func (g GCStats) Total() int {
+	return g.Lifetime + g.Idle + g.Other
+}
+
+// GCWithStats deletes all expired sessions from the session store in the same
+// way as GC, and counts them by the reason they expired. The store must
+// implement ExpiredFuncStore (as memstore does) for the sessions to be
+// counted; otherwise GCWithStats falls back to GC and counts all of the
+// sessions deleted as Other. Sessions deleted by a store's own cleanup
+// goroutine aren't counted, so disable it (for example with memstore's
+// NewWithCleanupInterval function) and call GCWithStats from a scheduled job
+// instead.
+func (s *SessionManager) GCWithStats(ctx context.Context) (GCStats, error) {
+	es, ok := s.Store.(ExpiredFuncStore)
+	if !ok {
+		n, err := s.GC(ctx)
+		return GCStats{Other: n}, err
+	}
+
+	var stats GCStats
+	now := time.Now()
+	_, err := es.DeleteExpiredFunc(func(token string, b []byte) {
+		switch s.expiryReason(token, b, now) {
+		case expiredLifetime:
+			stats.Lifetime++
+		case expiredIdle:
+			stats.Idle++
+		default:
+			stats.Other++
+		}
+	})
+	return stats, err
+}
+
+type expiryReason int
+
+const (
+	expiredOther expiryReason = iota
+	expiredLifetime
+	expiredIdle
+)
+
+// expiryReason returns the reason that the session data b for token expired.
+// Session data which has expired before its deadline can only have expired
+// because of the idle timeout.
+func (s *SessionManager) expiryReason(token string, b []byte, now time.Time) expiryReason {
+	// Records such as nonces and locks are stored with a prefix ending in a
+	// colon, which session tokens never contain.
+	if strings.Contains(token, ":") {
+		return expiredOther
+	}
+
+	deadline, values, err := s.decode(b)
+	if err != nil || isTombstone(values) {
+		return expiredOther
+	}
+
+	if !now.Before(deadline) {
+		return expiredLifetime
+	} else if s.idleTimeout() > 0 {
+		return expiredIdle
+	}
+	return expiredOther
+}
@@ -0,0 +1,75 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestGCWithStats(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Store = memstore.NewWithCleanupInterval(0)
+	s.IdleTimeout = time.Hour
+
+	commit := func(deadline, expiry time.Time) {
+		token, err := generateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := s.Codec.Encode(deadline, map[string]interface{}{"foo": "bar"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Store.Commit(token, b, expiry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-time.Minute)
+	commit(past, past)
+	commit(past, past)
+	commit(time.Now().Add(time.Hour), past)
+	commit(time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if err := s.UseNonce(context.Background(), "nonce", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GCWithStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GCStats{Lifetime: 2, Idle: 1, Other: 1}
+	if stats != want {
+		t.Errorf("got %+v: expected %+v", stats, want)
+	}
+	if stats.Total() != 4 {
+		t.Errorf("got %d: expected %d", stats.Total(), 4)
+	}
+
+	all, err := s.Store.(IterableStore).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("got %d: expected %d sessions to be kept", len(all), 1)
+	}
+}
+
+func TestGCWithStatsUnsupported(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Store = noAddStore{memstore.NewWithCleanupInterval(0)}
+
+	stats, err := s.GCWithStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (GCStats{}) {
+		t.Errorf("got %+v: expected zero stats", stats)
+	}
+}
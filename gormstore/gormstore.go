@@ -39,6 +39,7 @@ func NewWithCleanupInterval(db *gorm.DB, cleanupInterval time.Duration) (*GORMSt
 		return nil, err
 	}
 	if cleanupInterval > 0 {
+		g.stopCleanup = make(chan bool)
 		go g.startCleanup(cleanupInterval)
 	}
 	return g, nil
@@ -121,12 +122,11 @@ func (g *GORMStore) migrate() error {
 }
 
 func (g *GORMStore) startCleanup(interval time.Duration) {
-	g.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := g.deleteExpired()
+			_, err := g.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -153,10 +153,14 @@ func (g *GORMStore) StopCleanup() {
 	}
 }
 
-func (g *GORMStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (g *GORMStore) DeleteExpired() (int, error) {
 	row := g.db.Delete(&session{}, "expiry < ?", time.Now())
 	if row.Error != nil {
-		return row.Error
+		return 0, row.Error
 	}
-	return nil
+	return int(row.RowsAffected), nil
 }
@@ -3,6 +3,7 @@ package gormstore
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -30,7 +31,7 @@ func initWithCleanupInterval(t *testing.T, cleanupInterval time.Duration) (*GORM
 		dsn := os.Getenv("SCS_MYSQL_TEST_DSN")
 		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	default:
-		dsn := "./testSQL3lite.db"
+		dsn := filepath.Join(t.TempDir(), "testSQL3lite.db")
 		db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	}
 	if err != nil {
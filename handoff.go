@@ -0,0 +1,141 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidHandoff is returned by RedeemHandoff when the handoff token is
+// unknown, expired or has already been used.
+var ErrInvalidHandoff = errors.New("scs: invalid handoff token")
+
+// HandoffToken mints a single-use token which can be used to transfer the
+// current session to a different domain (for example, from app.example.com to
+// shop.example.net) where the session cookie isn't sent. The token is valid
+// for the duration ttl, which should be short (typically less than a minute).
+// It is intended to be passed as a URL query parameter when redirecting the
+// user to the destination domain, where it is redeemed with RedeemHandoff or
+// the HandoffHandler.
+//
+// Both domains must use the same session store. If the session has not yet
+// been committed to the store, HandoffToken commits it first.
+func (s *SessionManager) HandoffToken(ctx context.Context, ttl time.Duration) (string, error) {
+	token := s.Token(ctx)
+	if token == "" {
+		var err error
+		if token, _, err = s.Commit(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	handoff, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	expiry := time.Now().Add(ttl).UTC()
+	b, err := s.encode(expiry, map[string]interface{}{"token": token})
+	if err != nil {
+		return "", err
+	}
+
+	if err := storeCommit(ctx, s.Store, handoffKey(handoff), b, expiry); err != nil {
+		return "", err
+	}
+
+	return handoff, nil
+}
+
+// RedeemHandoff consumes a token created by HandoffToken and attaches the
+// session it refers to to the current request, replacing any existing session
+// data. The session status is set to Modified, so that the LoadAndSave
+// middleware writes a session cookie for the current domain. It returns
+// ErrInvalidHandoff if the token is unknown, expired or has already been used.
+// If the store implements AddStore, the token can only be redeemed once across
+// all instances of the application using the store; otherwise this is only
+// guaranteed within a single process.
+func (s *SessionManager) RedeemHandoff(ctx context.Context, handoff string) error {
+	if handoff == "" {
+		return ErrInvalidHandoff
+	}
+
+	key := handoffKey(handoff)
+	b, found, err := storeFind(ctx, s.Store, key)
+	if err != nil {
+		return err
+	} else if !found {
+		return ErrInvalidHandoff
+	}
+
+	expiry, values, err := s.decode(b)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent redemptions can all find the handoff token before it is
+	// deleted, so it is consumed by claiming it, and only the redemption
+	// which makes the claim succeeds.
+	claimed, err := s.claim(ctx, key+":used", expiry)
+	if err != nil {
+		return err
+	} else if !claimed {
+		return ErrInvalidHandoff
+	}
+	if err := storeDelete(ctx, s.Store, key); err != nil {
+		return err
+	}
+
+	token, _ := values["token"].(string)
+
+	b, fields, found, err := s.findSession(ctx, token)
+	if err != nil {
+		return err
+	} else if !found {
+		return ErrInvalidHandoff
+	}
+
+	deadline, values, _, err := s.decodeSession(b, fields)
+	if err != nil {
+		return err
+	} else if isTombstone(values) {
+		return ErrInvalidHandoff
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.token = token
+	sd.deadline = deadline
+	sd.values = values
+	sd.status = Modified
+
+	return nil
+}
+
+// handoffKey returns the key for a handoff token in the session store.
+func handoffKey(handoff string) string {
+	return "handoff:" + hashToken(handoff)
+}
+
+// HandoffHandler returns a handler which redeems the handoff token in the
+// "handoff" URL query parameter and then redirects the client to redirectURL.
+// If the token is invalid a HTTP 400 "Bad Request" response is sent. It must
+// be used inside the LoadAndSave() middleware.
+func (s *SessionManager) HandoffHandler(redirectURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := s.RedeemHandoff(r.Context(), r.URL.Query().Get("handoff"))
+		if errors.Is(err, ErrInvalidHandoff) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			s.ErrorFunc(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	})
+}
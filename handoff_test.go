@@ -0,0 +1,116 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestHandoff(t *testing.T) {
+	t.Parallel()
+
+	source := New()
+	destination := New()
+	destination.Store = source.Store
+	destination.Cookie.Name = "shop_session"
+
+	var handoff string
+	sourceHandler := source.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source.Put(r.Context(), "userID", 7)
+
+		var err error
+		handoff, err = source.HandoffToken(r.Context(), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	destinationHandler := destination.LoadAndSave(destination.HandoffHandler("/account"))
+
+	rr := httptest.NewRecorder()
+	sourceHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	sourceToken := rr.Result().Cookies()[0].Value
+
+	rr = httptest.NewRecorder()
+	destinationHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/handoff?handoff="+handoff, nil))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, rr.Code)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "shop_session" || cookies[0].Value != sourceToken {
+		t.Errorf("want shop_session cookie with token %q; got %v", sourceToken, cookies)
+	}
+
+	// Handoff tokens can only be used once.
+	rr = httptest.NewRecorder()
+	destinationHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/handoff?handoff="+handoff, nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want %d; got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// slowFindStore is a store which delays returning from Find, so that
+// concurrent requests all find a record before any of them can delete it.
+type slowFindStore struct {
+	*memstore.MemStore
+}
+
+func (s slowFindStore) Find(token string) ([]byte, bool, error) {
+	b, found, err := s.MemStore.Find(token)
+	time.Sleep(10 * time.Millisecond)
+	return b, found, err
+}
+
+func TestHandoffConcurrentRedeem(t *testing.T) {
+	t.Parallel()
+
+	stores := map[string]Store{
+		"AddStore": slowFindStore{memstore.NewWithCleanupInterval(0)},
+		"Store":    noAddStore{slowFindStore{memstore.NewWithCleanupInterval(0)}},
+	}
+	for name, store := range stores {
+		s := New()
+		s.Store = store
+
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "userID", 7)
+		handoff, err := s.HandoffToken(ctx, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		var redeemed int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, err := s.Load(context.Background(), "")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				err = s.RedeemHandoff(ctx, handoff)
+				if err == nil {
+					atomic.AddInt32(&redeemed, 1)
+				} else if !errors.Is(err, ErrInvalidHandoff) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if redeemed != 1 {
+			t.Errorf("%s: want handoff token redeemed once; got %d", name, redeemed)
+		}
+	}
+}
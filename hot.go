@@ -0,0 +1,156 @@
+package scs
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hotSettings is an immutable snapshot of the settings which can be changed
+// while the SessionManager is in use.
+type hotSettings struct {
+	lifetime            time.Duration
+	idleTimeout         time.Duration
+	secure              bool
+	sameSite            http.SameSite
+	encryptionKey       []byte
+	tokenFingerprintKey []byte
+}
+
+// hotConfig holds the current hotSettings snapshot. It is nil until one of the
+// setters is first called, and until then the exported fields are used.
+type hotConfig struct {
+	mu       sync.Mutex
+	settings atomic.Value
+}
+
+// SetLifetime changes the Lifetime setting while the SessionManager is in use.
+// It is safe to call concurrently with requests being served, unlike assigning
+// to the Lifetime field. Once any of the Set methods has been called, the
+// Lifetime, IdleTimeout, Cookie.Secure, Cookie.SameSite, EncryptionKey and
+// TokenFingerprintKey fields are no longer read, so these settings must only be
+// changed using the Set methods from then on.
+func (s *SessionManager) SetLifetime(lifetime time.Duration) {
+	s.updateSettings(func(hs *hotSettings) { hs.lifetime = lifetime })
+}
+
+// SetIdleTimeout changes the IdleTimeout setting while the SessionManager is
+// in use. See SetLifetime for details.
+func (s *SessionManager) SetIdleTimeout(idleTimeout time.Duration) {
+	s.updateSettings(func(hs *hotSettings) { hs.idleTimeout = idleTimeout })
+}
+
+// SetCookieSecure changes the Cookie.Secure setting while the SessionManager
+// is in use. See SetLifetime for details.
+func (s *SessionManager) SetCookieSecure(secure bool) {
+	s.updateSettings(func(hs *hotSettings) { hs.secure = secure })
+}
+
+// SetCookieSameSite changes the Cookie.SameSite setting while the
+// SessionManager is in use. See SetLifetime for details.
+func (s *SessionManager) SetCookieSameSite(sameSite http.SameSite) {
+	s.updateSettings(func(hs *hotSettings) { hs.sameSite = sameSite })
+}
+
+// SetEncryptionKey changes the EncryptionKey setting while the SessionManager
+// is in use, for example when the key is rotated. Values encrypted with the
+// previous key can no longer be decrypted. See SetLifetime for details.
+func (s *SessionManager) SetEncryptionKey(key []byte) {
+	key = append([]byte(nil), key...)
+	s.updateSettings(func(hs *hotSettings) { hs.encryptionKey = key })
+}
+
+// SetTokenFingerprintKey changes the TokenFingerprintKey setting while the
+// SessionManager is in use. See SetLifetime for details.
+func (s *SessionManager) SetTokenFingerprintKey(key []byte) {
+	key = append([]byte(nil), key...)
+	s.updateSettings(func(hs *hotSettings) { hs.tokenFingerprintKey = key })
+}
+
+func (s *SessionManager) updateSettings(fn func(hs *hotSettings)) {
+	s.hot.mu.Lock()
+	defer s.hot.mu.Unlock()
+
+	var next hotSettings
+	if current := s.hotSettings(); current != nil {
+		next = *current
+	} else {
+		next = hotSettings{
+			lifetime:            s.Lifetime,
+			idleTimeout:         s.IdleTimeout,
+			secure:              s.Cookie.Secure,
+			sameSite:            s.Cookie.SameSite,
+			encryptionKey:       s.EncryptionKey,
+			tokenFingerprintKey: s.TokenFingerprintKey,
+		}
+	}
+	fn(&next)
+	s.hot.settings.Store(&next)
+}
+
+func (s *SessionManager) hotSettings() *hotSettings {
+	hs, _ := s.hot.settings.Load().(*hotSettings)
+	return hs
+}
+
+func (s *SessionManager) lifetime() time.Duration {
+	if hs := s.hotSettings(); hs != nil {
+		return hs.lifetime
+	}
+	return s.Lifetime
+}
+
+func (s *SessionManager) idleTimeout() time.Duration {
+	if hs := s.hotSettings(); hs != nil {
+		return hs.idleTimeout
+	}
+	return s.IdleTimeout
+}
+
+func (s *SessionManager) encryptionKey() []byte {
+	if hs := s.hotSettings(); hs != nil {
+		return hs.encryptionKey
+	}
+	return s.EncryptionKey
+}
+
+func (s *SessionManager) tokenFingerprintKey() []byte {
+	if s.TokenFingerprintSecrets != nil {
+		return s.TokenFingerprintSecrets.Current()
+	}
+	if hs := s.hotSettings(); hs != nil {
+		return hs.tokenFingerprintKey
+	}
+	return s.TokenFingerprintKey
+}
+
+// cookie returns the session cookie settings, with the current values of the
+// settings which can be changed while the SessionManager is in use.
+func (s *SessionManager) cookie() SessionCookie {
+	c := s.Cookie
+	if hs := s.hotSettings(); hs != nil {
+		c.Secure = hs.secure
+		c.SameSite = hs.sameSite
+	}
+	return c
+}
+
+// CurrentCookie returns the session cookie settings in use, including any
+// changes made with SetCookieSecure and SetCookieSameSite. It is intended for
+// middleware for other frameworks which writes the session cookie itself.
+func (s *SessionManager) CurrentCookie() SessionCookie {
+	return s.cookie()
+}
+
+// companionSecure returns the Secure attribute for a cookie written alongside
+// the session cookie (such as the 'remember me' and CSRF cookies) which is
+// configured with secure. Once the settings have been changed with one of the
+// Set methods the current session cookie setting is used instead, so that
+// the cookie follows changes made with SetCookieSecure.
+func (s *SessionManager) companionSecure(secure bool) bool {
+	if hs := s.hotSettings(); hs != nil {
+		return hs.secure
+	}
+	return secure
+}
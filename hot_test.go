@@ -0,0 +1,67 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHotSettings(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.IdleTimeout = time.Hour
+	s.EncryptionKey = []byte("01234567890123456789012345678901")
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+		s.PutEncryptedString(r.Context(), "secret", "baz")
+	}))
+
+	// Requests are served while the settings change.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		s.SetLifetime(time.Duration(j+1) * time.Hour)
+		s.SetCookieSecure(j%2 == 0)
+	}
+	wg.Wait()
+
+	s.SetLifetime(2 * time.Hour)
+	s.SetIdleTimeout(0)
+	s.SetCookieSecure(true)
+	s.SetCookieSameSite(http.SameSiteStrictMode)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	cookie := rr.Header().Get("Set-Cookie")
+	if !strings.Contains(cookie, "Max-Age=7200") || !strings.Contains(cookie, "Secure") || !strings.Contains(cookie, "SameSite=Strict") {
+		t.Errorf("want cookie with new settings; got %q", cookie)
+	}
+
+	// Values encrypted with the old key can't be read after the key is
+	// rotated.
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutEncryptedString(ctx, "secret", "baz"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetEncryptionKey([]byte("10987654321098765432109876543210"))
+	if _, err := s.GetEncryptedString(ctx, "secret"); err != ErrDecryptionFailed {
+		t.Errorf("want %v; got %v", ErrDecryptionFailed, err)
+	}
+}
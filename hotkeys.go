@@ -0,0 +1,104 @@
+package scs
+
+import "context"
+
+// partialLoadReservedKeys are the reserved keys which are always loaded with
+// a partial load, because they are needed to check the session when it is
+// loaded or by the LoadAndSave middleware.
+var partialLoadReservedKeys = []string{
+	lastActivityKey,
+	tokenIssuedKey,
+	protectedKeysKey,
+	idleLockedKey,
+	authLevelKey,
+	authLevelFallbackKey,
+	authLevelExpiryKey,
+	impersonationOperatorKey,
+	impersonationTargetKey,
+	impersonationExpiryKey,
+	impersonationProtectedKey,
+	tombstoneKey,
+}
+
+// LoadKeys retrieves the session data for the given token from the session
+// store in the same way as Load, except that only the values for keys are
+// loaded if the store implements KeysStore. The rest of the session data is
+// loaded from the store when it is first used, for example by getting a value
+// for another key, by Keys, or by any method which changes the session data.
+// So a request which only reads the given keys needs only a small read from
+// the store, and if the session is committed only to extend its expiry, only
+// the changed values are written. If the store doesn't implement KeysStore,
+// LoadKeys is the same as Load.
+//
+// The LoadAndSave middleware loads sessions with the SessionManager.HotKeys in
+// the same way.
+func (s *SessionManager) LoadKeys(ctx context.Context, token string, keys ...string) (context.Context, error) {
+	if keys == nil {
+		keys = []string{}
+	}
+	return s.load(ctx, token, keys)
+}
+
+// partialLoadKeys returns the keys to load for a partial load of keys, or nil
+// if the full session data must be loaded.
+func (s *SessionManager) partialLoadKeys(keys []string) []string {
+	if keys == nil {
+		return nil
+	}
+	if _, ok := s.Store.(KeysStore); !ok {
+		return nil
+	}
+
+	all := make([]string, 0, 2*len(keys)+len(partialLoadReservedKeys))
+	for _, key := range keys {
+		all = append(all, key, keyExpiryPrefix+key)
+	}
+	return append(all, partialLoadReservedKeys...)
+}
+
+// getSessionDataForKey returns the session data for ctx, first loading the
+// rest of the session data if key wasn't loaded.
+func (s *SessionManager) getSessionDataForKey(ctx context.Context, key string) *sessionData {
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	if sd.partial != nil && !sd.partial[key] {
+		s.completeLoad(ctx, sd)
+	}
+	sd.mu.Unlock()
+
+	return sd
+}
+
+// completeLoad loads the rest of the session data after a partial load. The
+// values which were loaded, and any changes made to them, are kept. If the
+// store returns an error it is held in sd.loadErr and returned when the
+// session is committed, and the session data is left as it was. It must be
+// called with sd.mu held.
+func (s *SessionManager) completeLoad(ctx context.Context, sd *sessionData) {
+	loaded := sd.partial
+	sd.partial = nil
+
+	b, fields, found, err := s.findSession(ctx, sd.token)
+	if err != nil {
+		sd.loadErr = err
+		return
+	} else if !found {
+		// The session has been deleted from the store since it was loaded.
+		return
+	}
+
+	_, values, _, err := s.decodeSession(b, fields)
+	if err != nil {
+		sd.loadErr = err
+		return
+	}
+	for key, val := range values {
+		if !loaded[key] {
+			sd.values[key] = val
+		}
+	}
+	sd.fields, sd.fieldsToken = fields, sd.token
+
+	s.expireKeys(sd)
+}
@@ -0,0 +1,167 @@
+package scs
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keysStore is a KeysStore which counts the calls to FindKeys and FindValues.
+type keysStore struct {
+	*partialStore
+	findKeys   int32
+	findValues int32
+}
+
+func (k *keysStore) FindKeys(token string, keys []string) (map[string][]byte, bool, error) {
+	atomic.AddInt32(&k.findKeys, 1)
+	values, found, err := k.partialStore.FindValues(token)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	subset := make(map[string][]byte)
+	for _, key := range keys {
+		if b, ok := values[key]; ok {
+			subset[key] = b
+		}
+	}
+	return subset, true, nil
+}
+
+func (k *keysStore) FindValues(token string) (map[string][]byte, bool, error) {
+	atomic.AddInt32(&k.findValues, 1)
+	return k.partialStore.FindValues(token)
+}
+
+func (k *keysStore) counts() (int32, int32) {
+	return atomic.LoadInt32(&k.findKeys), atomic.LoadInt32(&k.findValues)
+}
+
+func TestHotKeys(t *testing.T) {
+	t.Parallel()
+
+	store := &keysStore{partialStore: newPartialStore()}
+	s := New()
+	s.Store = store
+	s.HotKeys = []string{"user_id", "locale"}
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/put":
+			s.Put(r.Context(), "user_id", 42)
+			s.Put(r.Context(), "locale", "en")
+			s.Put(r.Context(), "cart", "widget")
+		case "/hot":
+			w.Write([]byte(s.GetString(r.Context(), "locale")))
+		case "/cold":
+			w.Write([]byte(s.GetString(r.Context(), "cart")))
+		case "/template":
+			ts := template.Must(template.New("").Funcs(s.TemplateFuncs(r.Context())).Parse(`{{ sessionString "cart" }}`))
+			ts.Execute(w, nil)
+		case "/update":
+			s.Put(r.Context(), "locale", "fr")
+		}
+	}))
+
+	do := func(path, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if cookie != "" {
+			r.Header.Set("Cookie", "session="+cookie)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	token := extractTokenFromCookie(do("/put", "").Header().Get("Set-Cookie"))
+
+	keys, values := store.counts()
+	rr := do("/hot", token)
+	if rr.Body.String() != "en" {
+		t.Errorf("got %q: expected %q", rr.Body.String(), "en")
+	}
+	if k, v := store.counts(); k != keys+1 || v != values {
+		t.Errorf("want only FindKeys to be called for hot keys; got %d FindKeys and %d FindValues calls", k-keys, v-values)
+	}
+
+	keys, values = store.counts()
+	rr = do("/cold", token)
+	if rr.Body.String() != "widget" {
+		t.Errorf("got %q: expected %q", rr.Body.String(), "widget")
+	}
+	if k, v := store.counts(); k != keys+1 || v != values+1 {
+		t.Errorf("want the full session to be loaded for other keys; got %d FindKeys and %d FindValues calls", k-keys, v-values)
+	}
+
+	do("/update", token)
+	if c := store.lastCommit(); c.replace || len(c.set) != 1 || c.set[0] != "locale" {
+		t.Errorf("want only locale committed; got %+v", c)
+	}
+	rr = do("/cold", token)
+	if rr.Body.String() != "widget" {
+		t.Errorf("got %q: expected %q", rr.Body.String(), "widget")
+	}
+	if rr = do("/hot", token); rr.Body.String() != "fr" {
+		t.Errorf("got %q: expected %q", rr.Body.String(), "fr")
+	}
+	if rr = do("/template", token); rr.Body.String() != "widget" {
+		t.Errorf("got %q: expected %q", rr.Body.String(), "widget")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	t.Parallel()
+
+	store := &keysStore{partialStore: newPartialStore()}
+	s := New()
+	s.Store = store
+	s.IdleTimeout = time.Hour
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "a", "one")
+	s.Put(ctx, "b", "two")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.LoadKeys(context.Background(), token, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetString(ctx, "a"); got != "one" {
+		t.Errorf("got %q: expected %q", got, "one")
+	}
+	if _, values := store.counts(); values != 0 {
+		t.Errorf("got %d: expected no FindValues calls", values)
+	}
+
+	// Extending the idle timeout only writes the activity, without loading
+	// the rest of the session.
+	if s.Status(ctx) != Modified {
+		t.Fatalf("got %v: expected %v", s.Status(ctx), Modified)
+	}
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, values := store.counts(); values != 0 {
+		t.Errorf("got %d: expected no FindValues calls", values)
+	}
+	if c := store.lastCommit(); c.replace || len(c.remove) != 0 {
+		t.Errorf("want no values replaced or removed; got %+v", c)
+	}
+
+	if got := s.Keys(ctx); len(got) != 3 || got[0] != lastActivityKey || got[1] != "a" || got[2] != "b" {
+		t.Errorf("got %v: expected [%s a b]", got, lastActivityKey)
+	}
+	if _, values := store.counts(); values != 1 {
+		t.Errorf("got %d: expected the full session to be loaded", values)
+	}
+}
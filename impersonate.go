@@ -0,0 +1,233 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	impersonationOperatorKey  = "__impersonation.operator"
+	impersonationTargetKey    = "__impersonation.target"
+	impersonationExpiryKey    = "__impersonation.expiry"
+	impersonationProtectedKey = "__impersonation.protected"
+)
+
+// ErrImpersonationDisabled is returned by BeginImpersonation when
+// SessionManager.Impersonation is nil or has no AuditFunc.
+var ErrImpersonationDisabled = errors.New("scs: impersonation is not configured")
+
+// ErrImpersonating is returned by BeginImpersonation when the session is
+// already impersonating a user.
+var ErrImpersonating = errors.New("scs: session is already impersonating a user")
+
+// ErrNotImpersonating is returned by EndImpersonation when the session is not
+// impersonating a user.
+var ErrNotImpersonating = errors.New("scs: session is not impersonating a user")
+
+// ErrNoOperator is returned by BeginImpersonation when the session has no
+// value for SessionManager.UserKey.
+var ErrNoOperator = errors.New("scs: session has no user to impersonate from")
+
+// Impersonation contains the configuration settings for letting operators
+// (such as support staff) act as another user with BeginImpersonation.
+type Impersonation struct {
+	// AuditFunc is called for every impersonation event. It is required:
+	// BeginImpersonation returns ErrImpersonationDisabled if it is nil. If
+	// it returns an error when an impersonation begins or ends, the
+	// impersonation is not begun or ended and the error is returned.
+	// Errors for expired impersonations are logged, and the impersonation
+	// is ended regardless.
+	AuditFunc func(ctx context.Context, event ImpersonationEvent) error
+
+	// Timeout is the maximum length of time an impersonation lasts before
+	// it is automatically ended when the session is next loaded. If zero,
+	// one hour is used.
+	Timeout time.Duration
+}
+
+// ImpersonationEvent describes an impersonation being begun, ended or
+// expired. It is passed to Impersonation.AuditFunc.
+type ImpersonationEvent struct {
+	// Action is one of "begin", "end" or "expire".
+	Action string
+
+	// OperatorID is the value of SessionManager.UserKey for the operator.
+	OperatorID string
+
+	// TargetID is the ID of the user being impersonated.
+	TargetID string
+
+	// Time is when the event happened.
+	Time time.Time
+}
+
+// BeginImpersonation makes the current session act as the user targetUserID,
+// by replacing the value of SessionManager.UserKey, while retaining the
+// original value as the operator identity. The operator identity, and the
+// user key, are protected (see Protect) so that they can't be changed by
+// generic handlers during the impersonation. Impersonation.AuditFunc is
+// called before the impersonation begins, and the session token is renewed.
+// The impersonation ends when EndImpersonation is called, or automatically
+// once Impersonation.Timeout has passed.
+func (s *SessionManager) BeginImpersonation(ctx context.Context, targetUserID string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+	if s.UserKey == "" {
+		return ErrNoUserKey
+	}
+	if s.Impersonation == nil || s.Impersonation.AuditFunc == nil {
+		return ErrImpersonationDisabled
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	operator, exists := sd.values[s.UserKey]
+	_, impersonating := sd.values[impersonationOperatorKey]
+	sd.mu.Unlock()
+
+	if impersonating {
+		return ErrImpersonating
+	} else if !exists {
+		return ErrNoOperator
+	}
+
+	now := time.Now()
+	event := ImpersonationEvent{Action: "begin", OperatorID: fmt.Sprint(operator), TargetID: targetUserID, Time: now}
+	if err := s.Impersonation.AuditFunc(ctx, event); err != nil {
+		return err
+	}
+
+	if err := s.RenewToken(ctx); err != nil {
+		return err
+	}
+
+	timeout := s.Impersonation.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.values[impersonationOperatorKey] = operator
+	sd.values[impersonationTargetKey] = targetUserID
+	sd.values[impersonationExpiryKey] = now.Add(timeout).UnixNano()
+	sd.values[s.UserKey] = targetUserID
+	delete(sd.values, keyExpiryPrefix+s.UserKey)
+	added := protectKeys(sd, s.UserKey, impersonationOperatorKey, impersonationTargetKey, impersonationExpiryKey)
+	sd.values[impersonationProtectedKey] = added
+	sd.status = Modified
+
+	return nil
+}
+
+// EndImpersonation ends an impersonation begun with BeginImpersonation,
+// restoring the operator's value for SessionManager.UserKey and removing the
+// impersonation metadata. Impersonation.AuditFunc is called before the
+// impersonation ends, and the session token is renewed.
+func (s *SessionManager) EndImpersonation(ctx context.Context) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	event, ok := s.impersonationEvent(ctx, "end")
+	if !ok {
+		return ErrNotImpersonating
+	}
+	if s.Impersonation != nil && s.Impersonation.AuditFunc != nil {
+		if err := s.Impersonation.AuditFunc(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := s.RenewToken(ctx); err != nil {
+		return err
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	s.restoreOperator(sd)
+	sd.mu.Unlock()
+
+	return nil
+}
+
+// Impersonator returns the operator identity (the original value of
+// SessionManager.UserKey, formatted as a string) if the current session is
+// impersonating a user, and ok is false otherwise.
+func (s *SessionManager) Impersonator(ctx context.Context) (operatorID string, ok bool) {
+	event, ok := s.impersonationEvent(ctx, "")
+	return event.OperatorID, ok
+}
+
+// impersonationEvent returns an event describing the current impersonation,
+// and whether the session is impersonating a user.
+func (s *SessionManager) impersonationEvent(ctx context.Context, action string) (ImpersonationEvent, bool) {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	operator, ok := sd.values[impersonationOperatorKey]
+	if !ok {
+		return ImpersonationEvent{}, false
+	}
+	target, _ := sd.values[impersonationTargetKey].(string)
+	return ImpersonationEvent{Action: action, OperatorID: fmt.Sprint(operator), TargetID: target, Time: time.Now()}, true
+}
+
+// expireImpersonation ends the impersonation in newly loaded session data if
+// its timeout has passed. The caller must hold sd.mu.
+func (s *SessionManager) expireImpersonation(sd *sessionData) (ImpersonationEvent, bool) {
+	operator, ok := sd.values[impersonationOperatorKey]
+	if !ok {
+		return ImpersonationEvent{}, false
+	}
+	if ns, ok := sd.values[impersonationExpiryKey].(int64); ok && ns > time.Now().UnixNano() {
+		return ImpersonationEvent{}, false
+	}
+
+	target, _ := sd.values[impersonationTargetKey].(string)
+	event := ImpersonationEvent{Action: "expire", OperatorID: fmt.Sprint(operator), TargetID: target, Time: time.Now()}
+
+	status := sd.status
+	s.restoreOperator(sd)
+	if s.ReadOnly {
+		sd.status = status
+	}
+	return event, true
+}
+
+// auditExpiredImpersonation calls Impersonation.AuditFunc for an expired
+// impersonation, logging any error.
+func (s *SessionManager) auditExpiredImpersonation(ctx context.Context, event ImpersonationEvent) {
+	if s.Impersonation == nil || s.Impersonation.AuditFunc == nil {
+		return
+	}
+	if err := s.Impersonation.AuditFunc(ctx, event); err != nil {
+		log.Printf("scs: impersonation audit failed: %v", err)
+	}
+}
+
+// restoreOperator restores the operator's value for SessionManager.UserKey
+// and removes the impersonation metadata. The caller must hold sd.mu.
+func (s *SessionManager) restoreOperator(sd *sessionData) {
+	if operator, ok := sd.values[impersonationOperatorKey]; ok && s.UserKey != "" {
+		sd.values[s.UserKey] = operator
+	}
+
+	added, _ := sd.values[impersonationProtectedKey].([]string)
+	unprotectKeys(sd, added...)
+
+	delete(sd.values, impersonationOperatorKey)
+	delete(sd.values, impersonationTargetKey)
+	delete(sd.values, impersonationExpiryKey)
+	delete(sd.values, impersonationProtectedKey)
+	sd.status = Modified
+}
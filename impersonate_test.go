@@ -0,0 +1,159 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImpersonation(t *testing.T) {
+	t.Parallel()
+
+	var events []ImpersonationEvent
+	s := New()
+	s.UserKey = "userID"
+	s.Impersonation = &Impersonation{
+		AuditFunc: func(ctx context.Context, event ImpersonationEvent) error {
+			events = append(events, event)
+			return nil
+		},
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.BeginImpersonation(ctx, "bob"); !errors.Is(err, ErrNoOperator) {
+		t.Errorf("want %v; got %v", ErrNoOperator, err)
+	}
+	if err := s.EndImpersonation(ctx); !errors.Is(err, ErrNotImpersonating) {
+		t.Errorf("want %v; got %v", ErrNotImpersonating, err)
+	}
+
+	s.Put(ctx, "userID", 7)
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.BeginImpersonation(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Token(ctx) == token {
+		t.Error("want the session token to be renewed")
+	}
+	if got := s.GetString(ctx, "userID"); got != "bob" {
+		t.Errorf("want %q; got %q", "bob", got)
+	}
+	if operator, ok := s.Impersonator(ctx); !ok || operator != "7" {
+		t.Errorf("want %q, true; got %q, %v", "7", operator, ok)
+	}
+	if err := s.TryPut(ctx, "userID", "mallory"); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+	if err := s.TryPut(ctx, impersonationOperatorKey, "mallory"); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+	if err := s.BeginImpersonation(ctx, "carol"); !errors.Is(err, ErrImpersonating) {
+		t.Errorf("want %v; got %v", ErrImpersonating, err)
+	}
+
+	if err := s.EndImpersonation(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetInt(ctx, "userID"); got != 7 {
+		t.Errorf("want %d; got %d", 7, got)
+	}
+	if _, ok := s.Impersonator(ctx); ok {
+		t.Error("want impersonation to have ended")
+	}
+	if s.IsProtected(ctx, "userID") || s.Exists(ctx, protectedKeysKey) {
+		t.Error("want protection added for the impersonation to be removed")
+	}
+
+	if len(events) != 2 || events[0].Action != "begin" || events[1].Action != "end" ||
+		events[1].OperatorID != "7" || events[1].TargetID != "bob" {
+		t.Errorf("want begin and end events; got %+v", events)
+	}
+}
+
+func TestImpersonationExpiry(t *testing.T) {
+	t.Parallel()
+
+	var events []ImpersonationEvent
+	s := New()
+	s.UserKey = "userID"
+	s.Impersonation = &Impersonation{
+		AuditFunc: func(ctx context.Context, event ImpersonationEvent) error {
+			events = append(events, event)
+			return nil
+		},
+		Timeout: 20 * time.Millisecond,
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutProtected(ctx, "userID", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.BeginImpersonation(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetString(ctx, "userID"); got != "alice" {
+		t.Errorf("want %q; got %q", "alice", got)
+	}
+	if !s.IsProtected(ctx, "userID") {
+		t.Error("want userID to remain protected")
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("want status %v; got %v", Modified, s.Status(ctx))
+	}
+	if len(events) != 2 || events[1].Action != "expire" {
+		t.Errorf("want an expire event; got %+v", events)
+	}
+}
+
+func TestImpersonationAuditRequired(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "userID", "alice")
+
+	if err := s.BeginImpersonation(ctx, "bob"); !errors.Is(err, ErrImpersonationDisabled) {
+		t.Errorf("want %v; got %v", ErrImpersonationDisabled, err)
+	}
+
+	auditErr := errors.New("audit log unavailable")
+	s.Impersonation = &Impersonation{
+		AuditFunc: func(ctx context.Context, event ImpersonationEvent) error {
+			return auditErr
+		},
+	}
+	if err := s.BeginImpersonation(ctx, "bob"); !errors.Is(err, auditErr) {
+		t.Errorf("want %v; got %v", auditErr, err)
+	}
+	if got := s.GetString(ctx, "userID"); got != "alice" {
+		t.Errorf("want %q; got %q", "alice", got)
+	}
+}
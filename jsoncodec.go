@@ -0,0 +1,175 @@
+package scs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSONCodec is used for encoding/decoding session data to and from a byte
+// slice as JSON, which is readable by other languages and by people
+// inspecting the session store.
+//
+// JSON has no types for times, durations or binary data, so these are encoded
+// as objects with a single tagged member, following the canonical
+// representation described by the Codec interface:
+//
+//	{"$time": "2024-01-02T15:04:05.999999999Z"}
+//	{"$duration": 1500000000}
+//	{"$bytes": "aGVsbG8="}
+//	{"$strings": ["a", "b"]}
+//
+// String slices are tagged so that they are decoded as []string rather than
+// []interface{}, which is needed for the lists this package stores in the
+// session data (such as protected keys).
+//
+// Numbers without a fractional part are decoded as int (or int64 or float64
+// if they are too large), other numbers as float64, objects as
+// map[string]interface{} and arrays as []interface{}. Other types, such as
+// structs, are encoded using encoding/json and decoded as
+// map[string]interface{}, so they should be stored as JSON or with GobCodec
+// instead.
+type JSONCodec struct{}
+
+// Encode converts a session deadline and values into a byte slice.
+func (JSONCodec) Encode(deadline time.Time, values map[string]interface{}) ([]byte, error) {
+	encoded := make(map[string]interface{}, len(values))
+	for key, val := range values {
+		encoded[key] = jsonEncodeValue(val)
+	}
+
+	aux := struct {
+		Deadline time.Time              `json:"deadline"`
+		Values   map[string]interface{} `json:"values"`
+	}{
+		Deadline: deadline.UTC(),
+		Values:   encoded,
+	}
+
+	return json.Marshal(aux)
+}
+
+// Decode converts a byte slice into a session deadline and values.
+func (JSONCodec) Decode(b []byte) (time.Time, map[string]interface{}, error) {
+	aux := struct {
+		Deadline time.Time              `json:"deadline"`
+		Values   map[string]interface{} `json:"values"`
+	}{}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&aux); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	values := make(map[string]interface{}, len(aux.Values))
+	for key, val := range aux.Values {
+		v, err := jsonDecodeValue(val)
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("scs: unable to decode value for key %q: %w", key, err)
+		}
+		values[key] = v
+	}
+
+	return aux.Deadline.UTC(), values, nil
+}
+
+func jsonEncodeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return map[string]interface{}{"$time": v.UTC().Format(time.RFC3339Nano)}
+	case time.Duration:
+		return map[string]interface{}{"$duration": int64(v)}
+	case []byte:
+		return map[string]interface{}{"$bytes": base64.StdEncoding.EncodeToString(v)}
+	case []string:
+		return map[string]interface{}{"$strings": v}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = jsonEncodeValue(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = jsonEncodeValue(val)
+		}
+		return s
+	}
+	return v
+}
+
+func jsonDecodeValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if int64(int(i)) == i {
+				return int(i), nil
+			}
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if tagged, ok, err := jsonDecodeTagged(v); ok {
+				return tagged, err
+			}
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			dv, err := jsonDecodeValue(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = dv
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			dv, err := jsonDecodeValue(val)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = dv
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+// jsonDecodeTagged decodes an object with a single tagged member, reporting
+// whether the object was tagged.
+func jsonDecodeTagged(v map[string]interface{}) (interface{}, bool, error) {
+	if s, ok := v["$time"].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t.UTC(), true, err
+	}
+	if n, ok := v["$duration"].(json.Number); ok {
+		i, err := n.Int64()
+		return time.Duration(i), true, err
+	}
+	if s, ok := v["$bytes"].(string); ok {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return b, true, err
+	}
+	if list, ok := v["$strings"]; ok {
+		items, _ := list.([]interface{})
+		if items == nil {
+			return []string(nil), true, nil
+		}
+		strs := make([]string, len(items))
+		for i, item := range items {
+			str, ok := item.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("invalid $strings item %v", item)
+			}
+			strs[i] = str
+		}
+		return strs, true, nil
+	}
+	return nil, false, nil
+}
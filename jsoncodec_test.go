@@ -0,0 +1,91 @@
+package scs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONCodec(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	values := map[string]interface{}{
+		"string": "foo",
+		"int":    123,
+		"float":  1.5,
+		"bool":   true,
+		"nil":    nil,
+		"list":   []interface{}{"a", 1},
+		"bytes":  []byte("hello"),
+		"strs":   []string{"a", "b"},
+	}
+
+	var codec JSONCodec
+	b, err := codec.Encode(deadline.In(time.FixedZone("EST", -5*3600)), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `{"$bytes":"aGVsbG8="}`) {
+		t.Errorf("got %s: expected tagged bytes value", b)
+	}
+
+	gotDeadline, got, err := codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotDeadline.Equal(deadline) || gotDeadline.Location() != time.UTC {
+		t.Errorf("got %v: expected %v", gotDeadline, deadline)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("got %#v: expected %#v", got, values)
+	}
+}
+
+func TestJSONCodecInvalidTagged(t *testing.T) {
+	t.Parallel()
+
+	var codec JSONCodec
+	_, _, err := codec.Decode([]byte(`{"deadline":"2030-01-02T03:04:05Z","values":{"t":{"$time":"yesterday"}}}`))
+	if err == nil || !strings.Contains(err.Error(), `"t"`) {
+		t.Errorf("got %v: expected decode error for key t", err)
+	}
+}
+
+func TestJSONCodecSessionManager(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.Codec = JSONCodec{}
+
+	start := time.Date(2024, 5, 6, 7, 8, 9, 10, time.FixedZone("CET", 3600))
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionManager.Put(ctx, "start", start)
+	sessionManager.Put(ctx, "count", 3)
+	if err := sessionManager.Protect(ctx, "count"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = sessionManager.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionManager.GetTime(ctx, "start"); !got.Equal(start) {
+		t.Errorf("got %v: expected %v", got, start)
+	}
+	if got := sessionManager.GetInt(ctx, "count"); got != 3 {
+		t.Errorf("got %d: expected 3", got)
+	}
+	if !sessionManager.IsProtected(ctx, "count") {
+		t.Error("want count to still be protected")
+	}
+}
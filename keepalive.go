@@ -0,0 +1,158 @@
+package scs
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// KeepAlive starts a background goroutine which resets the idle timeout for
+// the current session in the session store every interval, until the returned
+// stop function is called, ctx is cancelled or Shutdown is called. It is
+// intended for long-running requests (such as generating a large report) which
+// might otherwise take longer than the idle timeout, causing the session to
+// expire before the request completes. For example:
+//
+//	func reportHandler(w http.ResponseWriter, r *http.Request) {
+//		defer sessionManager.KeepAlive(r.Context(), time.Minute)()
+//		...
+//	}
+//
+// Only the expiry time of the session in the store is updated; any changes
+// made to the session data by the handler are not committed until the end of
+// the request as usual. If the store implements TouchStore or PartialStore the
+// expiry time is changed without rewriting the session data. Otherwise the
+// stored data is read and written back, unless the SessionManager has
+// committed or deleted the session in the meantime. A commit made at the same
+// time by another instance of the application may still be overwritten.
+// KeepAlive is a no-op if there is no idle timeout or the session has not yet
+// been committed to the store. Errors from the session store are ignored.
+func (s *SessionManager) KeepAlive(ctx context.Context, interval time.Duration) (stop func()) {
+	token := s.Token(ctx)
+	if s.idleTimeout() <= 0 || s.ReadOnly || token == "" {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	s.goBackground(func(shutdown <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.touch(ctx, token)
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-shutdown:
+				return
+			}
+		}
+	})
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// tokenLocks serializes the writes to the store made by a SessionManager, so
+// that touch can write back the session data it read without overwriting a
+// commit or restoring a deleted session. Tokens are spread across a fixed
+// number of stripes, each with a lock and a count of the writes made. A lock
+// is only held while a single store write is made, and never while waiting
+// for a StoreLimit slot.
+type tokenLocks struct {
+	mu  [64]sync.Mutex
+	seq [64]uint64
+}
+
+// stripe returns the index in tl for a session token.
+func (tl *tokenLocks) stripe(token string) int {
+	h := fnv.New32a()
+	h.Write([]byte(token))
+	return int(h.Sum32() % uint32(len(tl.mu)))
+}
+
+// lock locks the stripe for token before a write, and returns the function to
+// unlock it.
+func (tl *tokenLocks) lock(token string) func() {
+	i := tl.stripe(token)
+	tl.mu[i].Lock()
+	tl.seq[i]++
+	return tl.mu[i].Unlock
+}
+
+// count returns the number of writes made to the stripe for token.
+func (tl *tokenLocks) count(token string) uint64 {
+	i := tl.stripe(token)
+	tl.mu[i].Lock()
+	defer tl.mu[i].Unlock()
+	return tl.seq[i]
+}
+
+// lockIfUnchanged locks the stripe for token in the same way as lock, unless
+// there have been writes to the stripe since count returned n.
+func (tl *tokenLocks) lockIfUnchanged(token string, n uint64) (unlock func(), ok bool) {
+	i := tl.stripe(token)
+	tl.mu[i].Lock()
+	if tl.seq[i] != n {
+		tl.mu[i].Unlock()
+		return nil, false
+	}
+	tl.seq[i]++
+	return tl.mu[i].Unlock, true
+}
+
+// touch updates the stored expiry time of a session to a new idle expiry
+// time, without changing the session data. If the data has to be written back
+// and the session is committed or deleted by this SessionManager after it was
+// read, the write is skipped, as the commit has already reset the expiry
+// time.
+func (s *SessionManager) touch(ctx context.Context, token string) error {
+	n := s.tokenLocks.count(token)
+
+	b, fields, found, err := s.findSession(ctx, token)
+	if err != nil || !found {
+		return err
+	}
+
+	deadline, values, _, err := s.decodeSession(b, fields)
+	if err != nil || isTombstone(values) {
+		return err
+	}
+
+	expiry := time.Now().Add(s.idleTimeout()).UTC()
+	if deadline.Before(expiry) {
+		expiry = deadline
+	}
+
+	release, err := s.acquireStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	storeToken := token
+	if s.HashTokenInStore {
+		storeToken = hashToken(storeToken)
+	}
+	if fields != nil {
+		ps, _ := s.partialStore()
+		return ps.CommitValues(storeToken, nil, nil, false, expiry)
+	}
+	if ts, ok := s.Store.(TouchStore); ok {
+		return ts.Touch(storeToken, expiry)
+	}
+
+	unlock, ok := s.tokenLocks.lockIfUnchanged(token, n)
+	if !ok {
+		return nil
+	}
+	defer unlock()
+	return s.storeCommitLocked(ctx, token, b, expiry)
+}
@@ -0,0 +1,151 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.IdleTimeout = 200 * time.Millisecond
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		defer sessionManager.KeepAlive(r.Context(), 50*time.Millisecond)()
+
+		token := sessionManager.Token(r.Context())
+		time.Sleep(500 * time.Millisecond)
+
+		// The session must still exist in the store at the end of the request.
+		if _, found, _ := sessionManager.Store.Find(token); !found {
+			http.Error(w, "session expired", 500)
+			return
+		}
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	})
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	ts.execute(t, "/put")
+
+	_, body := ts.execute(t, "/slow")
+	if body != "bar" {
+		t.Errorf("want %q; got %q", "bar", body)
+	}
+}
+
+func TestKeepAliveConcurrentCommit(t *testing.T) {
+	t.Parallel()
+
+	stores := map[string]Store{
+		"TouchStore": slowFindStore{memstore.NewWithCleanupInterval(0)},
+		"Store":      noAddStore{slowFindStore{memstore.NewWithCleanupInterval(0)}},
+	}
+	for name, store := range stores {
+		s := New()
+		s.Store = store
+		s.IdleTimeout = time.Hour
+
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "foo", "before")
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The commit is made while touch is waiting for the store to return
+		// the data it read, and must not be overwritten with that data.
+		done := make(chan error)
+		go func() { done <- s.touch(context.Background(), token) }()
+		time.Sleep(5 * time.Millisecond)
+
+		s.Put(ctx, "foo", "after")
+		if _, _, err := s.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err = s.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.GetString(ctx, "foo"); got != "after" {
+			t.Errorf("%s: want %q; got %q", name, "after", got)
+		}
+	}
+}
+
+// slowCommitStore is a store which delays Commit, and which doesn't implement
+// any of the optional store interfaces.
+type slowCommitStore struct {
+	Store
+}
+
+func (s slowCommitStore) Commit(token string, b []byte, expiry time.Time) error {
+	time.Sleep(5 * time.Millisecond)
+	return s.Store.Commit(token, b, expiry)
+}
+
+func TestKeepAliveStoreLimit(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Store = slowCommitStore{slowFindStore{memstore.NewWithCleanupInterval(0)}}
+	s.IdleTimeout = time.Hour
+	s.StoreLimit = &StoreLimit{MaxConcurrent: 1}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commits and touches of the same session share the StoreLimit slot,
+	// and must not wait for each other while holding it.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				s.Put(ctx, "foo", i)
+				s.Commit(ctx)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				s.touch(context.Background(), token)
+			}
+		}()
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("commits and touches deadlocked")
+	}
+}
@@ -0,0 +1,98 @@
+package scs
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+const keyExpiryPrefix = "__expires."
+
+// expiredKey is a value removed from the session data by expireKeys.
+type expiredKey struct {
+	key string
+	val interface{}
+}
+
+// PutWithTTL adds a key and corresponding value to the session data, in the
+// same way as TryPut, which is removed from the session data by Load once the
+// ttl has passed. SessionManager.OnKeyExpired is called for each value
+// removed. Replacing the value with Put (or removing it) also removes the
+// ttl. A zero or negative ttl is the same as calling TryPut.
+func (s *SessionManager) PutWithTTL(ctx context.Context, key string, val interface{}, ttl time.Duration) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := s.put(ctx, key, val, false); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	sd.values[keyExpiryPrefix+key] = time.Now().Add(ttl).UnixNano()
+	sd.mu.Unlock()
+	return nil
+}
+
+// KeyExpiry returns the time at which a value added with PutWithTTL will be
+// removed from the session data. The zero time is returned if the key has no
+// ttl.
+func (s *SessionManager) KeyExpiry(ctx context.Context, key string) time.Time {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	ns, ok := sd.values[keyExpiryPrefix+key].(int64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// expireKeys removes values whose ttl has passed from newly loaded session
+// data, and returns them sorted by key. The session data is only marked as
+// Modified if the SessionManager isn't ReadOnly, so that in read-only mode
+// expired values are hidden but left in the store.
+func (s *SessionManager) expireKeys(sd *sessionData) []expiredKey {
+	var expired []expiredKey
+	now := time.Now().UnixNano()
+
+	for k, v := range sd.values {
+		if !strings.HasPrefix(k, keyExpiryPrefix) {
+			continue
+		}
+		ns, ok := v.(int64)
+		if ok && ns > now {
+			continue
+		}
+
+		key := k[len(keyExpiryPrefix):]
+		if val, exists := sd.values[key]; exists {
+			expired = append(expired, expiredKey{key: key, val: val})
+			delete(sd.values, key)
+		}
+		delete(sd.values, k)
+		if !s.ReadOnly {
+			sd.status = Modified
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].key < expired[j].key })
+	return expired
+}
+
+// notifyExpiredKeys calls SessionManager.OnKeyExpired for each expired value.
+func (s *SessionManager) notifyExpiredKeys(ctx context.Context, expired []expiredKey) {
+	if s.OnKeyExpired == nil {
+		return
+	}
+	for _, e := range expired {
+		s.OnKeyExpired(ctx, e.key, e.val)
+	}
+}
@@ -0,0 +1,74 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPutWithTTL(t *testing.T) {
+	t.Parallel()
+
+	type expiry struct {
+		key string
+		val interface{}
+	}
+	var expired []expiry
+
+	s := New()
+	s.OnKeyExpired = func(ctx context.Context, key string, val interface{}) {
+		expired = append(expired, expiry{key, val})
+		if s.Exists(ctx, key) {
+			t.Errorf("want %q to be removed before OnKeyExpired is called", key)
+		}
+		s.Put(ctx, "notice", "invite expired")
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PutWithTTL(ctx, "invite", "abc", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWithTTL(ctx, "replaced", "abc", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWithTTL(ctx, "kept", "abc", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "replaced", "def")
+
+	if s.KeyExpiry(ctx, "invite").IsZero() || !s.KeyExpiry(ctx, "replaced").IsZero() {
+		t.Error("want expiry for invite only")
+	}
+
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expired) != 1 || expired[0].key != "invite" || expired[0].val != "abc" {
+		t.Fatalf("want invite to expire; got %v", expired)
+	}
+	if s.Exists(ctx, "invite") || !s.KeyExpiry(ctx, "invite").IsZero() {
+		t.Error("want invite and its expiry to be removed")
+	}
+	if s.GetString(ctx, "replaced") != "def" || s.GetString(ctx, "kept") != "abc" {
+		t.Error("want replaced and kept values to remain")
+	}
+	if s.GetString(ctx, "notice") != "invite expired" {
+		t.Error("want OnKeyExpired to be able to change the session data")
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("want status %v; got %v", Modified, s.Status(ctx))
+	}
+}
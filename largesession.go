@@ -0,0 +1,68 @@
+package scs
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// LargeSession contains information about a session whose encoded data
+// exceeded SessionManager.LargeSessionThreshold when it was committed.
+type LargeSession struct {
+	// ID is the fingerprint of the session token, as returned by
+	// RedactToken.
+	ID string
+
+	// Size is the size of the encoded session data in bytes.
+	Size int
+
+	// Keys lists the largest session values, largest first. At most
+	// SessionManager.LargeSessionKeys entries are included.
+	Keys []KeySize
+}
+
+// KeySize is the approximate encoded size of a single session value.
+type KeySize struct {
+	Key  string
+	Size int
+}
+
+// largeSession returns information about the session data if its encoded size
+// exceeds LargeSessionThreshold, or nil otherwise. The size of each value is
+// estimated by encoding it on its own with the session Codec.
+func (s *SessionManager) largeSession(storeToken string, size int, values map[string]interface{}) *LargeSession {
+	if s.LargeSessionFunc == nil || s.LargeSessionThreshold <= 0 || size <= s.LargeSessionThreshold {
+		return nil
+	}
+
+	n := s.LargeSessionKeys
+	if n <= 0 {
+		n = 5
+	}
+
+	keys := make([]KeySize, 0, len(values))
+	for key, val := range values {
+		b, err := s.Codec.Encode(time.Time{}, map[string]interface{}{key: val})
+		if err != nil {
+			continue
+		}
+		keys = append(keys, KeySize{Key: key, Size: len(b)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Size != keys[j].Size {
+			return keys[i].Size > keys[j].Size
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	return &LargeSession{ID: s.fingerprint(storeToken), Size: size, Keys: keys}
+}
+
+func (s *SessionManager) reportLargeSession(ctx context.Context, ls *LargeSession) {
+	if ls != nil {
+		s.LargeSessionFunc(ctx, *ls)
+	}
+}
@@ -0,0 +1,57 @@
+package scs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLargeSession(t *testing.T) {
+	t.Parallel()
+
+	var calls []LargeSession
+	s := New()
+	s.LargeSessionThreshold = 1000
+	s.LargeSessionKeys = 2
+	s.LargeSessionFunc = func(ctx context.Context, ls LargeSession) {
+		// The session can be used from the hook.
+		s.Keys(ctx)
+		calls = append(calls, ls)
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "small", "foo")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("want no calls for small session; got %v", calls)
+	}
+
+	s.Put(ctx, "big", strings.Repeat("a", 2000))
+	s.Put(ctx, "medium", strings.Repeat("b", 500))
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("want 1 call; got %d", len(calls))
+	}
+
+	ls := calls[0]
+	if ls.ID != s.RedactToken(token) {
+		t.Errorf("want ID %q; got %q", s.RedactToken(token), ls.ID)
+	}
+	if ls.Size <= 2500 {
+		t.Errorf("want size over 2500; got %d", ls.Size)
+	}
+	if len(ls.Keys) != 2 || ls.Keys[0].Key != "big" || ls.Keys[1].Key != "medium" {
+		t.Fatalf("want keys big and medium; got %v", ls.Keys)
+	}
+	if ls.Keys[0].Size <= 2000 || ls.Keys[0].Size >= ls.Size {
+		t.Errorf("want size of big between 2000 and %d; got %d", ls.Size, ls.Keys[0].Size)
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-var basePrefix string = "scs:session:"
+const basePrefix = "scs:session:"
 
 // LevelDBStore represents the session store.
 type LevelDBStore struct {
@@ -33,6 +33,7 @@ func NewWithCleanupInterval(db *leveldb.DB, cleanupInterval time.Duration) *Leve
 	}
 
 	if cleanupInterval > 0 {
+		bs.stopCleanup = make(chan bool)
 		go bs.startCleanup(cleanupInterval)
 	}
 
@@ -102,12 +103,11 @@ func (ls *LevelDBStore) All() (map[string][]byte, error) {
 }
 
 func (ls *LevelDBStore) startCleanup(cleanupInterval time.Duration) {
-	ls.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(cleanupInterval)
 	for {
 		select {
 		case <-ticker.C:
-			err := ls.deleteExpired()
+			_, err := ls.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -134,19 +134,29 @@ func (ls *LevelDBStore) StopCleanup() {
 	}
 }
 
-func (ls *LevelDBStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (ls *LevelDBStore) DeleteExpired() (int, error) {
+	var expiredKeys [][]byte
 	iter := ls.db.NewIterator(util.BytesPrefix([]byte(basePrefix)), nil)
 	for iter.Next() {
-		key := iter.Key()
 		val := iter.Value()
 		if uint64(time.Now().UnixNano()) > binary.BigEndian.Uint64(val[:8]) {
-			return ls.db.Delete([]byte(key), nil)
+			expiredKeys = append(expiredKeys, append([]byte(nil), iter.Key()...))
 		}
 	}
 	iter.Release()
 	if err := iter.Error(); err != nil {
-		return err
+		return 0, err
+	}
+
+	for i, key := range expiredKeys {
+		if err := ls.db.Delete(key, nil); err != nil {
+			return i, err
+		}
 	}
 
-	return nil
+	return len(expiredKeys), nil
 }
@@ -0,0 +1,118 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const localeKey = "__locale"
+
+type localeContextKey struct{}
+
+// SetLocale stores the user's preferred locale (a BCP 47 language tag such as
+// "en-GB" or "fr") in the session data. Setting the empty string "" removes the
+// preference, so that the locale is negotiated from the Accept-Language header
+// again.
+func (s *SessionManager) SetLocale(ctx context.Context, tag string) {
+	if tag == "" {
+		s.Remove(ctx, localeKey)
+		return
+	}
+	s.Put(ctx, localeKey, tag)
+}
+
+// Locale returns the locale for the current request. This is the preference
+// stored with SetLocale if there is one, and otherwise the locale resolved by
+// the LocaleHandler middleware. It returns the empty string "" if neither is
+// available.
+func (s *SessionManager) Locale(ctx context.Context) string {
+	if tag := s.GetString(ctx, localeKey); tag != "" {
+		return tag
+	}
+	tag, _ := ctx.Value(localeContextKey{}).(string)
+	return tag
+}
+
+// LocaleHandler returns middleware which resolves the locale for each request
+// and makes it available via the Locale method. If the session contains a
+// preference set with SetLocale, that is used. Otherwise the locale is
+// negotiated from the request's Accept-Language header against the supported
+// language tags, falling back to the first supported tag if there is no
+// match. A tag in the header matches a supported tag if they are equal (ignoring
+// case), or if one is a prefix of the other followed by a hyphen (so "en"
+// matches "en-GB", and "en-GB" matches "en"). It must be used inside the
+// LoadAndSave() middleware.
+func (s *SessionManager) LocaleHandler(supported ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tag := s.GetString(r.Context(), localeKey)
+			if tag == "" {
+				tag = negotiateLocale(r.Header.Get("Accept-Language"), supported)
+			}
+
+			ctx := context.WithValue(r.Context(), localeContextKey{}, tag)
+			w.Header().Add("Vary", "Accept-Language")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// negotiateLocale returns the supported tag which best matches the
+// Accept-Language header value, or the first supported tag if none match.
+func negotiateLocale(header string, supported []string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	for _, a := range accepted {
+		// Prefer an exact match over a prefix match.
+		for _, tag := range supported {
+			if strings.EqualFold(a.tag, tag) {
+				return tag
+			}
+		}
+		for _, tag := range supported {
+			if localePrefix(a.tag, tag) || localePrefix(tag, a.tag) {
+				return tag
+			}
+		}
+	}
+
+	if len(supported) > 0 {
+		return supported[0]
+	}
+	return ""
+}
+
+// localePrefix reports whether prefix is a language range prefix of tag (for
+// example "en" and "en-GB").
+func localePrefix(prefix, tag string) bool {
+	return len(tag) > len(prefix) && tag[len(prefix)] == '-' && strings.EqualFold(tag[:len(prefix)], prefix)
+}
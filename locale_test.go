@@ -0,0 +1,79 @@
+package scs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	t.Parallel()
+
+	supported := []string{"en-GB", "fr", "de-DE"}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en-GB"},
+		{"fr", "fr"},
+		{"fr-CA", "fr"},
+		{"de", "de-DE"},
+		{"es, de;q=0.5, fr;q=0.8", "fr"},
+		{"en-gb", "en-GB"},
+		{"fr;q=0, de", "de-DE"},
+		{"ja", "en-GB"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateLocale(tt.header, supported); got != tt.want {
+			t.Errorf("%q: got %q: expected %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleHandler(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/set", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.SetLocale(r.Context(), r.URL.Query().Get("tag"))
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sessionManager.Locale(r.Context())))
+	})
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(sessionManager.LocaleHandler("en", "fr", "de")(mux)))
+	defer ts.Close()
+
+	get := func(acceptLanguage string) string {
+		req, _ := http.NewRequest("GET", ts.URL+"/get", nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if got := get("fr-FR"); got != "fr" {
+		t.Errorf("got %q: expected %q", got, "fr")
+	}
+
+	ts.execute(t, "/set?tag=de")
+	if got := get("fr-FR"); got != "de" {
+		t.Errorf("got %q: expected %q", got, "de")
+	}
+
+	ts.execute(t, "/set?tag=")
+	if got := get("fr-FR"); got != "fr" {
+		t.Errorf("got %q: expected %q", got, "fr")
+	}
+}
@@ -0,0 +1,149 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned by Lock when the lock is held by another request.
+var ErrLocked = errors.New("scs: session lock is held")
+
+// ErrLockNotHeld is returned by Unlock when the lock isn't held by the current
+// request, either because Lock wasn't called or because the lock expired.
+var ErrLockNotHeld = errors.New("scs: session lock is not held")
+
+// lockMu serializes the check and record in Lock and Unlock for stores which
+// don't implement AddStore.
+var lockMu sync.Mutex
+
+// heldLock is a lock acquired by the current request.
+type heldLock struct {
+	key string
+	id  string
+}
+
+// Lock acquires the named lock for the current session, which is released by
+// Unlock or when ttl has passed. It returns ErrLocked if the lock is already
+// held by another request for the same session. It can be used to guard
+// critical sections which must not run concurrently for a session, such as
+// processing a payment when the form is submitted twice. For example:
+//
+//	if err := sessionManager.Lock(r.Context(), "checkout", 30*time.Second); errors.Is(err, scs.ErrLocked) {
+//		http.Error(w, "Your order is already being processed", http.StatusConflict)
+//		return
+//	} else if err != nil {
+//		// Handle the error.
+//	}
+//	defer sessionManager.Unlock(r.Context(), "checkout")
+//
+// Locks are recorded in the session store with the "lock:" prefix. If the
+// store implements AddStore (as memstore and redisstore do) the lock is held
+// across all instances of the application using the store. Otherwise it is
+// only held within a single process. A new session which hasn't been
+// committed can't be shared with other requests, so its locks are held by the
+// current request without using the store.
+func (s *SessionManager) Lock(ctx context.Context, name string, ttl time.Duration) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if _, held := sd.locks[name]; held {
+		return ErrLocked
+	}
+
+	var lock heldLock
+	if sd.token != "" {
+		id, err := randomString(16)
+		if err != nil {
+			return err
+		}
+		lock = heldLock{key: "lock:" + hashToken(sd.token+"\x00"+name), id: id}
+
+		expiry := time.Now().Add(ttl)
+		b, err := s.encode(expiry.UTC(), map[string]interface{}{"id": id})
+		if err != nil {
+			return err
+		}
+		if err := s.addLock(ctx, lock.key, b, expiry); err != nil {
+			return err
+		}
+	}
+
+	if sd.locks == nil {
+		sd.locks = make(map[string]heldLock)
+	}
+	sd.locks[name] = lock
+	return nil
+}
+
+// Unlock releases the named lock acquired by Lock. It returns ErrLockNotHeld
+// if the lock wasn't acquired by the current request, or has expired. Unlock
+// should be called well before the ttl passed to Lock, as a lock which
+// expires while it is being released may be released after it has been
+// acquired by another request.
+func (s *SessionManager) Unlock(ctx context.Context, name string) error {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	lock, held := sd.locks[name]
+	if !held {
+		return ErrLockNotHeld
+	}
+	delete(sd.locks, name)
+	if lock.key == "" {
+		return nil
+	}
+
+	if _, ok := s.Store.(AddStore); !ok {
+		lockMu.Lock()
+		defer lockMu.Unlock()
+	}
+
+	b, found, err := storeFind(ctx, s.Store, lock.key)
+	if err != nil {
+		return err
+	} else if !found {
+		return ErrLockNotHeld
+	}
+	_, values, err := s.decode(b)
+	if err != nil {
+		return err
+	} else if id, _ := values["id"].(string); id != lock.id {
+		return ErrLockNotHeld
+	}
+	return storeDelete(ctx, s.Store, lock.key)
+}
+
+// addLock records a lock in the store, returning ErrLocked if it is already
+// recorded.
+func (s *SessionManager) addLock(ctx context.Context, key string, b []byte, expiry time.Time) error {
+	if as, ok := s.Store.(AddStore); ok {
+		added, err := as.Add(key, b, expiry)
+		if err != nil {
+			return err
+		} else if !added {
+			return ErrLocked
+		}
+		return nil
+	}
+
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	_, found, err := storeFind(ctx, s.Store, key)
+	if err != nil {
+		return err
+	} else if found {
+		return ErrLocked
+	}
+	return storeCommit(ctx, s.Store, key, b, expiry)
+}
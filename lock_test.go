@@ -0,0 +1,167 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	for name, store := range map[string]Store{
+		"memstore":   New().Store,
+		"noaddstore": noAddStore{New().Store},
+	} {
+		s := New()
+		s.Store = store
+
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "foo", "bar")
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var acquired int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, err := s.Load(context.Background(), token)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				err = s.Lock(ctx, "checkout", time.Minute)
+				if err == nil {
+					atomic.AddInt32(&acquired, 1)
+				} else if !errors.Is(err, ErrLocked) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if acquired != 1 {
+			t.Errorf("%s: want lock acquired once; got %d", name, acquired)
+		}
+
+		// The lock for another name is independent.
+		if err := s.Lock(ctx, "other", time.Minute); err != nil {
+			t.Errorf("%s: want nil error for other lock; got %v", name, err)
+		}
+		if err := s.Unlock(ctx, "other"); err != nil {
+			t.Errorf("%s: want nil error for Unlock; got %v", name, err)
+		}
+
+		// A request which doesn't hold the lock can't release it.
+		if err := s.Unlock(ctx, "checkout"); !errors.Is(err, ErrLockNotHeld) {
+			t.Errorf("%s: got %v: expected %v", name, err, ErrLockNotHeld)
+		}
+		if err := s.Lock(ctx, "checkout", time.Minute); !errors.Is(err, ErrLocked) {
+			t.Errorf("%s: got %v: expected %v", name, err, ErrLocked)
+		}
+	}
+}
+
+func TestLockUnlock(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, _ = s.Load(context.Background(), token)
+	if err := s.Lock(ctx, "checkout", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "checkout", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("got %v: expected %v", err, ErrLocked)
+	}
+	if err := s.Unlock(ctx, "checkout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock(ctx, "checkout"); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("got %v: expected %v", err, ErrLockNotHeld)
+	}
+
+	other, _ := s.Load(context.Background(), token)
+	if err := s.Lock(other, "checkout", time.Minute); err != nil {
+		t.Errorf("want lock to be acquired after Unlock; got %v", err)
+	}
+}
+
+func TestLockExpired(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, _ := s.Load(context.Background(), "")
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, _ = s.Load(context.Background(), token)
+	if err := s.Lock(ctx, "checkout", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := s.Load(context.Background(), token)
+	if err := s.Lock(other, "checkout", time.Minute); err != nil {
+		t.Fatalf("want expired lock to be acquired; got %v", err)
+	}
+	if err := s.Unlock(ctx, "checkout"); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("got %v: expected %v", err, ErrLockNotHeld)
+	}
+	if err := s.Unlock(other, "checkout"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockNewSession(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, _ := s.Load(context.Background(), "")
+	if err := s.Lock(ctx, "checkout", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Lock(ctx, "checkout", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("got %v: expected %v", err, ErrLocked)
+	}
+	if err := s.Unlock(ctx, "checkout"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.ReadOnly = true
+	ctx, _ := s.Load(context.Background(), "")
+	if err := s.Lock(ctx, "checkout", time.Minute); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got %v: expected %v", err, ErrReadOnly)
+	}
+}
+
+// noAddStore wraps a store, hiding its Add method if it implements AddStore.
+type noAddStore struct {
+	Store
+}
@@ -15,6 +15,9 @@ type MemStore struct {
 	items       map[string]item
 	mu          sync.RWMutex
 	stopCleanup chan bool
+
+	snapshotPath  string
+	stopSnapshots chan bool
 }
 
 // New returns a new MemStore instance, with a background cleanup goroutine that
@@ -33,6 +36,7 @@ func NewWithCleanupInterval(cleanupInterval time.Duration) *MemStore {
 	}
 
 	if cleanupInterval > 0 {
+		m.stopCleanup = make(chan bool)
 		go m.startCleanup(cleanupInterval)
 	}
 
@@ -72,6 +76,39 @@ func (m *MemStore) Commit(token string, b []byte, expiry time.Time) error {
 	return nil
 }
 
+// Add adds a session token and data to the MemStore instance with the given
+// expiry time, but only if the token doesn't already exist (or has expired).
+// It reports whether the data was added.
+func (m *MemStore) Add(token string, b []byte, expiry time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, found := m.items[token]; found && time.Now().UnixNano() <= item.expiration {
+		return false, nil
+	}
+	m.items[token] = item{
+		object:     b,
+		expiration: expiry.UnixNano(),
+	}
+
+	return true, nil
+}
+
+// Touch updates the expiry time of a session token in the MemStore instance,
+// leaving its data unchanged. It does nothing if the token doesn't exist (or
+// has expired).
+func (m *MemStore) Touch(token string, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, found := m.items[token]; found && time.Now().UnixNano() <= item.expiration {
+		item.expiration = expiry.UnixNano()
+		m.items[token] = item
+	}
+
+	return nil
+}
+
 // Delete removes a session token and corresponding data from the MemStore
 // instance.
 func (m *MemStore) Delete(token string) error {
@@ -100,12 +137,11 @@ func (m *MemStore) All() (map[string][]byte, error) {
 }
 
 func (m *MemStore) startCleanup(interval time.Duration) {
-	m.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			m.deleteExpired()
+			m.DeleteExpired()
 		case <-m.stopCleanup:
 			ticker.Stop()
 			return
@@ -126,16 +162,35 @@ func (m *MemStore) startCleanup(interval time.Duration) {
 func (m *MemStore) StopCleanup() {
 	if m.stopCleanup != nil {
 		m.stopCleanup <- true
+		m.stopCleanup = nil
 	}
 }
 
-func (m *MemStore) deleteExpired() {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (m *MemStore) DeleteExpired() (int, error) {
+	return m.DeleteExpiredFunc(nil)
+}
+
+// DeleteExpiredFunc deletes all expired sessions from the store in the same
+// way as DeleteExpired, calling fn (if it is not nil) with the token and data
+// of each session deleted. It allows the SessionManager's GCWithStats method
+// to count the sessions by the reason they expired.
+func (m *MemStore) DeleteExpiredFunc(fn func(token string, b []byte)) (int, error) {
+	n := 0
 	now := time.Now().UnixNano()
 	m.mu.Lock()
 	for token, item := range m.items {
 		if now > item.expiration {
 			delete(m.items, token)
+			if fn != nil {
+				fn(token, item.object)
+			}
+			n++
 		}
 	}
 	m.mu.Unlock()
+	return n, nil
 }
@@ -2,6 +2,8 @@ package memstore
 
 import (
 	"bytes"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -73,6 +75,67 @@ func TestCommitUpdated(t *testing.T) {
 	}
 }
 
+func TestAdd(t *testing.T) {
+	m := NewWithCleanupInterval(0)
+
+	added, err := m.Add("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("got %v: expected %v", err, nil)
+	}
+	if added != true {
+		t.Fatalf("got %v: expected %v", added, true)
+	}
+
+	added, err = m.Add("session_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("got %v: expected %v", err, nil)
+	}
+	if added != false {
+		t.Fatalf("got %v: expected %v", added, false)
+	}
+
+	v := m.items["session_token"].object
+	if reflect.DeepEqual(v, []byte("encoded_data")) == false {
+		t.Fatalf("got %v: expected %v", v, []byte("encoded_data"))
+	}
+
+	m.Commit("expired_token", []byte("encoded_data"), time.Now().Add(-time.Minute))
+	added, err = m.Add("expired_token", []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("got %v: expected %v", err, nil)
+	}
+	if added != true {
+		t.Fatalf("got %v: expected %v", added, true)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	m := NewWithCleanupInterval(0)
+
+	m.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	expiry := time.Now().Add(time.Hour)
+	err := m.Touch("session_token", expiry)
+	if err != nil {
+		t.Fatalf("got %v: expected %v", err, nil)
+	}
+
+	item := m.items["session_token"]
+	if item.expiration != expiry.UnixNano() {
+		t.Fatalf("got %v: expected %v", item.expiration, expiry.UnixNano())
+	}
+	if reflect.DeepEqual(item.object, []byte("encoded_data")) == false {
+		t.Fatalf("got %v: expected %v", item.object, []byte("encoded_data"))
+	}
+
+	err = m.Touch("missing_token", expiry)
+	if err != nil {
+		t.Fatalf("got %v: expected %v", err, nil)
+	}
+	if _, found := m.items["missing_token"]; found {
+		t.Fatalf("got %v: expected %v", found, false)
+	}
+}
+
 func TestExpiry(t *testing.T) {
 	m := NewWithCleanupInterval(0)
 
@@ -111,16 +174,115 @@ func TestDelete(t *testing.T) {
 func TestCleanupInterval(t *testing.T) {
 	m := NewWithCleanupInterval(100 * time.Millisecond)
 	defer m.StopCleanup()
+	m.mu.Lock()
 	m.items["session_token"] = item{object: []byte("encoded_data"), expiration: time.Now().Add(500 * time.Millisecond).UnixNano()}
+	m.mu.Unlock()
 
+	m.mu.RLock()
 	_, ok := m.items["session_token"]
+	m.mu.RUnlock()
 	if !ok {
 		t.Fatalf("got %v: expected %v", ok, true)
 	}
 
 	time.Sleep(time.Second)
+	m.mu.RLock()
 	_, ok = m.items["session_token"]
+	m.mu.RUnlock()
 	if ok {
 		t.Fatalf("got %v: expected %v", ok, false)
 	}
 }
+
+func TestDeleteExpiredFunc(t *testing.T) {
+	m := NewWithCleanupInterval(0)
+	m.items["expired_token"] = item{object: []byte("expired_data"), expiration: time.Now().Add(-time.Second).UnixNano()}
+	m.items["session_token"] = item{object: []byte("encoded_data"), expiration: time.Now().Add(time.Minute).UnixNano()}
+
+	deleted := make(map[string][]byte)
+	n, err := m.DeleteExpiredFunc(func(token string, b []byte) {
+		deleted[token] = b
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d: expected %d", n, 1)
+	}
+	if !reflect.DeepEqual(deleted, map[string][]byte{"expired_token": []byte("expired_data")}) {
+		t.Fatalf("got %v: expected expired_token to be reported", deleted)
+	}
+	if _, ok := m.items["session_token"]; !ok {
+		t.Fatal("want session_token to be kept")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.gob")
+
+	m, err := NewWithSnapshot(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	m.Commit("expired_token", []byte("encoded_data"), time.Now().Add(-time.Minute))
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = NewWithSnapshot(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	b, found, err := m.Find("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || !bytes.Equal(b, []byte("encoded_data")) {
+		t.Errorf("got %v %q: expected %v %q", found, b, true, "encoded_data")
+	}
+	if _, ok := m.items["expired_token"]; ok {
+		t.Error("expected expired session not to be restored")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*tmp*"))
+	if len(matches) != 0 {
+		t.Errorf("expected temporary files to be removed; got %v", matches)
+	}
+}
+
+func TestSnapshotInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.gob")
+
+	m, err := NewWithSnapshot(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	m.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	time.Sleep(100 * time.Millisecond)
+
+	m2, err := NewWithSnapshot(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m2.StopCleanup()
+
+	if _, found, _ := m2.Find("session_token"); !found {
+		t.Error("expected session to be restored from periodic snapshot")
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.gob")
+	if err := ioutil.WriteFile(path, []byte("corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWithSnapshot(path, 0); err == nil {
+		t.Error("expected error loading corrupt snapshot")
+	}
+}
@@ -0,0 +1,143 @@
+package memstore
+
+import (
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type snapshotItem struct {
+	Object     []byte
+	Expiration int64
+}
+
+// NewWithSnapshot returns a new MemStore instance which persists its session
+// data to the file at path, so that sessions survive an application restart.
+// Any existing snapshot in the file is loaded (ignoring expired sessions)
+// before NewWithSnapshot returns, and a new snapshot is written every
+// snapshotInterval by a background goroutine. Setting snapshotInterval to 0
+// disables the periodic snapshots; you can still write one by calling
+// Snapshot() or Close().
+//
+// Expired session data is removed by a background cleanup goroutine which runs
+// every minute, as with New().
+//
+// This is intended as a middle ground for development servers and small
+// deployments. Sessions created or modified since the last snapshot are lost
+// if the application exits without calling Close().
+func NewWithSnapshot(path string, snapshotInterval time.Duration) (*MemStore, error) {
+	m := NewWithCleanupInterval(time.Minute)
+	m.snapshotPath = path
+
+	if err := m.loadSnapshot(); err != nil {
+		m.StopCleanup()
+		return nil, err
+	}
+
+	if snapshotInterval > 0 {
+		m.stopSnapshots = make(chan bool)
+		go m.startSnapshots(snapshotInterval)
+	}
+
+	return m, nil
+}
+
+// Snapshot writes all active (i.e. not expired) sessions to the snapshot file.
+// The file is written atomically, by writing to a temporary file in the same
+// directory and renaming it, so a crash during a snapshot never leaves a
+// partially written file. It does nothing if the MemStore was not created by
+// NewWithSnapshot.
+func (m *MemStore) Snapshot() error {
+	if m.snapshotPath == "" {
+		return nil
+	}
+
+	now := time.Now().UnixNano()
+	m.mu.RLock()
+	items := make(map[string]snapshotItem, len(m.items))
+	for token, item := range m.items {
+		if item.expiration > now {
+			items[token] = snapshotItem{Object: item.object, Expiration: item.expiration}
+		}
+	}
+	m.mu.RUnlock()
+
+	dir, name := filepath.Split(m.snapshotPath)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := gob.NewEncoder(f).Encode(items); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), m.snapshotPath)
+}
+
+// Close stops the background snapshot and cleanup goroutines and writes a
+// final snapshot. It should be called when the application shuts down.
+func (m *MemStore) Close() error {
+	if m.stopSnapshots != nil {
+		m.stopSnapshots <- true
+		m.stopSnapshots = nil
+	}
+	m.StopCleanup()
+
+	return m.Snapshot()
+}
+
+func (m *MemStore) loadSnapshot() error {
+	f, err := os.Open(m.snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var items map[string]snapshotItem
+	if err := gob.NewDecoder(f).Decode(&items); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	m.mu.Lock()
+	for token, si := range items {
+		if si.Expiration > now {
+			m.items[token] = item{object: si.Object, expiration: si.Expiration}
+		}
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *MemStore) startSnapshots(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ticker.C:
+			// Errors are ignored; the next snapshot will be attempted at the
+			// next interval, and Close() reports any error from the final one.
+			m.Snapshot()
+		case <-m.stopSnapshots:
+			ticker.Stop()
+			return
+		}
+	}
+}
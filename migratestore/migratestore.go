@@ -0,0 +1,208 @@
+// Package migratestore provides a session store which wraps two other session
+// stores, so that sessions can be moved from one to the other without logging
+// users out.
+package migratestore
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Store is the interface for the wrapped session stores. It has the same
+// methods as scs.Store.
+type Store interface {
+	Delete(token string) (err error)
+	Find(token string) (b []byte, found bool, err error)
+	Commit(token string, b []byte, expiry time.Time) (err error)
+}
+
+// ReadPreference controls which of the wrapped stores is read first.
+type ReadPreference int
+
+const (
+	// PreferNew reads from the new store, falling back to the old store if
+	// the session isn't found. This is the usual setting once the new store
+	// has been verified.
+	PreferNew ReadPreference = iota
+
+	// PreferOld reads from the old store, falling back to the new store if
+	// the session isn't found. This is useful at the start of a migration,
+	// while the new store is being populated.
+	PreferOld
+)
+
+// Stats contains counters for the reads made by a MigrateStore. The
+// proportion of sessions still only found in the old store is OldHits divided
+// by Reads; when it stays at zero for longer than the session lifetime it is
+// safe to cut over to the new store.
+type Stats struct {
+	// Reads is the number of calls to Find.
+	Reads uint64
+
+	// NewHits is the number of sessions found in the new store.
+	NewHits uint64
+
+	// OldHits is the number of sessions found in the old store.
+	OldHits uint64
+
+	// Misses is the number of sessions found in neither store.
+	Misses uint64
+}
+
+// MigrateStore represents the session store.
+type MigrateStore struct {
+	// The counters are first to keep them 64-bit aligned for atomic access.
+	reads, newHits, oldHits, misses uint64
+
+	old, new Store
+	prefer   ReadPreference
+}
+
+// New returns a new MigrateStore instance which migrates sessions from old to
+// new. Commits and deletes are written to both stores, and sessions are read
+// from the store given by prefer, with a fallback to the other. Once every
+// active session has been committed at least once since the MigrateStore was
+// introduced, the old store can be removed.
+func New(old, new Store, prefer ReadPreference) *MigrateStore {
+	return &MigrateStore{old: old, new: new, prefer: prefer}
+}
+
+// Find returns the data for a given session token from the preferred store,
+// or from the other store if it isn't found.
+func (m *MigrateStore) Find(token string) ([]byte, bool, error) {
+	atomic.AddUint64(&m.reads, 1)
+
+	first, second := m.new, m.old
+	firstHits, secondHits := &m.newHits, &m.oldHits
+	if m.prefer == PreferOld {
+		first, second = second, first
+		firstHits, secondHits = secondHits, firstHits
+	}
+
+	b, found, err := first.Find(token)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		atomic.AddUint64(firstHits, 1)
+		return b, true, nil
+	}
+
+	b, found, err = second.Find(token)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		atomic.AddUint64(secondHits, 1)
+		return b, true, nil
+	}
+
+	atomic.AddUint64(&m.misses, 1)
+	return nil, false, nil
+}
+
+// Commit adds a session token and data to both stores with the given expiry
+// time. If either commit fails, the first error is returned.
+func (m *MigrateStore) Commit(token string, b []byte, expiry time.Time) error {
+	errNew := m.new.Commit(token, b, expiry)
+	errOld := m.old.Commit(token, b, expiry)
+	if errNew != nil {
+		return errNew
+	}
+	return errOld
+}
+
+// Delete removes a session token and corresponding data from both stores. If
+// either delete fails, the first error is returned.
+func (m *MigrateStore) Delete(token string) error {
+	errNew := m.new.Delete(token)
+	errOld := m.old.Delete(token)
+	if errNew != nil {
+		return errNew
+	}
+	return errOld
+}
+
+// All returns a map containing the token and data for all active sessions in
+// both stores. Where a session is in both stores, the data from the preferred
+// store is used. It returns an error if either store doesn't support
+// iteration.
+func (m *MigrateStore) All() (map[string][]byte, error) {
+	type iterable interface {
+		All() (map[string][]byte, error)
+	}
+	newStore, ok1 := m.new.(iterable)
+	oldStore, ok2 := m.old.(iterable)
+	if !ok1 || !ok2 {
+		return nil, errors.New("migratestore: store does not support iteration")
+	}
+
+	first, second := iterable(newStore), iterable(oldStore)
+	if m.prefer == PreferOld {
+		first, second = second, first
+	}
+
+	mm, err := second.All()
+	if err != nil {
+		return nil, err
+	}
+	preferred, err := first.All()
+	if err != nil {
+		return nil, err
+	}
+	for token, b := range preferred {
+		mm[token] = b
+	}
+	return mm, nil
+}
+
+// DeleteExpired deletes expired sessions from each store which implements a
+// DeleteExpired method, and returns the total number deleted.
+func (m *MigrateStore) DeleteExpired() (int, error) {
+	total := 0
+	for _, store := range []Store{m.new, m.old} {
+		s, ok := store.(interface {
+			DeleteExpired() (int, error)
+		})
+		if !ok {
+			continue
+		}
+		n, err := s.DeleteExpired()
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Close closes each store which has a Close() error method, or otherwise
+// stops its cleanup goroutine if it has a StopCleanup() method. If closing
+// either store fails, the first error is returned.
+func (m *MigrateStore) Close() error {
+	var first error
+	for _, store := range []Store{m.new, m.old} {
+		var err error
+		switch s := store.(type) {
+		case interface{ Close() error }:
+			err = s.Close()
+		case interface{ StopCleanup() }:
+			s.StopCleanup()
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Stats returns the read counters for the MigrateStore.
+func (m *MigrateStore) Stats() Stats {
+	return Stats{
+		Reads:   atomic.LoadUint64(&m.reads),
+		NewHits: atomic.LoadUint64(&m.newHits),
+		OldHits: atomic.LoadUint64(&m.oldHits),
+		Misses:  atomic.LoadUint64(&m.misses),
+	}
+}
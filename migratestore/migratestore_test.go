@@ -0,0 +1,77 @@
+package migratestore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestFind(t *testing.T) {
+	oldStore := memstore.NewWithCleanupInterval(0)
+	newStore := memstore.NewWithCleanupInterval(0)
+	m := New(oldStore, newStore, PreferNew)
+
+	expiry := time.Now().Add(time.Minute)
+	oldStore.Commit("old_token", []byte("old"), expiry)
+	newStore.Commit("both_token", []byte("new"), expiry)
+	oldStore.Commit("both_token", []byte("stale"), expiry)
+
+	tests := map[string][]byte{
+		"old_token":     []byte("old"),
+		"both_token":    []byte("new"),
+		"missing_token": nil,
+	}
+	for token, want := range tests {
+		b, found, err := m.Find(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != (want != nil) || !bytes.Equal(b, want) {
+			t.Errorf("%s: want %q; got %q (found %v)", token, want, b, found)
+		}
+	}
+
+	want := Stats{Reads: 3, NewHits: 1, OldHits: 1, Misses: 1}
+	if got := m.Stats(); got != want {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+
+	m = New(oldStore, newStore, PreferOld)
+	if b, _, _ := m.Find("both_token"); string(b) != "stale" {
+		t.Errorf("want %q; got %q", "stale", b)
+	}
+}
+
+func TestCommitAndDelete(t *testing.T) {
+	oldStore := memstore.NewWithCleanupInterval(0)
+	newStore := memstore.NewWithCleanupInterval(0)
+	m := New(oldStore, newStore, PreferNew)
+
+	if err := m.Commit("session_token", []byte("data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"old": oldStore, "new": newStore} {
+		if _, found, _ := s.Find("session_token"); !found {
+			t.Errorf("want session committed to %s store", name)
+		}
+	}
+
+	all, err := m.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("want 1 session; got %d", len(all))
+	}
+
+	if err := m.Delete("session_token"); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"old": oldStore, "new": newStore} {
+		if _, found, _ := s.Find("session_token"); found {
+			t.Errorf("want session deleted from %s store", name)
+		}
+	}
+}
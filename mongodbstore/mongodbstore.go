@@ -40,6 +40,7 @@ func NewWithCleanupInterval(db *mongo.Database, cleanupInterval time.Duration) *
 	}
 
 	if cleanupInterval > 0 {
+		m.stopCleanup = make(chan bool)
 		go m.startCleanup(cleanupInterval)
 	}
 
@@ -140,12 +141,11 @@ func (m *MongoDBStore) All() (map[string][]byte, error) {
 }
 
 func (m *MongoDBStore) startCleanup(cleanupInterval time.Duration) {
-	m.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(cleanupInterval)
 	for {
 		select {
 		case <-ticker.C:
-			err := m.deleteExpired()
+			_, err := m.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -172,13 +172,17 @@ func (m *MongoDBStore) StopCleanup() {
 	}
 }
 
-func (m *MongoDBStore) deleteExpired() error {
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (m *MongoDBStore) DeleteExpired() (int, error) {
 	now := time.Now().UnixNano()
 	filter := bson.M{"expiration": bson.M{"$lt": now}}
-	_, err := m.collection.DeleteMany(context.Background(), filter, nil)
+	res, err := m.collection.DeleteMany(context.Background(), filter, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return int(res.DeletedCount), nil
 }
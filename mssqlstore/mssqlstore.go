@@ -3,13 +3,31 @@ package mssqlstore
 import (
 	"database/sql"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultBatchSize is the default maximum number of expired sessions deleted
+// by each statement run by DeleteExpired.
+const defaultBatchSize = 1000
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
 // MSSQLStore represents the session store.
 type MSSQLStore struct {
 	db          *sql.DB
 	stopCleanup chan bool
+
+	// batchSize is the maximum number of expired sessions deleted by each
+	// statement run by DeleteExpired.
+	batchSize int32
+
+	stmts  map[stmtKey]*sql.Stmt
+	stmtMu sync.Mutex
 }
 
 // New returns a new MSSQLStore instance, with a background cleanup goroutine
@@ -23,8 +41,9 @@ func New(db *sql.DB) *MSSQLStore {
 // background cleanup goroutine. Setting it to 0 prevents the cleanup goroutine
 // from running (i.e. expired sessions will not be removed).
 func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *MSSQLStore {
-	m := &MSSQLStore{db: db}
+	m := &MSSQLStore{db: db, batchSize: defaultBatchSize}
 	if cleanupInterval > 0 {
+		m.stopCleanup = make(chan bool)
 		go m.startCleanup(cleanupInterval)
 	}
 	return m
@@ -34,7 +53,7 @@ func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *MSSQLSto
 // If the session token is not found or is expired, the returned exists flag will
 // be set to false.
 func (m *MSSQLStore) Find(token string) (b []byte, exists bool, err error) {
-	row := m.db.QueryRow("SELECT data FROM sessions WHERE token = @p1 AND GETUTCDATE() < expiry", token)
+	row := m.queryRow(m.db, "SELECT data FROM sessions WHERE token = @p1 AND GETUTCDATE() < expiry", token)
 	err = row.Scan(&b)
 	if err == sql.ErrNoRows {
 		return nil, false, nil
@@ -48,7 +67,7 @@ func (m *MSSQLStore) Find(token string) (b []byte, exists bool, err error) {
 // given expiry time. If the session token already exists, then the data and expiry
 // time are updated.
 func (m *MSSQLStore) Commit(token string, b []byte, expiry time.Time) error {
-	_, err := m.db.Exec(`MERGE INTO sessions WITH (HOLDLOCK) AS T USING (VALUES(@p1)) AS S (token) ON (T.token = S.token)
+	_, err := m.exec(m.db, `MERGE INTO sessions WITH (HOLDLOCK) AS T USING (VALUES(@p1)) AS S (token) ON (T.token = S.token)
 						 WHEN MATCHED THEN UPDATE SET data = @p2, expiry = @p3
 						 WHEN NOT MATCHED THEN INSERT (token, data, expiry) VALUES(@p1, @p2, @p3);`, token, b, expiry.UTC())
 	if err != nil {
@@ -60,7 +79,7 @@ func (m *MSSQLStore) Commit(token string, b []byte, expiry time.Time) error {
 // Delete removes a session token and corresponding data from the MSSQLStore
 // instance.
 func (m *MSSQLStore) Delete(token string) error {
-	_, err := m.db.Exec("DELETE FROM sessions WHERE token = @p1", token)
+	_, err := m.exec(m.db, "DELETE FROM sessions WHERE token = @p1", token)
 	return err
 }
 
@@ -98,12 +117,11 @@ func (m *MSSQLStore) All() (map[string][]byte, error) {
 }
 
 func (m *MSSQLStore) startCleanup(interval time.Duration) {
-	m.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := m.deleteExpired()
+			_, err := m.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -130,7 +148,103 @@ func (m *MSSQLStore) StopCleanup() {
 	}
 }
 
-func (m *MSSQLStore) deleteExpired() error {
-	_, err := m.db.Exec("DELETE FROM sessions WHERE expiry < GETUTCDATE()")
+// Close stops the background cleanup goroutine, if it is running, and closes
+// the prepared statements used by the MSSQLStore. It does not close the
+// underlying database connection pool. The MSSQLStore can still be used after
+// Close, but statements will be prepared again.
+func (m *MSSQLStore) Close() error {
+	m.StopCleanup()
+	m.stopCleanup = nil
+
+	m.stmtMu.Lock()
+	defer m.stmtMu.Unlock()
+
+	var err error
+	for _, stmt := range m.stmts {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.stmts = nil
 	return err
 }
+
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled. Expired sessions are deleted in batches (see
+// SetCleanupBatchSize), so that each statement only holds locks briefly.
+func (m *MSSQLStore) DeleteExpired() (int, error) {
+	batchSize := atomic.LoadInt32(&m.batchSize)
+	if batchSize <= 0 {
+		res, err := m.db.Exec("DELETE FROM sessions WHERE expiry < GETUTCDATE()")
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		return int(n), err
+	}
+
+	total := 0
+	for {
+		res, err := m.exec(m.db, "DELETE TOP (@p1) FROM sessions WHERE expiry < GETUTCDATE()", batchSize)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		total += int(n)
+		if err != nil || n < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// SetCleanupBatchSize sets the maximum number of expired sessions deleted by
+// each statement run by DeleteExpired. The default is 1000. Setting it to 0
+// deletes all expired sessions with a single statement, which can lock a large
+// sessions table for a long time.
+func (m *MSSQLStore) SetCleanupBatchSize(n int) {
+	atomic.StoreInt32(&m.batchSize, int32(n))
+}
+
+// exec runs a statement on db using a cached prepared statement.
+func (m *MSSQLStore) exec(db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := m.prepare(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// queryRow runs a query on db using a cached prepared statement. If the
+// statement can't be prepared, the query is run directly so that the error is
+// returned by Scan.
+func (m *MSSQLStore) queryRow(db *sql.DB, query string, args ...interface{}) *sql.Row {
+	stmt, err := m.prepare(db, query)
+	if err != nil {
+		return db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// prepare returns the prepared statement for query on db, preparing it on
+// first use.
+func (m *MSSQLStore) prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+
+	m.stmtMu.Lock()
+	defer m.stmtMu.Unlock()
+
+	if stmt, ok := m.stmts[key]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if m.stmts == nil {
+		m.stmts = make(map[stmtKey]*sql.Stmt)
+	}
+	m.stmts[key] = stmt
+	return stmt, nil
+}
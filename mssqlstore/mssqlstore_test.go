@@ -271,3 +271,46 @@ func TestStopNilCleanup(t *testing.T) {
 	// A send to a nil channel will block forever
 	m.StopCleanup()
 }
+
+func TestClose(t *testing.T) {
+	dsn := os.Getenv("SCS_MSSQL_TEST_DSN")
+	db, err := sql.Open("sqlserver", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = db.Exec("TRUNCATE TABLE sessions"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewWithCleanupInterval(db, 10*time.Millisecond)
+	if err = m.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = m.Find("session_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.stmts != nil {
+		t.Errorf("want statements closed; got %d", len(m.stmts))
+	}
+	// A second Close must not block on the stopped cleanup goroutine.
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err := m.Find("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Error("want session found after Close")
+	}
+}
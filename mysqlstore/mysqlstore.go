@@ -5,14 +5,40 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultBatchSize is the default maximum number of expired sessions deleted
+// by each statement run by DeleteExpired.
+const defaultBatchSize = 1000
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
 // MySQLStore represents the session store.
 type MySQLStore struct {
 	*sql.DB
 	version     string
 	stopCleanup chan bool
+
+	// replica, if set, is used for Find, and recent holds the expiry times
+	// (as Unix nanoseconds) of the primary read window for recently written
+	// tokens.
+	replica       *sql.DB
+	primaryWindow time.Duration
+	recent        map[string]int64
+	mu            sync.Mutex
+
+	// batchSize is the maximum number of expired sessions deleted by each
+	// statement run by DeleteExpired.
+	batchSize int32
+
+	stmts  map[stmtKey]*sql.Stmt
+	stmtMu sync.Mutex
 }
 
 // New returns a new MySQLStore instance, with a background cleanup goroutine
@@ -27,17 +53,35 @@ func New(db *sql.DB) *MySQLStore {
 // from running (i.e. expired sessions will not be removed).
 func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *MySQLStore {
 	m := &MySQLStore{
-		DB:      db,
-		version: getVersion(db),
+		DB:        db,
+		version:   getVersion(db),
+		batchSize: defaultBatchSize,
 	}
 
 	if cleanupInterval > 0 {
+		m.stopCleanup = make(chan bool)
 		go m.startCleanup(cleanupInterval)
 	}
 
 	return m
 }
 
+// NewWithReplica returns a new MySQLStore instance which uses the primary
+// database for Commit, Delete and cleanup, and the read replica for Find and
+// All. Because replicas lag behind the primary, a token which has been
+// committed or deleted by this instance is read from the primary for
+// primaryWindow afterwards, so a request following a write doesn't load stale
+// session data. Set primaryWindow to a little more than your usual replication
+// lag. The cleanupInterval parameter is the same as for
+// NewWithCleanupInterval.
+func NewWithReplica(primary, replica *sql.DB, primaryWindow, cleanupInterval time.Duration) *MySQLStore {
+	m := NewWithCleanupInterval(primary, cleanupInterval)
+	m.replica = replica
+	m.primaryWindow = primaryWindow
+	m.recent = make(map[string]int64)
+	return m
+}
+
 // Find returns the data for a given session token from the MySQLStore instance.
 // If the session token is not found or is expired, the returned exists flag will
 // be set to false.
@@ -51,7 +95,7 @@ func (m *MySQLStore) Find(token string) ([]byte, bool, error) {
 		stmt = "SELECT data FROM sessions WHERE token = ? AND UTC_TIMESTAMP < expiry"
 	}
 
-	row := m.DB.QueryRow(stmt, token)
+	row := m.queryRow(m.reader(token), stmt, token)
 	err := row.Scan(&b)
 	if err == sql.ErrNoRows {
 		return nil, false, nil
@@ -65,7 +109,8 @@ func (m *MySQLStore) Find(token string) ([]byte, bool, error) {
 // expiry time. If the session token already exists, then the data and expiry
 // time are updated.
 func (m *MySQLStore) Commit(token string, b []byte, expiry time.Time) error {
-	_, err := m.DB.Exec("INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE data = VALUES(data), expiry = VALUES(expiry)", token, b, expiry.UTC())
+	m.written(token)
+	_, err := m.exec(m.DB, "INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE data = VALUES(data), expiry = VALUES(expiry)", token, b, expiry.UTC())
 	if err != nil {
 		return err
 	}
@@ -75,7 +120,8 @@ func (m *MySQLStore) Commit(token string, b []byte, expiry time.Time) error {
 // Delete removes a session token and corresponding data from the MySQLStore
 // instance.
 func (m *MySQLStore) Delete(token string) error {
-	_, err := m.DB.Exec("DELETE FROM sessions WHERE token = ?", token)
+	m.written(token)
+	_, err := m.exec(m.DB, "DELETE FROM sessions WHERE token = ?", token)
 	return err
 }
 
@@ -90,7 +136,7 @@ func (m *MySQLStore) All() (map[string][]byte, error) {
 		stmt = "SELECT token, data FROM sessions WHERE UTC_TIMESTAMP < expiry"
 	}
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.reader("").Query(stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -121,12 +167,11 @@ func (m *MySQLStore) All() (map[string][]byte, error) {
 }
 
 func (m *MySQLStore) startCleanup(interval time.Duration) {
-	m.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := m.deleteExpired()
+			_, err := m.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -153,7 +198,33 @@ func (m *MySQLStore) StopCleanup() {
 	}
 }
 
-func (m *MySQLStore) deleteExpired() error {
+// Close stops the background cleanup goroutine, if it is running, and closes
+// the prepared statements used by the MySQLStore. It does not close the
+// underlying database connection pool. The MySQLStore can still be used after
+// Close, but statements will be prepared again.
+func (m *MySQLStore) Close() error {
+	m.StopCleanup()
+	m.stopCleanup = nil
+
+	m.stmtMu.Lock()
+	defer m.stmtMu.Unlock()
+
+	var err error
+	for _, stmt := range m.stmts {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.stmts = nil
+	return err
+}
+
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled. Expired sessions are deleted in batches (see
+// SetCleanupBatchSize), so that each statement only holds locks briefly.
+func (m *MySQLStore) DeleteExpired() (int, error) {
 	var stmt string
 
 	if compareVersion("5.6.4", m.version) >= 0 {
@@ -162,8 +233,122 @@ func (m *MySQLStore) deleteExpired() error {
 		stmt = "DELETE FROM sessions WHERE expiry < UTC_TIMESTAMP"
 	}
 
-	_, err := m.DB.Exec(stmt)
-	return err
+	batchSize := atomic.LoadInt32(&m.batchSize)
+	if batchSize <= 0 {
+		res, err := m.DB.Exec(stmt)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		return int(n), err
+	}
+
+	total := 0
+	for {
+		res, err := m.exec(m.DB, stmt+" LIMIT ?", batchSize)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		total += int(n)
+		if err != nil || n < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// SetCleanupBatchSize sets the maximum number of expired sessions deleted by
+// each statement run by DeleteExpired. The default is 1000. Setting it to 0
+// deletes all expired sessions with a single statement, which can lock a large
+// sessions table for a long time.
+func (m *MySQLStore) SetCleanupBatchSize(n int) {
+	atomic.StoreInt32(&m.batchSize, int32(n))
+}
+
+// exec runs a statement on db using a cached prepared statement.
+func (m *MySQLStore) exec(db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := m.prepare(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// queryRow runs a query on db using a cached prepared statement. If the
+// statement can't be prepared, the query is run directly so that the error is
+// returned by Scan.
+func (m *MySQLStore) queryRow(db *sql.DB, query string, args ...interface{}) *sql.Row {
+	stmt, err := m.prepare(db, query)
+	if err != nil {
+		return db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// prepare returns the prepared statement for query on db, preparing it on
+// first use.
+func (m *MySQLStore) prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+
+	m.stmtMu.Lock()
+	defer m.stmtMu.Unlock()
+
+	if stmt, ok := m.stmts[key]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if m.stmts == nil {
+		m.stmts = make(map[stmtKey]*sql.Stmt)
+	}
+	m.stmts[key] = stmt
+	return stmt, nil
+}
+
+// reader returns the database to read the session data for token from: the
+// replica, unless there isn't one or the token was written recently.
+func (m *MySQLStore) reader(token string) *sql.DB {
+	if m.replica == nil {
+		return m.DB
+	}
+	if token == "" || m.primaryWindow <= 0 {
+		return m.replica
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if until, ok := m.recent[token]; ok {
+		if time.Now().UnixNano() < until {
+			return m.DB
+		}
+		delete(m.recent, token)
+	}
+	return m.replica
+}
+
+// written records that token has been written to the primary, so it is read
+// from the primary for the primary read window.
+func (m *MySQLStore) written(token string) {
+	if m.replica == nil || m.primaryWindow <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Sweep expired records before the map grows, so that tokens which are
+	// never read again don't accumulate.
+	if len(m.recent) >= 1024 && len(m.recent)%1024 == 0 {
+		for t, until := range m.recent {
+			if now >= until {
+				delete(m.recent, t)
+			}
+		}
+	}
+	m.recent[token] = now + int64(m.primaryWindow)
 }
 
 func getVersion(db *sql.DB) string {
@@ -0,0 +1,73 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNonceUsed is returned by UseNonce when the nonce has already been used.
+var ErrNonceUsed = errors.New("scs: nonce has already been used")
+
+// nonceMu serializes the check and record in claim for stores which don't
+// implement AddStore.
+var nonceMu sync.Mutex
+
+// NewNonce returns a new random nonce, suitable for embedding in a form so
+// that a resubmission of the form can be detected with UseNonce.
+func NewNonce() (string, error) {
+	return randomString(32)
+}
+
+// UseNonce records that nonce has been used, and returns ErrNonceUsed if it
+// was already used within the last ttl. It provides consume-once semantics for
+// values such as form nonces (to reject resubmitted forms) and webhook
+// delivery IDs (to ignore duplicate deliveries). For example:
+//
+//	if err := sessionManager.UseNonce(r.Context(), deliveryID, 24*time.Hour); errors.Is(err, scs.ErrNonceUsed) {
+//		// Duplicate delivery; acknowledge it without processing.
+//	}
+//
+// Used nonces are recorded in the session store with the "nonce:" prefix,
+// until ttl has passed. If the store implements AddStore (as memstore does)
+// the check and record is atomic across all instances of the application
+// using the store. Otherwise it is only atomic within a single process.
+func (s *SessionManager) UseNonce(ctx context.Context, nonce string, ttl time.Duration) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	claimed, err := s.claim(ctx, "nonce:"+hashToken(nonce), time.Now().Add(ttl))
+	if err != nil {
+		return err
+	} else if !claimed {
+		return ErrNonceUsed
+	}
+	return nil
+}
+
+// claim records key in the session store until expiry, and reports whether it
+// was recorded, which is false if the key was already there. If the store
+// implements AddStore the check and record is atomic across all instances of
+// the application using the store. Otherwise it is only atomic within a
+// single process.
+func (s *SessionManager) claim(ctx context.Context, key string, expiry time.Time) (bool, error) {
+	b, err := s.encode(expiry.UTC(), map[string]interface{}{})
+	if err != nil {
+		return false, err
+	}
+
+	if as, ok := s.Store.(AddStore); ok {
+		return as.Add(key, b, expiry)
+	}
+
+	nonceMu.Lock()
+	defer nonceMu.Unlock()
+
+	_, found, err := storeFind(ctx, s.Store, key)
+	if err != nil || found {
+		return false, err
+	}
+	return true, storeCommit(ctx, s.Store, key, b, expiry)
+}
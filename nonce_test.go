@@ -0,0 +1,87 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUseNonce(t *testing.T) {
+	t.Parallel()
+
+	for name, store := range map[string]Store{
+		"memstore":  New().Store,
+		"mockstore": &nonceMockStore{},
+	} {
+		s := New()
+		s.Store = store
+
+		nonce, err := NewNonce()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var used int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := s.UseNonce(context.Background(), nonce, time.Minute)
+				if err == nil {
+					atomic.AddInt32(&used, 1)
+				} else if !errors.Is(err, ErrNonceUsed) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if used != 1 {
+			t.Errorf("%s: want nonce used once; got %d", name, used)
+		}
+
+		if err := s.UseNonce(context.Background(), "other", time.Minute); err != nil {
+			t.Errorf("%s: want nil error for new nonce; got %v", name, err)
+		}
+		if err := s.UseNonce(context.Background(), "expired", -time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UseNonce(context.Background(), "expired", time.Minute); err != nil {
+			t.Errorf("%s: want nil error for expired nonce; got %v", name, err)
+		}
+	}
+}
+
+// nonceMockStore is a store which doesn't implement AddStore.
+type nonceMockStore struct {
+	mu    sync.Mutex
+	items map[string]time.Time
+}
+
+func (m *nonceMockStore) Find(token string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiry, found := m.items[token]
+	return nil, found && time.Now().Before(expiry), nil
+}
+
+func (m *nonceMockStore) Commit(token string, b []byte, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		m.items = make(map[string]time.Time)
+	}
+	m.items[token] = expiry
+	return nil
+}
+
+func (m *nonceMockStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, token)
+	return nil
+}
@@ -0,0 +1,133 @@
+package scs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOAuthState is returned by CompleteOAuth when the state value is
+// unknown, expired or has already been used.
+var ErrInvalidOAuthState = errors.New("scs: invalid OAuth state")
+
+const oauthKeyPrefix = "__oauth."
+
+// OAuthFlow contains the values needed to start an OAuth 2.0 authorization code
+// flow with PKCE (RFC 7636).
+type OAuthFlow struct {
+	// State is the value to send as the state parameter in the authorization
+	// request. It is returned by the authorization server in the redirect and
+	// should be passed to CompleteOAuth.
+	State string
+
+	// CodeVerifier is the PKCE code verifier. It is stored in the session and
+	// returned by CompleteOAuth, so it doesn't normally need to be used
+	// directly.
+	CodeVerifier string
+
+	// CodeChallenge is the value to send as the code_challenge parameter in
+	// the authorization request, with a code_challenge_method of "S256".
+	CodeChallenge string
+
+	// Nonce is the value to send as the nonce parameter in an OpenID Connect
+	// authentication request. Once the flow has been completed, the nonce
+	// claim in the ID token should be checked with VerifyNonce.
+	Nonce string
+}
+
+// BeginOAuth starts an OAuth 2.0 authorization code flow. It generates a
+// random state value and PKCE code verifier, stores them in the session data
+// for the duration ttl, and returns them along with the PKCE code challenge.
+// The ttl should be long enough for the user to log in with the authorization
+// server (typically 10 minutes).
+//
+// More than one flow can be in progress at the same time (for example, if the
+// user starts logging in from two browser tabs). Expired flows are removed
+// from the session data each time BeginOAuth is called.
+func (s *SessionManager) BeginOAuth(ctx context.Context, ttl time.Duration) (*OAuthFlow, error) {
+	if s.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.removeExpiredOAuthFlows(ctx)
+
+	prefix := oauthKeyPrefix + state
+	s.Put(ctx, prefix+".verifier", verifier)
+	s.Put(ctx, prefix+".nonce", nonce)
+	s.Put(ctx, prefix+".expiry", time.Now().Add(ttl).UnixNano())
+
+	hash := sha256.Sum256([]byte(verifier))
+	return &OAuthFlow{
+		State:         state,
+		CodeVerifier:  verifier,
+		CodeChallenge: base64.RawURLEncoding.EncodeToString(hash[:]),
+		Nonce:         nonce,
+	}, nil
+}
+
+// CompleteOAuth consumes the flow identified by state (the state parameter
+// received in the redirect from the authorization server), and returns the
+// PKCE code verifier to send in the token request. The flow's nonce is kept in
+// the session data until it is checked with VerifyNonce. Each flow can only be
+// completed once. It returns ErrInvalidOAuthState if the state is unknown,
+// expired or has already been used, in which case the login should be
+// rejected.
+func (s *SessionManager) CompleteOAuth(ctx context.Context, state string) (verifier string, err error) {
+	if state == "" {
+		return "", ErrInvalidOAuthState
+	}
+
+	prefix := oauthKeyPrefix + state
+	if !s.Exists(ctx, prefix+".verifier") {
+		return "", ErrInvalidOAuthState
+	}
+
+	verifier = s.GetString(ctx, prefix+".verifier")
+	nonce := s.GetString(ctx, prefix+".nonce")
+	expiry := s.GetInt64(ctx, prefix+".expiry")
+	s.removeOAuthFlow(ctx, state)
+
+	if time.Now().UnixNano() > expiry {
+		return "", ErrInvalidOAuthState
+	}
+
+	s.Put(ctx, oidcNonceKey, nonce)
+	return verifier, nil
+}
+
+func (s *SessionManager) removeOAuthFlow(ctx context.Context, state string) {
+	prefix := oauthKeyPrefix + state + "."
+	for _, key := range s.Keys(ctx) {
+		if strings.HasPrefix(key, prefix) {
+			s.Remove(ctx, key)
+		}
+	}
+}
+
+func (s *SessionManager) removeExpiredOAuthFlows(ctx context.Context) {
+	now := time.Now().UnixNano()
+	for _, key := range s.Keys(ctx) {
+		if strings.HasPrefix(key, oauthKeyPrefix) && strings.HasSuffix(key, ".expiry") {
+			if now > s.GetInt64(ctx, key) {
+				state := strings.TrimSuffix(strings.TrimPrefix(key, oauthKeyPrefix), ".expiry")
+				s.removeOAuthFlow(ctx, state)
+			}
+		}
+	}
+}
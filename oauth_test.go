@@ -0,0 +1,88 @@
+package scs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuth(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flow1, err := s.BeginOAuth(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow2, err := s.BeginOAuth(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flow1.State == flow2.State || flow1.CodeVerifier == flow2.CodeVerifier {
+		t.Error("expected each flow to have a unique state and verifier")
+	}
+
+	hash := sha256.Sum256([]byte(flow1.CodeVerifier))
+	if want := base64.RawURLEncoding.EncodeToString(hash[:]); flow1.CodeChallenge != want {
+		t.Errorf("got challenge %q: expected %q", flow1.CodeChallenge, want)
+	}
+
+	verifier, err := s.CompleteOAuth(ctx, flow2.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifier != flow2.CodeVerifier {
+		t.Errorf("got %q: expected %q", verifier, flow2.CodeVerifier)
+	}
+
+	if _, err := s.CompleteOAuth(ctx, flow2.State); err != ErrInvalidOAuthState {
+		t.Errorf("got %v: expected %v", err, ErrInvalidOAuthState)
+	}
+	if _, err := s.CompleteOAuth(ctx, "unknown"); err != ErrInvalidOAuthState {
+		t.Errorf("got %v: expected %v", err, ErrInvalidOAuthState)
+	}
+	if _, err := s.CompleteOAuth(ctx, flow1.State); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, key := range s.Keys(ctx) {
+		if strings.HasPrefix(key, oauthKeyPrefix) {
+			t.Errorf("expected completed flows to be removed; got key %q", key)
+		}
+	}
+}
+
+func TestOAuthExpiry(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := s.BeginOAuth(ctx, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteOAuth(ctx, expired.State); err != ErrInvalidOAuthState {
+		t.Errorf("got %v: expected %v", err, ErrInvalidOAuthState)
+	}
+
+	expired, _ = s.BeginOAuth(ctx, -time.Second)
+	if _, err := s.BeginOAuth(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, oauthKeyPrefix+expired.State+".verifier") {
+		t.Error("expected expired flow to be removed by BeginOAuth")
+	}
+}
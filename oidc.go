@@ -0,0 +1,108 @@
+package scs
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidNonce is returned by VerifyNonce when the nonce doesn't match the
+// one sent in the authentication request, or has already been used.
+var ErrInvalidNonce = errors.New("scs: invalid OpenID Connect nonce")
+
+const (
+	oidcNonceKey        = "__oidc.nonce"
+	oidcClaimPrefix     = "__oidc.claim."
+	oidcClaimsExpiryKey = "__oidc.claimsExpiry"
+)
+
+// VerifyNonce checks the nonce claim from a verified OpenID Connect ID token
+// against the nonce of the most recently completed OAuth flow (see BeginOAuth
+// and CompleteOAuth), protecting against replayed ID tokens. The stored nonce
+// is removed, so each nonce can only be verified once. It returns
+// ErrInvalidNonce if the nonces don't match or no flow has been completed.
+func (s *SessionManager) VerifyNonce(ctx context.Context, nonce string) error {
+	want := s.PopString(ctx, oidcNonceKey)
+	if want == "" || subtle.ConstantTimeCompare([]byte(want), []byte(nonce)) != 1 {
+		return ErrInvalidNonce
+	}
+	return nil
+}
+
+// CacheIDTokenClaims stores the named claims from a verified ID token in the
+// session data for the duration ttl, so that they can be used for
+// authorization decisions in later requests without keeping the whole token.
+// Any previously cached claims are replaced. Claims which are not present are
+// skipped.
+//
+// Claim values are stored as they are, except that json.Number values are
+// converted to an int64 or float64, and arrays of strings ([]interface{}
+// containing only strings) are converted to []string. Any other composite
+// values (such as JSON objects) must be registered with encoding/gob if you
+// are using the default codec.
+func (s *SessionManager) CacheIDTokenClaims(ctx context.Context, claims map[string]interface{}, ttl time.Duration, names ...string) {
+	s.removeIDTokenClaims(ctx)
+
+	for _, name := range names {
+		if val, ok := claims[name]; ok {
+			s.Put(ctx, oidcClaimPrefix+name, normalizeClaim(val))
+		}
+	}
+	s.Put(ctx, oidcClaimsExpiryKey, time.Now().Add(ttl).UnixNano())
+}
+
+// IDTokenClaim returns the value of a claim cached by CacheIDTokenClaims. The
+// ok return value is false if the claim wasn't cached or the cached claims have
+// expired (in which case all of the cached claims are removed).
+func (s *SessionManager) IDTokenClaim(ctx context.Context, name string) (val interface{}, ok bool) {
+	expiry := s.GetInt64(ctx, oidcClaimsExpiryKey)
+	if expiry == 0 {
+		return nil, false
+	}
+	if time.Now().UnixNano() > expiry {
+		s.removeIDTokenClaims(ctx)
+		return nil, false
+	}
+
+	key := oidcClaimPrefix + name
+	if !s.Exists(ctx, key) {
+		return nil, false
+	}
+	return s.Get(ctx, key), true
+}
+
+func (s *SessionManager) removeIDTokenClaims(ctx context.Context) {
+	for _, key := range s.Keys(ctx) {
+		if strings.HasPrefix(key, oidcClaimPrefix) {
+			s.Remove(ctx, key)
+		}
+	}
+	s.Remove(ctx, oidcClaimsExpiryKey)
+}
+
+func normalizeClaim(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, elem := range v {
+			str, ok := elem.(string)
+			if !ok {
+				return val
+			}
+			strs[i] = str
+		}
+		return strs
+	}
+	return val
+}
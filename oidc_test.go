@@ -0,0 +1,108 @@
+package scs
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyNonce(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.VerifyNonce(ctx, ""); err != ErrInvalidNonce {
+		t.Errorf("got %v: expected %v", err, ErrInvalidNonce)
+	}
+
+	flow, err := s.BeginOAuth(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.Nonce == "" {
+		t.Fatal("expected flow to have a nonce")
+	}
+	if _, err := s.CompleteOAuth(ctx, flow.State); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.VerifyNonce(ctx, "wrong"); err != ErrInvalidNonce {
+		t.Errorf("got %v: expected %v", err, ErrInvalidNonce)
+	}
+
+	// A failed verification consumes the nonce too.
+	if err := s.VerifyNonce(ctx, flow.Nonce); err != ErrInvalidNonce {
+		t.Errorf("got %v: expected %v", err, ErrInvalidNonce)
+	}
+
+	flow, _ = s.BeginOAuth(ctx, time.Minute)
+	s.CompleteOAuth(ctx, flow.State)
+	if err := s.VerifyNonce(ctx, flow.Nonce); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.VerifyNonce(ctx, flow.Nonce); err != ErrInvalidNonce {
+		t.Errorf("got %v: expected %v", err, ErrInvalidNonce)
+	}
+}
+
+func TestCacheIDTokenClaims(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(`{"sub":"alice","groups":["admin","dev"],"age":42,"secret":"x"}`))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		t.Fatal(err)
+	}
+
+	s.CacheIDTokenClaims(ctx, claims, time.Minute, "sub", "groups", "age", "missing")
+
+	// Round trip through the store to check the values can be encoded.
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		want   interface{}
+		wantOK bool
+	}{
+		{"sub", "alice", true},
+		{"groups", []string{"admin", "dev"}, true},
+		{"age", int64(42), true},
+		{"secret", nil, false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := s.IDTokenClaim(ctx, tt.name)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v %v: expected %v %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	s.CacheIDTokenClaims(ctx, claims, -time.Second, "sub")
+	if _, ok := s.IDTokenClaim(ctx, "sub"); ok {
+		t.Error("expected expired claims not to be returned")
+	}
+	if keys := s.Keys(ctx); len(keys) != 0 {
+		t.Errorf("expected expired claims to be removed; got keys %v", keys)
+	}
+}
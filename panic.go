@@ -0,0 +1,54 @@
+package scs
+
+import "net/http"
+
+// PanicPolicy controls what the LoadAndSave middleware does with the session
+// when the handler panics. The panic is always propagated, with its original
+// value, so that it can be handled by net/http or by recovery middleware.
+type PanicPolicy int
+
+const (
+	// DiscardOnPanic discards any changes made to the session data by the
+	// handler which haven't already been committed, and doesn't write a
+	// session cookie. This is the default.
+	DiscardOnPanic PanicPolicy = iota
+
+	// CommitOnPanic commits the session data and writes the session cookie
+	// before the panic is propagated, as if the handler had returned
+	// normally, so that a recovery middleware wrapping LoadAndSave sends the
+	// cookie with its error response. It has no effect when the panic value
+	// is http.ErrAbortHandler, as the response is being aborted.
+	CommitOnPanic
+)
+
+// serveHandler calls next, handling a panic according to the OnPanic setting.
+// It reports whether next returned normally.
+func (s *SessionManager) serveHandler(next http.Handler, sw *sessionResponseWriter, r *http.Request) (ok bool) {
+	defer func() {
+		if ok || s.OnPanic != CommitOnPanic {
+			return
+		}
+
+		// A nil value means that the handler called runtime.Goexit, which
+		// isn't a panic and continues once this function returns.
+		val := recover()
+		if val == nil {
+			return
+		}
+		if val != http.ErrAbortHandler {
+			s.commitAfterHandler(sw, r)
+		}
+		panic(val)
+	}()
+
+	next.ServeHTTP(sw, r)
+	return true
+}
+
+// commitAfterHandler commits the session data and writes the session cookie
+// once the handler has finished.
+func (s *SessionManager) commitAfterHandler(sw *sessionResponseWriter, r *http.Request) {
+	if written, token := sw.writeSessionCookie(); written {
+		s.commitLateWrite(sw.ResponseWriter, r, token)
+	}
+}
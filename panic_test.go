@@ -0,0 +1,93 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOnPanic(t *testing.T) {
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		policy     PanicPolicy
+		val        interface{}
+		wantCookie bool
+	}{
+		{DiscardOnPanic, errBoom, false},
+		{DiscardOnPanic, http.ErrAbortHandler, false},
+		{CommitOnPanic, errBoom, true},
+		{CommitOnPanic, http.ErrAbortHandler, false},
+	}
+
+	for _, tt := range tests {
+		s := New()
+		s.OnPanic = tt.policy
+
+		var token string
+		h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), "foo", "bar")
+			panic(tt.val)
+		}))
+
+		rr := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if val := recover(); val != tt.val {
+					t.Errorf("policy %v: got panic %v: expected %v", tt.policy, val, tt.val)
+				}
+			}()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+
+		if cookies := rr.Result().Cookies(); len(cookies) > 0 {
+			token = cookies[0].Value
+		}
+		if gotCookie := token != ""; gotCookie != tt.wantCookie {
+			t.Errorf("policy %v, panic %v: got cookie %v: expected %v", tt.policy, tt.val, gotCookie, tt.wantCookie)
+		}
+		if !tt.wantCookie {
+			continue
+		}
+
+		ctx, err := s.Load(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.GetString(ctx, "foo") != "bar" {
+			t.Errorf("policy %v: want session data committed", tt.policy)
+		}
+	}
+}
+
+func TestOnPanicAfterWrite(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+		w.WriteHeader(http.StatusOK)
+		s.Put(r.Context(), "foo", "baz")
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	// The session committed when the headers were written is kept, but the
+	// change made afterwards is discarded.
+	ctx, err := s.Load(context.Background(), rr.Result().Cookies()[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GetString(ctx, "foo") != "bar" {
+		t.Errorf("got %q: expected %q", s.GetString(ctx, "foo"), "bar")
+	}
+}
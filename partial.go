@@ -0,0 +1,132 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// deadlineField is the name of the field holding the session deadline for
+// stores which implement PartialStore.
+const deadlineField = "__deadline"
+
+// partialStore returns the session store as a PartialStore, if it implements
+// the interface.
+func (s *SessionManager) partialStore() (PartialStore, bool) {
+	ps, ok := s.Store.(PartialStore)
+	return ps, ok
+}
+
+// doFindSession returns the stored data for a session token. If the store
+// implements PartialStore and holds the session as separate values, they are
+// returned as fields and b is nil. Otherwise the encoded session data is
+// returned as b. If keys is not nil and the store implements KeysStore, only
+// the fields for keys (and the deadline) are returned.
+func (s *SessionManager) doFindSession(ctx context.Context, token string, keys []string) (b []byte, fields map[string][]byte, found bool, err error) {
+	if ps, ok := s.partialStore(); ok {
+		storeToken := token
+		if s.HashTokenInStore {
+			storeToken = hashToken(storeToken)
+		}
+		if ks, ok := ps.(KeysStore); ok && keys != nil {
+			fields, found, err = ks.FindKeys(storeToken, append(keys[:len(keys):len(keys)], deadlineField))
+		} else {
+			fields, found, err = ps.FindValues(storeToken)
+		}
+		if err != nil || found {
+			return nil, fields, found, err
+		}
+	}
+
+	b, found, err = s.doStoreFind(ctx, token)
+	return b, nil, found, err
+}
+
+// findStored finds the stored data for a session in the same way as
+// doFindSession, given the token as it appears in the store.
+func (s *SessionManager) findStored(ctx context.Context, storeToken string) (b []byte, fields map[string][]byte, found bool, err error) {
+	if ps, ok := s.partialStore(); ok {
+		fields, found, err = ps.FindValues(storeToken)
+		if err != nil || found {
+			return nil, fields, found, err
+		}
+	}
+
+	b, found, err = storeFind(ctx, s.Store, storeToken)
+	return b, nil, found, err
+}
+
+// decodeSession decodes the session data returned by findSession, reporting
+// whether the FallbackCodec was used.
+func (s *SessionManager) decodeSession(b []byte, fields map[string][]byte) (time.Time, map[string]interface{}, bool, error) {
+	if fields == nil {
+		return s.decodeFallback(b)
+	}
+
+	var deadline time.Time
+	var usedFallback bool
+	values := make(map[string]interface{}, len(fields))
+	for key, fb := range fields {
+		d, v, fallback, err := s.decodeFallback(fb)
+		if err != nil {
+			return time.Time{}, nil, false, err
+		}
+		usedFallback = usedFallback || fallback
+		if key == deadlineField {
+			deadline = d
+			continue
+		}
+		if val, ok := v[key]; ok {
+			values[key] = val
+		}
+	}
+
+	return deadline.UTC(), values, usedFallback, nil
+}
+
+// encodeFields encodes the session deadline and each session value separately
+// with the Codec, in the form used by PartialStore.
+func (s *SessionManager) encodeFields(deadline time.Time, values map[string]interface{}) (map[string][]byte, error) {
+	fields := make(map[string][]byte, len(values)+1)
+
+	b, err := s.encode(deadline, nil)
+	if err != nil {
+		return nil, err
+	}
+	fields[deadlineField] = b
+
+	for key, val := range values {
+		b, err := s.encode(time.Time{}, map[string]interface{}{key: val})
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = b
+	}
+
+	return fields, nil
+}
+
+// commitFields commits the encoded session values to a PartialStore, writing
+// only the fields which differ from base (the fields as last loaded or
+// committed). If base is nil all the fields are written, replacing any
+// existing data.
+func (s *SessionManager) commitFields(ps PartialStore, token string, fields map[string][]byte, base map[string][]byte, expiry time.Time) error {
+	set := make(map[string][]byte)
+	for key, b := range fields {
+		if old, ok := base[key]; !ok || !bytes.Equal(old, b) {
+			set[key] = b
+		}
+	}
+
+	var remove []string
+	for key := range base {
+		if _, ok := fields[key]; !ok {
+			remove = append(remove, key)
+		}
+	}
+
+	if s.HashTokenInStore {
+		token = hashToken(token)
+	}
+	return ps.CommitValues(token, set, remove, base == nil, expiry)
+}
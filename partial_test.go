@@ -0,0 +1,211 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+// partialStore is a PartialStore which records the values written by each
+// commit.
+type partialStore struct {
+	*memstore.MemStore
+
+	mu      sync.Mutex
+	values  map[string]map[string][]byte
+	expiry  map[string]time.Time
+	commits []partialCommit
+}
+
+type partialCommit struct {
+	set     []string
+	remove  []string
+	replace bool
+}
+
+func newPartialStore() *partialStore {
+	return &partialStore{
+		MemStore: memstore.NewWithCleanupInterval(0),
+		values:   make(map[string]map[string][]byte),
+		expiry:   make(map[string]time.Time),
+	}
+}
+
+func (p *partialStore) FindValues(token string) (map[string][]byte, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values, ok := p.values[token]
+	if !ok || !time.Now().Before(p.expiry[token]) {
+		return nil, false, nil
+	}
+	copied := make(map[string][]byte, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied, true, nil
+}
+
+func (p *partialStore) CommitValues(token string, set map[string][]byte, remove []string, replace bool, expiry time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	values, ok := p.values[token]
+	if !ok || replace {
+		values = make(map[string][]byte)
+		p.values[token] = values
+		p.MemStore.Delete(token)
+	}
+	c := partialCommit{remove: remove, replace: replace}
+	for k, v := range set {
+		values[k] = v
+		c.set = append(c.set, k)
+	}
+	for _, k := range remove {
+		delete(values, k)
+	}
+	sort.Strings(c.set)
+	p.expiry[token] = expiry
+	p.commits = append(p.commits, c)
+	return nil
+}
+
+func (p *partialStore) Delete(token string) error {
+	p.mu.Lock()
+	delete(p.values, token)
+	p.mu.Unlock()
+	return p.MemStore.Delete(token)
+}
+
+func (p *partialStore) AllValues() (map[string]map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all := make(map[string]map[string][]byte)
+	for token, values := range p.values {
+		if time.Now().Before(p.expiry[token]) {
+			all[token] = values
+		}
+	}
+	return all, nil
+}
+
+func (p *partialStore) lastCommit() partialCommit {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.commits[len(p.commits)-1]
+}
+
+func TestPartialStore(t *testing.T) {
+	t.Parallel()
+
+	store := newPartialStore()
+	s := New()
+	s.Store = store
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/put":
+			s.Put(r.Context(), "a", "one")
+			s.Put(r.Context(), "b", []int{1, 2, 3})
+		case "/flip":
+			s.Put(r.Context(), "flag", !s.GetBool(r.Context(), "flag"))
+		case "/remove":
+			s.Remove(r.Context(), "a")
+		}
+		w.Write([]byte(s.GetString(r.Context(), "a")))
+	}))
+
+	do := func(path, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if cookie != "" {
+			r.Header.Set("Cookie", "session="+cookie)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := do("/put", "")
+	token := extractTokenFromCookie(rr.Header().Get("Set-Cookie"))
+	if c := store.lastCommit(); !c.replace || len(c.set) != 3 {
+		t.Fatalf("want full commit of deadline and 2 values; got %+v", c)
+	}
+
+	rr = do("/flip", token)
+	if c := store.lastCommit(); c.replace || len(c.set) != 1 || c.set[0] != "flag" {
+		t.Errorf("want only flag committed; got %+v", c)
+	}
+	if rr.Body.String() != "one" {
+		t.Errorf("want %q; got %q", "one", rr.Body.String())
+	}
+
+	do("/remove", token)
+	if c := store.lastCommit(); c.replace || len(c.set) != 0 || len(c.remove) != 1 || c.remove[0] != "a" {
+		t.Errorf("want only a removed; got %+v", c)
+	}
+
+	// Sessions held as values are visible to Iterate.
+	var flags []bool
+	err := s.Iterate(context.Background(), func(ctx context.Context) error {
+		flags = append(flags, s.GetBool(ctx, "flag"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 || !flags[0] {
+		t.Errorf("want one session with flag set; got %v", flags)
+	}
+}
+
+func TestPartialStoreFallback(t *testing.T) {
+	t.Parallel()
+
+	// A session committed to the store in full is loaded with Find, and
+	// replaced with values when it is next committed.
+	store := newPartialStore()
+	s := New()
+	s.Store = store
+
+	token, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{"a": "one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MemStore.Commit(token, b, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetString(ctx, "a"); got != "one" {
+		t.Fatalf("want %q; got %q", "one", got)
+	}
+	s.Put(ctx, "b", "two")
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := store.lastCommit(); !c.replace {
+		t.Errorf("want full commit; got %+v", c)
+	}
+	if _, found, _ := store.MemStore.Find(token); found {
+		t.Error("want encoded session data replaced")
+	}
+	values, found, _ := store.FindValues(token)
+	if !found || len(values) != 3 {
+		t.Errorf("want deadline and 2 values; got %v", values)
+	}
+}
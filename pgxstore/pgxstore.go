@@ -28,6 +28,7 @@ func New(pool *pgxpool.Pool) *PostgresStore {
 func NewWithCleanupInterval(pool *pgxpool.Pool, cleanupInterval time.Duration) *PostgresStore {
 	p := &PostgresStore{pool: pool}
 	if cleanupInterval > 0 {
+		p.stopCleanup = make(chan bool)
 		go p.startCleanup(cleanupInterval)
 	}
 	return p
@@ -99,12 +100,11 @@ func (p *PostgresStore) All() (map[string][]byte, error) {
 }
 
 func (p *PostgresStore) startCleanup(interval time.Duration) {
-	p.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := p.deleteExpired()
+			_, err := p.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -131,7 +131,14 @@ func (p *PostgresStore) StopCleanup() {
 	}
 }
 
-func (p *PostgresStore) deleteExpired() error {
-	_, err := p.pool.Exec(context.Background(), "DELETE FROM sessions WHERE expiry < current_timestamp")
-	return err
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled.
+func (p *PostgresStore) DeleteExpired() (int, error) {
+	tag, err := p.pool.Exec(context.Background(), "DELETE FROM sessions WHERE expiry < current_timestamp")
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
 }
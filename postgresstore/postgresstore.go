@@ -3,13 +3,39 @@ package postgresstore
 import (
 	"database/sql"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultBatchSize is the default maximum number of expired sessions deleted
+// by each statement run by DeleteExpired.
+const defaultBatchSize = 1000
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
 // PostgresStore represents the session store.
 type PostgresStore struct {
 	db          *sql.DB
 	stopCleanup chan bool
+
+	// replica, if set, is used for Find, and recent holds the expiry times
+	// (as Unix nanoseconds) of the primary read window for recently written
+	// tokens.
+	replica       *sql.DB
+	primaryWindow time.Duration
+	recent        map[string]int64
+	mu            sync.Mutex
+
+	// batchSize is the maximum number of expired sessions deleted by each
+	// statement run by DeleteExpired.
+	batchSize int32
+
+	stmts  map[stmtKey]*sql.Stmt
+	stmtMu sync.Mutex
 }
 
 // New returns a new PostgresStore instance, with a background cleanup goroutine
@@ -23,18 +49,35 @@ func New(db *sql.DB) *PostgresStore {
 // background cleanup goroutine. Setting it to 0 prevents the cleanup goroutine
 // from running (i.e. expired sessions will not be removed).
 func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *PostgresStore {
-	p := &PostgresStore{db: db}
+	p := &PostgresStore{db: db, batchSize: defaultBatchSize}
 	if cleanupInterval > 0 {
+		p.stopCleanup = make(chan bool)
 		go p.startCleanup(cleanupInterval)
 	}
 	return p
 }
 
+// NewWithReplica returns a new PostgresStore instance which uses the primary
+// database for Commit, Delete and cleanup, and the read replica for Find and
+// All. Because replicas lag behind the primary, a token which has been
+// committed or deleted by this instance is read from the primary for
+// primaryWindow afterwards, so a request following a write doesn't load stale
+// session data. Set primaryWindow to a little more than your usual replication
+// lag. The cleanupInterval parameter is the same as for
+// NewWithCleanupInterval.
+func NewWithReplica(primary, replica *sql.DB, primaryWindow, cleanupInterval time.Duration) *PostgresStore {
+	p := NewWithCleanupInterval(primary, cleanupInterval)
+	p.replica = replica
+	p.primaryWindow = primaryWindow
+	p.recent = make(map[string]int64)
+	return p
+}
+
 // Find returns the data for a given session token from the PostgresStore instance.
 // If the session token is not found or is expired, the returned exists flag will
 // be set to false.
 func (p *PostgresStore) Find(token string) (b []byte, exists bool, err error) {
-	row := p.db.QueryRow("SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry", token)
+	row := p.queryRow(p.reader(token), "SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry", token)
 	err = row.Scan(&b)
 	if err == sql.ErrNoRows {
 		return nil, false, nil
@@ -48,7 +91,8 @@ func (p *PostgresStore) Find(token string) (b []byte, exists bool, err error) {
 // given expiry time. If the session token already exists, then the data and expiry
 // time are updated.
 func (p *PostgresStore) Commit(token string, b []byte, expiry time.Time) error {
-	_, err := p.db.Exec("INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry", token, b, expiry)
+	p.written(token)
+	_, err := p.exec(p.db, "INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3) ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry", token, b, expiry)
 	if err != nil {
 		return err
 	}
@@ -58,14 +102,15 @@ func (p *PostgresStore) Commit(token string, b []byte, expiry time.Time) error {
 // Delete removes a session token and corresponding data from the PostgresStore
 // instance.
 func (p *PostgresStore) Delete(token string) error {
-	_, err := p.db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	p.written(token)
+	_, err := p.exec(p.db, "DELETE FROM sessions WHERE token = $1", token)
 	return err
 }
 
 // All returns a map containing the token and data for all active (i.e.
 // not expired) sessions in the PostgresStore instance.
 func (p *PostgresStore) All() (map[string][]byte, error) {
-	rows, err := p.db.Query("SELECT token, data FROM sessions WHERE current_timestamp < expiry")
+	rows, err := p.reader("").Query("SELECT token, data FROM sessions WHERE current_timestamp < expiry")
 	if err != nil {
 		return nil, err
 	}
@@ -96,12 +141,11 @@ func (p *PostgresStore) All() (map[string][]byte, error) {
 }
 
 func (p *PostgresStore) startCleanup(interval time.Duration) {
-	p.stopCleanup = make(chan bool)
 	ticker := time.NewTicker(interval)
 	for {
 		select {
 		case <-ticker.C:
-			err := p.deleteExpired()
+			_, err := p.DeleteExpired()
 			if err != nil {
 				log.Println(err)
 			}
@@ -128,7 +172,147 @@ func (p *PostgresStore) StopCleanup() {
 	}
 }
 
-func (p *PostgresStore) deleteExpired() error {
-	_, err := p.db.Exec("DELETE FROM sessions WHERE expiry < current_timestamp")
+// Close stops the background cleanup goroutine, if it is running, and closes
+// the prepared statements used by the PostgresStore. It does not close the
+// underlying database connection pool. The PostgresStore can still be used after
+// Close, but statements will be prepared again.
+func (p *PostgresStore) Close() error {
+	p.StopCleanup()
+	p.stopCleanup = nil
+
+	p.stmtMu.Lock()
+	defer p.stmtMu.Unlock()
+
+	var err error
+	for _, stmt := range p.stmts {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	p.stmts = nil
 	return err
 }
+
+// DeleteExpired deletes all expired sessions from the store and returns the
+// number deleted. It is called periodically by the cleanup goroutine, and can
+// also be called directly, for example from a scheduled job when the cleanup
+// goroutine is disabled. Expired sessions are deleted in batches (see
+// SetCleanupBatchSize), so that each statement only holds locks briefly.
+func (p *PostgresStore) DeleteExpired() (int, error) {
+	batchSize := atomic.LoadInt32(&p.batchSize)
+	if batchSize <= 0 {
+		res, err := p.db.Exec("DELETE FROM sessions WHERE expiry < current_timestamp")
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		return int(n), err
+	}
+
+	total := 0
+	for {
+		res, err := p.exec(p.db, "DELETE FROM sessions WHERE token IN (SELECT token FROM sessions WHERE expiry < current_timestamp LIMIT $1)", batchSize)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		total += int(n)
+		if err != nil || n < int64(batchSize) {
+			return total, err
+		}
+	}
+}
+
+// SetCleanupBatchSize sets the maximum number of expired sessions deleted by
+// each statement run by DeleteExpired. The default is 1000. Setting it to 0
+// deletes all expired sessions with a single statement, which can lock a large
+// sessions table for a long time.
+func (p *PostgresStore) SetCleanupBatchSize(n int) {
+	atomic.StoreInt32(&p.batchSize, int32(n))
+}
+
+// exec runs a statement on db using a cached prepared statement.
+func (p *PostgresStore) exec(db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := p.prepare(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+// queryRow runs a query on db using a cached prepared statement. If the
+// statement can't be prepared, the query is run directly so that the error is
+// returned by Scan.
+func (p *PostgresStore) queryRow(db *sql.DB, query string, args ...interface{}) *sql.Row {
+	stmt, err := p.prepare(db, query)
+	if err != nil {
+		return db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+// prepare returns the prepared statement for query on db, preparing it on
+// first use.
+func (p *PostgresStore) prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+
+	p.stmtMu.Lock()
+	defer p.stmtMu.Unlock()
+
+	if stmt, ok := p.stmts[key]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if p.stmts == nil {
+		p.stmts = make(map[stmtKey]*sql.Stmt)
+	}
+	p.stmts[key] = stmt
+	return stmt, nil
+}
+
+// reader returns the database to read the session data for token from: the
+// replica, unless there isn't one or the token was written recently.
+func (p *PostgresStore) reader(token string) *sql.DB {
+	if p.replica == nil {
+		return p.db
+	}
+	if token == "" || p.primaryWindow <= 0 {
+		return p.replica
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until, ok := p.recent[token]; ok {
+		if time.Now().UnixNano() < until {
+			return p.db
+		}
+		delete(p.recent, token)
+	}
+	return p.replica
+}
+
+// written records that token has been written to the primary, so it is read
+// from the primary for the primary read window.
+func (p *PostgresStore) written(token string) {
+	if p.replica == nil || p.primaryWindow <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Sweep expired records before the map grows, so that tokens which are
+	// never read again don't accumulate.
+	if len(p.recent) >= 1024 && len(p.recent)%1024 == 0 {
+		for t, until := range p.recent {
+			if now >= until {
+				delete(p.recent, t)
+			}
+		}
+	}
+	p.recent[token] = now + int64(p.primaryWindow)
+}
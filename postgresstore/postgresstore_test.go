@@ -271,3 +271,77 @@ func TestStopNilCleanup(t *testing.T) {
 	// A send to a nil channel will block forever
 	p.StopCleanup()
 }
+
+func TestReaderWithReplica(t *testing.T) {
+	primary, replica := openUnconnected(t), openUnconnected(t)
+	p := NewWithReplica(primary, replica, 50*time.Millisecond, 0)
+
+	if db := p.reader("session_token"); db != replica {
+		t.Fatal("want replica before write")
+	}
+
+	p.written("session_token")
+	if db := p.reader("session_token"); db != primary {
+		t.Fatal("want primary after write")
+	}
+	if db := p.reader("other_token"); db != replica {
+		t.Fatal("want replica for other token")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if db := p.reader("session_token"); db != replica {
+		t.Fatal("want replica after primary read window")
+	}
+}
+
+// openUnconnected returns a *sql.DB which is never connected to a database.
+func openUnconnected(t *testing.T) *sql.DB {
+	db, err := sql.Open("postgres", "host=localhost port=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestClose(t *testing.T) {
+	dsn := os.Getenv("SCS_POSTGRES_TEST_DSN")
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = db.Exec("TRUNCATE TABLE sessions"); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithCleanupInterval(db, 10*time.Millisecond)
+	if err = p.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = p.Find("session_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if p.stmts != nil {
+		t.Errorf("want statements closed; got %d", len(p.stmts))
+	}
+	// A second Close must not block on the stopped cleanup goroutine.
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err := p.Find("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Error("want session found after Close")
+	}
+}
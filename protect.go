@@ -0,0 +1,145 @@
+package scs
+
+import (
+	"context"
+	"errors"
+)
+
+const protectedKeysKey = "__protected"
+
+// ErrKeyProtected is returned by TryPut and TryRemove when the key has been
+// protected with Protect.
+var ErrKeyProtected = errors.New("scs: session key is protected")
+
+// Protect marks the given keys in the session data as protected, so they can
+// only be changed through PutProtected and RemoveProtected. Put and Remove
+// leave a protected key unchanged (reporting the attempt to
+// SessionManager.RejectedWriteFunc), Pop returns nil, and TryPut and
+// TryRemove return ErrKeyProtected. This is useful for keys such as a user ID which should
+// only be set by dedicated APIs, such as a login handler, and never
+// overwritten by generic handlers. Protection is saved with the session data
+// and lasts until the key is removed with RemoveProtected or the session data
+// is cleared.
+func (s *SessionManager) Protect(ctx context.Context, keys ...string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	protectKeys(sd, keys...)
+	return nil
+}
+
+// IsProtected reports whether the given key has been protected with Protect.
+func (s *SessionManager) IsProtected(ctx context.Context, key string) bool {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	return isProtected(sd, key)
+}
+
+// PutProtected adds a key and corresponding value to the session data and
+// protects the key, replacing any existing value even if the key is already
+// protected. SessionManager.Validators still apply.
+func (s *SessionManager) PutProtected(ctx context.Context, key string, val interface{}) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+	if err := s.put(ctx, key, val, true); err != nil {
+		return err
+	}
+	return s.Protect(ctx, key)
+}
+
+// RemoveProtected deletes the given key and corresponding value from the
+// session data, even if the key is protected, and removes its protection.
+func (s *SessionManager) RemoveProtected(ctx context.Context, key string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if _, exists := sd.values[key]; exists {
+		delete(sd.values, key)
+		delete(sd.values, keyExpiryPrefix+key)
+		sd.status = Modified
+	}
+
+	unprotectKeys(sd, key)
+	return nil
+}
+
+// TryRemove deletes the given key and corresponding value from the session
+// data in the same way as Remove, but returns ErrKeyProtected if the key has
+// been protected with Protect, and ErrReadOnly if SessionManager.ReadOnly is
+// set.
+func (s *SessionManager) TryRemove(ctx context.Context, key string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+	return s.remove(ctx, key, false)
+}
+
+// isProtected reports whether key is protected. The list of protected keys
+// is itself protected, so it can't be replaced by Put. The caller must hold
+// sd.mu.
+func isProtected(sd *sessionData, key string) bool {
+	protected, _ := sd.values[protectedKeysKey].([]string)
+	if key == protectedKeysKey {
+		return len(protected) > 0
+	}
+	return containsString(protected, key)
+}
+
+// protectKeys adds keys to the list of protected keys, returning the keys
+// which were not already protected. The caller must hold sd.mu.
+func protectKeys(sd *sessionData, keys ...string) []string {
+	protected, _ := sd.values[protectedKeysKey].([]string)
+	updated := append([]string(nil), protected...)
+	var added []string
+	for _, key := range keys {
+		if !containsString(updated, key) {
+			updated = append(updated, key)
+			added = append(added, key)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	sd.values[protectedKeysKey] = updated
+	sd.status = Modified
+	return added
+}
+
+// unprotectKeys removes keys from the list of protected keys. The caller must
+// hold sd.mu.
+func unprotectKeys(sd *sessionData, keys ...string) {
+	protected, _ := sd.values[protectedKeysKey].([]string)
+	kept := make([]string, 0, len(protected))
+	for _, k := range protected {
+		if !containsString(keys, k) {
+			kept = append(kept, k)
+		}
+	}
+	if len(kept) == len(protected) {
+		return
+	}
+
+	if len(kept) == 0 {
+		delete(sd.values, protectedKeysKey)
+	} else {
+		sd.values[protectedKeysKey] = kept
+	}
+	sd.status = Modified
+}
@@ -0,0 +1,106 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	t.Parallel()
+
+	var rejected []error
+	s := New()
+	s.RejectedWriteFunc = func(ctx context.Context, key string, err error) {
+		rejected = append(rejected, err)
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PutProtected(ctx, "user_id", 42); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsProtected(ctx, "user_id") {
+		t.Error("want user_id to be protected")
+	}
+
+	s.Put(ctx, "user_id", 1)
+	s.Remove(ctx, "user_id")
+	if s.Pop(ctx, "user_id") != nil {
+		t.Error("want Pop to return nil for a protected key")
+	}
+	if _, err := s.TryPop(ctx, "user_id"); err != ErrKeyProtected {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+	s.Put(ctx, protectedKeysKey, []string{})
+	if got := s.GetInt(ctx, "user_id"); got != 42 {
+		t.Errorf("want %d; got %d", 42, got)
+	}
+	if len(rejected) != 3 || !errors.Is(rejected[0], ErrKeyProtected) {
+		t.Errorf("want 3 ErrKeyProtected errors; got %v", rejected)
+	}
+
+	if err := s.TryPut(ctx, "user_id", 1); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+	if err := s.TryRemove(ctx, "user_id"); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+
+	// Protection survives a round trip through the store.
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TryPut(ctx, "user_id", 1); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+
+	if err := s.PutProtected(ctx, "user_id", 7); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetInt(ctx, "user_id"); got != 7 {
+		t.Errorf("want %d; got %d", 7, got)
+	}
+
+	if err := s.RemoveProtected(ctx, "user_id"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(ctx, "user_id") || s.IsProtected(ctx, "user_id") || s.Exists(ctx, protectedKeysKey) {
+		t.Error("want user_id and its protection to be removed")
+	}
+	if err := s.TryPut(ctx, "user_id", 1); err != nil {
+		t.Errorf("want nil error; got %v", err)
+	}
+}
+
+func TestProtectUnsetKey(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Protect(ctx, "auth_level", "auth_level"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TryPut(ctx, "auth_level", 2); !errors.Is(err, ErrKeyProtected) {
+		t.Errorf("want %v; got %v", ErrKeyProtected, err)
+	}
+	if err := s.TryRemove(ctx, "auth_level"); err != nil {
+		t.Errorf("want nil error for a missing key; got %v", err)
+	}
+	if keys := s.Keys(ctx); len(keys) != 1 || keys[0] != protectedKeysKey {
+		t.Errorf("want only %q; got %v", protectedKeysKey, keys)
+	}
+}
@@ -0,0 +1,82 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUserQuotaExceeded is returned by Commit when committing the session data
+// would take the total size of a user's sessions over
+// SessionManager.UserQuota.
+var ErrUserQuotaExceeded = errors.New("scs: user session quota exceeded")
+
+// Usage describes the sessions in the store belonging to a user.
+type Usage struct {
+	// Sessions is the number of active sessions.
+	Sessions int
+
+	// Bytes is the total encoded size of the session data.
+	Bytes int
+}
+
+// UsageForUser returns the number of active sessions belonging to a user and
+// their total encoded size in the session store. The SessionManager.UserKey
+// setting is required and the session store must support iteration.
+func (s *SessionManager) UsageForUser(ctx context.Context, userID string) (Usage, error) {
+	sessions, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var usage Usage
+	for _, us := range sessions {
+		usage.Sessions++
+		usage.Bytes += us.size
+	}
+	return usage, nil
+}
+
+// checkUserQuota returns ErrUserQuotaExceeded if committing the session data
+// would take the total size of the user's sessions over UserQuota. Session
+// data which hasn't grown since it was loaded is not checked, so that users
+// already over the quota can still use their sessions. It must be called with
+// sd.mu held.
+func (s *SessionManager) checkUserQuota(ctx context.Context, sd *sessionData) error {
+	if s.UserQuota <= 0 || s.UserKey == "" {
+		return nil
+	}
+	userID, ok := sd.values[s.UserKey]
+	if !ok {
+		return nil
+	}
+
+	b, err := s.encode(sd.deadline, sd.values)
+	if err != nil {
+		return err
+	}
+	if len(b) <= sd.stats.size {
+		return nil
+	}
+
+	sessions, err := s.userSessions(ctx, fmt.Sprint(userID))
+	if err != nil {
+		return err
+	}
+
+	current := sd.token
+	if s.HashTokenInStore {
+		current = hashToken(current)
+	}
+	total := len(b)
+	for _, us := range sessions {
+		if us.storeToken != current {
+			total += us.size
+		}
+	}
+
+	if total > s.UserQuota {
+		return ErrUserQuotaExceeded
+	}
+	return nil
+}
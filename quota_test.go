@@ -0,0 +1,105 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUsageForUser(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+
+	var want int
+	for i := 0; i < 3; i++ {
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "userID", "alice")
+		s.Put(ctx, "data", strings.Repeat("x", 100*i))
+		if _, _, err := s.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		b, err := s.Codec.Encode(s.Deadline(ctx), map[string]interface{}{"userID": "alice", "data": strings.Repeat("x", 100*i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += len(b)
+	}
+
+	usage, err := s.UsageForUser(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Sessions != 3 || usage.Bytes != want {
+		t.Errorf("want {3 %d}; got %+v", want, usage)
+	}
+
+	if usage, err := s.UsageForUser(context.Background(), "bob"); err != nil || usage != (Usage{}) {
+		t.Errorf("want no usage; got %+v, %v", usage, err)
+	}
+}
+
+func TestUsageForUserNoUserKey(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	if _, err := s.UsageForUser(context.Background(), "alice"); !errors.Is(err, ErrNoUserKey) {
+		t.Errorf("want %v; got %v", ErrNoUserKey, err)
+	}
+}
+
+func TestUserQuota(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+	s.UserQuota = 1000
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "userID", "alice")
+	s.Put(ctx, "data", strings.Repeat("x", 500))
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second session takes the user over the quota.
+	other, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(other, "userID", "alice")
+	s.Put(other, "data", strings.Repeat("x", 500))
+	if _, _, err := s.Commit(other); !errors.Is(err, ErrUserQuotaExceeded) {
+		t.Errorf("want %v; got %v", ErrUserQuotaExceeded, err)
+	}
+
+	// Sessions for other users are unaffected.
+	s.Put(other, "userID", "bob")
+	if _, _, err := s.Commit(other); err != nil {
+		t.Errorf("want nil error; got %v", err)
+	}
+
+	// The existing session can be committed as long as it doesn't grow past
+	// the quota.
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "data", strings.Repeat("y", 500))
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Errorf("want nil error; got %v", err)
+	}
+	s.Put(ctx, "data", strings.Repeat("y", 1000))
+	if _, _, err := s.Commit(ctx); !errors.Is(err, ErrUserQuotaExceeded) {
+		t.Errorf("want %v; got %v", ErrUserQuotaExceeded, err)
+	}
+}
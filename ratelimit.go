@@ -0,0 +1,200 @@
+package scs
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter provides middleware which limits the rate of requests per
+// session using a token bucket. The bucket state is stored in the session
+// data, so no additional infrastructure is needed, but this means that every
+// request which passes through the middleware will cause the session to be
+// committed to the store.
+//
+// Each request works on its own copy of the session data, so the RateLimiter
+// also keeps the latest bucket state for each session in memory, and updates
+// it under a lock, so that concurrent requests from the same session are
+// counted exactly within a single process. When the application runs on more
+// than one instance, the limit is approximate for concurrent requests from the
+// same session which are handled by different instances.
+type RateLimiter struct {
+	// SessionManager is the session manager holding the bucket state.
+	SessionManager *SessionManager
+
+	// Name identifies the bucket in the session data. Use different names
+	// for rate limiters which should be counted separately.
+	Name string
+
+	// Limit is the number of requests permitted per Interval. It is also the
+	// bucket capacity, so a session may make Limit requests in a burst. If it
+	// is zero or less, all requests are rejected.
+	Limit int
+
+	// Interval is the period over which the bucket refills to Limit. If it is
+	// zero or less, all requests are rejected.
+	Interval time.Duration
+
+	// MaxDelay is the maximum length of time a request will be delayed while
+	// waiting for the bucket to refill. Requests which would need to wait
+	// longer than this are rejected. The default value is 0, which means that
+	// requests over the limit are rejected immediately.
+	MaxDelay time.Duration
+
+	// LimitedHandler is called when a request is rejected. The default
+	// behavior is to send a HTTP 429 "Too Many Requests" response with a
+	// Retry-After header.
+	LimitedHandler http.Handler
+
+	mu        sync.Mutex
+	buckets   map[string]bucket
+	lastPrune time.Time
+}
+
+// bucket is the state of a session's token bucket.
+type bucket struct {
+	tokens  float64
+	updated int64
+}
+
+// NewRateLimiter returns a new RateLimiter which permits limit requests per
+// interval for each session. It panics if limit is negative or interval is not
+// positive. A limit of zero rejects all requests.
+func NewRateLimiter(s *SessionManager, name string, limit int, interval time.Duration) *RateLimiter {
+	if limit < 0 {
+		panic(fmt.Sprintf("scs: NewRateLimiter called with negative limit %d", limit))
+	}
+	if interval <= 0 {
+		panic(fmt.Sprintf("scs: NewRateLimiter called with non-positive interval %v", interval))
+	}
+
+	return &RateLimiter{
+		SessionManager: s,
+		Name:           name,
+		Limit:          limit,
+		Interval:       interval,
+	}
+}
+
+// Handler returns the rate limiting middleware. It must be used inside the
+// LoadAndSave() middleware.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wait, ok := rl.take(r)
+
+		if !ok || wait > rl.MaxDelay {
+			if ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			}
+			if rl.LimitedHandler != nil {
+				rl.LimitedHandler.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take removes a token from the session's bucket and returns how long the
+// request must wait before it can proceed. If the returned duration is greater
+// than MaxDelay no token is taken. It returns false if the request can never
+// proceed, because Limit or Interval is zero or less.
+func (rl *RateLimiter) take(r *http.Request) (time.Duration, bool) {
+	if rl.Limit <= 0 || rl.Interval <= 0 {
+		return 0, false
+	}
+
+	ctx := r.Context()
+	s := rl.SessionManager
+	tokensKey := "__rateLimit." + rl.Name + ".tokens"
+	updatedKey := "__rateLimit." + rl.Name + ".updated"
+	token := s.Token(ctx)
+
+	b := bucket{tokens: float64(rl.Limit)}
+	if s.Exists(ctx, tokensKey) {
+		b = bucket{tokens: s.GetFloat(ctx, tokensKey), updated: s.GetInt64(ctx, updatedKey)}
+	}
+
+	wait, b, taken := rl.takeFrom(token, b)
+	if taken {
+		s.Put(ctx, tokensKey, b.tokens)
+		s.Put(ctx, updatedKey, b.updated)
+	}
+	return wait, true
+}
+
+// takeFrom removes a token from the bucket b, as read from the session data
+// for the session with the given token, and returns how long the request must
+// wait, the new state of the bucket and whether the token was taken. The
+// read-modify-write is made under a lock, using the latest state recorded for
+// the session if it is newer than b, so that it is atomic for concurrent
+// requests from the same session in this process.
+func (rl *RateLimiter) takeFrom(token string, b bucket) (time.Duration, bucket, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if latest, ok := rl.buckets[token]; ok && latest.updated > b.updated {
+		b = latest
+	}
+
+	now := time.Now()
+	rate := float64(rl.Limit) / float64(rl.Interval)
+	if b.updated != 0 {
+		elapsed := now.Sub(time.Unix(0, b.updated))
+		b.tokens = math.Min(float64(rl.Limit), b.tokens+float64(elapsed)*rate)
+	}
+
+	b.tokens--
+	b.updated = now.UnixNano()
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / rate)
+		if wait > rl.MaxDelay {
+			return wait, bucket{}, false
+		}
+	}
+
+	// A new session has no token until it is committed, so it can't be
+	// shared by concurrent requests.
+	if token != "" {
+		rl.remember(token, b, now)
+	}
+	return wait, b, true
+}
+
+// remember records the latest bucket state for a session, first removing the
+// state for sessions whose buckets have had time to refill completely. It
+// must be called with rl.mu held.
+func (rl *RateLimiter) remember(token string, b bucket, now time.Time) {
+	if rl.buckets == nil {
+		rl.buckets = make(map[string]bucket)
+	}
+
+	if now.Sub(rl.lastPrune) >= rl.Interval {
+		for t, old := range rl.buckets {
+			if now.Sub(time.Unix(0, old.updated)) >= rl.Interval {
+				delete(rl.buckets, t)
+			}
+		}
+		rl.lastPrune = now
+	}
+
+	rl.buckets[token] = b
+}
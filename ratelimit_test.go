@@ -0,0 +1,155 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	rl := NewRateLimiter(sessionManager, "api", 2, time.Second)
+
+	mux := http.NewServeMux()
+	mux.Handle("/limited", rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})))
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		_, body := ts.execute(t, "/limited")
+		if body != "OK" {
+			t.Fatalf("request %d: want %q; got %q", i, "OK", body)
+		}
+	}
+
+	header, body := ts.execute(t, "/limited")
+	if body != "Too Many Requests\n" {
+		t.Errorf("want %q; got %q", "Too Many Requests\n", body)
+	}
+	if header.Get("Retry-After") != "1" {
+		t.Errorf("want %q; got %q", "1", header.Get("Retry-After"))
+	}
+
+	time.Sleep(600 * time.Millisecond)
+
+	_, body = ts.execute(t, "/limited")
+	if body != "OK" {
+		t.Errorf("want %q; got %q", "OK", body)
+	}
+}
+
+func TestRateLimiterDelay(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	rl := NewRateLimiter(sessionManager, "api", 2, time.Second)
+	rl.MaxDelay = time.Second
+
+	mux := http.NewServeMux()
+	mux.Handle("/limited", rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})))
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		ts.execute(t, "/limited")
+	}
+
+	start := time.Now()
+	_, body := ts.execute(t, "/limited")
+	if body != "OK" {
+		t.Errorf("want %q; got %q", "OK", body)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("want request to be delayed; took %v", elapsed)
+	}
+}
+
+func TestRateLimiterZeroLimit(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	rl := NewRateLimiter(sessionManager, "api", 0, time.Second)
+
+	h := sessionManager.LoadAndSave(rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("want %d; got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	rl.Limit = 2
+	rl.Interval = 0
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("want %d; got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic for non-positive interval")
+		}
+	}()
+	NewRateLimiter(sessionManager, "api", 1, 0)
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// All the requests load the session before any of them commit it.
+	sessionManager := New()
+	sessionManager.Store = slowFindStore{memstore.NewWithCleanupInterval(0)}
+	rl := NewRateLimiter(sessionManager, "api", 3, time.Hour)
+
+	h := sessionManager.LoadAndSave(rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})))
+
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionManager.Put(ctx, "foo", "bar")
+	token, _, err := sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var allowed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: sessionManager.Cookie.Name, Value: token})
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if rr.Code == http.StatusOK {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 3 {
+		t.Errorf("want %d requests allowed; got %d", 3, allowed)
+	}
+}
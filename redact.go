@@ -0,0 +1,43 @@
+package scs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// RedactToken returns a short, stable fingerprint for a session token which is
+// safe to write to logs and pass to monitoring systems. The same token always
+// has the same fingerprint, so requests and sessions can be correlated across
+// log entries, but the fingerprint can't be used to access the session or to
+// recover the token. An empty string is returned for an empty token.
+//
+// The fingerprint is the same as the SessionInfo.ID reported by the
+// AdminHandler and SessionsForUser for the session. If
+// SessionManager.TokenFingerprintKey is set, the fingerprint is derived using
+// HMAC-SHA256 with that key; otherwise a plain SHA-256 hash is used. For
+// example, to log the session for a failed request:
+//
+//	log.Printf("checkout failed: session=%s err=%v", sessionManager.RedactToken(sessionManager.Token(r.Context())), err)
+func (s *SessionManager) RedactToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	if s.HashTokenInStore {
+		token = hashToken(token)
+	}
+	return s.fingerprint(token)
+}
+
+// fingerprint returns the fingerprint for a session, given the token as it
+// appears in the store.
+func (s *SessionManager) fingerprint(storeToken string) string {
+	key := s.tokenFingerprintKey()
+	if len(key) == 0 {
+		return hashToken(storeToken)[:16]
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(storeToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:16]
+}
@@ -0,0 +1,61 @@
+package scs
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRedactToken(t *testing.T) {
+	t.Parallel()
+
+	for _, hash := range []bool{false, true} {
+		s := New()
+		s.UserKey = "userID"
+		s.HashTokenInStore = hash
+
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "userID", 1)
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fp := s.RedactToken(token)
+		if len(fp) != 16 || strings.Contains(token, fp) {
+			t.Errorf("hash %v: want 16 character fingerprint not contained in the token; got %q", hash, fp)
+		}
+		if s.RedactToken(token) != fp {
+			t.Errorf("hash %v: want stable fingerprint", hash)
+		}
+
+		sessions, err := s.SessionsForUser(context.Background(), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != fp {
+			t.Errorf("hash %v: want session ID %q; got %v", hash, fp, sessions)
+		}
+
+		s.TokenFingerprintKey = []byte("01234567890123456789012345678901")
+		keyed := s.RedactToken(token)
+		if keyed == fp || len(keyed) != 16 {
+			t.Errorf("hash %v: want different keyed fingerprint; got %q", hash, keyed)
+		}
+
+		sessions, err = s.SessionsForUser(context.Background(), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != keyed {
+			t.Errorf("hash %v: want session ID %q; got %v", hash, keyed, sessions)
+		}
+	}
+
+	if fp := New().RedactToken(""); fp != "" {
+		t.Errorf("want empty fingerprint for empty token; got %q", fp)
+	}
+}
@@ -0,0 +1,192 @@
+package redisstore
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HashStore represents a session store which holds each session as a Redis
+// hash, with a field for each session value, instead of as a single encoded
+// string. It implements the scs.PartialStore interface, so that the session
+// manager only writes the values which have changed, and individual values can
+// be read and expired with the Redis hash commands.
+type HashStore struct {
+	*RedisStore
+}
+
+// NewHashStore returns a new HashStore instance. The pool parameter should be a
+// pointer to a redigo connection pool, and the prefix parameter controls the
+// Redis key prefix, as for NewWithPrefix. Sessions stored by a RedisStore with
+// the same prefix are still found, and are converted to hashes when they are
+// next committed.
+func NewHashStore(pool *redis.Pool, prefix string) *HashStore {
+	return &HashStore{RedisStore: NewWithPrefix(pool, prefix)}
+}
+
+// FindValues returns the encoded values for a given session token from the
+// HashStore instance. If the session token is not found, is expired or is not
+// stored as a hash, the returned exists flag will be set to false.
+func (h *HashStore) FindValues(token string) (values map[string][]byte, exists bool, err error) {
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.ByteSlices(conn.Do("HGETALL", h.prefix+token))
+	if isWrongType(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+
+	values = make(map[string][]byte, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		values[string(fields[i])] = fields[i+1]
+	}
+	return values, true, nil
+}
+
+// FindKeys returns the values for the given keys from the hash for a session
+// token. Keys which don't exist are omitted. If the session token is not
+// found, is expired, or is held as a string written by Commit, the returned
+// exists flag will be set to false.
+func (h *HashStore) FindKeys(token string, keys []string) (values map[string][]byte, exists bool, err error) {
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.ByteSlices(conn.Do("HMGET", redis.Args{h.prefix + token}.AddFlat(keys)...))
+	if isWrongType(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	values = make(map[string][]byte, len(keys))
+	for i, b := range fields {
+		if b != nil {
+			values[keys[i]] = b
+		}
+	}
+	if len(values) == 0 {
+		// HMGET returns nil values for a hash which doesn't exist.
+		exists, err := redis.Bool(conn.Do("EXISTS", h.prefix+token))
+		if err != nil || !exists {
+			return nil, false, err
+		}
+	}
+	return values, true, nil
+}
+
+// CommitValues sets and removes the given values in the hash for a session
+// token, and updates its expiry time. If replace is true, any existing data
+// for the session token is deleted first.
+func (h *HashStore) CommitValues(token string, set map[string][]byte, remove []string, replace bool, expiry time.Time) error {
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	key := h.prefix + token
+
+	err := conn.Send("MULTI")
+	if err != nil {
+		return err
+	}
+	if replace {
+		if err = conn.Send("DEL", key); err != nil {
+			return err
+		}
+	}
+	if len(set) > 0 {
+		args := redis.Args{key}
+		for field, b := range set {
+			args = append(args, field, b)
+		}
+		if err = conn.Send("HSET", args...); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if err = conn.Send("HDEL", redis.Args{key}.AddFlat(remove)...); err != nil {
+			return err
+		}
+	}
+	err = conn.Send("PEXPIREAT", key, makeMillisecondTimestamp(expiry))
+	if err != nil {
+		return err
+	}
+	return exec(conn)
+}
+
+// errAborted is returned by exec when a transaction is aborted.
+var errAborted = errors.New("redisstore: transaction aborted")
+
+// exec executes the transaction queued on conn with MULTI. It returns the
+// first error returned by a command in the transaction, as EXEC only returns
+// an error if the transaction can't be executed.
+func exec(conn redis.Conn) error {
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err == redis.ErrNil {
+		return errAborted
+	} else if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllValues returns a map containing the token and values for all active
+// sessions stored as hashes in the HashStore instance.
+func (h *HashStore) AllValues() (map[string]map[string][]byte, error) {
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	keys, err := redis.Strings(conn.Do("KEYS", h.prefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]map[string][]byte)
+	for _, key := range keys {
+		token := key[len(h.prefix):]
+
+		values, exists, err := h.FindValues(token)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			sessions[token] = values
+		}
+	}
+
+	return sessions, nil
+}
+
+// ExpireValue sets an expiry time for a single value in the hash for a session
+// token, after which Redis removes the value from the session. It requires
+// Redis 7.4 or newer. The session key is the same key used with the session
+// manager, for example:
+//
+//	err := store.ExpireValue(token, "flash", time.Now().Add(time.Minute))
+//
+// When the session token is hashed in the store (SessionManager.HashTokenInStore),
+// pass the hashed token.
+func (h *HashStore) ExpireValue(token, key string, expiry time.Time) error {
+	conn := h.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("HPEXPIREAT", h.prefix+token, makeMillisecondTimestamp(expiry), "FIELDS", 1, key)
+	return err
+}
+
+// isWrongType reports whether err is the error Redis returns when a command is
+// used with a key holding the wrong type of value, such as GET with a hash.
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
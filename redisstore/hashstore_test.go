@@ -0,0 +1,139 @@
+package redisstore
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestHashStore(t *testing.T) {
+	redisPool := redis.NewPool(func() (redis.Conn, error) {
+		addr := os.Getenv("SCS_REDIS_TEST_DSN")
+		conn, err := redis.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return conn, err
+	}, 1)
+	defer redisPool.Close()
+
+	h := NewHashStore(redisPool, "scs:session:")
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("FLUSHDB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A session stored as a string is replaced by the hash.
+	err = h.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, err := h.FindValues("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != false {
+		t.Fatalf("got %v: expected %v", found, false)
+	}
+
+	err = h.CommitValues("session_token", map[string][]byte{"a": []byte("one"), "b": []byte("two")}, nil, true, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.CommitValues("session_token", map[string][]byte{"c": []byte("three")}, []string{"a"}, false, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, found, err := h.FindValues("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"b": []byte("two"), "c": []byte("three")}
+	if found != true || !reflect.DeepEqual(values, want) {
+		t.Fatalf("got %v: expected %v", values, want)
+	}
+
+	_, found, err = h.Find("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != false {
+		t.Fatalf("got %v: expected %v", found, false)
+	}
+
+	all, err := h.AllValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all, map[string]map[string][]byte{"session_token": want}) {
+		t.Fatalf("got %v: expected %v", all, want)
+	}
+
+	ttl, err := redis.Int(conn.Do("TTL", h.prefix+"session_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl <= 0 || ttl > 60 {
+		t.Fatalf("got %d: expected TTL of up to 60 seconds", ttl)
+	}
+
+	values, found, err = h.FindKeys("session_token", []string{"c", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]byte{"c": []byte("three")}
+	if found != true || !reflect.DeepEqual(values, want) {
+		t.Fatalf("got %v: expected %v", values, want)
+	}
+
+	_, found, err = h.FindKeys("missing_token", []string{"c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != false {
+		t.Fatalf("got %v: expected %v", found, false)
+	}
+	// Errors from the commands in the transaction are returned.
+	if _, err := conn.Do("RPUSH", h.prefix+"list_token", "a"); err != nil {
+		t.Fatal(err)
+	}
+	err = h.CommitValues("list_token", map[string][]byte{"a": []byte("one")}, nil, false, time.Now().Add(time.Minute))
+	if _, ok := err.(redis.Error); !ok {
+		t.Fatalf("got %v: expected WRONGTYPE error", err)
+	}
+}
+
+// execConn is a redis.Conn which returns reply for EXEC.
+type execConn struct {
+	redis.Conn
+	reply interface{}
+}
+
+func (c execConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.reply, nil
+}
+
+func TestExec(t *testing.T) {
+	wrongType := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	tests := []struct {
+		reply interface{}
+		want  error
+	}{
+		{[]interface{}{int64(1), "OK"}, nil},
+		{[]interface{}{int64(1), wrongType, int64(1)}, wrongType},
+		{nil, errAborted},
+	}
+	for _, tt := range tests {
+		if err := exec(execConn{reply: tt.reply}); err != tt.want {
+			t.Errorf("got %v: expected %v", err, tt.want)
+		}
+	}
+}
@@ -36,7 +36,8 @@ func (r *RedisStore) Find(token string) (b []byte, exists bool, err error) {
 	defer conn.Close()
 
 	b, err = redis.Bytes(conn.Do("GET", r.prefix+token))
-	if err == redis.ErrNil {
+	if err == redis.ErrNil || isWrongType(err) {
+		// A session held as a hash by a HashStore isn't returned by Find.
 		return nil, false, nil
 	} else if err != nil {
 		return nil, false, err
@@ -67,6 +68,28 @@ func (r *RedisStore) Commit(token string, b []byte, expiry time.Time) error {
 	return err
 }
 
+// Add adds a session token and data to the RedisStore instance with the given
+// expiry time, but only if the token doesn't already exist. It reports whether
+// the data was added.
+func (r *RedisStore) Add(token string, b []byte, expiry time.Time) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	// SET with NX and PX is used rather than MULTI with PEXPIREAT, so that the
+	// expiry of an existing token isn't changed.
+	ttl := makeMillisecondTimestamp(expiry) - makeMillisecondTimestamp(time.Now())
+	if ttl < 1 {
+		ttl = 1
+	}
+	_, err := redis.String(conn.Do("SET", r.prefix+token, b, "PX", ttl, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Delete removes a session token and corresponding data from the RedisStore
 // instance.
 func (r *RedisStore) Delete(token string) error {
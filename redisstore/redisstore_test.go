@@ -223,3 +223,55 @@ func TestDelete(t *testing.T) {
 		t.Fatalf("got %v: expected %v", data, nil)
 	}
 }
+
+func TestAdd(t *testing.T) {
+	redisPool := redis.NewPool(func() (redis.Conn, error) {
+		addr := os.Getenv("SCS_REDIS_TEST_DSN")
+		conn, err := redis.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return conn, err
+	}, 1)
+	defer redisPool.Close()
+
+	conn := redisPool.Get()
+	defer conn.Close()
+	_, err := conn.Do("FLUSHDB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(redisPool)
+
+	added, err := r.Add("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added {
+		t.Fatal("want session_token to be added")
+	}
+
+	added, err = r.Add("session_token", []byte("new_encoded_data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added {
+		t.Fatal("want existing session_token not to be added")
+	}
+
+	data, err := redis.Bytes(conn.Do("GET", r.prefix+"session_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, []byte("encoded_data")) {
+		t.Fatalf("got %v: expected %v", data, []byte("encoded_data"))
+	}
+	ttl, err := redis.Int(conn.Do("TTL", r.prefix+"session_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl > 60 {
+		t.Fatalf("got %d: expected expiry to be unchanged", ttl)
+	}
+}
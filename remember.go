@@ -0,0 +1,242 @@
+package scs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const rememberValidatorKey = "__rememberValidator"
+
+// PersistentLogin implements long-lived 'remember me' logins which are
+// independent of the session token. When a user logs in, Issue creates a new
+// login series and sends the client a second cookie containing a
+// selector:validator pair. The selector identifies the series in the store, and
+// only a SHA-256 hash of the validator is stored.
+//
+// If the user's session later expires, the Restore middleware uses the cookie
+// to silently create a new session containing the remembered values. The
+// validator is rotated every time the cookie is used, and if a cookie is
+// presented with a valid selector but the wrong validator (which suggests the
+// cookie has been stolen and used by someone else) the whole series is
+// revoked.
+type PersistentLogin struct {
+	// SessionManager is the session manager used to create restored sessions.
+	SessionManager *SessionManager
+
+	// Store controls where the login series are persisted. The default is the
+	// session manager's store. Series are stored with the "remember:" prefix,
+	// so if you use Iterate() with a shared store you should set this to a
+	// separate store instead.
+	Store Store
+
+	// Cookie contains the configuration settings for the 'remember me'
+	// cookie. The default name is "remember", and the cookie is always
+	// persistent. Secure defaults to the session cookie setting, and follows
+	// changes made with SessionManager.SetCookieSecure.
+	Cookie SessionCookie
+
+	// Lifetime controls how long a login series is valid for. It is set when
+	// the series is issued and does not change when the validator is rotated.
+	// The default value is 30 days.
+	Lifetime time.Duration
+
+	// Keys lists the session data keys (such as "userID") which are saved
+	// with the login series and restored into the new session.
+	Keys []string
+}
+
+// NewPersistentLogin returns a new PersistentLogin for the session manager s
+// with the default settings, restoring the given session data keys.
+func NewPersistentLogin(s *SessionManager, keys ...string) *PersistentLogin {
+	return &PersistentLogin{
+		SessionManager: s,
+		Store:          s.Store,
+		Lifetime:       30 * 24 * time.Hour,
+		Keys:           keys,
+		Cookie: SessionCookie{
+			Name:     "remember",
+			HttpOnly: true,
+			Path:     "/",
+			SameSite: http.SameSiteLaxMode,
+			Secure:   s.cookie().Secure,
+		},
+	}
+}
+
+// Issue starts a new login series containing the current values of p.Keys from
+// the session data, and writes the 'remember me' cookie to w. It should be
+// called after a successful login.
+func (p *PersistentLogin) Issue(ctx context.Context, w http.ResponseWriter) error {
+	values := make(map[string]interface{}, len(p.Keys)+1)
+	for _, key := range p.Keys {
+		if p.SessionManager.Exists(ctx, key) {
+			values[key] = p.SessionManager.Get(ctx, key)
+		}
+	}
+
+	selector, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	return p.commitSeries(ctx, w, selector, values, time.Now().Add(p.Lifetime).UTC())
+}
+
+// Revoke deletes the login series identified by the 'remember me' cookie in
+// the request r, and writes an expired cookie to w. It should be called when
+// the user logs out.
+func (p *PersistentLogin) Revoke(w http.ResponseWriter, r *http.Request) error {
+	p.writeCookie(w, "", time.Time{})
+
+	selector, _, ok := p.parseCookie(r)
+	if !ok {
+		return nil
+	}
+
+	return p.store().Delete(p.storeKey(selector))
+}
+
+// Restore provides middleware which restores the remembered session data when
+// the current session doesn't contain any of p.Keys and the request has a
+// valid 'remember me' cookie. The session token is renewed before the data is
+// restored. It must be used inside the LoadAndSave() middleware.
+func (p *PersistentLogin) Restore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := p.restore(w, r); err != nil {
+			p.SessionManager.ErrorFunc(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *PersistentLogin) restore(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	s := p.SessionManager
+
+	for _, key := range p.Keys {
+		if s.Exists(ctx, key) {
+			return nil
+		}
+	}
+
+	selector, validator, ok := p.parseCookie(r)
+	if !ok {
+		return nil
+	}
+
+	b, found, err := p.store().Find(p.storeKey(selector))
+	if err != nil {
+		return err
+	} else if !found {
+		p.writeCookie(w, "", time.Time{})
+		return nil
+	}
+
+	expiry, values, err := s.decode(b)
+	if err != nil {
+		return err
+	}
+
+	hash, _ := values[rememberValidatorKey].(string)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(validator))) != 1 {
+		p.writeCookie(w, "", time.Time{})
+		return p.store().Delete(p.storeKey(selector))
+	}
+	delete(values, rememberValidatorKey)
+
+	if err := s.RenewToken(ctx); err != nil {
+		return err
+	}
+	for key, val := range values {
+		s.Put(ctx, key, val)
+	}
+
+	return p.commitSeries(ctx, w, selector, values, expiry)
+}
+
+func (p *PersistentLogin) commitSeries(ctx context.Context, w http.ResponseWriter, selector string, values map[string]interface{}, expiry time.Time) error {
+	validator, err := randomString(32)
+	if err != nil {
+		return err
+	}
+
+	record := make(map[string]interface{}, len(values)+1)
+	for key, val := range values {
+		record[key] = val
+	}
+	record[rememberValidatorKey] = hashToken(validator)
+
+	b, err := p.SessionManager.encode(expiry, record)
+	if err != nil {
+		return err
+	}
+
+	if err := p.store().Commit(p.storeKey(selector), b, expiry); err != nil {
+		return err
+	}
+
+	p.writeCookie(w, selector+":"+validator, expiry)
+	return nil
+}
+
+func (p *PersistentLogin) parseCookie(r *http.Request) (selector, validator string, ok bool) {
+	cookie, err := r.Cookie(p.Cookie.Name)
+	if err != nil {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(cookie.Value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+func (p *PersistentLogin) writeCookie(w http.ResponseWriter, value string, expiry time.Time) {
+	cookie := &http.Cookie{
+		Name:     p.Cookie.Name,
+		Value:    value,
+		Path:     p.Cookie.Path,
+		Domain:   p.Cookie.domain(nil),
+		Secure:   p.SessionManager.companionSecure(p.Cookie.Secure),
+		HttpOnly: p.Cookie.HttpOnly,
+		SameSite: p.Cookie.SameSite,
+	}
+
+	if expiry.IsZero() {
+		cookie.Expires = time.Unix(1, 0)
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = time.Unix(expiry.Unix()+1, 0)
+		cookie.MaxAge = int(time.Until(expiry).Seconds() + 1)
+	}
+
+	w.Header().Add("Set-Cookie", p.SessionManager.cookieString(cookie))
+}
+
+func (p *PersistentLogin) store() Store {
+	if p.Store == nil {
+		return p.SessionManager.Store
+	}
+	return p.Store
+}
+
+func (p *PersistentLogin) storeKey(selector string) string {
+	return "remember:" + hashToken(selector)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,94 @@
+package scs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPersistentLogin(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	p := NewPersistentLogin(s, "userID")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "userID", 42)
+		if err := p.Issue(r.Context(), w); err != nil {
+			t.Fatal(err)
+		}
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		if err := p.Revoke(w, r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		if s.GetInt(r.Context(), "userID") != 42 {
+			w.Write([]byte("anonymous"))
+			return
+		}
+		w.Write([]byte("user"))
+	})
+	h := s.LoadAndSave(p.Restore(mux))
+
+	execute := func(path string, cookies ...string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(cookies) > 0 {
+			r.Header.Set("Cookie", strings.Join(cookies, "; "))
+		}
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rememberCookie := func(rr *httptest.ResponseRecorder) string {
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == "remember" {
+				return c.Name + "=" + c.Value
+			}
+		}
+		return ""
+	}
+
+	rr := execute("/login")
+	remember1 := rememberCookie(rr)
+	if remember1 == "" {
+		t.Fatal("want remember cookie")
+	}
+
+	// Without a session cookie, the session is restored and the validator
+	// rotated.
+	rr = execute("/get", remember1)
+	if rr.Body.String() != "user" {
+		t.Fatalf("want %q; got %q", "user", rr.Body.String())
+	}
+	remember2 := rememberCookie(rr)
+	if remember2 == "" || remember2 == remember1 {
+		t.Fatalf("want rotated remember cookie; got %q", remember2)
+	}
+	if !strings.Contains(strings.Join(rr.Header().Values("Set-Cookie"), "\n"), "session=") {
+		t.Error("want new session cookie")
+	}
+
+	// Presenting the old validator revokes the series.
+	rr = execute("/get", remember1)
+	if rr.Body.String() != "anonymous" {
+		t.Errorf("want %q; got %q", "anonymous", rr.Body.String())
+	}
+	rr = execute("/get", remember2)
+	if rr.Body.String() != "anonymous" {
+		t.Errorf("want %q; got %q", "anonymous", rr.Body.String())
+	}
+
+	// Logging out revokes the series.
+	rr = execute("/login")
+	remember3 := rememberCookie(rr)
+	execute("/logout", remember3)
+	rr = execute("/get", remember3)
+	if rr.Body.String() != "anonymous" {
+		t.Errorf("want %q; got %q", "anonymous", rr.Body.String())
+	}
+}
@@ -0,0 +1,25 @@
+module github.com/alexedwards/scs/scsctl
+
+go 1.21
+
+require (
+	github.com/alexedwards/scs/goredisstore v0.0.0
+	github.com/alexedwards/scs/mysqlstore v0.0.0
+	github.com/alexedwards/scs/postgresstore v0.0.0
+	github.com/alexedwards/scs/v2 v2.8.0
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/lib/pq v1.4.0
+	github.com/redis/go-redis/v9 v9.0.2
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+)
+
+replace (
+	github.com/alexedwards/scs/goredisstore => ../goredisstore
+	github.com/alexedwards/scs/mysqlstore => ../mysqlstore
+	github.com/alexedwards/scs/postgresstore => ../postgresstore
+	github.com/alexedwards/scs/v2 => ../
+)
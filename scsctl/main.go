@@ -0,0 +1,196 @@
+// Command scsctl is a maintenance tool for SCS session stores. It connects
+// directly to a session store and can be used to remove expired sessions, count
+// active sessions, inspect or delete individual sessions, and migrate sessions
+// to a different store.
+//
+// Usage:
+//
+//	scsctl -store <type> -dsn <dsn> [flags] <command> [arguments]
+//
+// The supported store types are "postgres", "mysql" and "redis". For the SQL
+// stores the DSN is passed to sql.Open; for Redis it is a redis:// URL.
+//
+// The commands are:
+//
+//	count           print the number of active sessions
+//	gc              delete expired sessions
+//	inspect <token> print the deadline and data for a session as JSON
+//	delete <token>  delete a session
+//	migrate         copy all active sessions to the store given by the
+//	                -to-store and -to-dsn flags
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alexedwards/scs/goredisstore"
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/v2"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// store is a session store opened by scsctl, along with a function to close
+// the connection.
+type store struct {
+	scs.Store
+	close func() error
+}
+
+func main() {
+	storeType := flag.String("store", "", `session store type ("postgres", "mysql" or "redis")`)
+	dsn := flag.String("dsn", "", "session store data source name")
+	toStoreType := flag.String("to-store", "", "destination session store type for the migrate command")
+	toDSN := flag.String("to-dsn", "", "destination session store data source name for the migrate command")
+	hash := flag.Bool("hash", false, "session tokens are hashed in the store (HashTokenInStore)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "session idle timeout, used to calculate expiry times when migrating")
+	flag.Parse()
+
+	src, err := openStore(*storeType, *dsn)
+	if err != nil {
+		fatal(err)
+	}
+	defer src.close()
+
+	sessionManager := scs.New()
+	sessionManager.Store = src.Store
+	sessionManager.HashTokenInStore = *hash
+	sessionManager.IdleTimeout = *idleTimeout
+
+	openDst := func() (*store, error) {
+		return openStore(*toStoreType, *toDSN)
+	}
+
+	if err := run(context.Background(), sessionManager, src, openDst, flag.Args(), os.Stdout); err != nil {
+		fatal(err)
+	}
+}
+
+func run(ctx context.Context, s *scs.SessionManager, src *store, openDst func() (*store, error), args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+
+	switch args[0] {
+	case "count":
+		n := 0
+		err := s.Iterate(ctx, func(context.Context) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, n)
+	case "gc":
+		n, err := s.GC(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "deleted %d expired sessions\n", n)
+	case "inspect":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: scsctl %s <token>", args[0])
+		}
+
+		// Inspect reads the session without changing the store, so data
+		// which can't be decoded is reported rather than deleted.
+		deadline, values, found, err := s.Inspect(ctx, args[1])
+		if err != nil {
+			return err
+		} else if !found {
+			return errors.New("session not found")
+		}
+
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Deadline time.Time              `json:"deadline"`
+			Values   map[string]interface{} `json:"values"`
+		}{deadline, values})
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: scsctl %s <token>", args[0])
+		}
+
+		sctx, err := s.Load(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if s.Token(sctx) == "" {
+			return errors.New("session not found")
+		}
+
+		if err := s.Destroy(sctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "deleted")
+	case "migrate":
+		dst, err := openDst()
+		if err != nil {
+			return err
+		}
+		defer dst.close()
+
+		n, err := s.Migrate(ctx, src.Store, dst.Store)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "migrated %d sessions\n", n)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return nil
+}
+
+func openStore(storeType, dsn string) (*store, error) {
+	switch storeType {
+	case "postgres", "mysql":
+		db, err := sql.Open(storeType, dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		s := &store{close: db.Close}
+		if storeType == "postgres" {
+			s.Store = postgresstore.NewWithCleanupInterval(db, 0)
+		} else {
+			s.Store = mysqlstore.NewWithCleanupInterval(db, 0)
+		}
+		return s, nil
+	case "redis":
+		opt, err := redis.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		client := redis.NewClient(opt)
+
+		// Redis removes expired keys automatically, so the gc command has
+		// nothing to do.
+		return &store{Store: goredisstore.New(client), close: client.Close}, nil
+	case "":
+		return nil, errors.New("no store type given")
+	default:
+		return nil, fmt.Errorf("unsupported store type %q", storeType)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "scsctl: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func newMemStore() *store {
+	return &store{
+		Store: memstore.NewWithCleanupInterval(0),
+		close: func() error { return nil },
+	}
+}
+
+func TestRun(t *testing.T) {
+	src := newMemStore()
+	dst := newMemStore()
+
+	s := scs.New()
+	s.Store = src.Store
+
+	b, err := s.Codec.Encode(time.Now().Add(time.Hour), map[string]interface{}{"foo": "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Commit("token1", b, time.Now().Add(time.Hour))
+	src.Commit("token2", b, time.Now().Add(time.Hour))
+
+	execute := func(args ...string) string {
+		var out bytes.Buffer
+		err := run(context.Background(), s, src, func() (*store, error) { return dst, nil }, args, &out)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	if out := execute("count"); out != "2\n" {
+		t.Errorf("count: want %q; got %q", "2\n", out)
+	}
+	if out := execute("inspect", "token1"); !strings.Contains(out, `"foo": "bar"`) {
+		t.Errorf("inspect: want session data; got %q", out)
+	}
+	if out := execute("migrate"); out != "migrated 2 sessions\n" {
+		t.Errorf("migrate: want %q; got %q", "migrated 2 sessions\n", out)
+	}
+	if _, found, _ := dst.Find("token1"); !found {
+		t.Error("migrate: want token1 in destination store")
+	}
+	if out := execute("delete", "token1"); out != "deleted\n" {
+		t.Errorf("delete: want %q; got %q", "deleted\n", out)
+	}
+	if out := execute("count"); out != "1\n" {
+		t.Errorf("count: want %q; got %q", "1\n", out)
+	}
+
+	var out bytes.Buffer
+	if err := run(context.Background(), s, src, nil, []string{"inspect", "missing"}, &out); err == nil {
+		t.Error("inspect: want error for missing session")
+	}
+	if err := run(context.Background(), s, src, nil, []string{"unknown"}, &out); err == nil {
+		t.Error("want error for unknown command")
+	}
+}
+
+func TestInspectUndecodable(t *testing.T) {
+	src := newMemStore()
+	s := scs.New()
+	s.Store = src.Store
+
+	src.Commit("bad", []byte("not session data"), time.Now().Add(time.Hour))
+
+	var out bytes.Buffer
+	if err := run(context.Background(), s, src, nil, []string{"inspect", "bad"}, &out); err == nil {
+		t.Error("inspect: want decode error")
+	}
+	if _, found, _ := src.Find("bad"); !found {
+		t.Error("inspect: want undecodable session to be kept in the store")
+	}
+}
+
+func TestGC(t *testing.T) {
+	src := newMemStore()
+	s := scs.New()
+	s.Store = src.Store
+
+	src.Commit("expired", []byte("data"), time.Now().Add(-time.Hour))
+	src.Commit("active", []byte("data"), time.Now().Add(time.Hour))
+
+	var out bytes.Buffer
+	if err := run(context.Background(), s, src, nil, []string{"gc"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "deleted 1 expired sessions\n" {
+		t.Errorf("gc: want %q; got %q", "deleted 1 expired sessions\n", out.String())
+	}
+}
@@ -0,0 +1,47 @@
+package scsecho
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// LoadAndSave returns Echo middleware which automatically loads and saves
+// session data for the current request, and communicates the session token to
+// and from the client in a cookie. It is the Echo equivalent of the
+// SessionManager.LoadAndSave() middleware.
+//
+// The writer underlying the echo.Response is swapped for the duration of the
+// request, so that the session cookie is added to the response headers at the
+// moment Echo commits the response, rather than after the headers have been
+// flushed.
+func LoadAndSave(s *scs.SessionManager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			res := c.Response()
+			w := res.Writer
+
+			s.LoadAndSave(http.HandlerFunc(func(sw http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				res.Writer = sw
+				defer func() { res.Writer = w }()
+
+				err = next(c)
+			})).ServeHTTP(w, c.Request())
+
+			return err
+		}
+	}
+}
+
+// Context returns the context.Context containing the session data for the
+// current request. It should be passed to the SessionManager methods from
+// within Echo handlers. For example:
+//
+//	msg := sessionManager.GetString(scsecho.Context(c), "message")
+func Context(c echo.Context) context.Context {
+	return c.Request().Context()
+}
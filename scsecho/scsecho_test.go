@@ -0,0 +1,68 @@
+package scsecho
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/labstack/echo/v4"
+)
+
+func newEcho(sessionManager *scs.SessionManager) *echo.Echo {
+	e := echo.New()
+	e.Use(LoadAndSave(sessionManager))
+	e.GET("/put", func(c echo.Context) error {
+		sessionManager.Put(Context(c), "foo", "bar")
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/put-nocontent", func(c echo.Context) error {
+		sessionManager.Put(Context(c), "foo", "bar")
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.GET("/put-error", func(c echo.Context) error {
+		sessionManager.Put(Context(c), "foo", "bar")
+		return errors.New("boom")
+	})
+	e.GET("/get", func(c echo.Context) error {
+		return c.String(http.StatusOK, sessionManager.GetString(Context(c), "foo"))
+	})
+	return e
+}
+
+func execute(h http.Handler, path string, cookie string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if cookie != "" {
+		r.Header.Set("Cookie", cookie)
+	}
+	h.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestLoadAndSave(t *testing.T) {
+	sessionManager := scs.New()
+	e := newEcho(sessionManager)
+
+	for _, path := range []string{"/put", "/put-nocontent", "/put-error"} {
+		rr := execute(e, path, "")
+
+		setCookie := rr.Header().Get("Set-Cookie")
+		if !strings.HasPrefix(setCookie, "session=") {
+			t.Fatalf("%s: want session cookie; got %q", path, setCookie)
+		}
+		if got := len(rr.Header().Values("Set-Cookie")); got != 1 {
+			t.Errorf("%s: want 1 Set-Cookie header; got %d", path, got)
+		}
+
+		rr = execute(e, "/get", strings.SplitN(setCookie, ";", 2)[0])
+		if rr.Body.String() != "bar" {
+			t.Errorf("%s: want %q; got %q", path, "bar", rr.Body.String())
+		}
+		if rr.Header().Get("Set-Cookie") != "" {
+			t.Errorf("%s: want no Set-Cookie header; got %q", path, rr.Header().Get("Set-Cookie"))
+		}
+	}
+}
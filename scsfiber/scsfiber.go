@@ -0,0 +1,110 @@
+// Package scsfiber provides Fiber middleware for SCS sessions.
+//
+// The LoadAndSave middleware in this package loads and commits the session
+// with SessionManager.Load and SessionManager.Commit, and doesn't share the
+// request handling of the net/http SessionManager.LoadAndSave middleware. The
+// session data methods, stores, codecs and encryption work as usual, but the
+// following SessionManager settings are not supported and have no effect:
+//
+//   - StrictTokens and UnknownTokenHandler
+//   - DuplicateCookieFunc, ClearInvalidCookies and RenewStaleCookies
+//   - RotateEvery
+//   - DeviceTracking and ActivityTracking
+//   - OnPanic and RecoverFunc
+//   - CacheablePaths and SuppressCookieOnCacheable
+//   - OnStoreTimeout (store timeouts are always returned as errors)
+//   - StoreLimit (idle timeout extensions are never skipped)
+//   - DuplicateCreation
+//   - AsyncSave (sessions are always committed on the request goroutine)
+//   - AffinityHeader
+//   - CommitAfterWrite, WrapperCompat, TokenTrailer and LateWriteFunc
+//   - Cookie.DomainFunc, and the token header when MobileCompat is enabled
+//     (the token is only read from the session cookie)
+//   - ErrorFunc (errors are returned to Fiber instead)
+//
+// The OnCommit hook is called with a nil request.
+package scsfiber
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LoadAndSave returns Fiber middleware which automatically loads and saves
+// session data for the current request, and communicates the session token to
+// and from the client in a cookie. It is the Fiber equivalent of the
+// SessionManager.LoadAndSave() middleware, and works with any session store
+// and codec supported by the session manager.
+//
+// Unlike the net/http middleware, errors encountered while loading or
+// committing the session are returned to Fiber (and handled by the
+// application's fiber.ErrorHandler) rather than being passed to
+// SessionManager.ErrorFunc.
+func LoadAndSave(s *scs.SessionManager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Append("Vary", "Cookie")
+
+		ctx, err := s.Load(c.UserContext(), c.Cookies(s.CurrentCookie().Name))
+		if err != nil {
+			return err
+		}
+		c.SetUserContext(ctx)
+
+		nextErr := c.Next()
+
+		switch s.Status(ctx) {
+		case scs.Modified:
+			token, expiry, err := s.Commit(ctx)
+			if err != nil {
+				return err
+			}
+			writeSessionCookie(c, s, ctx, token, expiry)
+		case scs.Destroyed:
+			writeSessionCookie(c, s, ctx, "", time.Time{})
+		}
+
+		return nextErr
+	}
+}
+
+// Context returns the context.Context containing the session data for the
+// current request. It should be passed to the SessionManager methods from
+// within Fiber handlers. For example:
+//
+//	msg := sessionManager.GetString(scsfiber.Context(c), "message")
+func Context(c *fiber.Ctx) context.Context {
+	return c.UserContext()
+}
+
+// writeSessionCookie uses SessionManager.WriteSessionCookie to generate the
+// session cookie headers, so that the cookie attributes are identical to those
+// used by the net/http middleware, and copies them to the fasthttp response.
+func writeSessionCookie(c *fiber.Ctx, s *scs.SessionManager, ctx context.Context, token string, expiry time.Time) {
+	hw := headerWriter{header: make(http.Header)}
+	s.WriteSessionCookie(ctx, hw, token, expiry)
+
+	for key, values := range hw.header {
+		for _, value := range values {
+			c.Response().Header.Add(key, value)
+		}
+	}
+}
+
+// headerWriter is a http.ResponseWriter which only records headers.
+type headerWriter struct {
+	header http.Header
+}
+
+func (hw headerWriter) Header() http.Header {
+	return hw.header
+}
+
+func (hw headerWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (hw headerWriter) WriteHeader(int) {}
@@ -0,0 +1,95 @@
+package scsfiber
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newApp(sessionManager *scs.SessionManager) *fiber.App {
+	app := fiber.New()
+	app.Use(LoadAndSave(sessionManager))
+	app.Get("/put", func(c *fiber.Ctx) error {
+		sessionManager.Put(Context(c), "foo", "bar")
+		return c.SendString("ok")
+	})
+	app.Get("/put-error", func(c *fiber.Ctx) error {
+		sessionManager.Put(Context(c), "foo", "bar")
+		return errors.New("boom")
+	})
+	app.Get("/get", func(c *fiber.Ctx) error {
+		return c.SendString(sessionManager.GetString(Context(c), "foo"))
+	})
+	app.Get("/destroy", func(c *fiber.Ctx) error {
+		return sessionManager.Destroy(Context(c))
+	})
+	return app
+}
+
+func execute(t *testing.T, app *fiber.App, path string, cookie string) (*http.Response, string) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if cookie != "" {
+		r.Header.Set("Cookie", cookie)
+	}
+	rs, err := app.Test(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs, string(body)
+}
+
+func TestLoadAndSave(t *testing.T) {
+	sessionManager := scs.New()
+	app := newApp(sessionManager)
+
+	for _, path := range []string{"/put", "/put-error"} {
+		rs, _ := execute(t, app, path, "")
+
+		setCookie := rs.Header.Get("Set-Cookie")
+		if !strings.HasPrefix(setCookie, "session=") {
+			t.Fatalf("%s: want session cookie; got %q", path, setCookie)
+		}
+		if got := len(rs.Header.Values("Set-Cookie")); got != 1 {
+			t.Errorf("%s: want 1 Set-Cookie header; got %d", path, got)
+		}
+
+		rs, body := execute(t, app, "/get", strings.SplitN(setCookie, ";", 2)[0])
+		if body != "bar" {
+			t.Errorf("%s: want %q; got %q", path, "bar", body)
+		}
+		if rs.Header.Get("Set-Cookie") != "" {
+			t.Errorf("%s: want no Set-Cookie header; got %q", path, rs.Header.Get("Set-Cookie"))
+		}
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	sessionManager := scs.New()
+	app := newApp(sessionManager)
+
+	rs, _ := execute(t, app, "/put", "")
+	cookie := strings.SplitN(rs.Header.Get("Set-Cookie"), ";", 2)[0]
+
+	rs, _ = execute(t, app, "/destroy", cookie)
+	setCookie := rs.Header.Get("Set-Cookie")
+	if !strings.HasPrefix(setCookie, "session=;") || !strings.Contains(setCookie, "Max-Age=0") {
+		t.Errorf("want expired session cookie; got %q", setCookie)
+	}
+
+	_, body := execute(t, app, "/get", cookie)
+	if body != "" {
+		t.Errorf("want %q; got %q", "", body)
+	}
+}
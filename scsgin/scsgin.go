@@ -0,0 +1,63 @@
+package scsgin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// LoadAndSave returns Gin middleware which automatically loads and saves
+// session data for the current request, and communicates the session token to
+// and from the client in a cookie. It is the Gin equivalent of the
+// SessionManager.LoadAndSave() middleware.
+func LoadAndSave(s *scs.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gw := c.Writer
+
+		s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Request = r
+			c.Writer = &responseWriter{ResponseWriter: gw, sw: w}
+			c.Next()
+		})).ServeHTTP(gw, c.Request)
+
+		c.Writer = gw
+	}
+}
+
+// Context returns the context.Context containing the session data for the
+// current request. It should be passed to the SessionManager methods from
+// within Gin handlers. For example:
+//
+//	msg := sessionManager.GetString(scsgin.Context(c), "message")
+func Context(c *gin.Context) context.Context {
+	return c.Request.Context()
+}
+
+// responseWriter wraps the gin.ResponseWriter so that writes are routed via
+// the session response writer, ensuring the session cookie is written before
+// the response headers are sent.
+type responseWriter struct {
+	gin.ResponseWriter
+	sw http.ResponseWriter
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.sw.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.sw.Write([]byte(s))
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.sw.WriteHeader(code)
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.sw.WriteHeader(w.Status())
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
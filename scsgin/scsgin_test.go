@@ -0,0 +1,79 @@
+package scsgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func newRouter(sessionManager *scs.SessionManager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(LoadAndSave(sessionManager))
+	router.GET("/put", func(c *gin.Context) {
+		sessionManager.Put(Context(c), "foo", "bar")
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/put-abort", func(c *gin.Context) {
+		sessionManager.Put(Context(c), "foo", "bar")
+		c.AbortWithStatus(http.StatusAccepted)
+	})
+	router.GET("/put-empty", func(c *gin.Context) {
+		sessionManager.Put(Context(c), "foo", "bar")
+	})
+	router.GET("/get", func(c *gin.Context) {
+		c.String(http.StatusOK, sessionManager.GetString(Context(c), "foo"))
+	})
+
+	return router
+}
+
+func execute(router http.Handler, path string, cookie string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if cookie != "" {
+		r.Header.Set("Cookie", cookie)
+	}
+	router.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestLoadAndSave(t *testing.T) {
+	sessionManager := scs.New()
+	router := newRouter(sessionManager)
+
+	for _, path := range []string{"/put", "/put-abort", "/put-empty"} {
+		rr := execute(router, path, "")
+
+		setCookie := rr.Header().Get("Set-Cookie")
+		if !strings.HasPrefix(setCookie, "session=") {
+			t.Fatalf("%s: want session cookie; got %q", path, setCookie)
+		}
+
+		rr = execute(router, "/get", strings.SplitN(setCookie, ";", 2)[0])
+		if rr.Body.String() != "bar" {
+			t.Errorf("%s: want %q; got %q", path, "bar", rr.Body.String())
+		}
+		if rr.Header().Get("Set-Cookie") != "" {
+			t.Errorf("%s: want no Set-Cookie header; got %q", path, rr.Header().Get("Set-Cookie"))
+		}
+	}
+}
+
+func TestLoadAndSaveStatus(t *testing.T) {
+	sessionManager := scs.New()
+	router := newRouter(sessionManager)
+
+	rr := execute(router, "/put-abort", "")
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("want %d; got %d", http.StatusAccepted, rr.Code)
+	}
+	if got := len(rr.Header().Values("Set-Cookie")); got != 1 {
+		t.Errorf("want 1 Set-Cookie header; got %d", got)
+	}
+}
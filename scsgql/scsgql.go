@@ -0,0 +1,159 @@
+// Package scsgql provides helpers for using a SessionManager with GraphQL
+// servers built on net/http, such as gqlgen, whose resolvers and dataloaders
+// only receive a context.
+package scsgql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// ErrNoSession is returned by Key.Put when the context doesn't come from a
+// request served by Handler for the Key's SessionManager.
+var ErrNoSession = errors.New("scsgql: no session in context")
+
+type mountedKey struct{}
+
+// Handler wraps a GraphQL server, such as a gqlgen handler.Server, with the
+// LoadAndSave middleware of s, so that the session is loaded into the context
+// before any resolver runs:
+//
+//	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+//	http.Handle("/query", scsgql.Handler(sessionManager, srv))
+//
+// The response to a query or mutation is buffered, so that the session is
+// committed (and the session cookie written) only once all of the resolvers
+// have finished. Streamed responses, such as subscriptions over websockets or
+// server-sent events and incremental delivery with @defer, are not buffered,
+// so changes made to the session after the first part has been sent are
+// handled as described for SessionManager.CommitAfterWrite.
+func Handler(s *scs.SessionManager, h http.Handler) http.Handler {
+	return s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), mountedKey{}, s))
+		if streamed(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w}
+		h.ServeHTTP(bw, r)
+		bw.flush()
+	}))
+}
+
+// streamed reports whether the response to r may be streamed.
+func streamed(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/event-stream") || strings.Contains(accept, "multipart/mixed")
+}
+
+// bufferedWriter holds the status code and body of a response until flush is
+// called.
+type bufferedWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+// Flush does nothing, as the response is sent when the handler returns.
+func (bw *bufferedWriter) Flush() {}
+
+func (bw *bufferedWriter) flush() {
+	if bw.code == 0 {
+		return
+	}
+	bw.ResponseWriter.WriteHeader(bw.code)
+	bw.ResponseWriter.Write(bw.body.Bytes())
+}
+
+// Key is a typed accessor for a session value, for use in resolvers and
+// dataloaders:
+//
+//	var userID = scsgql.NewKey[int64](sessionManager, "userID")
+//
+//	func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+//		id, ok := userID.Get(ctx)
+//		if !ok {
+//			return nil, errNotLoggedIn
+//		}
+//		return r.Users.Load(ctx, id)
+//	}
+//
+// Dataloaders must be passed a context derived from the resolver's context.
+type Key[T any] struct {
+	manager *scs.SessionManager
+	name    string
+}
+
+// NewKey returns the Key for the session value with the given name.
+func NewKey[T any](s *scs.SessionManager, name string) Key[T] {
+	return Key[T]{manager: s, name: name}
+}
+
+// Get returns the session value and true, or the zero value and false if the
+// value doesn't exist, has a different type, or ctx doesn't come from a
+// request served by Handler.
+func (k Key[T]) Get(ctx context.Context) (T, bool) {
+	var zero T
+	if !k.mounted(ctx) {
+		return zero, false
+	}
+	v, ok := k.manager.Get(ctx, k.name).(T)
+	if !ok {
+		return zero, false
+	}
+	return v, true
+}
+
+// Put sets the session value. It returns ErrNoSession if ctx doesn't come from
+// a request served by Handler.
+func (k Key[T]) Put(ctx context.Context, v T) error {
+	if !k.mounted(ctx) {
+		return ErrNoSession
+	}
+	k.manager.Put(ctx, k.name, v)
+	return nil
+}
+
+// Pop returns the session value and removes it from the session, in the same
+// way as Get.
+func (k Key[T]) Pop(ctx context.Context) (T, bool) {
+	v, ok := k.Get(ctx)
+	if ok {
+		k.manager.Remove(ctx, k.name)
+	}
+	return v, ok
+}
+
+// Remove removes the session value. It does nothing if ctx doesn't come from a
+// request served by Handler.
+func (k Key[T]) Remove(ctx context.Context) {
+	if k.mounted(ctx) {
+		k.manager.Remove(ctx, k.name)
+	}
+}
+
+func (k Key[T]) mounted(ctx context.Context) bool {
+	return ctx.Value(mountedKey{}) == k.manager
+}
@@ -0,0 +1,121 @@
+package scsgql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func execute(h http.Handler, cookie string, accept string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"query":"{ me }"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	if cookie != "" {
+		r.Header.Set("Cookie", cookie)
+	}
+	h.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestHandler(t *testing.T) {
+	sessionManager := scs.New()
+	visits := NewKey[int](sessionManager, "visits")
+
+	// The server writes part of the response before the last resolver runs,
+	// as a GraphQL server streaming its output might.
+	srv := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":`))
+		n, _ := visits.Get(r.Context())
+		if err := visits.Put(r.Context(), n+1); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"me":null}}`))
+	})
+	h := Handler(sessionManager, srv)
+
+	rr := execute(h, "", "")
+	if body := rr.Body.String(); body != `{"data":{"me":null}}` {
+		t.Errorf("got body %q", body)
+	}
+	cookie := rr.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(cookie, "session=") {
+		t.Fatalf("want session cookie; got %q", cookie)
+	}
+	cookie = strings.SplitN(cookie, ";", 2)[0]
+
+	execute(h, cookie, "")
+	ctx, err := sessionManager.Load(context.Background(), strings.TrimPrefix(cookie, "session="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := sessionManager.GetInt(ctx, "visits"); n != 2 {
+		t.Errorf("want %d; got %d", 2, n)
+	}
+
+	// Streamed responses aren't buffered, so the change made after the first
+	// part is sent is discarded.
+	rr = execute(h, "", "multipart/mixed")
+	if cookie := rr.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("want no session cookie; got %q", cookie)
+	}
+}
+
+func TestKey(t *testing.T) {
+	sessionManager := scs.New()
+	userID := NewKey[int64](sessionManager, "userID")
+
+	if _, ok := userID.Get(context.Background()); ok {
+		t.Error("want no value outside Handler")
+	}
+	if err := userID.Put(context.Background(), 1); err != ErrNoSession {
+		t.Errorf("want %v; got %v", ErrNoSession, err)
+	}
+
+	h := Handler(sessionManager, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := userID.Put(ctx, 42); err != nil {
+			t.Fatal(err)
+		}
+		sessionManager.Put(ctx, "name", "alice")
+
+		// A value with a different type isn't returned.
+		if _, ok := NewKey[string](sessionManager, "userID").Get(ctx); ok {
+			t.Error("want no value for a different type")
+		}
+
+		// Dataloader batches run on other goroutines with a context derived
+		// from the resolver's.
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if id, ok := userID.Get(ctx); !ok || id != 42 {
+					t.Errorf("want 42; got %d, %v", id, ok)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if id, ok := userID.Pop(ctx); !ok || id != 42 {
+			t.Errorf("want 42; got %d, %v", id, ok)
+		}
+		if _, ok := userID.Get(ctx); ok {
+			t.Error("want value removed by Pop")
+		}
+		NewKey[string](sessionManager, "name").Remove(ctx)
+		if sessionManager.Exists(ctx, "name") {
+			t.Error("want value removed by Remove")
+		}
+	}))
+	execute(h, "", "")
+}
@@ -0,0 +1,7 @@
+module github.com/alexedwards/scs/scsrpc
+
+go 1.21
+
+require github.com/alexedwards/scs/v2 v2.8.0
+
+replace github.com/alexedwards/scs/v2 => ../
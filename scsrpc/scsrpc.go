@@ -0,0 +1,92 @@
+// Package scsrpc adapts a SessionManager for RPC frameworks built on net/http,
+// such as connect-go and Twirp, whose handlers are mounted as POST-only
+// endpoints under a path for each service and called by clients which don't
+// follow redirects or (outside browsers) keep cookies.
+package scsrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// ErrNoSession is returned by RequireSession when the request didn't have a
+// valid session.
+var ErrNoSession = errors.New("scsrpc: no session")
+
+// ErrAuthLevel is returned by RequireAuthLevel when the session's
+// authentication level is too low.
+var ErrAuthLevel = errors.New("scsrpc: authentication level too low")
+
+type mountedKey struct{}
+
+// Configure adapts the settings of s for RPC clients. It enables
+// MobileCompat, so that the session token is accepted from and returned in the
+// TokenHeader as well as the session cookie, and sets the cookie Path to "/",
+// so that the cookie is sent to every procedure whatever path the service is
+// mounted under. The token is only returned in the TokenHeader to clients
+// which sent it there, so a client which doesn't keep cookies should send an
+// empty TokenHeader until it has a token. It should be called before s is
+// used.
+func Configure(s *scs.SessionManager) {
+	s.MobileCompat = true
+	s.Cookie.Path = "/"
+}
+
+// Handle wraps the handler for an RPC service with the LoadAndSave middleware
+// of s. It takes and returns the path the service is mounted under, so that it
+// can wrap the constructors generated by connect-go directly:
+//
+//	mux.Handle(scsrpc.Handle(sessionManager, greetv1connect.NewGreetServiceHandler(svc)))
+//
+// For Twirp, pass the server's path prefix:
+//
+//	mux.Handle(scsrpc.Handle(sessionManager, server.PathPrefix(), server))
+func Handle(s *scs.SessionManager, path string, h http.Handler) (string, http.Handler) {
+	return path, s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), mountedKey{}, s)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// HasSession reports whether the request for the context passed to an RPC
+// method had a valid session. It returns false if the service wasn't mounted
+// with Handle for s, rather than panicking as the SessionManager methods do.
+func HasSession(s *scs.SessionManager, ctx context.Context) bool {
+	if ctx.Value(mountedKey{}) != s {
+		return false
+	}
+	return s.Token(ctx) != ""
+}
+
+// RequireSession returns ErrNoSession unless the request for the context
+// passed to an RPC method had a valid session. RPC methods can return it with
+// their framework's "unauthenticated" error code, for example:
+//
+//	if err := scsrpc.RequireSession(sessionManager, ctx); err != nil {
+//		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+//	}
+func RequireSession(s *scs.SessionManager, ctx context.Context) error {
+	if !HasSession(s, ctx) {
+		return ErrNoSession
+	}
+	return nil
+}
+
+// RequireAuthLevel returns ErrNoSession unless the request for the context
+// passed to an RPC method had a valid session, and ErrAuthLevel if the
+// session's authentication level is lower than level. It replaces the
+// SessionManager.RequireAuthLevel middleware, whose HTTP 403 response (or
+// AuthLevelHandler redirect) RPC clients can't interpret, so that RPC methods
+// can return their framework's "permission denied" error code instead.
+func RequireAuthLevel(s *scs.SessionManager, ctx context.Context, level int) error {
+	if err := RequireSession(s, ctx); err != nil {
+		return err
+	}
+	if s.AuthLevel(ctx) < level {
+		return ErrAuthLevel
+	}
+	return nil
+}
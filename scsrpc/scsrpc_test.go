@@ -0,0 +1,103 @@
+package scsrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// newService returns a handler which routes POST requests to RPC methods in
+// the same way as the handlers generated by connect-go and Twirp.
+func newService(sessionManager *scs.SessionManager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/greet.v1.GreetService/Login", func(w http.ResponseWriter, r *http.Request) {
+		if err := sessionManager.RenewToken(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sessionManager.SetAuthLevel(r.Context(), 1)
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/greet.v1.GreetService/Greet", func(w http.ResponseWriter, r *http.Request) {
+		if err := RequireAuthLevel(sessionManager, r.Context(), 1); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"greeting":"hello"}`))
+	})
+	return mux
+}
+
+func call(h http.Handler, procedure string, token string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, procedure, strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	// The client doesn't keep cookies, so it always sends the header, which
+	// is empty until it has a token.
+	r.Header.Set("X-Session-Token", token)
+	h.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestHandle(t *testing.T) {
+	sessionManager := scs.New()
+	sessionManager.Cookie.Path = "/app"
+	Configure(sessionManager)
+
+	mux := http.NewServeMux()
+	mux.Handle(Handle(sessionManager, "/greet.v1.GreetService/", newService(sessionManager)))
+
+	rr := call(mux, "/greet.v1.GreetService/Greet", "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	rr = call(mux, "/greet.v1.GreetService/Login", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+	token := rr.Header().Get("X-Session-Token")
+	if token == "" {
+		t.Fatal("want session token header")
+	}
+	if cookie := rr.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Path=/;") && !strings.HasSuffix(cookie, "Path=/") {
+		t.Errorf("want cookie path /; got %q", cookie)
+	}
+
+	rr = call(mux, "/greet.v1.GreetService/Greet", token)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+	if body := rr.Body.String(); body != `{"greeting":"hello"}` {
+		t.Errorf("got body %q", body)
+	}
+}
+
+func TestRequireAuthLevel(t *testing.T) {
+	sessionManager := scs.New()
+
+	// Contexts from services not mounted with Handle have no session.
+	if err := RequireSession(sessionManager, context.Background()); err != ErrNoSession {
+		t.Errorf("want %v; got %v", ErrNoSession, err)
+	}
+
+	var errs []error
+	_, h := Handle(sessionManager, "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs = append(errs, RequireAuthLevel(sessionManager, r.Context(), 2))
+		sessionManager.SetAuthLevel(r.Context(), 1)
+	}))
+
+	rr := call(h, "/", "")
+	cookie := rr.Header().Get("Set-Cookie")
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Cookie", strings.SplitN(cookie, ";", 2)[0])
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(errs) != 2 || errs[0] != ErrNoSession || errs[1] != ErrAuthLevel {
+		t.Errorf("want [%v %v]; got %v", ErrNoSession, ErrAuthLevel, errs)
+	}
+}
@@ -0,0 +1,167 @@
+package scs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider supplies the secret keys used by the SessionManager, so that
+// keys can be rotated without restarting the application. It is used by the
+// EncryptionSecrets and TokenFingerprintSecrets settings. Implementations must
+// be safe for concurrent use.
+type SecretProvider interface {
+	// Current returns the key to use for new values. It returns nil if there
+	// is no key.
+	Current() []byte
+
+	// All returns all of the keys which are accepted for existing values,
+	// starting with the current key. During a rotation it includes the
+	// previous keys, until the values using them have expired.
+	All() [][]byte
+}
+
+// StaticSecrets is a SecretProvider with a fixed list of keys, the first of
+// which is the current key. For example, to rotate an encryption key, deploy
+// with the new key first and the old key second, and remove the old key once
+// the sessions using it have expired:
+//
+//	sessionManager.EncryptionSecrets = scs.StaticSecrets{newKey, oldKey}
+type StaticSecrets [][]byte
+
+// Current returns the first key, or nil if there are no keys.
+func (s StaticSecrets) Current() []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+// All returns all of the keys.
+func (s StaticSecrets) All() [][]byte {
+	return s
+}
+
+// FileSecrets is a SecretProvider which reads its keys from a file, and reads
+// the file again periodically so that the keys can be rotated by changing the
+// file (for example, a Kubernetes secret mounted as a volume, or a file
+// rendered by the Vault agent). The file holds one base64-encoded key per
+// line, with the current key first. Blank lines and lines starting with "#"
+// are ignored.
+//
+// If the file can't be read or parsed, the error is logged and the previous
+// keys are kept.
+type FileSecrets struct {
+	path string
+	stop chan struct{}
+
+	mu   sync.RWMutex
+	raw  []byte
+	keys [][]byte
+}
+
+// NewFileSecrets returns a FileSecrets which reads the keys from the file at
+// path, and reads the file again every interval. It returns an error if the
+// file can't be read or parsed, or holds no keys. If interval is zero or
+// negative the file is only read once. Call Close to stop reading the file.
+func NewFileSecrets(path string, interval time.Duration) (*FileSecrets, error) {
+	f := &FileSecrets{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		f.stop = make(chan struct{})
+		go f.watch(interval)
+	}
+	return f, nil
+}
+
+// Current returns the first key in the file.
+func (f *FileSecrets) Current() []byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.keys[0]
+}
+
+// All returns all of the keys in the file.
+func (f *FileSecrets) All() [][]byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.keys
+}
+
+// Close stops reading the file. The keys last read are still returned.
+func (f *FileSecrets) Close() error {
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	return nil
+}
+
+func (f *FileSecrets) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stop := f.stop
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.reload(); err != nil {
+				log.Printf("scs: reading secrets: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reload reads the file, and replaces the keys if it has changed.
+func (f *FileSecrets) reload() error {
+	raw, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	unchanged := f.keys != nil && bytes.Equal(raw, f.raw)
+	f.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	keys, err := parseSecrets(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.raw, f.keys = raw, keys
+	f.mu.Unlock()
+	return nil
+}
+
+func parseSecrets(raw []byte) ([][]byte, error) {
+	var keys [][]byte
+	for i, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid base64-encoded key", i+1)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,157 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticSecrets(t *testing.T) {
+	t.Parallel()
+
+	if key := (StaticSecrets{}).Current(); key != nil {
+		t.Errorf("got %v: expected nil", key)
+	}
+
+	secrets := StaticSecrets{[]byte("new"), []byte("old")}
+	if key := secrets.Current(); string(key) != "new" {
+		t.Errorf("got %q: expected %q", key, "new")
+	}
+	if keys := secrets.All(); len(keys) != 2 {
+		t.Errorf("got %d: expected %d keys", len(keys), 2)
+	}
+}
+
+func TestEncryptionSecretsRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey := bytes.Repeat([]byte("o"), 32)
+	newKey := bytes.Repeat([]byte("n"), 32)
+
+	s := New()
+	s.EncryptionSecrets = StaticSecrets{oldKey}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutEncryptedString(ctx, "a", "before"); err != nil {
+		t.Fatal(err)
+	}
+
+	// During the rotation, values encrypted with either key can be read, and
+	// new values are encrypted with the new key.
+	s.EncryptionSecrets = StaticSecrets{newKey, oldKey}
+	if err := s.PutEncryptedString(ctx, "b", "after"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "before", "b": "after"} {
+		got, err := s.GetEncryptedString(ctx, key)
+		if err != nil || got != want {
+			t.Errorf("got %q, %v: expected %q", got, err, want)
+		}
+	}
+
+	// Once the old key is removed, only the new values can be read.
+	s.EncryptionSecrets = StaticSecrets{newKey}
+	if _, err := s.GetEncryptedString(ctx, "a"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("got %v: expected %v", err, ErrDecryptionFailed)
+	}
+	if got, err := s.GetEncryptedString(ctx, "b"); err != nil || got != "after" {
+		t.Errorf("got %q, %v: expected %q", got, err, "after")
+	}
+
+	s.EncryptionSecrets = StaticSecrets{}
+	if err := s.PutEncryptedString(ctx, "c", "none"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("got %v: expected %v", err, ErrNoEncryptionKey)
+	}
+	if _, err := s.GetEncryptedString(ctx, "b"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("got %v: expected %v", err, ErrNoEncryptionKey)
+	}
+}
+
+func TestTokenFingerprintSecrets(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.TokenFingerprintKey = []byte("field")
+	fromField := s.RedactToken("token")
+
+	s.TokenFingerprintSecrets = StaticSecrets{[]byte("provider")}
+	if got := s.RedactToken("token"); got == fromField {
+		t.Error("want fingerprint to use the key from TokenFingerprintSecrets")
+	}
+}
+
+func TestFileSecrets(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "secrets")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encode := base64.StdEncoding.EncodeToString
+
+	write("# current key first\n" + encode([]byte("one")) + "\n")
+	f, err := NewFileSecrets(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if key := f.Current(); string(key) != "one" {
+		t.Fatalf("got %q: expected %q", key, "one")
+	}
+
+	write(encode([]byte("two")) + "\n\n" + encode([]byte("one")) + "\n")
+	waitForKey(t, f, "two")
+	if keys := f.All(); len(keys) != 2 || string(keys[1]) != "one" {
+		t.Errorf("got %q: expected [two one]", keys)
+	}
+
+	// Invalid content is ignored, and the previous keys are kept.
+	write("not base64!\n")
+	time.Sleep(50 * time.Millisecond)
+	if key := f.Current(); string(key) != "two" {
+		t.Errorf("got %q: expected %q", key, "two")
+	}
+
+	write(encode([]byte("three")))
+	waitForKey(t, f, "three")
+}
+
+func TestFileSecretsInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if _, err := NewFileSecrets(filepath.Join(dir, "missing"), 0); err == nil {
+		t.Error("want error for missing file")
+	}
+
+	path := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(path, []byte("# no keys\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSecrets(path, 0); err == nil {
+		t.Error("want error for file with no keys")
+	}
+}
+
+func waitForKey(t *testing.T, f *FileSecrets, want string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for string(f.Current()) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q: expected %q", f.Current(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,68 @@
+package scs
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SecurityHeaders provides middleware which stops shared caches (such as
+// CDNs and corporate proxies) from storing responses which carry a session,
+// so that one user's personalized response is never served to another. When
+// the request has a session, or the handler creates one, the
+// "Cache-Control: private, no-store" and "Vary: Cookie" headers are set on the
+// response just before it is written, replacing any Cache-Control header set
+// by the handler. Responses without a session are left unchanged, so public
+// pages can still be cached. It must be used inside the LoadAndSave()
+// middleware.
+func (s *SessionManager) SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &securityHeadersWriter{ResponseWriter: w, request: r, sessionManager: s}
+		next.ServeHTTP(hw, r)
+		hw.setHeaders()
+	})
+}
+
+type securityHeadersWriter struct {
+	http.ResponseWriter
+	request        *http.Request
+	sessionManager *SessionManager
+	done           bool
+}
+
+func (hw *securityHeadersWriter) setHeaders() {
+	if hw.done {
+		return
+	}
+	hw.done = true
+
+	s := hw.sessionManager
+	ctx := hw.request.Context()
+	if s.Token(ctx) == "" && s.Status(ctx) == Unmodified {
+		return
+	}
+
+	h := hw.Header()
+	h.Set("Cache-Control", "private, no-store")
+	for _, v := range h["Vary"] {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), "Cookie") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Cookie")
+}
+
+func (hw *securityHeadersWriter) Write(b []byte) (int, error) {
+	hw.setHeaders()
+	return hw.ResponseWriter.Write(b)
+}
+
+func (hw *securityHeadersWriter) WriteHeader(code int) {
+	hw.setHeaders()
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+func (hw *securityHeadersWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
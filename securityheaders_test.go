@@ -0,0 +1,53 @@
+package scs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		sessionManager.Put(r.Context(), "foo", "bar")
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/silent", func(w http.ResponseWriter, r *http.Request) {})
+	h := sessionManager.LoadAndSave(sessionManager.SecurityHeaders(mux))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/public", nil))
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("want public response to be unchanged; got %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+	if got := rr.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("want %q; got %q", "private, no-store", got)
+	}
+	if got := rr.Header()["Vary"]; len(got) != 1 || got[0] != "Cookie" {
+		t.Errorf("want a single Vary: Cookie header; got %q", got)
+	}
+	token := extractTokenFromCookie(rr.Header().Get("Set-Cookie"))
+
+	// Responses to requests with a session are marked private even if the
+	// handler doesn't write anything.
+	r := httptest.NewRequest("GET", "/silent", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if got := rr.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("want %q; got %q", "private, no-store", got)
+	}
+}
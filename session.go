@@ -2,17 +2,36 @@ package scs
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/alexedwards/scs/v2/memstore"
 )
 
+// ErrNestedMiddleware is passed to the ErrorFunc when the LoadAndSave
+// middleware is wrapped around a handler which is already inside a
+// LoadAndSave middleware using the same session cookie name (either the same
+// SessionManager twice, or two SessionManagers sharing a cookie name). Both
+// would write the session cookie, so each response would overwrite the
+// cookie written by the other.
+var ErrNestedMiddleware = errors.New("scs: LoadAndSave middleware is nested with the same session cookie name")
+
+// middlewareKey is the context key for the session cookie names used by the
+// LoadAndSave middlewares handling a request.
+type middlewareKey struct{}
+
 // Deprecated: Session is a backwards-compatible alias for SessionManager.
 type Session = SessionManager
 
-// SessionManager holds the configuration settings for your sessions.
+// SessionManager holds the configuration settings for your sessions. The
+// settings must not be changed by assigning to the fields once the
+// SessionManager is in use, except for those which can be changed safely with
+// the Set methods (such as SetLifetime and SetEncryptionKey).
 type SessionManager struct {
 	// IdleTimeout controls the maximum length of time a session can be inactive
 	// before it expires. For example, some applications may wish to set this so
@@ -26,17 +45,84 @@ type SessionManager struct {
 	// hours.
 	Lifetime time.Duration
 
+	// RotateEvery controls how often the session token is regenerated. When
+	// it is set, the LoadAndSave middleware replaces the token on the first
+	// request after RotateEvery has elapsed since the token was issued,
+	// keeping the session data and deadline unchanged. This is required by
+	// some security standards even when there's no change in privilege level.
+	// Please note that a request which is still using the old token when it
+	// is rotated (for example, a concurrent request from the same browser)
+	// will start a new session. By default RotateEvery is not set and tokens
+	// are only regenerated by RenewToken.
+	RotateEvery time.Duration
+
+	// RecordActivity controls whether the time of the last activity is
+	// recorded in the session data of existing sessions when an idle timeout
+	// is being used. The time is always recorded for sessions created while
+	// an idle timeout is being used. It allows the time remaining before the
+	// idle timeout to be calculated (for example by the ExpiryHandler), and
+	// means that a request marked with NoExtend doesn't reset the idle timer
+	// even if it modifies the session data. Enabling RecordActivity also
+	// records it for sessions created before then. The default value is
+	// false.
+	RecordActivity bool
+
+	// SoftIdleTimeout controls whether a session which exceeds the
+	// IdleTimeout is locked rather than expired. A locked session keeps its
+	// data until the end of its Lifetime, but its authentication level (see
+	// SetAuthLevel) is dropped to 0, and IsIdleLocked reports true until the
+	// user re-authenticates and ResumeIdleSession is called. The time of the
+	// last activity is always recorded when SoftIdleTimeout is enabled. The
+	// default value is false.
+	SoftIdleTimeout bool
+
 	// Store controls the session store where the session data is persisted.
 	Store Store
 
+	// HotKeys lists the session keys which most requests need. If it is set
+	// and the Store implements KeysStore, the LoadAndSave middleware and Load
+	// only load the values for these keys, and the rest of the session data
+	// is loaded when it is first used (see LoadKeys). By default HotKeys is
+	// nil and the whole session is loaded.
+	HotKeys []string
+
+	// TokenFilter, if set, is used to reject unknown session tokens without
+	// a round trip to the Store. It must be built with RebuildTokenFilter
+	// before it takes effect, and must only be used when this process is the
+	// only one which creates sessions in the Store. See TokenFilter for
+	// details. By default TokenFilter is nil.
+	TokenFilter *TokenFilter
+
 	// Cookie contains the configuration settings for session cookies.
 	Cookie SessionCookie
 
 	// Codec controls the encoder/decoder used to transform session data to a
 	// byte slice for use by the session store. By default session data is
-	// encoded/decoded using encoding/gob.
+	// encoded/decoded using encoding/gob. Use CanonicalCodec if identical
+	// session data must always encode to identical bytes.
 	Codec Codec
 
+	// FallbackCodec is used to decode session data which the Codec fails to
+	// decode, allowing the Codec to be changed without making existing
+	// sessions unreadable. Sessions decoded by the FallbackCodec are
+	// re-encoded with the Codec when they are next saved (and are marked as
+	// modified when loaded, so that this happens at the end of the request).
+	// Once all sessions created before the change have expired, the
+	// FallbackCodec can be removed.
+	FallbackCodec Codec
+
+	// Checksum controls whether a checksum is added to the session data (and
+	// the other records) written to the store, so that data which has been
+	// corrupted or only partly written is detected when it is loaded, rather
+	// than decoded into the wrong values. A checksum mismatch is handled
+	// according to OnDecodeError, with the error ErrChecksum. While the
+	// setting is enabled, data without a checksum is treated as a mismatch,
+	// so sessions written before it was enabled are handled in the same way
+	// (with the default DiscardOnDecodeError, their users must sign in
+	// again). Data with a checksum can still be loaded after the setting is
+	// disabled. The default value is false.
+	Checksum bool
+
 	// ErrorFunc allows you to control behavior when an error is encountered by
 	// the LoadAndSave middleware. The default behavior is for a HTTP 500
 	// "Internal Server Error" message to be sent to the client and the error
@@ -45,12 +131,400 @@ type SessionManager struct {
 	// a function which logs the error and returns a customized HTML error page.
 	ErrorFunc func(http.ResponseWriter, *http.Request, error)
 
+	// OnPanic controls what the LoadAndSave middleware does with the session
+	// when the handler panics. By default changes which haven't already been
+	// committed are discarded and no session cookie is written. The panic,
+	// including http.ErrAbortHandler, is always propagated with its original
+	// value.
+	OnPanic PanicPolicy
+
+	// OnCommit is called with each session cookie just before its Set-Cookie
+	// header is written, including the expired cookies written to delete a
+	// session cookie. The cookie may be changed, for example to set the
+	// Domain for the tenant of the request, and returning false vetoes the
+	// write, so no Set-Cookie header is sent. The session data has already
+	// been committed to the store when OnCommit is called. By default it is
+	// nil.
+	OnCommit func(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) bool
+
+	// OnDecodeError controls what happens when the session data in the store
+	// can't be decoded (for example, because it has been corrupted or was
+	// written by an incompatible codec). The default value is
+	// DiscardOnDecodeError, which deletes the data and starts a new session,
+	// so that one bad record doesn't lock a user out until it expires.
+	OnDecodeError DecodeErrorPolicy
+
+	// RecoverFunc is called when OnDecodeError is RecoverOnDecodeError and the
+	// session data can't be decoded. It receives the raw data from the store
+	// and the decode error, and should return the session deadline and values
+	// to use instead. If it returns an error, that error is returned by Load.
+	RecoverFunc func(ctx context.Context, b []byte, err error) (deadline time.Time, values map[string]interface{}, rerr error)
+
+	// FindTimeout and SaveTimeout, if set, limit how long loading a session
+	// from the store and committing a session to the store may take, so that
+	// a hung store doesn't use up the whole request (or serverless
+	// invocation). The store call is made with a context which has the
+	// timeout as its deadline, independent of the request deadline, and
+	// ErrStoreTimeout is returned if it doesn't finish in time. Stores which
+	// don't accept a context can't be interrupted, so their call is
+	// abandoned and allowed to finish in the background. What happens next
+	// is controlled by OnStoreTimeout. The default values are 0 (no
+	// timeout).
+	FindTimeout time.Duration
+	SaveTimeout time.Duration
+
+	// OnStoreTimeout controls what the LoadAndSave middleware does when a
+	// store operation exceeds FindTimeout or SaveTimeout. The default value
+	// is FailOnStoreTimeout.
+	OnStoreTimeout StoreTimeoutPolicy
+
+	// TolerateMissingSession controls what happens when a method is called
+	// with a context.Context which doesn't contain session data (usually
+	// because the handler isn't wrapped with the LoadAndSave() middleware).
+	// By default the method panics with ErrNoSessionManager, which makes the
+	// mistake obvious during development. When TolerateMissingSession is
+	// true, methods which return an error (such as Commit, Destroy, RenewToken
+	// and Scan) return ErrNoSessionManager instead, and other methods behave
+	// as if the session were new and empty, with any changes discarded.
+	TolerateMissingSession bool
+
+	// TombstoneTTL, if set, enables retention of destroyed and revoked
+	// sessions for forensic review. Instead of being deleted from the store,
+	// sessions removed by Destroy, DestroyToken or the AdminHandler are kept,
+	// flagged as tombstones, for TombstoneTTL before the store expires them.
+	// A tombstoned session can't be used again: Load returns
+	// ErrSessionRevoked for its token, and it is excluded from Iterate and
+	// session listings. The retained data is available via the Tombstone
+	// method. Sessions erased by EraseUserData are always deleted. By
+	// default TombstoneTTL is not set and sessions are deleted immediately.
+	TombstoneTTL time.Duration
+
+	// EncryptionKey is the AES key used by PutEncryptedString and
+	// GetEncryptedString to encrypt individual session values. It must be
+	// 16, 24 or 32 bytes long (selecting AES-128, AES-192 or AES-256), and
+	// should be kept secret. Changing the key makes existing encrypted
+	// values unreadable. By default it is nil and the encrypted value methods
+	// return ErrNoEncryptionKey.
+	EncryptionKey []byte
+
+	// EncryptionSecrets, if set, supplies the AES keys used by
+	// PutEncryptedString and GetEncryptedString instead of EncryptionKey, so
+	// that the key can be rotated without making existing encrypted values
+	// unreadable: values are encrypted with the current key, and decrypted
+	// with whichever of the keys returned by All was used. By default it is
+	// nil.
+	EncryptionSecrets SecretProvider
+
+	// TokenFingerprintKey is the secret key used to derive the token
+	// fingerprints returned by RedactToken and used as session IDs by the
+	// AdminHandler. Setting it to at least 32 random bytes means that a
+	// fingerprint can't be matched against a token (or the hashed token in
+	// the store) by anyone without the key. Use the same key for all
+	// instances of an application so that fingerprints can be correlated
+	// across them. By default it is nil and a plain SHA-256 hash is used.
+	TokenFingerprintKey []byte
+
+	// TokenFingerprintSecrets, if set, supplies the key used for token
+	// fingerprints instead of TokenFingerprintKey. Only the current key is
+	// used, so fingerprints change when the key is rotated. By default it is
+	// nil.
+	TokenFingerprintSecrets SecretProvider
+
+	// MobileCompat enables a compatibility mode for native mobile clients,
+	// whose HTTP stacks sometimes mishandle the full set of Set-Cookie
+	// attributes, and for backends which serve both browsers and native
+	// clients. When it is true the session cookie is written in a minimal
+	// form, with no Expires attribute (only Max-Age) and the remaining
+	// attributes in a conservative order. The token is also accepted from
+	// the TokenHeader request header when there is no valid session cookie,
+	// so that clients without a cookie jar can store it themselves. The token
+	// is only sent in the TokenHeader response header (whenever the session
+	// cookie is written) if the request included a TokenHeader header and no
+	// session cookie, so it isn't exposed to scripts and proxies in responses
+	// to browsers. A native client starting a new session should send an
+	// empty TokenHeader header. The default value is false.
+	MobileCompat bool
+
+	// TokenHeader is the name of the request and response header used for
+	// the session token when MobileCompat is true. The default value is
+	// "X-Session-Token".
+	TokenHeader string
+
+	// AffinityHeader, if set, is the name of a response header which the
+	// LoadAndSave middleware sets to the AffinityKey for the session token
+	// whenever the request has a session. Layer 7 load balancers can use it
+	// to route the requests for a session to the same backend (for example,
+	// to make the best use of a per-instance cache in a cachestore), without
+	// the token being exposed to them. The default value is "" (no header is
+	// sent).
+	AffinityHeader string
+
 	// HashTokenInStore controls whether or not to store the session token or a hashed version in the store.
 	HashTokenInStore bool
 
+	// ReadOnly prevents the session data from being changed. When set, Put,
+	// Remove and SetDeadline silently leave the session data unchanged, Pop
+	// returns nil, methods which return an error (such as TryPut, TryPop,
+	// TryRemove, Commit, Destroy, Clear and RenewToken) return ErrReadOnly,
+	// and the LoadAndSave middleware never commits the session or writes a
+	// session cookie. This is intended for use with a dedicated
+	// SessionManager (sharing the same Store, Codec and Cookie settings as
+	// your main one) which wraps routes that should only read session data.
+	ReadOnly bool
+
+	// UserKey is the name of the session data key which identifies the user
+	// that a session belongs to (for example "userID"). It is used by the
+	// methods which operate on all of the sessions for a user, such as
+	// ExportUserData and EraseUserData. These methods compare the string
+	// representation of the stored value (as formatted by fmt.Sprint) with
+	// the given user ID, and require a session store which supports iteration.
+	UserKey string
+
+	// UserQuota, if greater than zero, is the maximum total size in bytes of
+	// the encoded session data for all of a user's sessions (identified by
+	// UserKey). Commit returns ErrUserQuotaExceeded, and the session data is
+	// not saved, when the session data has grown since it was loaded and
+	// would take the user over the quota. Checking the quota requires
+	// iterating over all sessions in the store, so it should only be used
+	// with stores which support iteration efficiently. The default value is
+	// 0 (no quota).
+	UserQuota int
+
+	// DeviceTracking, if set, enables recording of client metadata (user
+	// agent, IP address, location and last-seen time) alongside each session
+	// in the LoadAndSave middleware. The metadata is available via the
+	// DeviceInfo and SessionsForUser methods. By default it is nil and no
+	// metadata is recorded.
+	DeviceTracking *DeviceTracking
+
+	// Impersonation, if set, enables BeginImpersonation and
+	// EndImpersonation, which let an operator act as another user. Its
+	// AuditFunc must be set. The default value is nil.
+	Impersonation *Impersonation
+
+	// ActivityTracking, if set, enables recording of a sliding window of
+	// recent request times (with a coarse category for each request) in the
+	// LoadAndSave middleware. The window is available via the RecentActivity
+	// method. Because every request changes the window, enabling this causes
+	// the session to be committed to the store on every request. By default
+	// it is nil and no activity is recorded.
+	ActivityTracking *ActivityTracking
+
+	// AuthLevelTimeout controls how long a raised authentication level (see
+	// SetAuthLevel) lasts before the session is automatically downgraded to
+	// its previous level. By default AuthLevelTimeout is not set and
+	// authentication levels do not expire.
+	AuthLevelTimeout time.Duration
+
+	// AuthLevelHandler is called by the RequireAuthLevel middleware when the
+	// session's authentication level is too low. A typical use would be to
+	// redirect the user to a re-authentication page. The default behavior is
+	// to send a HTTP 403 "Forbidden" response.
+	AuthLevelHandler http.Handler
+
+	// ExposureFunc is called by the Bucket method whenever a session is
+	// assigned to a variant of an A/B experiment for the first time. A typical
+	// use would be to record an exposure event with your analytics system.
+	ExposureFunc func(ctx context.Context, experiment string, variant string)
+
+	// CommitAfterWrite controls what happens when the session data is
+	// modified after the response headers have been written (for example,
+	// because a template or reverse proxy flushed the response early). By
+	// default these late modifications are discarded. When CommitAfterWrite
+	// is true, the LoadAndSave middleware makes a best-effort attempt to
+	// commit them to the session store at the end of the request. This is only
+	// possible if the session token already sent to the client is still
+	// valid, so it won't work for a new session or after RenewToken.
+	CommitAfterWrite bool
+
+	// WrapperCompat enables compatibility with response writer wrappers,
+	// such as http.TimeoutHandler and compression middleware, which may write
+	// the response from another goroutine, flush it before writing, or replay
+	// headers which have already been set. When it is true, the LoadAndSave
+	// middleware commits the session on the first call to Write, WriteHeader
+	// or Flush, and sets the session cookie idempotently, replacing any
+	// Set-Cookie header already in the response for the same cookie, so that
+	// exactly one is sent. The default value is false.
+	WrapperCompat bool
+
+	// TokenTrailer, if set, is the name of a HTTP trailer used to send the
+	// session token when the session data is modified after the response
+	// headers have been written, such as on gRPC-web and other streaming
+	// endpoints which flush the headers immediately. The LoadAndSave
+	// middleware declares the trailer before calling the handler, and if the
+	// session is modified (including by RenewToken) or destroyed after the
+	// headers are written, it commits the session and sends the new token
+	// (or an empty value if the session was destroyed) in the trailer.
+	// Clients read it with TrailerToken. Because declaring a trailer makes
+	// HTTP/1.1 responses use chunked encoding, only enable this for
+	// endpoints which need it, for example with a separate SessionManager.
+	// The default value is "" (no trailer is sent).
+	TokenTrailer string
+
+	// CacheablePaths is a list of URL path prefixes for responses which may
+	// be cached by a CDN or other shared cache. The LoadAndSave middleware
+	// never sends the session cookie (or the TokenHeader or TokenTrailer)
+	// with these responses, so a cached response can't hand one visitor's
+	// session token to everyone. Changes to an existing session are still
+	// committed, but a session created by the request is discarded, and
+	// RotateEvery is ignored for these paths. Handlers for these paths
+	// should not call RenewToken, because the client would never receive
+	// the new token. The default value is nil.
+	CacheablePaths []string
+
+	// SuppressCookieOnCacheable extends CacheablePaths to responses which
+	// the handler has marked as cacheable by shared caches, with a
+	// Cache-Control header containing the "public" or "s-maxage"
+	// directive. The header must be set before the response is written.
+	// The default value is false.
+	SuppressCookieOnCacheable bool
+
+	// LargeSessionFunc, if set, is called by Commit when the encoded size of
+	// the session data exceeds LargeSessionThreshold bytes, with the size
+	// and the largest session values. It is intended to help find the code
+	// responsible for storing too much data in sessions, for example by
+	// logging a warning or recording a metric. The session data is committed
+	// as usual. By default LargeSessionFunc is nil.
+	LargeSessionFunc func(ctx context.Context, ls LargeSession)
+
+	// LargeSessionThreshold is the encoded session size, in bytes, above
+	// which LargeSessionFunc is called. If it is zero, LargeSessionFunc is
+	// never called.
+	LargeSessionThreshold int
+
+	// LargeSessionKeys is the maximum number of session values described in
+	// the LargeSession passed to LargeSessionFunc. If zero, 5 is used.
+	LargeSessionKeys int
+
+	// CleanupFunc, if set, is called with the references to external
+	// resources recorded by AddCleanupRef when a session is destroyed by
+	// Destroy, or is found to have expired by CleanupExpired. It should
+	// delete the resources, and return an error only if they should be
+	// retried later. By default CleanupFunc is nil and references are not
+	// tracked in the store.
+	CleanupFunc func(ctx context.Context, refs []string) error
+
+	// LateWriteFunc is called by the LoadAndSave middleware when the session
+	// data has been modified after the response headers were written. The
+	// error is nil if the modifications were committed to the session store,
+	// and otherwise explains why they were discarded. A typical use would be
+	// to log a warning. By default LateWriteFunc is nil.
+	LateWriteFunc func(r *http.Request, err error)
+
+	// DuplicateCookieFunc is called by the LoadAndSave middleware when a
+	// request contains more than one session cookie, along with the number of
+	// session cookies. This usually indicates a misconfiguration, such as
+	// cookies being set for both a domain and its subdomains, or with
+	// overlapping paths. The first cookie containing the token of a session
+	// which exists in the store is used, and expired session cookies (for the
+	// configured Domain, and also without a Domain attribute if Domain is set)
+	// are sent to remove the others before a fresh session cookie is written.
+	// A typical use would be to log a warning. By default
+	// DuplicateCookieFunc is nil.
+	DuplicateCookieFunc func(r *http.Request, n int)
+
+	// ClearInvalidCookies controls whether the LoadAndSave middleware sends
+	// an expired session cookie when the request contains a session cookie
+	// with a malformed token (one which could not have been generated by this
+	// package, such as an oversized or garbage value). Malformed tokens are
+	// always ignored without looking them up in the session store; setting
+	// this stops the client from sending them again. The default value is
+	// false.
+	ClearInvalidCookies bool
+
+	// RenewStaleCookies controls whether the LoadAndSave middleware replaces
+	// a session cookie whose token doesn't match a session in the store
+	// (because the session has expired or been destroyed). When set, the new
+	// empty session is committed and its cookie sent at the end of the
+	// request, even if the session data wasn't modified, so the client stops
+	// sending the stale token. By default a new session is only committed if
+	// it is modified, and the stale cookie is left in place until then.
+	RenewStaleCookies bool
+
+	// StrictTokens controls whether the LoadAndSave middleware rejects
+	// requests which present a session cookie whose token doesn't match a
+	// session in the store (or is malformed), instead of silently starting a
+	// new anonymous session. This is useful for APIs, where an unknown token
+	// should usually result in a 401 response. Rejected requests are passed
+	// to UnknownTokenHandler if it is set, and otherwise to ErrorFunc with
+	// the error ErrUnknownToken. The default value is false.
+	StrictTokens bool
+
+	// UnknownTokenHandler is called by the LoadAndSave middleware to respond
+	// to requests rejected because of the StrictTokens setting. A typical use
+	// would be to send a HTTP 401 "Unauthorized" response. By default it is
+	// nil and ErrorFunc is called instead.
+	UnknownTokenHandler http.Handler
+
+	// AsyncSave, if set, enables committing modified session data to the
+	// store from a pool of background workers, so the LoadAndSave middleware
+	// can send the response without waiting for the store. The session cookie
+	// is written immediately. Because the save runs concurrently with
+	// writing the response, a following request may briefly see the previous
+	// session data, and store errors are reported to AsyncSave.ErrorFunc
+	// rather than ErrorFunc.
+	// Call Shutdown to wait for queued saves before the application exits.
+	// The default value is nil (saves are synchronous).
+	AsyncSave *AsyncSave
+
+	// StoreLimit, if set, limits the number of concurrent loads and saves,
+	// queueing or shedding further operations so that a slow store isn't
+	// overwhelmed. Shed operations return ErrStoreOverloaded, which is passed
+	// to ErrorFunc by the LoadAndSave middleware. When the limit has been
+	// reached, the middleware skips saves which would only extend the idle
+	// timeout. Use StoreLimitStats to monitor saturation. The default value
+	// is nil (no limit).
+	StoreLimit *StoreLimit
+
+	// DuplicateCreation, if set, enables reconciling the sessions created by
+	// parallel requests from a client which doesn't have a session cookie yet,
+	// so that data stored by one of the requests isn't lost when its cookie
+	// is overwritten by the other's. See DuplicateCreation for details. The
+	// default value is nil.
+	DuplicateCreation *DuplicateCreation
+
+	// Validators, if set, maps session data keys to functions which check
+	// values before they are added by Put (and the methods built on it),
+	// so that invalid data is rejected before it is saved and read by later
+	// requests. A key ending in "*" is a prefix pattern which applies to all
+	// keys starting with the rest of the key. A value rejected by Put is
+	// discarded and reported to RejectedWriteFunc; use TryPut to get the
+	// error instead. Validators must not be modified after the
+	// SessionManager is in use. The default value is nil.
+	Validators map[string]ValueValidator
+
+	// RejectedWriteFunc is called when Put discards a value because it was
+	// rejected by one of the Validators, and when Put or Remove is called for
+	// a key protected with Protect (with the error ErrKeyProtected). If it is
+	// nil, the error is logged using Go's standard logger.
+	RejectedWriteFunc func(ctx context.Context, key string, err error)
+
+	// OnKeyExpired is called when Load finds that a value added with
+	// PutWithTTL has expired, after the value has been removed from the
+	// session data. It is passed the context returned by Load (so it can
+	// read and change the session data), the key and the expired value. A
+	// typical use would be to clean up after an abandoned flow, such as an
+	// invitation which was never accepted. By default OnKeyExpired is nil.
+	OnKeyExpired func(ctx context.Context, key string, val interface{})
+
 	// contextKey is the key used to set and retrieve the session data from a
 	// context.Context. It's automatically generated to ensure uniqueness.
 	contextKey contextKey
+
+	// lifecycle tracks background goroutines for Shutdown.
+	lifecycle lifecycle
+
+	// hot holds the settings changed by the Set methods.
+	hot hotConfig
+
+	// saver holds the queues and counters for AsyncSave.
+	saver saver
+
+	// limiter holds the semaphore and counters for StoreLimit.
+	limiter storeLimiter
+
+	// tokenLocks serializes the store writes made for each session token.
+	tokenLocks tokenLocks
 }
 
 // SessionCookie contains the configuration settings for session cookies.
@@ -62,10 +536,33 @@ type SessionCookie struct {
 	// the cookie name for each is unique.
 	Name string
 
-	// Domain sets the 'Domain' attribute on the session cookie. By default
-	// it will be set to the domain name that the cookie was issued from.
+	// Domain sets the 'Domain' attribute on the session cookie, so that the
+	// cookie is sent to the domain and all of its subdomains. A leading dot
+	// is ignored. By default it is empty and the cookie is host-only: it is
+	// only sent to the host that issued it.
 	Domain string
 
+	// DomainFunc, if set, is called when the session cookie is written to
+	// choose its 'Domain' attribute for the request, for example to use the
+	// custom domain of a tenant in a multi-tenant application. Returning the
+	// empty string makes the cookie host-only. Cookies written without a
+	// request (such as by WriteSessionCookie) use Domain instead. The
+	// returned Domain is checked in the same way as Domain by the
+	// LoadAndSave middleware. By default it is nil.
+	DomainFunc func(r *http.Request) string
+
+	// HostOnly explicitly makes the session cookie host-only, so that it is
+	// never sent to subdomains. When it is true, setting Domain is reported
+	// as an error by Validate. The default value is false.
+	HostOnly bool
+
+	// PublicSuffix, if set, is used by Validate to reject a Domain which is a
+	// public suffix (such as "co.uk" or "github.io"), for which browsers
+	// refuse to set cookies. It has the same signature as the PublicSuffix
+	// function in the golang.org/x/net/publicsuffix package, which can be
+	// used directly. Top-level domains are always rejected.
+	PublicSuffix func(domain string) (publicSuffix string, icann bool)
+
 	// HttpOnly sets the 'HttpOnly' attribute on the session cookie. The
 	// default value is true.
 	HttpOnly bool
@@ -95,6 +592,21 @@ type SessionCookie struct {
 	// requests over HTTPS in production environments.
 	// See https://github.com/OWASP/CheatSheetSeries/blob/master/cheatsheets/Session_Management_Cheat_Sheet.md#transport-layer-security.
 	Secure bool
+
+	// ExpireAtDeadline controls the expiry time of persistent session cookies
+	// when an idle timeout is being used. By default the cookie expiry slides
+	// along with the session's idle expiry: whenever the session is committed
+	// with a new expiry time (which happens on every request that extends the
+	// session) the cookie is rewritten with the same expiry, so the browser
+	// and server agree on when the session will expire. This means the cookie
+	// isn't refreshed when the idle timeout is extended without a session
+	// cookie being written, such as by KeepAlive during a request whose
+	// response has already started. Setting ExpireAtDeadline to true makes the
+	// cookie expire at the session's absolute deadline instead, so the browser
+	// never discards the cookie while the session is still valid on the
+	// server; the idle timeout is still enforced by the server. The default
+	// value is false.
+	ExpireAtDeadline bool
 }
 
 // New returns a new session manager with the default options. It is safe for
@@ -106,6 +618,7 @@ func New() *SessionManager {
 		Store:       memstore.New(),
 		Codec:       GobCodec{},
 		ErrorFunc:   defaultErrorFunc,
+		TokenHeader: "X-Session-Token",
 		contextKey:  generateContextKey(),
 		Cookie: SessionCookie{
 			Name:     "session",
@@ -131,21 +644,54 @@ func NewSession() *SessionManager {
 // the client in a cookie.
 func (s *SessionManager) LoadAndSave(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Vary", "Cookie")
+		if err := s.cookie().validateRequest(r); err != nil {
+			s.ErrorFunc(w, r, err)
+			return
+		}
 
-		var token string
-		cookie, err := r.Cookie(s.Cookie.Name)
-		if err == nil {
-			token = cookie.Value
+		r, err := s.claimCookieName(r)
+		if err != nil {
+			s.ErrorFunc(w, r, err)
+			return
 		}
 
-		ctx, err := s.Load(r.Context(), token)
+		w.Header().Add("Vary", "Cookie")
+		if s.MobileCompat {
+			w.Header().Add("Vary", s.TokenHeader)
+		}
+		s.declareTokenTrailer(w)
+
+		ctx, rc, err := s.loadFromCookies(r)
 		if err != nil {
 			s.ErrorFunc(w, r, err)
 			return
 		}
 
 		sr := r.WithContext(ctx)
+		if s.StrictTokens && s.Token(ctx) == "" && rc.count > 0 && !s.degraded(ctx) {
+			if s.UnknownTokenHandler != nil {
+				s.UnknownTokenHandler.ServeHTTP(w, sr)
+			} else {
+				s.ErrorFunc(w, sr, ErrUnknownToken)
+			}
+			return
+		}
+		if rc.count > 1 {
+			s.resolveDuplicateCookies(w, sr, rc.count)
+		} else if rc.invalid > 0 && s.ClearInvalidCookies && !s.ReadOnly {
+			s.writeExpiredCookie(w, r, s.cookie())
+		}
+		if rc.stale && s.RenewStaleCookies && !s.ReadOnly {
+			s.markModified(ctx)
+		}
+		if s.RotateEvery > 0 && !s.ReadOnly && !s.cacheablePath(r) {
+			if err := s.rotateToken(ctx); err != nil {
+				s.ErrorFunc(w, sr, err)
+				return
+			}
+		}
+		s.trackDevice(sr)
+		s.trackActivity(sr)
 
 		sw := &sessionResponseWriter{
 			ResponseWriter: w,
@@ -153,62 +699,327 @@ func (s *SessionManager) LoadAndSave(next http.Handler) http.Handler {
 			sessionManager: s,
 		}
 
-		next.ServeHTTP(sw, sr)
-
-		if !sw.written {
-			s.commitAndWriteSessionCookie(w, sr)
+		if s.serveHandler(next, sw, sr) {
+			s.commitAfterHandler(sw, sr)
 		}
 	})
 }
 
+// claimCookieName records the session cookie name in the request context, so
+// that nested LoadAndSave middlewares using the same name can be detected. It
+// returns ErrNestedMiddleware if the name has already been claimed.
+func (s *SessionManager) claimCookieName(r *http.Request) (*http.Request, error) {
+	name := s.cookie().Name
+	names, _ := r.Context().Value(middlewareKey{}).([]string)
+	if containsString(names, name) {
+		return r, ErrNestedMiddleware
+	}
+
+	names = append(names[:len(names):len(names)], name)
+	return r.WithContext(context.WithValue(r.Context(), middlewareKey{}, names)), nil
+}
+
+// requestCookies describes the session cookies sent with a request.
+type requestCookies struct {
+	// count is the number of cookies with the session cookie name.
+	count int
+
+	// invalid is the number of those cookies which contain a malformed token.
+	invalid int
+
+	// stale is true if a well-formed token was sent but none of the tokens
+	// matched a session in the store (because the session has expired or
+	// been destroyed).
+	stale bool
+}
+
+// maxCookieTokens is the maximum number of distinct session tokens from the
+// request cookies which are looked up in the store.
+const maxCookieTokens = 4
+
+// loadFromCookies loads the session identified by the session cookie in r.
+// Cookies containing a malformed token are ignored without a store lookup. If
+// the request contains more than one session cookie, the first one containing
+// a token for a session which exists in the store is used.
+func (s *SessionManager) loadFromCookies(r *http.Request) (context.Context, requestCookies, error) {
+	var rc requestCookies
+	var tokens []string
+	name := s.cookie().Name
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != name {
+			continue
+		}
+		rc.count++
+		if !validToken(cookie.Value) {
+			rc.invalid++
+			continue
+		}
+		// The cookies are attacker-controlled, so the number of tokens looked
+		// up in the store is limited.
+		if len(tokens) < maxCookieTokens && !containsString(tokens, cookie.Value) {
+			tokens = append(tokens, cookie.Value)
+		}
+	}
+
+	if s.MobileCompat {
+		if token := r.Header.Get(s.TokenHeader); validToken(token) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, token := range tokens {
+		ctx, err := s.Load(r.Context(), token)
+		if errors.Is(err, ErrSessionRevoked) {
+			continue
+		} else if err != nil {
+			return nil, rc, err
+		}
+		if s.Token(ctx) != "" || s.degraded(ctx) {
+			return ctx, rc, nil
+		}
+	}
+
+	rc.stale = len(tokens) > 0
+
+	ctx, err := s.Load(r.Context(), "")
+	return ctx, rc, err
+}
+
+// resolveDuplicateCookies writes expired session cookies to w, so that the
+// client discards the duplicate cookies, and marks the session as modified so
+// that a fresh cookie for the chosen session is written afterwards.
+func (s *SessionManager) resolveDuplicateCookies(w http.ResponseWriter, r *http.Request, n int) {
+	if s.DuplicateCookieFunc != nil {
+		s.DuplicateCookieFunc(r, n)
+	}
+
+	if s.ReadOnly {
+		return
+	}
+
+	c := s.cookie()
+	s.writeExpiredCookie(w, r, c)
+	if c.domain(r) != "" {
+		expired := c
+		expired.Domain = ""
+		expired.DomainFunc = nil
+		s.writeExpiredCookie(w, r, expired)
+	}
+
+	if s.Token(r.Context()) != "" {
+		s.markModified(r.Context())
+	}
+}
+
+// commitLateWrite handles modifications made to the session data after the
+// response headers (and session cookie) were sent with the given token.
+func (s *SessionManager) commitLateWrite(w http.ResponseWriter, r *http.Request, token string) {
+	ctx := r.Context()
+	if s.ReadOnly {
+		return
+	}
+	if s.TokenTrailer != "" && !s.cacheable(w, r) {
+		status := s.Status(ctx)
+		if status == Modified || (status == Destroyed && token != "") {
+			err := s.writeTokenTrailer(w, r)
+			if s.LateWriteFunc != nil {
+				s.LateWriteFunc(r, err)
+			}
+		}
+		return
+	}
+	if s.Status(ctx) != Modified {
+		return
+	}
+
+	var err error
+	switch {
+	case !s.CommitAfterWrite:
+		err = ErrHeadersWritten
+	case token == "" || s.Token(ctx) != token:
+		err = ErrHeadersWritten
+	default:
+		_, _, err = s.Commit(ctx)
+	}
+
+	if s.LateWriteFunc != nil {
+		s.LateWriteFunc(r, err)
+	}
+}
+
 func (s *SessionManager) commitAndWriteSessionCookie(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.writeAffinityHeader(w, r.Context())
+		return
+	}
+	if s.cacheable(w, r) {
+		s.commitWithoutCookie(w, r)
+		return
+	}
+	if s.degraded(r.Context()) {
+		return
+	}
+
 	ctx := r.Context()
+	s.trackDevice(r)
+	s.trackActivity(r)
 
 	switch s.Status(ctx) {
 	case Modified:
-		token, expiry, err := s.Commit(ctx)
-		if err != nil {
+		if s.skipTouch(ctx) {
+			break
+		}
+		reconcile := s.DuplicateCreation != nil && s.AsyncSave == nil && s.Token(ctx) == ""
+		commit := s.Commit
+		if s.AsyncSave != nil {
+			commit = s.commitAsync
+		}
+		token, expiry, err := commit(ctx)
+		if err == nil && reconcile {
+			token, expiry, err = s.reconcileCreation(ctx, r, token, expiry)
+		}
+		if errors.Is(err, ErrStoreTimeout) && s.OnStoreTimeout == DegradeOnStoreTimeout {
+			log.Print(err)
+			return
+		} else if err != nil {
 			s.ErrorFunc(w, r, err)
 			return
 		}
 
-		s.WriteSessionCookie(ctx, w, token, expiry)
+		s.writeSessionCookie(ctx, w, r, token, expiry)
+		s.markCommitted(ctx)
 	case Destroyed:
-		s.WriteSessionCookie(ctx, w, "", time.Time{})
+		s.writeSessionCookie(ctx, w, r, "", time.Time{})
+		return
 	}
+	s.writeAffinityHeader(w, ctx)
 }
 
 // WriteSessionCookie writes a cookie to the HTTP response with the provided
 // token as the cookie value and expiry as the cookie expiry time. The expiry
 // time will be included in the cookie only if the session is set to persist
-// or has had RememberMe(true) called on it. If expiry is an empty time.Time
+// or has had RememberMe(true) called on it, and is replaced by the session
+// deadline if Cookie.ExpireAtDeadline is set. If expiry is an empty time.Time
 // struct (so that it's IsZero() method returns true) the cookie will be
 // marked with a historical expiry time and negative max-age (so the browser
 // deletes it).
 //
+// The OnCommit hook is called with a nil request when the cookie is written by
+// WriteSessionCookie.
+//
 // Most applications will use the LoadAndSave() middleware and will not need to
 // use this method.
 func (s *SessionManager) WriteSessionCookie(ctx context.Context, w http.ResponseWriter, token string, expiry time.Time) {
+	s.writeSessionCookie(ctx, w, nil, token, expiry)
+}
+
+func (s *SessionManager) writeSessionCookie(ctx context.Context, w http.ResponseWriter, r *http.Request, token string, expiry time.Time) {
+	c := s.cookie()
 	cookie := &http.Cookie{
-		Name:     s.Cookie.Name,
+		Name:     c.Name,
 		Value:    token,
-		Path:     s.Cookie.Path,
-		Domain:   s.Cookie.Domain,
-		Secure:   s.Cookie.Secure,
-		HttpOnly: s.Cookie.HttpOnly,
-		SameSite: s.Cookie.SameSite,
+		Path:     c.Path,
+		Domain:   c.domain(r),
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
 	}
 
 	if expiry.IsZero() {
 		cookie.Expires = time.Unix(1, 0)
 		cookie.MaxAge = -1
-	} else if s.Cookie.Persist || s.GetBool(ctx, "__rememberMe") {
+	} else if c.Persist || s.GetBool(ctx, "__rememberMe") {
+		if c.ExpireAtDeadline {
+			if _, ok := ctx.Value(s.contextKey).(*sessionData); ok {
+				expiry = s.Deadline(ctx)
+			}
+		}
 		cookie.Expires = time.Unix(expiry.Unix()+1, 0)        // Round up to the nearest second.
 		cookie.MaxAge = int(time.Until(expiry).Seconds() + 1) // Round up to the nearest second.
 	}
 
-	w.Header().Add("Set-Cookie", cookie.String())
-	w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+	if s.OnCommit != nil && !s.OnCommit(w, r, cookie) {
+		return
+	}
+
+	if s.WrapperCompat {
+		setCookieOnce(w.Header(), cookie, s.cookieString(cookie))
+	} else {
+		w.Header().Add("Set-Cookie", s.cookieString(cookie))
+		w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+	}
+	if s.tokenHeaderClient(r) {
+		w.Header().Set(s.TokenHeader, cookie.Value)
+	}
+}
+
+// tokenHeaderClient reports whether the session token should be sent in the
+// TokenHeader response header for r, because MobileCompat is enabled and r
+// included a TokenHeader header but no session cookie.
+func (s *SessionManager) tokenHeaderClient(r *http.Request) bool {
+	if !s.MobileCompat || r == nil {
+		return false
+	}
+	if _, ok := r.Header[http.CanonicalHeaderKey(s.TokenHeader)]; !ok {
+		return false
+	}
+	_, err := r.Cookie(s.cookie().Name)
+	return err != nil
+}
+
+func (s *SessionManager) writeExpiredCookie(w http.ResponseWriter, r *http.Request, c SessionCookie) {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Path:     c.Path,
+		Domain:   c.domain(r),
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+		Expires:  time.Unix(1, 0),
+		MaxAge:   -1,
+	}
+	if s.OnCommit != nil && !s.OnCommit(w, r, cookie) {
+		return
+	}
+	w.Header().Add("Set-Cookie", s.cookieString(cookie))
+}
+
+// cookieString returns the Set-Cookie header value for a cookie. When
+// MobileCompat is true it returns the minimal form, which omits the Expires
+// attribute and always lists the attributes in the same conservative order.
+func (s *SessionManager) cookieString(cookie *http.Cookie) string {
+	if !s.MobileCompat {
+		return cookie.String()
+	}
+
+	var b strings.Builder
+	b.WriteString((&http.Cookie{Name: cookie.Name, Value: cookie.Value}).String())
+	if cookie.Path != "" {
+		b.WriteString("; Path=" + cookie.Path)
+	}
+	if cookie.Domain != "" {
+		b.WriteString("; Domain=" + strings.TrimPrefix(cookie.Domain, "."))
+	}
+	if cookie.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(cookie.MaxAge))
+	} else if cookie.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if cookie.Secure {
+		b.WriteString("; Secure")
+	}
+	if cookie.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	switch cookie.SameSite {
+	case http.SameSiteLaxMode:
+		b.WriteString("; SameSite=Lax")
+	case http.SameSiteStrictMode:
+		b.WriteString("; SameSite=Strict")
+	case http.SameSiteNoneMode:
+		b.WriteString("; SameSite=None")
+	}
+	return b.String()
 }
 
 func defaultErrorFunc(w http.ResponseWriter, r *http.Request, err error) {
@@ -220,23 +1031,41 @@ type sessionResponseWriter struct {
 	http.ResponseWriter
 	request        *http.Request
 	sessionManager *SessionManager
-	written        bool
+
+	// mu protects written and token, as wrappers such as http.TimeoutHandler
+	// may write to the response from a different goroutine to the handler.
+	mu      sync.Mutex
+	written bool
+	token   string
 }
 
-func (sw *sessionResponseWriter) Write(b []byte) (int, error) {
-	if !sw.written {
-		sw.sessionManager.commitAndWriteSessionCookie(sw.ResponseWriter, sw.request)
-		sw.written = true
+// writeSessionCookie commits the session and writes the session cookie, unless
+// this has already been done, and reports whether it was already done and the
+// token which was sent.
+func (sw *sessionResponseWriter) writeSessionCookie() (bool, string) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.written {
+		return true, sw.token
 	}
+	sw.sessionManager.commitAndWriteSessionCookie(sw.ResponseWriter, sw.request)
+	sw.token = sw.sessionManager.Token(sw.request.Context())
+	sw.written = true
+	return false, sw.token
+}
+
+func (sw *sessionResponseWriter) Write(b []byte) (int, error) {
+	sw.writeSessionCookie()
 
 	return sw.ResponseWriter.Write(b)
 }
 
 func (sw *sessionResponseWriter) WriteHeader(code int) {
-	if !sw.written {
-		sw.sessionManager.commitAndWriteSessionCookie(sw.ResponseWriter, sw.request)
-		sw.written = true
+	if as := sw.sessionManager.AsyncSave; as != nil && as.WaitForRedirects && code >= 300 && code < 400 {
+		sw.sessionManager.WaitForSave(sw.request.Context())
 	}
+	sw.writeSessionCookie()
 
 	sw.ResponseWriter.WriteHeader(code)
 }
@@ -10,6 +10,9 @@ import (
 )
 
 func (sw *sessionResponseWriter) Flush() {
+	if sw.sessionManager.WrapperCompat {
+		sw.writeSessionCookie()
+	}
 	http.NewResponseController(sw.ResponseWriter).Flush()
 }
 
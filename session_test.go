@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -348,3 +349,632 @@ func TestIterate(t *testing.T) {
 		t.Fatal("didn't get expected error")
 	}
 }
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.IdleTimeout = time.Hour
+
+	readOnlyManager := New()
+	readOnlyManager.Store = sessionManager.Store
+	readOnlyManager.IdleTimeout = time.Hour
+	readOnlyManager.ReadOnly = true
+
+	mux := http.NewServeMux()
+	mux.Handle("/put", sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})))
+	mux.Handle("/readonly", readOnlyManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readOnlyManager.Put(r.Context(), "baz", "qux")
+		readOnlyManager.Remove(r.Context(), "foo")
+		if v := readOnlyManager.Pop(r.Context(), "foo"); v != nil {
+			http.Error(w, "unexpected Pop value", 500)
+			return
+		}
+		if err := readOnlyManager.TryPut(r.Context(), "baz", "qux"); err != ErrReadOnly {
+			http.Error(w, fmt.Sprintf("unexpected TryPut error: %v", err), 500)
+			return
+		}
+		if _, err := readOnlyManager.TryPop(r.Context(), "foo"); err != ErrReadOnly {
+			http.Error(w, fmt.Sprintf("unexpected TryPop error: %v", err), 500)
+			return
+		}
+		if err := readOnlyManager.TryRemove(r.Context(), "foo"); err != ErrReadOnly {
+			http.Error(w, fmt.Sprintf("unexpected TryRemove error: %v", err), 500)
+			return
+		}
+		if err := readOnlyManager.Destroy(r.Context()); err != ErrReadOnly {
+			http.Error(w, fmt.Sprintf("unexpected Destroy error: %v", err), 500)
+			return
+		}
+		if err := readOnlyManager.RenewToken(r.Context()); err != ErrReadOnly {
+			http.Error(w, fmt.Sprintf("unexpected RenewToken error: %v", err), 500)
+			return
+		}
+		w.Write([]byte(strings.Join(readOnlyManager.Keys(r.Context()), ",") + ":" + readOnlyManager.GetString(r.Context(), "foo")))
+	})))
+
+	ts := newTestServer(t, mux)
+	defer ts.Close()
+
+	ts.execute(t, "/put")
+
+	header, body := ts.execute(t, "/readonly")
+	if want := lastActivityKey + ",foo:bar"; body != want {
+		t.Errorf("want %q; got %q", want, body)
+	}
+	if header.Get("Set-Cookie") != "" {
+		t.Errorf("want %q; got %q", "", header.Get("Set-Cookie"))
+	}
+}
+
+func TestCommitAfterWrite(t *testing.T) {
+	t.Parallel()
+
+	for _, commitAfterWrite := range []bool{false, true} {
+		sessionManager := New()
+		sessionManager.CommitAfterWrite = commitAfterWrite
+
+		lateErrs := make(chan error, 10)
+		sessionManager.LateWriteFunc = func(r *http.Request, err error) {
+			lateErrs <- err
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+			sessionManager.Put(r.Context(), "foo", "bar")
+		})
+		mux.HandleFunc("/late-put", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("flushed"))
+			sessionManager.Put(r.Context(), "foo", "baz")
+		})
+		mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+		})
+
+		ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+
+		// A new session modified after the headers are written can never be
+		// committed, because the client doesn't have a token.
+		ts.execute(t, "/late-put")
+		if err := <-lateErrs; err != ErrHeadersWritten {
+			t.Errorf("want %v; got %v", ErrHeadersWritten, err)
+		}
+
+		ts.execute(t, "/put")
+		ts.execute(t, "/late-put")
+
+		want := "bar"
+		var wantErr error = ErrHeadersWritten
+		if commitAfterWrite {
+			want = "baz"
+			wantErr = nil
+		}
+
+		if err := <-lateErrs; err != wantErr {
+			t.Errorf("CommitAfterWrite=%v: want %v; got %v", commitAfterWrite, wantErr, err)
+		}
+
+		_, body := ts.execute(t, "/get")
+		if body != want {
+			t.Errorf("CommitAfterWrite=%v: want %q; got %q", commitAfterWrite, want, body)
+		}
+		if len(lateErrs) != 0 {
+			t.Errorf("CommitAfterWrite=%v: want no further late writes; got %d", commitAfterWrite, len(lateErrs))
+		}
+
+		ts.Close()
+	}
+}
+
+func TestDuplicateCookies(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.Cookie.Domain = "example.com"
+
+	var duplicates int
+	sessionManager.DuplicateCookieFunc = func(r *http.Request, n int) {
+		duplicates = n
+	}
+
+	ctx, err := sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionManager.Put(ctx, "foo", "bar")
+	token, _, err := sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	}))
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "session=stale; session="+token)
+	h.ServeHTTP(rr, r)
+
+	if rr.Body.String() != "bar" {
+		t.Errorf("want %q; got %q", "bar", rr.Body.String())
+	}
+	if duplicates != 2 {
+		t.Errorf("want %d; got %d", 2, duplicates)
+	}
+
+	cookies := rr.Header()["Set-Cookie"]
+	if len(cookies) != 3 {
+		t.Fatalf("want 3 Set-Cookie headers; got %d: %q", len(cookies), cookies)
+	}
+	if !strings.HasPrefix(cookies[0], "session=; Path=/; Domain=example.com; Expires=Thu, 01 Jan 1970 00:00:01 GMT; Max-Age=0") {
+		t.Errorf("want expired domain cookie; got %q", cookies[0])
+	}
+	if !strings.HasPrefix(cookies[1], "session=; Path=/; Expires=Thu, 01 Jan 1970 00:00:01 GMT; Max-Age=0") {
+		t.Errorf("want expired host-only cookie; got %q", cookies[1])
+	}
+	if !strings.HasPrefix(cookies[2], "session="+token+";") {
+		t.Errorf("want fresh session cookie; got %q", cookies[2])
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	t.Parallel()
+
+	for _, clear := range []bool{false, true} {
+		sessionManager := New()
+		sessionManager.ClearInvalidCookies = clear
+		sessionManager.Store = &findCounterStore{Store: sessionManager.Store}
+
+		h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		for _, token := range []string{strings.Repeat("a", 4096), strings.Repeat("!", 43)} {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Cookie", "session="+token)
+			h.ServeHTTP(rr, r)
+
+			cookie := rr.Header().Get("Set-Cookie")
+			if clear && !strings.HasPrefix(cookie, "session=; Path=/; Expires=Thu, 01 Jan 1970 00:00:01 GMT; Max-Age=0") {
+				t.Errorf("want expired cookie; got %q", cookie)
+			}
+			if !clear && cookie != "" {
+				t.Errorf("want no cookie; got %q", cookie)
+			}
+		}
+
+		if n := sessionManager.Store.(*findCounterStore).finds; n != 0 {
+			t.Errorf("want no store lookups; got %d", n)
+		}
+	}
+}
+
+type findCounterStore struct {
+	Store
+	finds int
+}
+
+func (s *findCounterStore) Find(token string) ([]byte, bool, error) {
+	s.finds++
+	return s.Store.Find(token)
+}
+
+func TestCookieTokenLimit(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.Store = &findCounterStore{Store: sessionManager.Store}
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var cookies []string
+	for i := 0; i < 100; i++ {
+		token, err := generateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Repeated tokens are only looked up once.
+		cookies = append(cookies, "session="+token, "session="+token)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", strings.Join(cookies, "; "))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if n := sessionManager.Store.(*findCounterStore).finds; n != maxCookieTokens {
+		t.Errorf("want %d store lookups; got %d", maxCookieTokens, n)
+	}
+}
+
+func TestRenewStaleCookies(t *testing.T) {
+	t.Parallel()
+
+	for _, renew := range []bool{false, true} {
+		sessionManager := New()
+		sessionManager.RenewStaleCookies = renew
+
+		h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		stale, err := generateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Cookie", "session="+stale)
+		h.ServeHTTP(rr, r)
+
+		cookie := rr.Header().Get("Set-Cookie")
+		if !renew {
+			if cookie != "" {
+				t.Errorf("want no cookie; got %q", cookie)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(cookie, "session=") {
+			t.Fatalf("want session cookie; got %q", cookie)
+		}
+		token := extractTokenFromCookie(cookie)
+		if token == stale {
+			t.Errorf("want new token; got stale token")
+		}
+		if _, found, _ := sessionManager.Store.Find(token); !found {
+			t.Errorf("want new session to be committed to the store")
+		}
+
+		// A request without a cookie should not create a session.
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		if cookie := rr.Header().Get("Set-Cookie"); cookie != "" {
+			t.Errorf("want no cookie; got %q", cookie)
+		}
+	}
+}
+
+func TestStrictTokens(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.StrictTokens = true
+
+	var errs []error
+	sessionManager.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		errs = append(errs, err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+
+	h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+	token := extractTokenFromCookie(rr.Header().Get("Set-Cookie"))
+
+	rr = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "session="+token)
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+
+	unknown, _ := generateToken()
+	rr = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", "session="+unknown)
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want no cookie; got %q", rr.Header().Get("Set-Cookie"))
+	}
+	if len(errs) != 1 || errs[0] != ErrUnknownToken {
+		t.Errorf("want [%v]; got %v", ErrUnknownToken, errs)
+	}
+
+	sessionManager.UnknownTokenHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("want %d; got %d", http.StatusTeapot, rr.Code)
+	}
+}
+
+func TestCookieExpiry(t *testing.T) {
+	t.Parallel()
+
+	for _, expireAtDeadline := range []bool{false, true} {
+		sessionManager := New()
+		sessionManager.IdleTimeout = time.Hour
+		sessionManager.Lifetime = 24 * time.Hour
+		sessionManager.Cookie.ExpireAtDeadline = expireAtDeadline
+
+		h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessionManager.Put(r.Context(), "foo", "bar")
+		}))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("want 1 cookie; got %d", len(cookies))
+		}
+
+		want := time.Hour
+		if expireAtDeadline {
+			want = 24 * time.Hour
+		}
+		if got := time.Duration(cookies[0].MaxAge) * time.Second; got < want || got > want+2*time.Second {
+			t.Errorf("ExpireAtDeadline=%v: want Max-Age of %v; got %v", expireAtDeadline, want, got)
+		}
+	}
+}
+
+func TestRotateEvery(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.RotateEvery = 100 * time.Millisecond
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %d", sessionManager.GetString(r.Context(), "foo"), sessionManager.Deadline(r.Context()).UnixNano())
+	})
+
+	ts := newTestServer(t, sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	header, _ := ts.execute(t, "/put")
+	token1 := extractTokenFromCookie(header.Get("Set-Cookie"))
+
+	header, body1 := ts.execute(t, "/get")
+	if header.Get("Set-Cookie") != "" {
+		t.Errorf("want no cookie before the interval; got %q", header.Get("Set-Cookie"))
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	header, body2 := ts.execute(t, "/get")
+	token2 := extractTokenFromCookie(header.Get("Set-Cookie"))
+	if token2 == token1 {
+		t.Error("want token to be rotated")
+	}
+	if body2 != body1 || !strings.HasPrefix(body2, "bar ") {
+		t.Errorf("want values and deadline to be preserved (%q); got %q", body1, body2)
+	}
+	if _, found, _ := sessionManager.Store.Find(token1); found {
+		t.Error("want old token to be deleted from the store")
+	}
+}
+
+func TestMobileCompat(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.MobileCompat = true
+	sessionManager.Cookie.Domain = ".example.com"
+	sessionManager.Cookie.Secure = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sessionManager.GetString(r.Context(), "foo")))
+	})
+	mux.HandleFunc("/destroy", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Destroy(r.Context())
+	})
+	h := sessionManager.LoadAndSave(mux)
+
+	// A browser request isn't sent the token in a header.
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/put", nil))
+	if token := rr.Header().Get("X-Session-Token"); token != "" {
+		t.Errorf("want no token header for browser request; got %q", token)
+	}
+
+	// A native client starts a new session with an empty header.
+	r := httptest.NewRequest("GET", "/put", nil)
+	r.Header.Set("X-Session-Token", "")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	token := rr.Header().Get("X-Session-Token")
+	if !validToken(token) {
+		t.Fatalf("want token in response header; got %q", token)
+	}
+	want := fmt.Sprintf("session=%s; Path=/; Domain=example.com; Max-Age=86400; Secure; HttpOnly; SameSite=Lax", token)
+	if got := rr.Header().Get("Set-Cookie"); got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+	if !strings.Contains(strings.Join(rr.Header()["Vary"], ","), "X-Session-Token") {
+		t.Errorf("want Vary header to include X-Session-Token; got %q", rr.Header()["Vary"])
+	}
+
+	// The token is accepted from the header when there is no cookie.
+	r = httptest.NewRequest("GET", "/get", nil)
+	r.Header.Set("X-Session-Token", token)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if body := rr.Body.String(); body != "bar" {
+		t.Errorf("want %q; got %q", "bar", body)
+	}
+	if rr.Header().Get("X-Session-Token") != "" {
+		t.Errorf("want no token header for unmodified session; got %q", rr.Header().Get("X-Session-Token"))
+	}
+
+	// A valid cookie takes precedence over the header.
+	r = httptest.NewRequest("GET", "/get", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	r.Header.Set("X-Session-Token", strings.Repeat("a", 43))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if body := rr.Body.String(); body != "bar" {
+		t.Errorf("want %q; got %q", "bar", body)
+	}
+
+	// The token isn't sent in a header to a request which sent a cookie.
+	r = httptest.NewRequest("GET", "/put", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	r.Header.Set("X-Session-Token", token)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Header().Get("Set-Cookie") == "" {
+		t.Error("want session cookie")
+	}
+	if _, ok := rr.Header()["X-Session-Token"]; ok {
+		t.Errorf("want no token header for cookie request; got %q", rr.Header().Get("X-Session-Token"))
+	}
+
+	// Without MobileCompat the header is ignored.
+	plain := New()
+	plain.Store = sessionManager.Store
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Session-Token", token)
+	rr = httptest.NewRecorder()
+	plain.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(plain.GetString(r.Context(), "foo")))
+	})).ServeHTTP(rr, r)
+	if body := rr.Body.String(); body != "" {
+		t.Errorf("want header to be ignored; got %q", body)
+	}
+
+	r = httptest.NewRequest("GET", "/destroy", nil)
+	r.Header.Set("X-Session-Token", token)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	want = "session=; Path=/; Domain=example.com; Max-Age=0; Secure; HttpOnly; SameSite=Lax"
+	if got := rr.Header().Get("Set-Cookie"); got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+	if values, ok := rr.Header()["X-Session-Token"]; !ok || values[0] != "" {
+		t.Errorf("want empty token header; got %q", values)
+	}
+}
+
+func TestConcurrentManagers(t *testing.T) {
+	t.Parallel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sessionManager := New()
+			h := sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sessionManager.Put(r.Context(), "foo", "bar")
+			}))
+			for j := 0; j < 10; j++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNestedMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var nestedErr error
+	errorFunc := func(w http.ResponseWriter, r *http.Request, err error) {
+		nestedErr = err
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	first := New()
+	first.ErrorFunc = errorFunc
+	second := New()
+	second.ErrorFunc = errorFunc
+
+	// The same manager twice.
+	rr := httptest.NewRecorder()
+	first.LoadAndSave(first.LoadAndSave(ok)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError || !errors.Is(nestedErr, ErrNestedMiddleware) {
+		t.Errorf("want %v; got %d, %v", ErrNestedMiddleware, rr.Code, nestedErr)
+	}
+
+	// Two managers sharing a cookie name.
+	nestedErr = nil
+	rr = httptest.NewRecorder()
+	first.LoadAndSave(second.LoadAndSave(ok)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if !errors.Is(nestedErr, ErrNestedMiddleware) {
+		t.Errorf("want %v; got %v", ErrNestedMiddleware, nestedErr)
+	}
+
+	// Two managers with different cookie names can be nested.
+	nestedErr = nil
+	second.Cookie.Name = "admin_session"
+	rr = httptest.NewRecorder()
+	first.LoadAndSave(second.LoadAndSave(ok)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK || nestedErr != nil {
+		t.Errorf("want %d; got %d, %v", http.StatusOK, rr.Code, nestedErr)
+	}
+}
+
+func TestOnCommit(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.OnCommit = func(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) bool {
+		if r.Header.Get("X-Veto") != "" {
+			return false
+		}
+		cookie.Domain = r.Host
+		return true
+	}
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+	}))
+
+	r := httptest.NewRequest("GET", "http://tenant.example.com/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if cookie := rr.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Domain=tenant.example.com") {
+		t.Errorf("want cookie with tenant domain; got %q", cookie)
+	}
+
+	r = httptest.NewRequest("GET", "http://tenant.example.com/", nil)
+	r.Header.Set("X-Veto", "1")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if cookie := rr.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("want no cookie; got %q", cookie)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "" {
+		t.Errorf("want no Cache-Control header; got %q", cc)
+	}
+
+	// The hook is called with a nil request by WriteSessionCookie.
+	s.OnCommit = func(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) bool {
+		if r != nil {
+			t.Error("want nil request")
+		}
+		cookie.Path = "/app"
+		return true
+	}
+	rr = httptest.NewRecorder()
+	s.WriteSessionCookie(context.Background(), rr, "token", time.Now().Add(time.Hour))
+	if cookie := rr.Header().Get("Set-Cookie"); !strings.Contains(cookie, "Path=/app") {
+		t.Errorf("want cookie with changed path; got %q", cookie)
+	}
+}
@@ -0,0 +1,137 @@
+// Package sessiontest provides helpers for testing HTTP handlers which use an
+// scs.SessionManager, without needing to run the full LoadAndSave() middleware
+// and a test server.
+package sessiontest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+type contextKey struct{}
+
+// NewRequest returns a new *http.Request (created using httptest.NewRequest)
+// with a new session loaded into its context. The session is pre-populated with
+// the given values. The request can be passed directly to a handler which reads
+// from or writes to the session, or to a handler wrapped with LoadAndSave() (in
+// which case the pre-populated session will be used and committed).
+func NewRequest(t testing.TB, s *scs.SessionManager, method, target string, values map[string]interface{}) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(method, target, nil)
+
+	ctx, err := s.Load(context.WithValue(r.Context(), contextKey{}, s), "")
+	if err != nil {
+		t.Fatalf("sessiontest: unable to load session: %v", err)
+	}
+
+	for key, val := range values {
+		s.Put(ctx, key, val)
+	}
+
+	return r.WithContext(ctx)
+}
+
+// AssertValue checks that the session data for the request contains the given
+// key, and that its value is deeply equal to want. The request must have been
+// created by NewRequest (or be derived from one).
+func AssertValue(t testing.TB, r *http.Request, key string, want interface{}) {
+	t.Helper()
+
+	s := sessionManager(t, r)
+	if !s.Exists(r.Context(), key) {
+		t.Errorf("sessiontest: key %q not found in session", key)
+		return
+	}
+
+	got := s.Get(r.Context(), key)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sessiontest: key %q: got %#v; want %#v", key, got, want)
+	}
+}
+
+// AssertNotExists checks that the session data for the request does not
+// contain the given key. The request must have been created by NewRequest (or
+// be derived from one).
+func AssertNotExists(t testing.TB, r *http.Request, key string) {
+	t.Helper()
+
+	s := sessionManager(t, r)
+	if s.Exists(r.Context(), key) {
+		t.Errorf("sessiontest: key %q unexpectedly found in session", key)
+	}
+}
+
+func sessionManager(t testing.TB, r *http.Request) *scs.SessionManager {
+	t.Helper()
+
+	s, ok := r.Context().Value(contextKey{}).(*scs.SessionManager)
+	if !ok {
+		t.Fatal("sessiontest: request was not created by sessiontest.NewRequest")
+	}
+	return s
+}
+
+// Recorder is an extension of httptest.ResponseRecorder which also records the
+// session state committed to the session store at the end of the request.
+type Recorder struct {
+	*httptest.ResponseRecorder
+
+	// Token is the session token sent to the client in the session cookie.
+	// It is empty if no session cookie was written.
+	Token string
+
+	// Destroyed is true if the session cookie was deleted.
+	Destroyed bool
+
+	// Values contains the session data committed to the session store. It is
+	// nil if no session data was committed.
+	Values map[string]interface{}
+
+	// Deadline is the absolute expiry time of the committed session.
+	Deadline time.Time
+}
+
+// Serve executes the handler h, wrapped with the LoadAndSave() middleware for
+// the session manager s, and returns a Recorder containing the response and
+// the committed session state.
+func Serve(t testing.TB, s *scs.SessionManager, h http.Handler, r *http.Request) *Recorder {
+	t.Helper()
+
+	rec := &Recorder{ResponseRecorder: httptest.NewRecorder()}
+	s.LoadAndSave(h).ServeHTTP(rec.ResponseRecorder, r)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name != s.CurrentCookie().Name {
+			continue
+		}
+		if cookie.MaxAge < 0 {
+			rec.Destroyed = true
+			return rec
+		}
+		rec.Token = cookie.Value
+	}
+
+	if rec.Token == "" {
+		return rec
+	}
+
+	ctx, err := s.Load(context.Background(), rec.Token)
+	if err != nil {
+		t.Fatalf("sessiontest: unable to load committed session: %v", err)
+	}
+
+	rec.Values = make(map[string]interface{})
+	for _, key := range s.Keys(ctx) {
+		rec.Values[key] = s.Get(ctx, key)
+	}
+	rec.Deadline = s.Deadline(ctx)
+
+	return rec
+}
@@ -0,0 +1,89 @@
+package sessiontest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failed = true
+}
+
+func TestNewRequest(t *testing.T) {
+	s := scs.New()
+
+	r := NewRequest(t, s, http.MethodGet, "/", map[string]interface{}{"foo": "bar", "baz": 10})
+	if got := s.GetString(r.Context(), "foo"); got != "bar" {
+		t.Errorf("want %q; got %q", "bar", got)
+	}
+
+	AssertValue(t, r, "foo", "bar")
+	AssertValue(t, r, "baz", 10)
+	AssertNotExists(t, r, "qux")
+
+	ft := &fakeT{TB: t}
+	AssertValue(ft, r, "foo", "qux")
+	if !ft.failed {
+		t.Error("want AssertValue to fail for a different value")
+	}
+
+	ft = &fakeT{TB: t}
+	AssertValue(ft, r, "qux", nil)
+	if !ft.failed {
+		t.Error("want AssertValue to fail for a missing key")
+	}
+
+	ft = &fakeT{TB: t}
+	AssertNotExists(ft, r, "foo")
+	if !ft.failed {
+		t.Error("want AssertNotExists to fail for an existing key")
+	}
+}
+
+func TestServe(t *testing.T) {
+	s := scs.New()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "count", s.GetInt(r.Context(), "count")+1)
+		io.WriteString(w, fmt.Sprint(s.GetInt(r.Context(), "count")))
+	})
+
+	r := NewRequest(t, s, http.MethodGet, "/", map[string]interface{}{"count": 41})
+	rec := Serve(t, s, h, r)
+
+	if rec.Body.String() != "42" {
+		t.Errorf("want %q; got %q", "42", rec.Body.String())
+	}
+	if rec.Token == "" {
+		t.Fatal("want session token to be recorded")
+	}
+	if rec.Values["count"] != 42 {
+		t.Errorf("want %d; got %v", 42, rec.Values["count"])
+	}
+	if rec.Deadline.IsZero() {
+		t.Error("want deadline to be recorded")
+	}
+
+	h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Destroy(r.Context())
+	})
+
+	rec = Serve(t, s, h, NewRequest(t, s, http.MethodGet, "/", nil))
+	if !rec.Destroyed {
+		t.Error("want session to be recorded as destroyed")
+	}
+	if rec.Values != nil {
+		t.Errorf("want nil values; got %v", rec.Values)
+	}
+}
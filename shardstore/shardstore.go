@@ -0,0 +1,214 @@
+// Package shardstore provides a session store which spreads sessions across
+// several other session stores using consistent hashing.
+package shardstore
+
+import (
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Store is the interface for the shard stores. It has the same methods as
+// scs.Store.
+type Store interface {
+	Delete(token string) (err error)
+	Find(token string) (b []byte, found bool, err error)
+	Commit(token string, b []byte, expiry time.Time) (err error)
+}
+
+// HashFunc is a function which hashes a session token (or a point on the
+// hash ring) to a 32-bit value.
+type HashFunc func(b []byte) uint32
+
+// pointsPerShard is the number of points each shard has on the hash ring.
+// More points give a more even spread of sessions.
+const pointsPerShard = 160
+
+type point struct {
+	hash  uint32
+	shard int
+}
+
+// ShardStore represents the session store.
+type ShardStore struct {
+	shards   []Store
+	hash     HashFunc
+	replicas int
+	ring     []point
+}
+
+// New returns a new ShardStore instance which spreads sessions across shards.
+// Each session is written to replicas shards (or one, if replicas is less than
+// one), chosen by consistent hashing of the session token, and is read from
+// the first of those shards which has it, so that sessions survive the loss
+// of a shard if replicas is greater than one.
+//
+// Shards are identified by their position in the slice. Appending a new shard
+// only moves the sessions which hash to it; removing or reordering shards
+// moves many more. If hash is nil, CRC-32 is used.
+func New(shards []Store, hash HashFunc, replicas int) *ShardStore {
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	if replicas < 1 {
+		replicas = 1
+	}
+	if replicas > len(shards) {
+		replicas = len(shards)
+	}
+
+	s := &ShardStore{
+		shards:   shards,
+		hash:     hash,
+		replicas: replicas,
+		ring:     make([]point, 0, len(shards)*pointsPerShard),
+	}
+	for i := range shards {
+		for j := 0; j < pointsPerShard; j++ {
+			key := strconv.Itoa(i) + "-" + strconv.Itoa(j)
+			s.ring = append(s.ring, point{hash: hash([]byte(key)), shard: i})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		return s.ring[i].hash < s.ring[j].hash
+	})
+
+	return s
+}
+
+// Shards returns the positions of the shards which hold the session data for
+// a token, in the order they are read.
+func (s *ShardStore) Shards(token string) []int {
+	if len(s.ring) == 0 {
+		return nil
+	}
+
+	h := s.hash([]byte(token))
+	i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= h })
+
+	shards := make([]int, 0, s.replicas)
+	for n := 0; n < len(s.ring) && len(shards) < s.replicas; n++ {
+		p := s.ring[(i+n)%len(s.ring)]
+		if !containsInt(shards, p.shard) {
+			shards = append(shards, p.shard)
+		}
+	}
+	return shards
+}
+
+// Find returns the data for a given session token from the first of its
+// shards which has it. An error from a shard is only returned if the session
+// isn't found in any of the others.
+func (s *ShardStore) Find(token string) ([]byte, bool, error) {
+	var firstErr error
+	for _, i := range s.Shards(token) {
+		b, found, err := s.shards[i].Find(token)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if found {
+			return b, true, nil
+		}
+	}
+	return nil, false, firstErr
+}
+
+// Commit adds a session token and data to each of its shards with the given
+// expiry time. If any commit fails, the first error is returned.
+func (s *ShardStore) Commit(token string, b []byte, expiry time.Time) error {
+	var firstErr error
+	for _, i := range s.Shards(token) {
+		if err := s.shards[i].Commit(token, b, expiry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Delete removes a session token and corresponding data from each of its
+// shards. If any delete fails, the first error is returned.
+func (s *ShardStore) Delete(token string) error {
+	var firstErr error
+	for _, i := range s.Shards(token) {
+		if err := s.shards[i].Delete(token); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// All returns a map containing the token and data for all active sessions in
+// all shards. It returns an error if any shard doesn't support iteration.
+func (s *ShardStore) All() (map[string][]byte, error) {
+	mm := make(map[string][]byte)
+	for _, shard := range s.shards {
+		is, ok := shard.(interface {
+			All() (map[string][]byte, error)
+		})
+		if !ok {
+			return nil, errors.New("shardstore: shard does not support iteration")
+		}
+		all, err := is.All()
+		if err != nil {
+			return nil, err
+		}
+		for token, b := range all {
+			mm[token] = b
+		}
+	}
+	return mm, nil
+}
+
+// DeleteExpired deletes expired sessions from each shard which implements a
+// DeleteExpired method, and returns the total number deleted.
+func (s *ShardStore) DeleteExpired() (int, error) {
+	total := 0
+	for _, shard := range s.shards {
+		ds, ok := shard.(interface {
+			DeleteExpired() (int, error)
+		})
+		if !ok {
+			continue
+		}
+		n, err := ds.DeleteExpired()
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Close closes each shard which has a Close() error method, or otherwise stops
+// its cleanup goroutine if it has a StopCleanup() method. If closing any shard
+// fails, the first error is returned.
+func (s *ShardStore) Close() error {
+	var firstErr error
+	for _, shard := range s.shards {
+		var err error
+		switch cs := shard.(type) {
+		case interface{ Close() error }:
+			err = cs.Close()
+		case interface{ StopCleanup() }:
+			cs.StopCleanup()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package shardstore
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func newShards(n int) []Store {
+	shards := make([]Store, n)
+	for i := range shards {
+		shards[i] = memstore.NewWithCleanupInterval(0)
+	}
+	return shards
+}
+
+func TestShards(t *testing.T) {
+	s := New(newShards(3), nil, 1)
+
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		shards := s.Shards("token_" + strconv.Itoa(i))
+		if len(shards) != 1 {
+			t.Fatalf("want 1 shard; got %v", shards)
+		}
+		counts[shards[0]]++
+	}
+	for i, n := range counts {
+		if n < 700 || n > 1300 {
+			t.Errorf("shard %d: want roughly 1000 sessions; got %d", i, n)
+		}
+	}
+
+	// Adding a shard only moves the sessions which now belong to it.
+	grown := New(newShards(4), nil, 1)
+	for i := 0; i < 3000; i++ {
+		token := "token_" + strconv.Itoa(i)
+		before, after := s.Shards(token)[0], grown.Shards(token)[0]
+		if before != after && after != 3 {
+			t.Fatalf("%s: moved from shard %d to %d", token, before, after)
+		}
+	}
+}
+
+type failingStore struct {
+	*memstore.MemStore
+}
+
+func (failingStore) Find(token string) ([]byte, bool, error) {
+	return nil, false, errors.New("shard down")
+}
+
+func TestReplicas(t *testing.T) {
+	shards := newShards(3)
+	s := New(shards, nil, 2)
+
+	if err := s.Commit("session_token", []byte("data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	replicas := s.Shards("session_token")
+	if len(replicas) != 2 || replicas[0] == replicas[1] {
+		t.Fatalf("want 2 distinct shards; got %v", replicas)
+	}
+	for i, shard := range shards {
+		_, found, _ := shard.Find("session_token")
+		if want := containsInt(replicas, i); found != want {
+			t.Errorf("shard %d: want found %v; got %v", i, want, found)
+		}
+	}
+
+	// The session can still be read if its first shard fails.
+	shards[replicas[0]] = failingStore{shards[replicas[0]].(*memstore.MemStore)}
+	b, found, err := s.Find("session_token")
+	if err != nil || !found || string(b) != "data" {
+		t.Errorf("want %q; got %q, %v, %v", "data", b, found, err)
+	}
+
+	if err := s.Delete("session_token"); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("want no sessions; got %d", len(all))
+	}
+}
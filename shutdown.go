@@ -0,0 +1,94 @@
+package scs
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle tracks the background goroutines started by a SessionManager, so
+// that Shutdown can stop them and wait for them to finish.
+type lifecycle struct {
+	mu   sync.Mutex
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// doneChan returns a channel which is closed when Shutdown is called.
+func (l *lifecycle) doneChan() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done == nil {
+		l.done = make(chan struct{})
+	}
+	return l.done
+}
+
+// Shutdown stops the SessionManager's background work and releases the
+// session store, for use when the application shuts down gracefully (for
+// example, after http.Server.Shutdown has returned). It:
+//
+//   - Stops any KeepAlive goroutines and waits for in-flight background work
+//     to finish.
+//   - Closes the session store if it has a Close() error method (such as a
+//     memstore created by NewWithSnapshot, which writes a final snapshot), or
+//     otherwise stops its cleanup goroutine if it has a StopCleanup() method.
+//
+// Database connections passed to a store by the application are not closed.
+// If ctx is done before the background work has finished, Shutdown returns the
+// context's error without closing the store. The SessionManager must not be
+// used after Shutdown has been called.
+func (s *SessionManager) Shutdown(ctx context.Context) error {
+	done := s.lifecycle.doneChan()
+
+	s.lifecycle.mu.Lock()
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+	s.lifecycle.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.lifecycle.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	switch store := s.Store.(type) {
+	case interface{ Close() error }:
+		return store.Close()
+	case interface{ StopCleanup() }:
+		store.StopCleanup()
+	}
+	return nil
+}
+
+// goBackground runs fn in a goroutine which Shutdown waits for. The fn is
+// passed a channel which is closed when Shutdown is called. It reports false
+// without running fn if Shutdown has already been called.
+func (s *SessionManager) goBackground(fn func(shutdown <-chan struct{})) bool {
+	done := s.lifecycle.doneChan()
+
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+
+	select {
+	case <-done:
+		return false
+	default:
+	}
+
+	s.lifecycle.wg.Add(1)
+	go func() {
+		defer s.lifecycle.wg.Done()
+		fn(done)
+	}()
+	return true
+}
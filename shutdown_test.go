@@ -0,0 +1,76 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+type closeStore struct {
+	*memstore.MemStore
+	closed bool
+}
+
+func (c *closeStore) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	store := &closeStore{MemStore: memstore.NewWithCleanupInterval(0)}
+	s := New()
+	s.Store = store
+	s.IdleTimeout = time.Hour
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The KeepAlive goroutine is stopped by Shutdown, without the stop
+	// function being called.
+	s.KeepAlive(ctx, time.Hour)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !store.closed {
+		t.Error("want store to be closed")
+	}
+
+	// Shutdown can be called more than once, and KeepAlive does nothing
+	// afterwards.
+	s.KeepAlive(ctx, time.Hour)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	release := make(chan struct{})
+	s.goBackground(func(shutdown <-chan struct{}) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
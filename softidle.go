@@ -0,0 +1,58 @@
+package scs
+
+import (
+	"context"
+	"time"
+)
+
+const idleLockedKey = "__idleLocked"
+
+// IsIdleLocked reports whether the session has been locked because it
+// exceeded the IdleTimeout while SoftIdleTimeout is enabled. The session data
+// is preserved, but the user should be asked to re-authenticate, after which
+// ResumeIdleSession should be called.
+func (s *SessionManager) IsIdleLocked(ctx context.Context) bool {
+	return s.GetBool(ctx, idleLockedKey)
+}
+
+// ResumeIdleSession unlocks a session locked by the soft idle timeout, once the
+// user has re-authenticated, and sets the session's authentication level. The
+// session token is renewed, as with any change in privilege level. The user
+// resumes with the same session data they had before the session was locked.
+func (s *SessionManager) ResumeIdleSession(ctx context.Context, level int) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := s.RenewToken(ctx); err != nil {
+		return err
+	}
+	s.Remove(ctx, idleLockedKey)
+	s.SetAuthLevel(ctx, level)
+	return nil
+}
+
+// lockIdleSession locks the session data loaded from the store if it has
+// exceeded the idle timeout and SoftIdleTimeout is enabled, dropping its
+// authentication level. The change is not marked as modified if the
+// SessionManager is read-only. It must be called before sd is shared.
+func (s *SessionManager) lockIdleSession(sd *sessionData) {
+	if !s.SoftIdleTimeout || s.idleTimeout() <= 0 {
+		return
+	}
+	if locked, _ := sd.values[idleLockedKey].(bool); locked {
+		return
+	}
+	ns, ok := sd.values[lastActivityKey].(int64)
+	if !ok || time.Now().Before(time.Unix(0, ns).Add(s.idleTimeout())) {
+		return
+	}
+
+	sd.values[idleLockedKey] = true
+	sd.values[authLevelKey] = 0
+	delete(sd.values, authLevelFallbackKey)
+	delete(sd.values, authLevelExpiryKey)
+	if !s.ReadOnly {
+		sd.status = Modified
+	}
+}
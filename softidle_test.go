@@ -0,0 +1,100 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSoftIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.IdleTimeout = time.Minute
+	s.SoftIdleTimeout = true
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "cart", "widget")
+	s.SetAuthLevel(ctx, 2)
+	token, expiry, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.After(time.Now().Add(time.Hour)) {
+		t.Errorf("got %v: expected the store expiry to be the session deadline", expiry)
+	}
+
+	// A recently active session isn't locked.
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.IsIdleLocked(ctx) {
+		t.Error("want session not to be locked")
+	}
+
+	// Make the session idle.
+	b, _, _ := s.Store.Find(token)
+	deadline, values, err := s.Codec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values[lastActivityKey] = time.Now().Add(-2 * time.Minute).UnixNano()
+	b, _ = s.Codec.Encode(deadline, values)
+	if err := s.Store.Commit(token, b, deadline); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsIdleLocked(ctx) {
+		t.Fatal("want session to be locked")
+	}
+	if got := s.AuthLevel(ctx); got != 0 {
+		t.Errorf("got %d: expected %d", got, 0)
+	}
+	if got := s.GetString(ctx, "cart"); got != "widget" {
+		t.Errorf("got %q: expected %q", got, "widget")
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("got %v: expected %v", s.Status(ctx), Modified)
+	}
+
+	if err := s.ResumeIdleSession(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsIdleLocked(ctx) {
+		t.Error("want session to be unlocked")
+	}
+	if got := s.AuthLevel(ctx); got != 1 {
+		t.Errorf("got %d: expected %d", got, 1)
+	}
+	if s.Token(ctx) == token {
+		t.Error("want session token to be renewed")
+	}
+	if got := s.GetString(ctx, "cart"); got != "widget" {
+		t.Errorf("got %q: expected %q", got, "widget")
+	}
+}
+
+func TestHardIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.IdleTimeout = time.Minute
+
+	ctx, _ := s.Load(context.Background(), "")
+	s.Put(ctx, "foo", "bar")
+	_, expiry, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiry.After(time.Now().Add(2 * time.Minute)) {
+		t.Errorf("got %v: expected the store expiry to be the idle timeout", expiry)
+	}
+}
@@ -318,3 +318,94 @@ func TestStopNilCleanup(t *testing.T) {
 	// A send to a nil channel will block forever
 	p.StopCleanup()
 }
+
+func TestDeleteExpiredBatches(t *testing.T) {
+	dsn := "./testSQL3lite.db"
+	if err := removeDBfile(dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	defer os.Remove(dsn)
+
+	if err := createDBwithSessionTable(db); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithCleanupInterval(db, 0)
+	p.SetCleanupBatchSize(2)
+
+	for i := 0; i < 5; i++ {
+		err = p.Commit(fmt.Sprintf("expired_%d", i), []byte("encoded_data"), time.Now().Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = p.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := p.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d: expected %d", n, 5)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("got %d: expected %d", count, 1)
+	}
+}
+
+func TestClose(t *testing.T) {
+	dsn := "./testSQL3lite.db"
+	if err := removeDBfile(dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	defer os.Remove(dsn)
+
+	if err = createDBwithSessionTable(db); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWithCleanupInterval(db, 10*time.Millisecond)
+	if err = p.Commit("session_token", []byte("encoded_data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = p.Find("session_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if p.stmts != nil {
+		t.Errorf("want statements closed; got %d", len(p.stmts))
+	}
+	// A second Close must not block on the stopped cleanup goroutine.
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, found, err := p.Find("session_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Error("want session found after Close")
+	}
+}
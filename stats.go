@@ -0,0 +1,76 @@
+package scs
+
+import (
+	"context"
+	"time"
+)
+
+// loadStats records how the session data for a request was loaded.
+type loadStats struct {
+	found    bool
+	cacheHit bool
+	size     int
+	duration time.Duration
+}
+
+// SessionStats contains information about how the session data for the current
+// request was loaded, intended for logging alongside request logs (for example
+// to find slow or unusually large sessions).
+type SessionStats struct {
+	// Found is true if the session was loaded from the store, and false if a
+	// new session was created.
+	Found bool
+
+	// CacheHit is true if the session data was served from a cache in front
+	// of the session store. The store must implement a Cached(token string)
+	// bool method, as the cachestore package does; otherwise CacheHit is
+	// always false.
+	CacheHit bool
+
+	// Size is the size of the encoded session data in bytes, as loaded from
+	// the store.
+	Size int
+
+	// LoadDuration is how long it took to find and decode the session data.
+	// It is zero if no session token was presented.
+	LoadDuration time.Duration
+
+	// WillSave is true if the session will be committed to the store at the
+	// end of the request, given the changes made so far.
+	WillSave bool
+}
+
+// Stats returns information about how the session data for the current request
+// was loaded.
+func (s *SessionManager) Stats(ctx context.Context) SessionStats {
+	willSave := !s.ReadOnly && s.Status(ctx) == Modified
+
+	sd := s.getPartialSessionData(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	return SessionStats{
+		Found:        sd.stats.found,
+		CacheHit:     sd.stats.cacheHit,
+		Size:         sd.stats.size,
+		LoadDuration: sd.stats.duration,
+		WillSave:     willSave,
+	}
+}
+
+// storeCached reports whether the session store has the data for token in a
+// cache.
+func (s *SessionManager) storeCached(token string) bool {
+	c, ok := s.Store.(interface {
+		Cached(token string) bool
+	})
+	if !ok {
+		return false
+	}
+
+	if s.HashTokenInStore {
+		token = hashToken(token)
+	}
+	return c.Cached(token)
+}
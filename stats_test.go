@@ -0,0 +1,52 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/cachestore"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.Store = cachestore.New(s.Store, time.Minute)
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(ctx); stats.Found || stats.WillSave {
+		t.Errorf("got %+v: expected new unmodified session", stats)
+	}
+
+	s.Put(ctx, "foo", "bar")
+	if stats := s.Stats(ctx); !stats.WillSave {
+		t.Errorf("got %+v: expected WillSave", stats)
+	}
+
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := s.Stats(ctx)
+	if !stats.Found || !stats.CacheHit || stats.Size == 0 || stats.LoadDuration <= 0 || stats.WillSave {
+		t.Errorf("got %+v: expected found, cached, unmodified session", stats)
+	}
+
+	s.Store = cachestore.New(New().Store, time.Minute)
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(ctx); stats.Found || stats.CacheHit {
+		t.Errorf("got %+v: expected session not to be found", stats)
+	}
+}
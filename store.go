@@ -56,3 +56,101 @@ type IterableCtxStore interface {
 	// context.Context.
 	AllCtx(ctx context.Context) (map[string][]byte, error)
 }
+
+// CleanupStore is the interface for session stores which need expired
+// sessions to be deleted periodically, rather than expiring them
+// automatically.
+type CleanupStore interface {
+	// DeleteExpired should delete all expired sessions from the store and
+	// return the number of sessions deleted.
+	DeleteExpired() (n int, err error)
+}
+
+// CleanupCtxStore is the interface for session stores which need expired
+// sessions to be deleted periodically and which take a context.Context
+// parameter.
+type CleanupCtxStore interface {
+	// DeleteExpiredCtx is the same as CleanupStore.DeleteExpired, except it
+	// takes a context.Context.
+	DeleteExpiredCtx(ctx context.Context) (n int, err error)
+}
+
+// ExpiredFuncStore is the interface for session stores which can report the
+// data for the sessions deleted by DeleteExpired, allowing GCWithStats to count
+// them by the reason they expired.
+type ExpiredFuncStore interface {
+	// DeleteExpiredFunc should delete expired session data from the store in
+	// the same way as DeleteExpired, calling fn with the token and data of
+	// each session deleted, and return the number deleted.
+	DeleteExpiredFunc(fn func(token string, b []byte)) (n int, err error)
+}
+
+// AddStore is the interface for session stores which can atomically add data
+// for a token only if the token doesn't already exist.
+type AddStore interface {
+	// Add should add the token and data to the store with the given expiry
+	// time if the token does not exist (or has expired), and report whether
+	// it was added. If the token exists, the store must not be modified.
+	Add(token string, b []byte, expiry time.Time) (added bool, err error)
+}
+
+// TouchStore is the interface for session stores which can change the expiry
+// time of a token without rewriting its data.
+type TouchStore interface {
+	// Touch should set the expiry time of the token to the given time if the
+	// token exists (and has not expired). If the token doesn't exist the store
+	// must not be modified and err should be nil.
+	Touch(token string, expiry time.Time) (err error)
+}
+
+// PartialStore is the interface for session stores which can hold each
+// session value separately, so that a commit only needs to write the values
+// which have changed. When the session store implements PartialStore, the
+// SessionManager uses FindValues and CommitValues for session data, and only
+// falls back to Find for sessions which aren't found by FindValues (such as
+// sessions committed before the store was switched to PartialStore). Find,
+// Commit and Delete are still used for other records, such as nonces and locks.
+//
+// Each value is encoded separately with the SessionManager's Codec, keyed by
+// the session key. The session deadline is held in the same way, under the
+// key "__deadline".
+type PartialStore interface {
+	Store
+
+	// FindValues should return the encoded values for a session token. If
+	// the session token is not found or is expired, or is held in the form
+	// written by Commit, the found return value should be false (and the err
+	// return value should be nil).
+	FindValues(token string) (values map[string][]byte, found bool, err error)
+
+	// CommitValues should set the given values and remove the given keys for
+	// the session token, and set the expiry time for the whole session. If
+	// replace is true, any existing data for the token (including data
+	// written by Commit) should be discarded first. Commit and Delete should
+	// likewise discard any values written by CommitValues for the token.
+	CommitValues(token string, set map[string][]byte, remove []string, replace bool, expiry time.Time) (err error)
+}
+
+// KeysStore is the interface for session stores which implement PartialStore
+// and can find some of the values for a session, allowing the SessionManager
+// to load only the values most requests need (see SessionManager.HotKeys and
+// LoadKeys).
+type KeysStore interface {
+	PartialStore
+
+	// FindKeys should return the encoded values for the given keys of a
+	// session token, in the same way as FindValues. Keys which don't exist
+	// should be omitted. If the session token is not found, is expired, or is
+	// held in the form written by Commit, the found return value should be
+	// false (and the err return value should be nil).
+	FindKeys(token string, keys []string) (values map[string][]byte, found bool, err error)
+}
+
+// IterablePartialStore is the interface for session stores which implement
+// PartialStore and support iteration.
+type IterablePartialStore interface {
+	// AllValues should return a map containing the values for all active
+	// sessions held as separate values. Sessions written by Commit should be
+	// returned by All instead.
+	AllValues() (map[string]map[string][]byte, error)
+}
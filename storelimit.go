@@ -0,0 +1,155 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStoreOverloaded is returned by Load and Commit when a session store
+// operation is shed because the SessionManager.StoreLimit has been reached.
+var ErrStoreOverloaded = errors.New("scs: session store is overloaded")
+
+// StoreLimit contains the configuration settings for limiting the number of
+// concurrent session store operations, so that a slow store isn't overwhelmed
+// by a growing number of requests waiting for it.
+type StoreLimit struct {
+	// MaxConcurrent is the maximum number of loads and saves which can be in
+	// progress at once. If zero, 64 is used.
+	MaxConcurrent int
+
+	// MaxQueue is the maximum number of operations which can be waiting for
+	// one of the MaxConcurrent slots. Further operations are shed and return
+	// ErrStoreOverloaded. If zero, MaxConcurrent is used.
+	MaxQueue int
+
+	// QueueTimeout is the maximum time an operation waits for a slot before
+	// it is shed and returns ErrStoreOverloaded. If zero, operations wait
+	// until their context is done.
+	QueueTimeout time.Duration
+}
+
+// StoreLimitStats contains counters describing the activity of the
+// StoreLimit, for exporting as metrics.
+type StoreLimitStats struct {
+	// Active is the number of store operations currently in progress.
+	Active int
+
+	// Waiting is the number of store operations currently waiting for a
+	// slot.
+	Waiting int
+
+	// Shed is the number of store operations which returned
+	// ErrStoreOverloaded.
+	Shed uint64
+
+	// SkippedTouches is the number of saves skipped by the LoadAndSave
+	// middleware because they would only have extended the idle timeout.
+	SkippedTouches uint64
+}
+
+// storeLimiter holds the semaphore and counters for StoreLimit.
+type storeLimiter struct {
+	once sync.Once
+	sem  chan struct{}
+
+	waiting              int64
+	shed, skippedTouches uint64
+}
+
+// StoreLimitStats returns the current StoreLimit counters. It returns the
+// zero StoreLimitStats if StoreLimit is not set.
+func (s *SessionManager) StoreLimitStats() StoreLimitStats {
+	if s.StoreLimit == nil {
+		return StoreLimitStats{}
+	}
+
+	l := &s.limiter
+	return StoreLimitStats{
+		Active:         len(s.storeSemaphore()),
+		Waiting:        int(atomic.LoadInt64(&l.waiting)),
+		Shed:           atomic.LoadUint64(&l.shed),
+		SkippedTouches: atomic.LoadUint64(&l.skippedTouches),
+	}
+}
+
+func (s *SessionManager) storeSemaphore() chan struct{} {
+	l := &s.limiter
+	l.once.Do(func() {
+		n := s.StoreLimit.MaxConcurrent
+		if n <= 0 {
+			n = 64
+		}
+		l.sem = make(chan struct{}, n)
+	})
+	return l.sem
+}
+
+// acquireStore waits for a slot for a store operation, as described by
+// StoreLimit, and returns the function to release it. It returns
+// ErrStoreOverloaded if the operation is shed.
+func (s *SessionManager) acquireStore(ctx context.Context) (func(), error) {
+	if s.StoreLimit == nil {
+		return func() {}, nil
+	}
+
+	l := &s.limiter
+	sem := s.storeSemaphore()
+	release := func() { <-sem }
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	maxQueue := s.StoreLimit.MaxQueue
+	if maxQueue <= 0 {
+		maxQueue = cap(sem)
+	}
+	if atomic.AddInt64(&l.waiting, 1) > int64(maxQueue) {
+		atomic.AddInt64(&l.waiting, -1)
+		atomic.AddUint64(&l.shed, 1)
+		return nil, ErrStoreOverloaded
+	}
+	defer atomic.AddInt64(&l.waiting, -1)
+
+	var timeout <-chan time.Time
+	if s.StoreLimit.QueueTimeout > 0 {
+		timer := time.NewTimer(s.StoreLimit.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+	atomic.AddUint64(&l.shed, 1)
+	return nil, ErrStoreOverloaded
+}
+
+// skipTouch reports whether the LoadAndSave middleware should skip saving the
+// session in ctx, because the save would only extend the idle timeout and the
+// StoreLimit has no free slots.
+func (s *SessionManager) skipTouch(ctx context.Context) bool {
+	if s.StoreLimit == nil {
+		return false
+	}
+
+	sd := s.getPartialSessionData(ctx)
+	sd.mu.Lock()
+	touchOnly := sd.status == Unmodified && sd.touched
+	sd.mu.Unlock()
+
+	sem := s.storeSemaphore()
+	if !touchOnly || len(sem) < cap(sem) {
+		return false
+	}
+	atomic.AddUint64(&s.limiter.skippedTouches, 1)
+	return true
+}
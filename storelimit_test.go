@@ -0,0 +1,134 @@
+package scs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestStoreLimit(t *testing.T) {
+	t.Parallel()
+
+	store := &blockingStore{MemStore: memstore.NewWithCleanupInterval(0), release: make(chan struct{})}
+	s := New()
+	s.Store = store
+	s.StoreLimit = &StoreLimit{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: 50 * time.Millisecond}
+
+	commit := func() <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			ctx, err := s.Load(context.Background(), "")
+			if err != nil {
+				ch <- err
+				return
+			}
+			s.Put(ctx, "foo", "bar")
+			_, _, err = s.Commit(ctx)
+			ch <- err
+		}()
+		return ch
+	}
+	waitFor := func(cond func(StoreLimitStats) bool) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if cond(s.StoreLimitStats()) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("timed out: got %+v", s.StoreLimitStats())
+	}
+
+	// The first commit holds the only slot while the store blocks, and the
+	// second waits for it.
+	first := commit()
+	waitFor(func(stats StoreLimitStats) bool { return stats.Active == 1 })
+	second := commit()
+	waitFor(func(stats StoreLimitStats) bool { return stats.Waiting == 1 })
+
+	// The queue is full, so the third commit is shed immediately.
+	if err := <-commit(); err != ErrStoreOverloaded {
+		t.Errorf("got %v: expected %v", err, ErrStoreOverloaded)
+	}
+
+	// The second commit is shed once the QueueTimeout passes.
+	if err := <-second; err != ErrStoreOverloaded {
+		t.Errorf("got %v: expected %v", err, ErrStoreOverloaded)
+	}
+
+	close(store.release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	want := StoreLimitStats{Shed: 2}
+	if stats := s.StoreLimitStats(); stats != want {
+		t.Errorf("got %+v: expected %+v", stats, want)
+	}
+}
+
+func TestStoreLimitSkipsTouches(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.IdleTimeout = time.Hour
+	s.StoreLimit = &StoreLimit{MaxConcurrent: 1, QueueTimeout: time.Millisecond}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var modify bool
+	var gotErr error
+	s.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		gotErr = err
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if modify {
+			s.Put(r.Context(), "foo", "baz")
+		}
+		// Use up the only slot, as if the store were busy.
+		s.storeSemaphore() <- struct{}{}
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: s.Cookie.Name, Value: token})
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		<-s.storeSemaphore()
+		return rr
+	}
+
+	// A request which only extends the idle timeout isn't saved.
+	rr := serve()
+	if rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want no session cookie; got %q", rr.Header().Get("Set-Cookie"))
+	}
+	if gotErr != nil {
+		t.Errorf("want no error; got %v", gotErr)
+	}
+	if stats := s.StoreLimitStats(); stats.SkippedTouches != 1 {
+		t.Errorf("got %+v: expected 1 skipped touch", stats)
+	}
+
+	// A request which modifies the session waits for a slot and is shed.
+	modify = true
+	rr = serve()
+	if gotErr != ErrStoreOverloaded {
+		t.Errorf("got %v: expected %v", gotErr, ErrStoreOverloaded)
+	}
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d: expected %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
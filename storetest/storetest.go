@@ -0,0 +1,318 @@
+// Package storetest provides a conformance test suite for scs.Store
+// implementations, so that the authors of third-party session stores can
+// check that their store behaves in the way the SessionManager expects.
+//
+// A typical use in the store's own tests is:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) scs.Store {
+//			return mystore.New(db)
+//		})
+//	}
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// Run runs the conformance tests as subtests of t. The newStore function is
+// called at the start of each subtest to get the store to test. It may return
+// the same store each time, or a store backed by a shared database: every
+// subtest uses its own random tokens and only checks those tokens.
+//
+// The expiry tests allow a store to keep the data for up to one second after
+// its expiry time, so the suite takes a few seconds to run.
+func Run(t *testing.T, newStore func(t *testing.T) scs.Store) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, scs.Store)
+	}{
+		{"CommitAndFind", testCommitAndFind},
+		{"FindMissing", testFindMissing},
+		{"CommitOverwrites", testCommitOverwrites},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"Expiry", testExpiry},
+		{"ExpiredCommit", testExpiredCommit},
+		{"ConcurrentCommits", testConcurrentCommits},
+		{"CommitOrdering", testCommitOrdering},
+		{"LargePayload", testLargePayload},
+		{"BinarySafety", testBinarySafety},
+		{"All", testAll},
+		{"CtxStore", testCtxStore},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func newToken(t *testing.T) string {
+	t.Helper()
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func commit(t *testing.T, store scs.Store, token string, b []byte, expiry time.Time) {
+	t.Helper()
+
+	if err := store.Commit(token, b, expiry); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func mustFind(t *testing.T, store scs.Store, token string, want []byte) {
+	t.Helper()
+
+	b, found, err := store.Find(token)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if !found {
+		t.Fatalf("Find: want token found; got not found")
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("Find: want %d bytes %q; got %d bytes %q", len(want), abbreviate(want), len(b), abbreviate(b))
+	}
+}
+
+func mustNotFind(t *testing.T, store scs.Store, token string) {
+	t.Helper()
+
+	b, found, err := store.Find(token)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if found {
+		t.Fatalf("Find: want token not found; got found with %q", abbreviate(b))
+	}
+}
+
+func abbreviate(b []byte) []byte {
+	if len(b) > 32 {
+		return b[:32]
+	}
+	return b
+}
+
+func testCommitAndFind(t *testing.T, store scs.Store) {
+	token := newToken(t)
+	commit(t, store, token, []byte("encoded_data"), time.Now().Add(time.Minute))
+	mustFind(t, store, token, []byte("encoded_data"))
+}
+
+func testFindMissing(t *testing.T, store scs.Store) {
+	mustNotFind(t, store, newToken(t))
+}
+
+func testCommitOverwrites(t *testing.T, store scs.Store) {
+	token := newToken(t)
+	commit(t, store, token, []byte("encoded_data"), time.Now().Add(time.Second))
+	commit(t, store, token, []byte("new_encoded_data"), time.Now().Add(time.Minute))
+	mustFind(t, store, token, []byte("new_encoded_data"))
+
+	// The expiry time is overwritten too, so the session outlives the first
+	// expiry time.
+	time.Sleep(2 * time.Second)
+	mustFind(t, store, token, []byte("new_encoded_data"))
+}
+
+func testDelete(t *testing.T, store scs.Store) {
+	token, other := newToken(t), newToken(t)
+	commit(t, store, token, []byte("encoded_data"), time.Now().Add(time.Minute))
+	commit(t, store, other, []byte("other_data"), time.Now().Add(time.Minute))
+
+	if err := store.Delete(token); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	mustNotFind(t, store, token)
+	mustFind(t, store, other, []byte("other_data"))
+}
+
+func testDeleteMissing(t *testing.T, store scs.Store) {
+	if err := store.Delete(newToken(t)); err != nil {
+		t.Fatalf("Delete: want nil error for missing token; got %v", err)
+	}
+}
+
+func testExpiry(t *testing.T, store scs.Store) {
+	token := newToken(t)
+	expiry := time.Now().Add(1500 * time.Millisecond)
+	commit(t, store, token, []byte("encoded_data"), expiry)
+	mustFind(t, store, token, []byte("encoded_data"))
+
+	time.Sleep(time.Until(expiry) + time.Second)
+	mustNotFind(t, store, token)
+}
+
+func testExpiredCommit(t *testing.T, store scs.Store) {
+	token := newToken(t)
+	commit(t, store, token, []byte("encoded_data"), time.Now().Add(-time.Minute))
+	mustNotFind(t, store, token)
+}
+
+func testConcurrentCommits(t *testing.T, store scs.Store) {
+	const n = 20
+
+	tokens := make([]string, n)
+	for i := range tokens {
+		tokens[i] = newToken(t)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for i, token := range tokens {
+		wg.Add(2)
+		go func(i int, token string) {
+			defer wg.Done()
+			errs <- store.Commit(token, []byte(fmt.Sprintf("data_%d", i)), time.Now().Add(time.Minute))
+		}(i, token)
+
+		// Concurrent commits to a shared token must leave one of the
+		// committed values, not a mixture of them.
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Commit(tokens[0]+"_shared", []byte(fmt.Sprintf("shared_%d", i)), time.Now().Add(time.Minute))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+
+	for i, token := range tokens {
+		mustFind(t, store, token, []byte(fmt.Sprintf("data_%d", i)))
+	}
+
+	b, found, err := store.Find(tokens[0] + "_shared")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if !found {
+		t.Fatal("Find: want shared token found; got not found")
+	}
+	valid := false
+	for i := 0; i < n; i++ {
+		if string(b) == fmt.Sprintf("shared_%d", i) {
+			valid = true
+		}
+	}
+	if !valid {
+		t.Fatalf("Find: want one of the committed values for shared token; got %q", abbreviate(b))
+	}
+}
+
+func testCommitOrdering(t *testing.T, store scs.Store) {
+	token := newToken(t)
+	for i := 0; i < 20; i++ {
+		commit(t, store, token, []byte(fmt.Sprintf("data_%d", i)), time.Now().Add(time.Minute))
+	}
+	mustFind(t, store, token, []byte("data_19"))
+}
+
+func testLargePayload(t *testing.T, store scs.Store) {
+	b := make([]byte, 256*1024)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+
+	token := newToken(t)
+	commit(t, store, token, b, time.Now().Add(time.Minute))
+	mustFind(t, store, token, b)
+}
+
+func testBinarySafety(t *testing.T, store scs.Store) {
+	b := make([]byte, 0, 512)
+	for i := 0; i < 256; i++ {
+		b = append(b, byte(i))
+	}
+	for i := 255; i >= 0; i-- {
+		b = append(b, byte(i))
+	}
+
+	token := newToken(t)
+	commit(t, store, token, b, time.Now().Add(time.Minute))
+	mustFind(t, store, token, b)
+
+	// Trailing zero bytes must not be trimmed.
+	token = newToken(t)
+	commit(t, store, token, []byte{'a', 0, 0}, time.Now().Add(time.Minute))
+	mustFind(t, store, token, []byte{'a', 0, 0})
+}
+
+func testAll(t *testing.T, store scs.Store) {
+	var all func() (map[string][]byte, error)
+	switch s := store.(type) {
+	case scs.IterableCtxStore:
+		all = func() (map[string][]byte, error) { return s.AllCtx(context.Background()) }
+	case scs.IterableStore:
+		all = s.All
+	default:
+		t.Skip("store does not support iteration")
+	}
+
+	active, expired := newToken(t), newToken(t)
+	commit(t, store, active, []byte("active_data"), time.Now().Add(time.Minute))
+	commit(t, store, expired, []byte("expired_data"), time.Now().Add(-time.Minute))
+
+	sessions, err := all()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if sessions == nil {
+		t.Fatal("All: want non-nil map")
+	}
+	if b, ok := sessions[active]; !ok || !bytes.Equal(b, []byte("active_data")) {
+		t.Errorf("All: want %q for active token; got %q", "active_data", b)
+	}
+	if _, ok := sessions[expired]; ok {
+		t.Error("All: want expired token omitted")
+	}
+}
+
+func testCtxStore(t *testing.T, store scs.Store) {
+	cs, ok := store.(scs.CtxStore)
+	if !ok {
+		t.Skip("store does not support context.Context")
+	}
+	ctx := context.Background()
+
+	token := newToken(t)
+	if err := cs.CommitCtx(ctx, token, []byte("encoded_data"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("CommitCtx: %v", err)
+	}
+
+	b, found, err := cs.FindCtx(ctx, token)
+	if err != nil {
+		t.Fatalf("FindCtx: %v", err)
+	}
+	if !found || !bytes.Equal(b, []byte("encoded_data")) {
+		t.Fatalf("FindCtx: want %q; got %q (found %v)", "encoded_data", b, found)
+	}
+
+	if err := cs.DeleteCtx(ctx, token); err != nil {
+		t.Fatalf("DeleteCtx: %v", err)
+	}
+	if _, found, err := cs.FindCtx(ctx, token); err != nil || found {
+		t.Fatalf("FindCtx: want token not found after DeleteCtx; got found %v, err %v", found, err)
+	}
+}
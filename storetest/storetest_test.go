@@ -0,0 +1,15 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestMemStore(t *testing.T) {
+	store := memstore.NewWithCleanupInterval(0)
+	Run(t, func(t *testing.T) scs.Store {
+		return store
+	})
+}
@@ -0,0 +1,133 @@
+package scs
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const (
+	tagsKey      = "__tags"
+	tagsSinceKey = "__tags.since"
+)
+
+// Tag attaches tags (such as "role:admin" or "app:mobile") to the current
+// session, so that it can be found with FindByTag and revoked with
+// RevokeByTag. Tags are intended to be attached when the session is created,
+// for example at login, and the time the first tag was attached is recorded
+// for use by RevokeByTag. Tags which are already attached are ignored.
+func (s *SessionManager) Tag(ctx context.Context, tags ...string) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	current, _ := sd.values[tagsKey].([]string)
+	next := append([]string(nil), current...)
+	for _, tag := range tags {
+		if !containsString(next, tag) {
+			next = append(next, tag)
+		}
+	}
+	if len(next) == len(current) {
+		return nil
+	}
+	sort.Strings(next)
+
+	sd.values[tagsKey] = next
+	if _, ok := sd.values[tagsSinceKey].(int64); !ok {
+		sd.values[tagsSinceKey] = time.Now().UnixNano()
+	}
+	sd.status = Modified
+	return nil
+}
+
+// Tags returns the tags attached to the current session with Tag, sorted
+// alphabetically.
+func (s *SessionManager) Tags(ctx context.Context) []string {
+	sd := s.getSessionDataFromContext(ctx)
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	tags, _ := sd.values[tagsKey].([]string)
+	return append([]string(nil), tags...)
+}
+
+// FindByTag returns summary information about all active sessions with the
+// given tag, ordered by deadline. The session store must support iteration.
+func (s *SessionManager) FindByTag(ctx context.Context, tag string) ([]SessionInfo, error) {
+	sessions, err := s.taggedSessions(ctx, tag, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SessionInfo, len(sessions))
+	for i, us := range sessions {
+		infos[i] = s.newSessionInfo(us.storeToken, us.deadline, us.values)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Deadline.Before(infos[j].Deadline)
+	})
+	return infos, nil
+}
+
+// RevokeByTag revokes all active sessions with the given tag which were tagged
+// before the given time, and returns the number of sessions revoked. For
+// example, to revoke all mobile sessions issued before a breach was fixed:
+//
+//	n, err := sessionManager.RevokeByTag(ctx, "app:mobile", fixedAt)
+//
+// If before is the zero time, all sessions with the tag are revoked. Revoked
+// sessions are deleted, or kept as tombstones if SessionManager.TombstoneTTL is
+// set. The session store must support iteration.
+func (s *SessionManager) RevokeByTag(ctx context.Context, tag string, before time.Time) (int, error) {
+	if s.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	sessions, err := s.taggedSessions(ctx, tag, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, us := range sessions {
+		if err := s.revoke(ctx, us.storeToken); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// taggedSessions returns all active sessions in the store with the tag, which
+// were tagged before the given time unless it is the zero time.
+func (s *SessionManager) taggedSessions(ctx context.Context, tag string, before time.Time) ([]userSession, error) {
+	all, err := s.doStoreAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []userSession
+	for token, b := range all {
+		deadline, values, err := s.decode(b)
+		if err != nil {
+			return nil, err
+		}
+
+		tags, _ := values[tagsKey].([]string)
+		if !containsString(tags, tag) || isTombstone(values) {
+			continue
+		}
+		if since, _ := values[tagsSinceKey].(int64); !before.IsZero() && !time.Unix(0, since).Before(before) {
+			continue
+		}
+
+		sessions = append(sessions, userSession{storeToken: token, deadline: deadline, values: values, size: len(b)})
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,107 @@
+package scs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTag(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.UserKey = "userID"
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "userID", 1)
+	if err := s.Tag(ctx, "role:admin", "app:mobile"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Tag(ctx, "app:mobile"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app:mobile", "role:admin"}
+	if got := s.Tags(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v: expected %v", got, want)
+	}
+	if s.Status(ctx) != Modified {
+		t.Errorf("got %v: expected %v", s.Status(ctx), Modified)
+	}
+
+	if _, _, err := s.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	commitTestSession(t, s, "other", map[string]interface{}{"userID": 2})
+
+	infos, err := s.FindByTag(context.Background(), "app:mobile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("got %d: expected %d sessions", len(infos), 1)
+	}
+	if infos[0].User != "1" || !reflect.DeepEqual(infos[0].Tags, want) {
+		t.Errorf("got %+v", infos[0])
+	}
+
+	infos, err = s.FindByTag(context.Background(), "role:guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("got %d: expected no sessions", len(infos))
+	}
+
+	s.ReadOnly = true
+	if err := s.Tag(ctx, "role:guest"); err != ErrReadOnly {
+		t.Errorf("got %v: expected %v", err, ErrReadOnly)
+	}
+}
+
+func TestRevokeByTag(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	tagged := func(tags []string, since time.Time) map[string]interface{} {
+		return map[string]interface{}{tagsKey: tags, tagsSinceKey: since.UnixNano()}
+	}
+
+	breach := time.Now().Add(-time.Hour)
+	commitTestSession(t, s, "a", tagged([]string{"app:mobile"}, breach.Add(-time.Minute)))
+	commitTestSession(t, s, "b", tagged([]string{"app:mobile"}, breach.Add(time.Minute)))
+	commitTestSession(t, s, "c", tagged([]string{"app:web"}, breach.Add(-time.Minute)))
+
+	n, err := s.RevokeByTag(context.Background(), "app:mobile", breach)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d: expected %d", n, 1)
+	}
+	for token, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if _, found, _ := s.Store.Find(token); found != want {
+			t.Errorf("%s: want found %v; got %v", token, want, found)
+		}
+	}
+
+	n, err = s.RevokeByTag(context.Background(), "app:mobile", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d: expected %d", n, 1)
+	}
+	if _, found, _ := s.Store.Find("b"); found {
+		t.Errorf("b: want session revoked")
+	}
+
+	s.ReadOnly = true
+	if _, err := s.RevokeByTag(context.Background(), "app:web", time.Time{}); err != ErrReadOnly {
+		t.Errorf("got %v: expected %v", err, ErrReadOnly)
+	}
+}
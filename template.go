@@ -0,0 +1,87 @@
+package scs
+
+import (
+	"context"
+	"html/template"
+)
+
+// TemplateFuncs returns a template.FuncMap containing functions for reading
+// session data from within templates. The session data is copied when
+// TemplateFuncs is called, so that rendering the template doesn't hold the
+// session lock and reads are consistent across the whole template.
+//
+// The following functions are included:
+//
+//	{{ sessionGet "key" }}     the value for key, or nil
+//	{{ sessionString "key" }}  the string value for key (see GetString)
+//	{{ sessionInt "key" }}     the int value for key (see GetInt)
+//	{{ sessionBool "key" }}    the bool value for key (see GetBool)
+//	{{ sessionExists "key" }}  true if key is present in the session data
+//	{{ flash "key" }}          the string value for key, which is then removed
+//	                           from the session data (see PopString)
+//	{{ flashes }}              the flash messages added with AddFlash, which
+//	                           are then removed from the session data (see
+//	                           Flashes)
+//
+// A typical use is to add the functions to a cloned template set before
+// executing it:
+//
+//	ts, err := templates.Clone()
+//	if err != nil {
+//		return err
+//	}
+//	err = ts.Funcs(sessionManager.TemplateFuncs(r.Context())).Execute(w, data)
+//
+// Because html/template requires functions to be defined before a template is
+// parsed, the template set should be parsed with the functions returned by
+// TemplateFuncs(context.Background()). If ctx doesn't contain any session data
+// then the functions behave as if the session is empty.
+func (s *SessionManager) TemplateFuncs(ctx context.Context) template.FuncMap {
+	values := make(map[string]interface{})
+
+	_, ok := ctx.Value(s.contextKey).(*sessionData)
+	if ok {
+		// The rest of the session data is loaded first if only some of it
+		// was loaded (see HotKeys).
+		sd := s.getSessionDataFromContext(ctx)
+		sd.mu.Lock()
+		for key, val := range sd.values {
+			values[key] = val
+		}
+		sd.mu.Unlock()
+	}
+
+	return template.FuncMap{
+		"sessionGet": func(key string) interface{} {
+			return values[key]
+		},
+		"sessionString": func(key string) string {
+			str, _ := values[key].(string)
+			return str
+		},
+		"sessionInt": func(key string) int {
+			i, _ := toInt(values[key])
+			return i
+		},
+		"sessionBool": func(key string) bool {
+			b, _ := values[key].(bool)
+			return b
+		},
+		"sessionExists": func(key string) bool {
+			_, exists := values[key]
+			return exists
+		},
+		"flash": func(key string) string {
+			if !ok {
+				return ""
+			}
+			return s.PopString(ctx, key)
+		},
+		"flashes": func() []string {
+			if !ok {
+				return nil
+			}
+			return s.Flashes(ctx)
+		},
+	}
+}
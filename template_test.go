@@ -0,0 +1,62 @@
+package scs
+
+import (
+	"context"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	base, err := template.New("").Funcs(s.TemplateFuncs(context.Background())).Parse(
+		`{{ sessionString "name" }}|{{ sessionInt "count" }}|{{ sessionBool "admin" }}|{{ sessionExists "name" }}|{{ sessionExists "missing" }}|{{ flash "message" }}|{{ flash "message" }}|{{ range flashes }}[{{ . }}]{{ end }}|{{ range flashes }}[{{ . }}]{{ end }}`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := base.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if err := ts.Execute(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "|0|false|false|false||||" {
+		t.Errorf("want %q; got %q", "|0|false|false|false||||", sb.String())
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "name", "<alice>")
+	s.Put(ctx, "count", int64(3))
+	s.Put(ctx, "admin", true)
+	s.Put(ctx, "message", "hello")
+	s.AddFlash(ctx, "saved")
+	s.AddFlash(ctx, "<sent>")
+
+	ts, err = base.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sb.Reset()
+	if err := ts.Funcs(s.TemplateFuncs(ctx)).Execute(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "&lt;alice&gt;|3|true|true|false|hello||[saved][&lt;sent&gt;]|"
+	if sb.String() != want {
+		t.Errorf("want %q; got %q", want, sb.String())
+	}
+	if s.Exists(ctx, "message") {
+		t.Error("want flash message to be removed from the session")
+	}
+}
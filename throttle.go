@@ -0,0 +1,210 @@
+package scs
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	loginFailuresKey     = "__loginFailures.count"
+	loginFailuresLastKey = "__loginFailures.last"
+)
+
+// LoginThrottle provides brute-force protection for login forms using an
+// exponentially increasing lockout after repeated failed login attempts.
+// Failures are counted in two places: in the session data (which catches one
+// client trying many different accounts) and in the session store keyed by the
+// login identifier, such as a username or email address (which catches many
+// clients, or a client discarding its session cookie, trying one account). The
+// higher of the two counts is used.
+//
+// A typical login handler would look like:
+//
+//	lockout, err := throttle.LoginLockout(r.Context(), email)
+//	if err != nil { ... }
+//	if lockout > 0 {
+//		// Reject the attempt without checking the password.
+//	}
+//	if !checkPassword(email, password) {
+//		throttle.RecordFailedLogin(r.Context(), email)
+//		...
+//	}
+//	throttle.ResetFailedLogins(r.Context(), email)
+type LoginThrottle struct {
+	// SessionManager is the session manager holding the per-session count.
+	SessionManager *SessionManager
+
+	// Store controls where the per-identifier counts are persisted. The
+	// default is the session manager's store. Counts are stored with the
+	// "login:" prefix, so if you use Iterate() with a shared store you
+	// should set this to a separate store instead.
+	Store Store
+
+	// Threshold is the number of failed attempts allowed before the lockout
+	// starts. The default value is 3.
+	Threshold int
+
+	// BaseDelay is the lockout after the first failure over the threshold.
+	// It doubles with each further failure. The default value is 1 second.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum lockout. The default value is 15 minutes.
+	MaxDelay time.Duration
+
+	// Window controls how long failed attempts are remembered after the most
+	// recent one. The default value is 24 hours.
+	Window time.Duration
+}
+
+// NewLoginThrottle returns a new LoginThrottle for the session manager s with
+// the default settings.
+func NewLoginThrottle(s *SessionManager) *LoginThrottle {
+	return &LoginThrottle{
+		SessionManager: s,
+		Store:          s.Store,
+		Threshold:      3,
+		BaseDelay:      time.Second,
+		MaxDelay:       15 * time.Minute,
+		Window:         24 * time.Hour,
+	}
+}
+
+// RecordFailedLogin records a failed login attempt for identifier, and returns
+// the resulting lockout (which is zero if the threshold hasn't been reached).
+func (lt *LoginThrottle) RecordFailedLogin(ctx context.Context, identifier string) (time.Duration, error) {
+	s := lt.SessionManager
+	now := time.Now()
+
+	count, _, err := lt.find(ctx, identifier)
+	if err != nil {
+		return 0, err
+	}
+	count++
+
+	values := map[string]interface{}{"count": count, "last": now.UnixNano()}
+	expiry := now.Add(lt.Window).UTC()
+	b, err := s.encode(expiry, values)
+	if err != nil {
+		return 0, err
+	}
+	if err := storeCommit(ctx, lt.store(), lt.storeKey(identifier), b, expiry); err != nil {
+		return 0, err
+	}
+
+	sessionCount := s.GetInt(ctx, loginFailuresKey)
+	if now.Sub(time.Unix(0, s.GetInt64(ctx, loginFailuresLastKey))) > lt.Window {
+		sessionCount = 0
+	}
+	sessionCount++
+	s.Put(ctx, loginFailuresKey, sessionCount)
+	s.Put(ctx, loginFailuresLastKey, now.UnixNano())
+
+	if sessionCount > count {
+		count = sessionCount
+	}
+	return lt.delay(count), nil
+}
+
+// FailedLoginCount returns the number of recent failed login attempts for
+// identifier or the current session, whichever is higher.
+func (lt *LoginThrottle) FailedLoginCount(ctx context.Context, identifier string) (int, error) {
+	count, _, err := lt.failures(ctx, identifier)
+	return count, err
+}
+
+// LoginLockout returns how much longer login attempts for identifier (or from
+// the current session) should be rejected. It returns zero if login attempts
+// are allowed.
+func (lt *LoginThrottle) LoginLockout(ctx context.Context, identifier string) (time.Duration, error) {
+	count, last, err := lt.failures(ctx, identifier)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+
+	remaining := time.Until(last.Add(lt.delay(count)))
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// ResetFailedLogins clears the failed login attempts for identifier and the
+// current session. It should be called after a successful login.
+func (lt *LoginThrottle) ResetFailedLogins(ctx context.Context, identifier string) error {
+	lt.SessionManager.Remove(ctx, loginFailuresKey)
+	lt.SessionManager.Remove(ctx, loginFailuresLastKey)
+
+	return storeDelete(ctx, lt.store(), lt.storeKey(identifier))
+}
+
+// failures returns the higher of the identifier and session failure counts,
+// along with the time of the most recent failure.
+func (lt *LoginThrottle) failures(ctx context.Context, identifier string) (int, time.Time, error) {
+	s := lt.SessionManager
+
+	count, last, err := lt.find(ctx, identifier)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	sessionLast := time.Unix(0, s.GetInt64(ctx, loginFailuresLastKey))
+	if time.Since(sessionLast) <= lt.Window {
+		if sessionCount := s.GetInt(ctx, loginFailuresKey); sessionCount > count {
+			count = sessionCount
+		}
+		if sessionLast.After(last) {
+			last = sessionLast
+		}
+	}
+
+	return count, last, nil
+}
+
+// find returns the failure count and time of the most recent failure for
+// identifier from the store.
+func (lt *LoginThrottle) find(ctx context.Context, identifier string) (int, time.Time, error) {
+	b, found, err := storeFind(ctx, lt.store(), lt.storeKey(identifier))
+	if err != nil || !found {
+		return 0, time.Time{}, err
+	}
+
+	_, values, err := lt.SessionManager.decode(b)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	count, _ := toInt(values["count"])
+	last, _ := toInt64(values["last"])
+	return count, time.Unix(0, last), nil
+}
+
+// delay returns the lockout after count failed attempts.
+func (lt *LoginThrottle) delay(count int) time.Duration {
+	over := count - lt.Threshold
+	if over <= 0 {
+		return 0
+	}
+
+	delay := lt.BaseDelay
+	for i := 1; i < over; i++ {
+		delay *= 2
+		if delay >= lt.MaxDelay {
+			return lt.MaxDelay
+		}
+	}
+	if delay > lt.MaxDelay {
+		return lt.MaxDelay
+	}
+	return delay
+}
+
+func (lt *LoginThrottle) store() Store {
+	if lt.Store == nil {
+		return lt.SessionManager.Store
+	}
+	return lt.Store
+}
+
+func (lt *LoginThrottle) storeKey(identifier string) string {
+	return "login:" + hashToken(identifier)
+}
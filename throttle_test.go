@@ -0,0 +1,74 @@
+package scs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	throttle := NewLoginThrottle(s)
+	throttle.Threshold = 2
+	throttle.BaseDelay = time.Minute
+	throttle.MaxDelay = 3 * time.Minute
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantDelays := []time.Duration{0, 0, time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, want := range wantDelays {
+		got, err := throttle.RecordFailedLogin(ctx, "alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("failure %d: got lockout %v: expected %v", i+1, got, want)
+		}
+	}
+
+	n, err := throttle.FailedLoginCount(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(wantDelays) {
+		t.Errorf("got %d: expected %d", n, len(wantDelays))
+	}
+
+	lockout, err := throttle.LoginLockout(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout <= 2*time.Minute || lockout > 3*time.Minute {
+		t.Errorf("got lockout %v: expected just under %v", lockout, 3*time.Minute)
+	}
+
+	// A new session is still locked out for the same identifier.
+	other, _ := s.Load(context.Background(), "")
+	if lockout, _ := throttle.LoginLockout(other, "alice@example.com"); lockout == 0 {
+		t.Error("expected identifier to be locked out from a new session")
+	}
+
+	// The original session is locked out for a different identifier.
+	if lockout, _ := throttle.LoginLockout(ctx, "bob@example.com"); lockout == 0 {
+		t.Error("expected session to be locked out for a different identifier")
+	}
+
+	if lockout, _ := throttle.LoginLockout(other, "bob@example.com"); lockout != 0 {
+		t.Errorf("got lockout %v: expected none", lockout)
+	}
+
+	if err := throttle.ResetFailedLogins(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := throttle.FailedLoginCount(ctx, "alice@example.com"); n != 0 {
+		t.Errorf("got %d: expected %d", n, 0)
+	}
+	if lockout, _ := throttle.LoginLockout(other, "alice@example.com"); lockout != 0 {
+		t.Errorf("got lockout %v: expected none", lockout)
+	}
+}
@@ -0,0 +1,159 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StoreTimeoutPolicy controls what the LoadAndSave middleware does when a
+// session store operation takes longer than SessionManager.FindTimeout or
+// SessionManager.SaveTimeout.
+type StoreTimeoutPolicy int
+
+const (
+	// FailOnStoreTimeout passes ErrStoreTimeout to the ErrorFunc. This is
+	// the default.
+	FailOnStoreTimeout StoreTimeoutPolicy = iota
+
+	// DegradeOnStoreTimeout serves the request without the session store.
+	// If loading the session times out, the request gets a new, empty
+	// session which is never committed and doesn't replace the session
+	// cookie, so the client keeps its session for later requests. If saving
+	// the session times out, the error is logged using Go's standard logger
+	// and the response is sent without a new session cookie.
+	DegradeOnStoreTimeout
+)
+
+// ErrStoreTimeout is returned by Load and Commit when a session store
+// operation takes longer than SessionManager.FindTimeout or
+// SessionManager.SaveTimeout.
+var ErrStoreTimeout = errors.New("scs: session store operation timed out")
+
+// detachedContext carries the values of its parent context, but not its
+// deadline or cancellation, so that store timeouts are independent of the
+// request deadline.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// findResult holds the results of doFindSession.
+type findResult struct {
+	b      []byte
+	fields map[string][]byte
+	found  bool
+	err    error
+}
+
+// commitResult holds the results of writeSession.
+type commitResult struct {
+	expiry time.Time
+	large  *LargeSession
+	fields map[string][]byte
+	err    error
+}
+
+// findSession finds the session data for token in the store, returning
+// ErrStoreTimeout if it takes longer than FindTimeout. The store call is
+// made with a context which has the FindTimeout as its deadline. Stores
+// which don't accept a context can't be interrupted, so their call is
+// abandoned and allowed to finish in the background. If StoreLimit is set,
+// the call waits for a slot first, and an abandoned call keeps its slot until
+// it finishes.
+func (s *SessionManager) findSession(ctx context.Context, token string) (b []byte, fields map[string][]byte, found bool, err error) {
+	return s.findSessionKeys(ctx, token, nil)
+}
+
+// findSessionKeys finds the session data for token in the store in the same
+// way as findSession, but only finds the values for keys (as described by
+// doFindSession) if keys is not nil.
+func (s *SessionManager) findSessionKeys(ctx context.Context, token string, keys []string) (b []byte, fields map[string][]byte, found bool, err error) {
+	release, err := s.acquireStore(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if s.FindTimeout <= 0 {
+		defer release()
+		return s.doFindSession(ctx, token, keys)
+	}
+
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, s.FindTimeout)
+	defer cancel()
+
+	ch := make(chan findResult, 1)
+	go func() {
+		defer release()
+		var r findResult
+		r.b, r.fields, r.found, r.err = s.doFindSession(ctx, token, keys)
+		ch <- r
+	}()
+
+	select {
+	case r := <-ch:
+		return r.b, r.fields, r.found, r.err
+	case <-ctx.Done():
+		return nil, nil, false, ErrStoreTimeout
+	}
+}
+
+// commitValues encodes the session data and commits it to the store, as
+// described by commitEncoded. The values are encoded before commitValues
+// returns, so the caller may change them afterwards.
+func (s *SessionManager) commitValues(ctx context.Context, token string, deadline time.Time, values map[string]interface{}, base map[string][]byte) (time.Time, *LargeSession, map[string][]byte, error) {
+	enc, err := s.encodeSession(token, deadline, values)
+	if err != nil {
+		return time.Time{}, nil, nil, err
+	}
+	return s.commitEncoded(ctx, token, enc, base)
+}
+
+// commitEncoded writes the encoded session data to the store, as described by
+// writeSession, returning ErrStoreTimeout if it takes longer than
+// SaveTimeout. If StoreLimit is set, the write waits for a slot first.
+func (s *SessionManager) commitEncoded(ctx context.Context, token string, enc *encodedSession, base map[string][]byte) (time.Time, *LargeSession, map[string][]byte, error) {
+	release, err := s.acquireStore(ctx)
+	if err != nil {
+		return time.Time{}, nil, nil, err
+	}
+
+	if s.SaveTimeout <= 0 {
+		defer release()
+		return s.writeSession(ctx, token, enc, base)
+	}
+
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, s.SaveTimeout)
+	defer cancel()
+
+	ch := make(chan commitResult, 1)
+	go func() {
+		defer release()
+		var r commitResult
+		r.expiry, r.large, r.fields, r.err = s.writeSession(ctx, token, enc, base)
+		ch <- r
+	}()
+
+	select {
+	case r := <-ch:
+		return r.expiry, r.large, r.fields, r.err
+	case <-ctx.Done():
+		return time.Time{}, nil, nil, ErrStoreTimeout
+	}
+}
+
+// degraded reports whether the session in ctx was replaced by an empty
+// session because loading it timed out.
+func (s *SessionManager) degraded(ctx context.Context) bool {
+	sd, ok := ctx.Value(s.contextKey).(*sessionData)
+	if !ok {
+		return false
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.degraded
+}
@@ -0,0 +1,131 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+// slowStore delays Find and Commit calls, and records whether the context
+// passed to CommitCtx had a deadline.
+type slowStore struct {
+	*memstore.MemStore
+	findDelay   int64
+	commitDelay int64
+	deadline    chan bool
+}
+
+func (ss *slowStore) Find(token string) ([]byte, bool, error) {
+	time.Sleep(time.Duration(atomic.LoadInt64(&ss.findDelay)))
+	return ss.MemStore.Find(token)
+}
+
+func (ss *slowStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	_, ok := ctx.Deadline()
+	ss.deadline <- ok
+
+	select {
+	case <-time.After(time.Duration(atomic.LoadInt64(&ss.commitDelay))):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return ss.MemStore.Commit(token, b, expiry)
+}
+
+func TestStoreTimeouts(t *testing.T) {
+	t.Parallel()
+
+	store := &slowStore{MemStore: memstore.New(), deadline: make(chan bool, 10)}
+	s := New()
+	s.Store = store
+	s.FindTimeout = 20 * time.Millisecond
+	s.SaveTimeout = 20 * time.Millisecond
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !<-store.deadline {
+		t.Error("want the store context to have a deadline")
+	}
+
+	atomic.StoreInt64(&store.findDelay, int64(time.Second))
+	if _, err := s.Load(context.Background(), token); !errors.Is(err, ErrStoreTimeout) {
+		t.Errorf("want %v; got %v", ErrStoreTimeout, err)
+	}
+
+	atomic.StoreInt64(&store.findDelay, 0)
+	atomic.StoreInt64(&store.commitDelay, int64(time.Second))
+	ctx, err = s.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "baz")
+	if _, _, err := s.Commit(ctx); !errors.Is(err, ErrStoreTimeout) {
+		t.Errorf("want %v; got %v", ErrStoreTimeout, err)
+	}
+	<-store.deadline
+}
+
+func TestDegradeOnStoreTimeout(t *testing.T) {
+	t.Parallel()
+
+	store := &slowStore{MemStore: memstore.New(), deadline: make(chan bool, 10)}
+	s := New()
+	s.Store = store
+	s.FindTimeout = 20 * time.Millisecond
+	s.SaveTimeout = 20 * time.Millisecond
+	s.OnStoreTimeout = DegradeOnStoreTimeout
+
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foo := s.GetString(r.Context(), "foo")
+		s.Put(r.Context(), "foo", "bar")
+		io.WriteString(w, foo)
+	}))
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "stored")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-store.deadline
+
+	// A find timeout serves the request with an empty session which isn't
+	// committed.
+	atomic.StoreInt64(&store.findDelay, int64(time.Second))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK || rr.Body.String() != "" || rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want an empty session and no cookie; got %d %q %q", rr.Code, rr.Body.String(), rr.Header().Get("Set-Cookie"))
+	}
+
+	// A save timeout sends the response without a cookie.
+	atomic.StoreInt64(&store.findDelay, 0)
+	atomic.StoreInt64(&store.commitDelay, int64(time.Second))
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK || rr.Body.String() != "stored" || rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("want the stored session and no cookie; got %d %q %q", rr.Code, rr.Body.String(), rr.Header().Get("Set-Cookie"))
+	}
+	<-store.deadline
+}
@@ -0,0 +1,173 @@
+package scs
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// TokenFilter is an in-process bloom filter of the session tokens in the
+// session store. When SessionManager.TokenFilter is set, tokens which are
+// definitely not in the filter are treated as unknown without a round trip to
+// the store, which protects the store from cookie-stuffing and scanning
+// traffic where most of the tokens presented don't exist.
+//
+// Tokens are added to the filter when sessions are committed. A bloom filter
+// can't have tokens removed from it, so destroyed and expired sessions stay
+// in the filter (at the cost of a store round trip if their tokens are
+// presented) until it is rebuilt with SessionManager.RebuildTokenFilter. The
+// filter lets all tokens through until it has been built for the first time.
+//
+// Because the filter only knows about sessions committed by this process, it
+// must only be used when this process is the only one which creates sessions
+// in the store. Otherwise sessions created elsewhere will be treated as
+// unknown.
+type TokenFilter struct {
+	mu    sync.RWMutex
+	bits  []uint64
+	next  []uint64
+	k     uint32
+	ready bool
+}
+
+// NewTokenFilter returns a TokenFilter sized to hold the given number of
+// tokens with the given false positive rate, such as 0.01. A false positive
+// costs a store round trip, and the rate rises if the filter holds more
+// tokens than its capacity.
+func NewTokenFilter(capacity int, falsePositiveRate float64) *TokenFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(capacity) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+
+	return &TokenFilter{
+		bits: make([]uint64, (int(m)+63)/64),
+		k:    uint32(k),
+	}
+}
+
+// Add adds a token to the filter.
+func (f *TokenFilter) Add(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.set(f.bits, token)
+	if f.next != nil {
+		f.set(f.next, token)
+	}
+}
+
+// MayContain reports whether the token may be in the filter. It always
+// returns true until the filter has been built for the first time.
+func (f *TokenFilter) MayContain(token string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if !f.ready {
+		return true
+	}
+
+	n := uint64(len(f.bits)) * 64
+	h1, h2 := filterHashes(token)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (uint64(h1) + uint64(i)*uint64(h2)) % n
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// set sets the bits for token in bits. It must be called with f.mu held.
+func (f *TokenFilter) set(bits []uint64, token string) {
+	n := uint64(len(bits)) * 64
+	h1, h2 := filterHashes(token)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (uint64(h1) + uint64(i)*uint64(h2)) % n
+		bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// rebuild replaces the contents of the filter with the tokens returned by
+// fn. Tokens added while fn is running are kept.
+func (f *TokenFilter) rebuild(fn func() ([]string, error)) error {
+	f.mu.Lock()
+	f.next = make([]uint64, len(f.bits))
+	f.mu.Unlock()
+
+	tokens, err := fn()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		f.next = nil
+		return err
+	}
+	for _, token := range tokens {
+		f.set(f.next, token)
+	}
+	f.bits, f.next, f.ready = f.next, nil, true
+	return nil
+}
+
+// filterHashes returns the two hashes used to derive the bit positions for a
+// token, using double hashing.
+func filterHashes(token string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(token))
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// RebuildTokenFilter rebuilds the SessionManager.TokenFilter from the session
+// store, removing the tokens of sessions which no longer exist. It must be
+// called once before the filter is used to reject tokens, and should then be
+// called periodically, for example after running GC. The session store must
+// support iteration.
+func (s *SessionManager) RebuildTokenFilter(ctx context.Context) error {
+	if s.TokenFilter == nil {
+		return nil
+	}
+
+	return s.TokenFilter.rebuild(func() ([]string, error) {
+		all, err := s.doStoreAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens := make([]string, 0, len(all))
+		for token := range all {
+			tokens = append(tokens, token)
+		}
+		return tokens, nil
+	})
+}
+
+// filterToken adds a token, as it appears in the store, to the TokenFilter.
+func (s *SessionManager) filterToken(storeToken string) {
+	if s.TokenFilter != nil {
+		s.TokenFilter.Add(storeToken)
+	}
+}
+
+// unknownToken reports whether the TokenFilter shows that there is no session
+// in the store for token.
+func (s *SessionManager) unknownToken(token string) bool {
+	if s.TokenFilter == nil {
+		return false
+	}
+	if s.HashTokenInStore {
+		token = hashToken(token)
+	}
+	return !s.TokenFilter.MayContain(token)
+}
@@ -0,0 +1,115 @@
+package scs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestTokenFilter(t *testing.T) {
+	t.Parallel()
+
+	f := NewTokenFilter(1000, 0.01)
+	if !f.MayContain("unknown") {
+		t.Errorf("want all tokens let through before the filter is built")
+	}
+
+	if err := f.rebuild(func() ([]string, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		f.Add(fmt.Sprintf("token-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.MayContain(fmt.Sprintf("token-%d", i)) {
+			t.Fatalf("token-%d: want true; got false", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("got %d false positives in 10000", falsePositives)
+	}
+}
+
+func TestSessionManagerTokenFilter(t *testing.T) {
+	t.Parallel()
+
+	for _, hashed := range []bool{false, true} {
+		s := New()
+		s.HashTokenInStore = hashed
+		s.TokenFilter = NewTokenFilter(100, 0.001)
+
+		// Sessions committed before the filter is built are kept.
+		ctx, err := s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "foo", "bar")
+		existing, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UseNonce(context.Background(), "nonce", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.RebuildTokenFilter(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err = s.Load(context.Background(), existing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.GetString(ctx, "foo") != "bar" {
+			t.Errorf("hashed %v: want existing session loaded", hashed)
+		}
+
+		// Sessions committed after the filter is built are added to it.
+		ctx, err = s.Load(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, "foo", "baz")
+		created, _, err := s.Commit(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err = s.Load(context.Background(), created)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.GetString(ctx, "foo") != "baz" {
+			t.Errorf("hashed %v: want new session loaded", hashed)
+		}
+
+		// Unknown tokens are rejected without finding them in the store.
+		store := &findCounterStore{Store: s.Store}
+		s.Store = store
+		ctx, err = s.Load(context.Background(), "unknown")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Stats(ctx).Found {
+			t.Errorf("hashed %v: want unknown token not found", hashed)
+		}
+		if store.finds != 0 {
+			t.Errorf("hashed %v: want no store lookups; got %d", hashed, store.finds)
+		}
+	}
+}
+
+func TestRebuildTokenFilterWithoutFilter(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	if err := s.RebuildTokenFilter(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
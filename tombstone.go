@@ -0,0 +1,116 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const tombstoneKey = "__tombstone"
+
+// ErrSessionRevoked is returned by Load when the token belongs to a session
+// which has been destroyed or revoked and is being retained as a tombstone.
+// The LoadAndSave middleware treats such a token in a cookie as if the
+// session had expired.
+var ErrSessionRevoked = errors.New("scs: session has been revoked")
+
+// Tombstone contains the retained data for a session which has been destroyed
+// or revoked while SessionManager.TombstoneTTL is set.
+type Tombstone struct {
+	// RevokedAt is the time that the session was destroyed or revoked.
+	RevokedAt time.Time
+
+	// Deadline is the absolute expiry time the session had when it was
+	// revoked.
+	Deadline time.Time
+
+	// Values is the session data at the time the session was revoked.
+	Values map[string]interface{}
+}
+
+// Tombstone returns the retained data for a destroyed or revoked session, for
+// forensic review. It returns nil if there is no tombstone for the token, which
+// is the case if the session is still active, never existed, or the tombstone
+// has expired.
+func (s *SessionManager) Tombstone(ctx context.Context, token string) (*Tombstone, error) {
+	b, fields, found, err := s.findSession(ctx, token)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	deadline, values, _, err := s.decodeSession(b, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, ok := values[tombstoneKey].(int64)
+	if !ok {
+		return nil, nil
+	}
+	delete(values, tombstoneKey)
+
+	return &Tombstone{
+		RevokedAt: time.Unix(0, revoked).UTC(),
+		Deadline:  deadline,
+		Values:    values,
+	}, nil
+}
+
+// revoke removes a session from use, given the token as it appears in the
+// store. If TombstoneTTL is set, the session data is kept in the store,
+// flagged as a tombstone, until the TTL has passed; otherwise it is deleted.
+func (s *SessionManager) revoke(ctx context.Context, storeToken string) error {
+	if s.TombstoneTTL <= 0 {
+		return storeDelete(ctx, s.Store, storeToken)
+	}
+
+	b, fields, found, err := s.findStored(ctx, storeToken)
+	if err != nil || !found {
+		return err
+	}
+
+	deadline, values, _, err := s.decodeSession(b, fields)
+	if err != nil {
+		return storeDelete(ctx, s.Store, storeToken)
+	}
+	if isTombstone(values) {
+		return nil
+	}
+
+	return s.tombstone(ctx, storeToken, deadline, values)
+}
+
+// tombstone writes a tombstone for the session data to the store, under the
+// token as it appears in the store. If the store implements PartialStore the
+// tombstone replaces the session values, so that it is found in the same way
+// as the session was.
+func (s *SessionManager) tombstone(ctx context.Context, storeToken string, deadline time.Time, values map[string]interface{}) error {
+	retained := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		retained[k] = v
+	}
+	now := time.Now()
+	retained[tombstoneKey] = now.UnixNano()
+
+	expiry := now.Add(s.TombstoneTTL)
+
+	if ps, ok := s.partialStore(); ok {
+		fields, err := s.encodeFields(deadline, retained)
+		if err != nil {
+			return err
+		}
+		return ps.CommitValues(storeToken, fields, nil, true, expiry)
+	}
+
+	b, err := s.encode(deadline, retained)
+	if err != nil {
+		return err
+	}
+
+	return storeCommit(ctx, s.Store, storeToken, b, expiry)
+}
+
+func isTombstone(values map[string]interface{}) bool {
+	_, ok := values[tombstoneKey]
+	return ok
+}
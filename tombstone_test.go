@@ -0,0 +1,194 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTombstone(t *testing.T) {
+	t.Parallel()
+
+	for _, hash := range []bool{false, true} {
+		s := New()
+		s.UserKey = "userID"
+		s.HashTokenInStore = hash
+		s.TombstoneTTL = time.Hour
+
+		newSession := func() string {
+			ctx, err := s.Load(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Put(ctx, "userID", 1)
+			token, _, err := s.Commit(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+
+		destroyed := newSession()
+		ctx, err := s.Load(context.Background(), destroyed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Destroy(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		revoked := newSession()
+		if err := s.DestroyToken(context.Background(), revoked); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, token := range []string{destroyed, revoked} {
+			if _, err := s.Load(context.Background(), token); !errors.Is(err, ErrSessionRevoked) {
+				t.Errorf("hash %v: want %v; got %v", hash, ErrSessionRevoked, err)
+			}
+
+			ts, err := s.Tombstone(context.Background(), token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts == nil {
+				t.Fatalf("hash %v: want tombstone", hash)
+			}
+			if ts.Values["userID"] != 1 || isTombstone(ts.Values) {
+				t.Errorf("hash %v: want retained session data; got %v", hash, ts.Values)
+			}
+			if time.Since(ts.RevokedAt) > time.Minute || ts.Deadline.IsZero() {
+				t.Errorf("hash %v: want revocation time and deadline; got %v and %v", hash, ts.RevokedAt, ts.Deadline)
+			}
+		}
+
+		active := newSession()
+		if ts, err := s.Tombstone(context.Background(), active); err != nil || ts != nil {
+			t.Errorf("hash %v: want no tombstone for active session; got %v, %v", hash, ts, err)
+		}
+
+		sessions, err := s.SessionsForUser(context.Background(), "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != s.RedactToken(active) {
+			t.Errorf("hash %v: want only the active session; got %v", hash, sessions)
+		}
+
+		n := 0
+		err = s.Iterate(context.Background(), func(context.Context) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("hash %v: want 1 session iterated; got %d", hash, n)
+		}
+	}
+}
+
+func TestTombstoneCookie(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.TombstoneTTL = time.Hour
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "foo", "bar")
+	token, _, err := s.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DestroyToken(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = s.Token(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: s.Cookie.Name, Value: token})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, rr.Code)
+	}
+	if got != "" {
+		t.Errorf("want new session; got token %q", got)
+	}
+}
+
+func TestTombstonePartialStore(t *testing.T) {
+	t.Parallel()
+
+	for _, hotKeys := range []bool{false, true} {
+		s := New()
+		s.UserKey = "userID"
+		s.TombstoneTTL = time.Hour
+		s.Store = newPartialStore()
+		if hotKeys {
+			s.Store = &keysStore{partialStore: newPartialStore()}
+			s.HotKeys = []string{"cart"}
+		}
+
+		newSession := func() string {
+			ctx, err := s.Load(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Put(ctx, "userID", 42)
+			token, _, err := s.Commit(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+
+		destroyed := newSession()
+		ctx, err := s.Load(context.Background(), destroyed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Destroy(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		revoked := newSession()
+		if err := s.DestroyToken(context.Background(), revoked); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, token := range []string{destroyed, revoked} {
+			if _, err := s.Load(context.Background(), token); !errors.Is(err, ErrSessionRevoked) {
+				t.Errorf("hot keys %v: want %v; got %v", hotKeys, ErrSessionRevoked, err)
+			}
+
+			ts, err := s.Tombstone(context.Background(), token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts == nil || ts.Values["userID"] != 42 {
+				t.Errorf("hot keys %v: want tombstone with retained session data; got %+v", hotKeys, ts)
+			}
+		}
+
+		sessions, err := s.SessionsForUser(context.Background(), "42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("hot keys %v: want no active sessions; got %v", hotKeys, sessions)
+		}
+	}
+}
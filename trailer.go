@@ -0,0 +1,52 @@
+package scs
+
+import (
+	"net/http"
+	"net/textproto"
+)
+
+// declareTokenTrailer announces the TokenTrailer in the response headers. It
+// must be called before the headers are written.
+func (s *SessionManager) declareTokenTrailer(w http.ResponseWriter) {
+	if s.TokenTrailer != "" && !s.ReadOnly {
+		w.Header().Add("Trailer", s.TokenTrailer)
+	}
+}
+
+// writeTokenTrailer commits session data which was modified after the
+// response headers were written and sends the session token in the
+// TokenTrailer. The trailer is empty if the session was destroyed.
+func (s *SessionManager) writeTokenTrailer(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	switch s.Status(ctx) {
+	case Modified:
+		token, _, err := s.Commit(ctx)
+		if err != nil {
+			return err
+		}
+		w.Header().Set(s.TokenTrailer, token)
+		s.markCommitted(ctx)
+	case Destroyed:
+		w.Header().Set(s.TokenTrailer, "")
+	}
+	return nil
+}
+
+// TrailerToken returns the session token sent in the named HTTP trailer of a
+// response from a server using SessionManager.TokenTrailer. It must be called
+// after the response body has been read to EOF, because trailers are only
+// received after the body. ok is false if the server didn't send the trailer,
+// meaning the session token is unchanged. An empty token with ok true means
+// the session was destroyed.
+//
+// Clients which don't use a cookie jar should store the returned token and
+// send it in a session cookie (or the SessionManager.TokenHeader, if
+// MobileCompat is enabled) with later requests.
+func TrailerToken(res *http.Response, name string) (token string, ok bool) {
+	vals := res.Trailer[textproto.CanonicalMIMEHeaderKey(name)]
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
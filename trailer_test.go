@@ -0,0 +1,84 @@
+package scs
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenTrailer(t *testing.T) {
+	t.Parallel()
+
+	sessionManager := New()
+	sessionManager.TokenTrailer = "X-Session-Token"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/put", func(w http.ResponseWriter, r *http.Request) {
+		sessionManager.Put(r.Context(), "foo", "bar")
+		io.WriteString(w, "OK")
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "chunk 1\n")
+		if err := sessionManager.RenewToken(r.Context()); err != nil {
+			t.Error(err)
+		}
+		sessionManager.Put(r.Context(), "baz", "boz")
+		io.WriteString(w, "chunk 2\n")
+	})
+	mux.HandleFunc("/destroy", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "OK")
+		if err := sessionManager.Destroy(r.Context()); err != nil {
+			t.Error(err)
+		}
+	})
+	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, sessionManager.GetString(r.Context(), "foo")+sessionManager.GetString(r.Context(), "baz"))
+	})
+
+	ts := httptest.NewServer(sessionManager.LoadAndSave(mux))
+	defer ts.Close()
+
+	do := func(path, token string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: token})
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body)
+	}
+
+	// The session cookie is used when the headers haven't been written.
+	res, _ := do("/put", "")
+	if _, ok := TrailerToken(res, "X-Session-Token"); ok {
+		t.Error("want no trailer for an unstreamed response")
+	}
+	token := extractTokenFromCookie(res.Header.Get("Set-Cookie"))
+
+	// The renewed token is sent in the trailer of a streamed response.
+	res, _ = do("/stream", token)
+	renewed, ok := TrailerToken(res, "X-Session-Token")
+	if !ok || renewed == "" || renewed == token {
+		t.Fatalf("want a renewed token in the trailer; got %q, %v", renewed, ok)
+	}
+	if _, body := do("/get", renewed); body != "barboz" {
+		t.Errorf("want %q; got %q", "barboz", body)
+	}
+
+	res, _ = do("/destroy", renewed)
+	if got, ok := TrailerToken(res, "X-Session-Token"); !ok || got != "" {
+		t.Errorf("want an empty trailer; got %q, %v", got, ok)
+	}
+}
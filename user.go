@@ -0,0 +1,149 @@
+package scs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNoUserKey is returned by methods which operate on all of the sessions for
+// a user when the SessionManager.UserKey setting is empty.
+var ErrNoUserKey = errors.New("scs: UserKey is not set")
+
+// userSession holds the decoded data for a session belonging to a user. The
+// token is the token as it appears in the store.
+type userSession struct {
+	storeToken string
+	deadline   time.Time
+	values     map[string]interface{}
+	size       int
+}
+
+// userSessions returns all active sessions in the store where the value for
+// SessionManager.UserKey matches userID.
+func (s *SessionManager) userSessions(ctx context.Context, userID string) ([]userSession, error) {
+	if s.UserKey == "" {
+		return nil, ErrNoUserKey
+	}
+
+	all, err := s.doStoreAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []userSession
+	for token, b := range all {
+		deadline, values, err := s.decode(b)
+		if err != nil {
+			return nil, err
+		}
+
+		val, exists := values[s.UserKey]
+		if !exists || isTombstone(values) || fmt.Sprint(val) != userID {
+			continue
+		}
+
+		sessions = append(sessions, userSession{storeToken: token, deadline: deadline, values: values, size: len(b)})
+	}
+
+	return sessions, nil
+}
+
+// ExportUserData writes the data for all active sessions belonging to a user
+// to w as a JSON array, for use in responding to data subject access requests.
+// Each element contains the session deadline and session data. Session tokens
+// are not included. Values which can't be represented as JSON will cause an
+// error to be returned.
+func (s *SessionManager) ExportUserData(ctx context.Context, userID string, w io.Writer) error {
+	sessions, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	type exportedSession struct {
+		Deadline time.Time              `json:"deadline"`
+		Values   map[string]interface{} `json:"values"`
+	}
+
+	exported := make([]exportedSession, len(sessions))
+	for i, us := range sessions {
+		exported[i] = exportedSession{Deadline: us.deadline, Values: us.values}
+	}
+
+	return json.NewEncoder(w).Encode(exported)
+}
+
+// EraseUserData deletes all active sessions belonging to a user from the
+// session store, for use in responding to data erasure requests. It returns
+// the number of sessions deleted.
+func (s *SessionManager) EraseUserData(ctx context.Context, userID string) (int, error) {
+	sessions, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, us := range sessions {
+		if err := storeDelete(ctx, s.Store, us.storeToken); err != nil {
+			return i, err
+		}
+	}
+
+	return len(sessions), nil
+}
+
+// OnCredentialChange revokes the sessions belonging to a user, and is intended
+// to be called when the user changes their password or multi-factor
+// authentication settings, so that anyone holding a stolen session token is
+// logged out everywhere. It returns the number of sessions revoked.
+//
+// If ctx contains a session belonging to the user (typically because the user
+// made the change from that session), it is handled according to keepCurrent.
+// When keepCurrent is true the session is kept but its token is regenerated, as
+// with RenewToken, so the user stays logged in on the device they are using.
+// Otherwise it is destroyed, as with Destroy. Revoked sessions are deleted, or
+// kept as tombstones if SessionManager.TombstoneTTL is set. The
+// SessionManager.UserKey setting is required and the session store must
+// support iteration.
+func (s *SessionManager) OnCredentialChange(ctx context.Context, userID string, keepCurrent bool) (int, error) {
+	if s.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	sessions, err := s.userSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var current string
+	if sd, ok := ctx.Value(s.contextKey).(*sessionData); ok {
+		sd.mu.Lock()
+		current = sd.token
+		sd.mu.Unlock()
+		if current != "" && s.HashTokenInStore {
+			current = hashToken(current)
+		}
+	}
+
+	n := 0
+	for _, us := range sessions {
+		if current != "" && us.storeToken == current {
+			continue
+		}
+		if err := s.revoke(ctx, us.storeToken); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if current == "" || fmt.Sprint(s.Get(ctx, s.UserKey)) != userID {
+		return n, nil
+	}
+
+	if keepCurrent {
+		return n, s.RenewToken(ctx)
+	}
+	return n + 1, s.Destroy(ctx)
+}
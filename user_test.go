@@ -0,0 +1,120 @@
+package scs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func commitTestSession(t *testing.T, s *SessionManager, token string, values map[string]interface{}) {
+	t.Helper()
+
+	b, err := s.Codec.Encode(time.Now().Add(time.Hour), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.Commit(token, b, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUserData(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+
+	if _, err := s.EraseUserData(context.Background(), "1"); err != ErrNoUserKey {
+		t.Errorf("want %v; got %v", ErrNoUserKey, err)
+	}
+
+	s.UserKey = "userID"
+	commitTestSession(t, s, "a", map[string]interface{}{"userID": 1, "theme": "dark"})
+	commitTestSession(t, s, "b", map[string]interface{}{"userID": 1})
+	commitTestSession(t, s, "c", map[string]interface{}{"userID": 2})
+	commitTestSession(t, s, "d", map[string]interface{}{})
+
+	var buf bytes.Buffer
+	if err := s.ExportUserData(context.Background(), "1", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 2 {
+		t.Errorf("want %d sessions; got %d", 2, len(exported))
+	}
+
+	n, err := s.EraseUserData(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want %d; got %d", 2, n)
+	}
+
+	for token, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if _, found, _ := s.Store.Find(token); found != want {
+			t.Errorf("%s: want found %v; got %v", token, want, found)
+		}
+	}
+}
+
+func TestOnCredentialChange(t *testing.T) {
+	t.Parallel()
+
+	for _, keepCurrent := range []bool{true, false} {
+		s := New()
+		s.UserKey = "userID"
+		commitTestSession(t, s, "a", map[string]interface{}{"userID": 1})
+		commitTestSession(t, s, "b", map[string]interface{}{"userID": 1})
+		commitTestSession(t, s, "c", map[string]interface{}{"userID": 2})
+		commitTestSession(t, s, "current", map[string]interface{}{"userID": 1, "theme": "dark"})
+
+		ctx, err := s.Load(context.Background(), "current")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := s.OnCredentialChange(ctx, "1", keepCurrent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 2; keepCurrent && n != want || !keepCurrent && n != want+1 {
+			t.Errorf("keepCurrent %v: got %d sessions revoked", keepCurrent, n)
+		}
+
+		for token, want := range map[string]bool{"a": false, "b": false, "c": true, "current": false} {
+			if _, found, _ := s.Store.Find(token); found != want {
+				t.Errorf("keepCurrent %v: token %q: want found %v; got %v", keepCurrent, token, want, found)
+			}
+		}
+
+		if keepCurrent {
+			token := s.Token(ctx)
+			if token == "" || token == "current" {
+				t.Errorf("want new token; got %q", token)
+			}
+			if s.GetString(ctx, "theme") != "dark" {
+				t.Errorf("want session data to be kept")
+			}
+		} else if s.Status(ctx) != Destroyed {
+			t.Errorf("want current session destroyed; got status %v", s.Status(ctx))
+		}
+	}
+
+	// Without a current session, all of the user's sessions are revoked.
+	s := New()
+	s.UserKey = "userID"
+	commitTestSession(t, s, "a", map[string]interface{}{"userID": 1})
+	n, err := s.OnCredentialChange(context.Background(), "1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1; got %d", n)
+	}
+}
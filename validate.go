@@ -0,0 +1,135 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidValue is matched (using errors.Is) by the errors returned by
+// TryPut when a value is rejected by one of SessionManager.Validators.
+var ErrInvalidValue = errors.New("scs: invalid session value")
+
+// ValueValidator checks a value before it is added to the session data. It
+// should return a non-nil error describing the problem if the value must not
+// be stored.
+type ValueValidator func(key string, val interface{}) error
+
+// ValidationError is returned by TryPut when a value is rejected by a
+// ValueValidator. It wraps the error returned by the validator.
+type ValidationError struct {
+	// Key is the session data key the value was written to.
+	Key string
+
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("scs: invalid value for key %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the error returned by the validator.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidValue.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidValue
+}
+
+// TryPut adds a key and corresponding value to the session data, in the same
+// way as Put, but returns an error instead of discarding the value when it
+// is rejected by one of SessionManager.Validators. The returned error is a
+// *ValidationError, ErrKeyProtected is returned if the key has been protected
+// with Protect, and ErrReadOnly is returned if SessionManager.ReadOnly is
+// set.
+func (s *SessionManager) TryPut(ctx context.Context, key string, val interface{}) error {
+	if s.ReadOnly {
+		return ErrReadOnly
+	}
+	return s.put(ctx, key, val, false)
+}
+
+// validate runs the validators which apply to key. A validator registered
+// for the exact key runs first, followed by the validators for any matching
+// prefix patterns in lexical order.
+func (s *SessionManager) validate(key string, val interface{}) error {
+	if len(s.Validators) == 0 {
+		return nil
+	}
+
+	if fn, ok := s.Validators[key]; ok && fn != nil && !strings.HasSuffix(key, "*") {
+		if err := fn(key, val); err != nil {
+			return &ValidationError{Key: key, Err: err}
+		}
+	}
+
+	var patterns []string
+	for pattern, fn := range s.Validators {
+		if fn != nil && strings.HasSuffix(pattern, "*") && strings.HasPrefix(key, pattern[:len(pattern)-1]) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if err := s.Validators[pattern](key, val); err != nil {
+			return &ValidationError{Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// rejectWrite reports a write rejected by Put or Remove.
+func (s *SessionManager) rejectWrite(ctx context.Context, key string, err error) {
+	if s.RejectedWriteFunc != nil {
+		s.RejectedWriteFunc(ctx, key, err)
+		return
+	}
+	log.Print(err)
+}
+
+// ValidateMaxLength returns a ValueValidator which rejects string and []byte
+// values longer than n bytes. Values of other types are rejected.
+func ValidateMaxLength(n int) ValueValidator {
+	return func(key string, val interface{}) error {
+		var l int
+		switch v := val.(type) {
+		case string:
+			l = len(v)
+		case []byte:
+			l = len(v)
+		default:
+			return fmt.Errorf("unexpected type %T", val)
+		}
+		if l > n {
+			return fmt.Errorf("length %d exceeds maximum of %d", l, n)
+		}
+		return nil
+	}
+}
+
+// ValidateOneOf returns a ValueValidator which rejects values which are not
+// one of the given strings.
+func ValidateOneOf(values ...string) ValueValidator {
+	allowed := make(map[string]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+
+	return func(key string, val interface{}) error {
+		str, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("unexpected type %T", val)
+		}
+		if !allowed[str] {
+			return fmt.Errorf("%q is not an allowed value", str)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package scs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	t.Parallel()
+
+	var rejected []string
+	s := New()
+	s.Validators = map[string]ValueValidator{
+		"theme":    ValidateOneOf("light", "dark"),
+		"prefs.*":  ValidateMaxLength(8),
+		"prefs.a*": ValidateOneOf("aaaa"),
+	}
+	s.RejectedWriteFunc = func(ctx context.Context, key string, err error) {
+		rejected = append(rejected, key)
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Put(ctx, "theme", "dark")
+	s.Put(ctx, "theme", "pink")
+	if got := s.GetString(ctx, "theme"); got != "dark" {
+		t.Errorf("want %q; got %q", "dark", got)
+	}
+
+	s.Put(ctx, "prefs.lang", "en")
+	s.Put(ctx, "prefs.tz", "America/Los_Angeles")
+	if got := s.GetString(ctx, "prefs.lang"); got != "en" {
+		t.Errorf("want %q; got %q", "en", got)
+	}
+	if s.Exists(ctx, "prefs.tz") {
+		t.Error("want prefs.tz to be rejected")
+	}
+
+	// Unvalidated keys are unaffected.
+	s.Put(ctx, "other", strings.Repeat("x", 100))
+	if !s.Exists(ctx, "other") {
+		t.Error("want other to be stored")
+	}
+
+	if strings.Join(rejected, ",") != "theme,prefs.tz" {
+		t.Errorf("want rejected keys %q; got %q", "theme,prefs.tz", rejected)
+	}
+
+	// All matching patterns apply.
+	err = s.TryPut(ctx, "prefs.abc", "abc")
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Key != "prefs.abc" || !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("want ValidationError wrapping ErrInvalidValue; got %v", err)
+	}
+	if err := s.TryPut(ctx, "prefs.abc", "aaaa"); err != nil {
+		t.Errorf("want nil error; got %v", err)
+	}
+
+	if err := s.TryPut(ctx, "theme", 1); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("want %v; got %v", ErrInvalidValue, err)
+	}
+}
+
+func TestValidatorsEncryptedString(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.EncryptionKey = []byte("01234567890123456789012345678901")
+	s.Validators = map[string]ValueValidator{
+		"ssn": ValidateMaxLength(64),
+	}
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PutEncryptedString(ctx, "ssn", "078-05-1120"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutEncryptedString(ctx, "ssn", strings.Repeat("1", 64)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("want %v; got %v", ErrInvalidValue, err)
+	}
+}
+
+func TestTryPutReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.ReadOnly = true
+
+	ctx, err := s.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TryPut(ctx, "foo", "bar"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("want %v; got %v", ErrReadOnly, err)
+	}
+}
@@ -0,0 +1,36 @@
+package scs
+
+import (
+	"net/http"
+	"strings"
+)
+
+// setCookieOnce adds the Set-Cookie header line for cookie to h, removing any
+// Set-Cookie header lines already in h for a cookie with the same name, path
+// and domain, and adds the Cache-Control directive for the cookie unless it
+// is already present. It is used when SessionManager.WrapperCompat is set.
+func setCookieOnce(h http.Header, cookie *http.Cookie, line string) {
+	lines := h["Set-Cookie"][:0:0]
+	for _, l := range h["Set-Cookie"] {
+		if !sameCookie(l, cookie) {
+			lines = append(lines, l)
+		}
+	}
+	h["Set-Cookie"] = append(lines, line)
+
+	if !containsString(h["Cache-Control"], `no-cache="Set-Cookie"`) {
+		h.Add("Cache-Control", `no-cache="Set-Cookie"`)
+	}
+}
+
+// sameCookie reports whether the Set-Cookie header line sets the same cookie
+// as c, which is identified by its name, path and domain.
+func sameCookie(line string, c *http.Cookie) bool {
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": {line}}}).Cookies()
+	if len(cookies) != 1 {
+		return false
+	}
+	other := cookies[0]
+	return other.Name == c.Name && other.Path == c.Path &&
+		strings.TrimPrefix(other.Domain, ".") == strings.TrimPrefix(c.Domain, ".")
+}
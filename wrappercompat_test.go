@@ -0,0 +1,136 @@
+package scs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWrapperCompatTimeoutHandler(t *testing.T) {
+	t.Parallel()
+
+	for _, compat := range []bool{false, true} {
+		s := New()
+		s.WrapperCompat = compat
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), "foo", "bar")
+			w.Write([]byte("OK"))
+		})
+
+		stacks := map[string]http.Handler{
+			"inner": s.LoadAndSave(http.TimeoutHandler(h, time.Second, "timeout")),
+			"outer": http.TimeoutHandler(s.LoadAndSave(h), time.Second, "timeout"),
+		}
+		for name, stack := range stacks {
+			rr := httptest.NewRecorder()
+			stack.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if n := len(rr.Result().Header["Set-Cookie"]); n != 1 {
+				t.Errorf("compat %v, %s: got %d Set-Cookie headers: expected 1", compat, name, n)
+			}
+			if rr.Body.String() != "OK" {
+				t.Errorf("compat %v, %s: got body %q", compat, name, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestWrapperCompatTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.WrapperCompat = true
+
+	done := make(chan struct{})
+	h := s.LoadAndSave(http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		s.Put(r.Context(), "foo", "bar")
+		time.Sleep(50 * time.Millisecond)
+		s.Put(r.Context(), "foo", "baz")
+		w.Write([]byte("OK"))
+	}), 10*time.Millisecond, "timeout"))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-done
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d: expected %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if n := len(rr.Result().Header["Set-Cookie"]); n != 1 {
+		t.Errorf("got %d Set-Cookie headers: expected 1", n)
+	}
+}
+
+func TestWrapperCompatDuplicateWrites(t *testing.T) {
+	t.Parallel()
+
+	for _, compat := range []bool{false, true} {
+		s := New()
+		s.WrapperCompat = compat
+		h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.Put(r.Context(), "foo", "bar")
+			token, expiry, err := s.Commit(r.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.WriteSessionCookie(r.Context(), w, token, expiry)
+		}))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		want := 2
+		if compat {
+			want = 1
+		}
+		if n := len(rr.Result().Header["Set-Cookie"]); n != want {
+			t.Errorf("compat %v: got %d Set-Cookie headers: expected %d", compat, n, want)
+		}
+		if n := len(rr.Result().Header["Cache-Control"]); n != want {
+			t.Errorf("compat %v: got %d Cache-Control headers: expected %d", compat, n, want)
+		}
+	}
+}
+
+func TestWrapperCompatFlush(t *testing.T) {
+	t.Parallel()
+
+	s := New()
+	s.WrapperCompat = true
+	h := s.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Put(r.Context(), "foo", "bar")
+		w.(http.Flusher).Flush()
+		w.Write([]byte("OK"))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// The session cookie is written before the response is flushed.
+	if n := len(rr.Result().Header["Set-Cookie"]); n != 1 {
+		t.Errorf("got %d Set-Cookie headers: expected 1", n)
+	}
+}
+
+func TestSameCookie(t *testing.T) {
+	t.Parallel()
+
+	c := &http.Cookie{Name: "session", Path: "/", Domain: ".example.com"}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"session=abc; Path=/; Domain=example.com", true},
+		{"session=; Path=/; Domain=example.com; Max-Age=0", true},
+		{"session=abc; Path=/", false},
+		{"session=abc; Path=/admin; Domain=example.com", false},
+		{"other=abc; Path=/; Domain=example.com", false},
+	}
+	for _, tt := range tests {
+		if got := sameCookie(tt.line, c); got != tt.want {
+			t.Errorf("%q: got %v: expected %v", tt.line, got, tt.want)
+		}
+	}
+}